@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/audit"
+	"github.com/UjjavalParmar/twitter-automation/internal/config"
+	"github.com/UjjavalParmar/twitter-automation/internal/gen"
+	"github.com/UjjavalParmar/twitter-automation/internal/leader"
+	"github.com/UjjavalParmar/twitter-automation/internal/metrics"
+	"github.com/UjjavalParmar/twitter-automation/internal/rank"
+	"github.com/UjjavalParmar/twitter-automation/internal/selector"
+	"github.com/UjjavalParmar/twitter-automation/internal/shadow"
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+	"github.com/UjjavalParmar/twitter-automation/internal/usage"
+	"github.com/UjjavalParmar/twitter-automation/internal/xclient"
+	"github.com/rs/zerolog"
+)
+
+// app is the set of clients every command shares.
+type app struct {
+	log   zerolog.Logger
+	cfg   *config.Config
+	live  *config.Live
+	loc   *time.Location
+	store storage.Store
+	met   *metrics.Metrics
+	audit *audit.Log
+	acct  *usage.Accountant
+
+	genr     *gen.Generator
+	x        *xclient.Client
+	recorder *shadow.Recorder
+	pub      *shadow.Switch
+	ranker   *rank.Ranker
+}
+
+// loadConfig loads and validates the config, logging every error found.
+func loadConfig(log zerolog.Logger, file, profile string) (*config.Config, error) {
+	cfg, err := config.Load(file, profile)
+	if err != nil {
+		var errs config.Errors
+		if errors.As(err, &errs) {
+			log.Error().Strs("errors", errs).Msg("invalid config")
+		}
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// newApp opens the store and builds the Gemini and X clients.
+func newApp(ctx context.Context, log zerolog.Logger, cfg *config.Config) (*app, error) {
+	a := &app{log: log, cfg: cfg, live: config.NewLive(cfg)}
+	selector.SetCatalog(cfg.Topics, cfg.Styles)
+
+	var err error
+	if a.loc, err = time.LoadLocation(cfg.TZ); err != nil {
+		return nil, fmt.Errorf("load TZ: %w", err)
+	}
+	if a.store, err = openStore(log, cfg); err != nil {
+		return nil, err
+	}
+	a.met = metrics.New(cfg.Account)
+	a.met.RegisterStorageSize(a.store.Size)
+	a.audit = audit.New(a.store, log)
+
+	prices, err := usage.ParsePrices(cfg.GenPrices)
+	if err != nil {
+		a.Close()
+		return nil, fmt.Errorf("GEN_PRICES: %w", err)
+	}
+	a.acct = usage.New(a.store, prices, float64(cfg.GenBudgetDaily), float64(cfg.GenBudgetMonthly), a.loc)
+
+	met := a.met
+	a.genr, err = gen.New(ctx, gen.Options{
+		APIKey:      cfg.GeminiKey,
+		Model:       cfg.Model,
+		MaxTokens:   int32(cfg.MaxTokens), // match latest API type
+		Temperature: cfg.Temperature,
+		TopP:        cfg.TopP,
+		Lang:        cfg.Lang,
+
+		FallbackModels:   cfg.GenFallbackModels,
+		Timeout:          cfg.GenTimeout,
+		MaxRetries:       cfg.GenMaxRetries,
+		BreakerThreshold: cfg.GenBreakerThreshold,
+		BreakerCooldown:  cfg.GenBreakerCooldown,
+		Meter:            a.acct,
+		OnCall: func(u gen.Usage, err error) {
+			code := "ok"
+			if err != nil {
+				if code = gen.ErrorCode(err); code == "" {
+					code = "error"
+				}
+			}
+			met.GeneratorCall(u.Model, u.Purpose, code, u.Latency)
+			if err == nil {
+				met.GeneratorTokens(u.Model, u.Purpose, int(u.PromptTokens), int(u.CompletionTokens))
+			}
+		},
+	})
+	if err != nil {
+		a.Close()
+		return nil, fmt.Errorf("genai client: %w", err)
+	}
+
+	// Prepare Twitter client with creds
+	a.x = xclient.NewWithCreds(&http.Client{}, xCreds(cfg))
+	a.x.SetObserver(met.XRequest)
+
+	// dry-run mode swaps publishing for the shadow recorder; reads still hit X
+	a.recorder = shadow.NewRecorder(a.store, cfg.Account)
+	a.pub = &shadow.Switch{Live: a.x, Shadow: a.recorder, DryRun: func() bool {
+		return a.live.Get().DryRunFor(cfg.Account)
+	}}
+
+	store := a.store
+	a.ranker = rank.New(
+		rank.Policy{BannedWords: cfg.BannedWords, MaxHashtags: cfg.MaxHashtags},
+		rank.Weighted{Scorer: rank.Length{Ideal: cfg.RankIdealLength}, Weight: float64(cfg.RankWeightLen)},
+		rank.Weighted{Scorer: rank.Hook{Judge: a.genr.JudgeHook}, Weight: float64(cfg.RankWeightHook)},
+		rank.Weighted{Scorer: rank.Novelty{Past: func(ctx context.Context) []string {
+			texts, _ := store.RecentPostTexts(ctx, 200)
+			return texts
+		}}, Weight: float64(cfg.RankWeightNovel)},
+	)
+	return a, nil
+}
+
+// openStore opens the database with cfg's retention, logging any schema
+// migrations it ran.
+func openStore(log zerolog.Logger, cfg *config.Config) (storage.Store, error) {
+	store, err := storage.Open(cfg.StorageBackend, cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("open %s store %s (is the bot already running?): %w", cfg.StorageBackend, cfg.DataDir, err)
+	}
+	if m := store.Migrated(); len(m) > 0 {
+		log.Info().Str("backend", cfg.StorageBackend).Strs("migrations", m).Msg("storage migrated")
+	}
+	store.SetRetention(retention(cfg))
+	return store, nil
+}
+
+// newElector builds the leader election configured by leader.lock.
+func newElector(log zerolog.Logger, cfg *config.Config) *leader.Elector {
+	id := cfg.ReplicaID
+	if id == "" {
+		id = leader.DefaultID()
+	}
+	var lock leader.Lock = leader.Single{}
+	switch cfg.LeaderLock {
+	case "file":
+		path := cfg.LeaderFile
+		if path == "" {
+			path = filepath.Join(cfg.DataDir, "leader.lock")
+		}
+		lock = leader.NewFileLock(path)
+	case "http":
+		lock = &leader.HTTPLock{Addr: cfg.LeaderURL, Name: "twitter-automation/" + cfg.Account, Holder: id}
+	}
+	return leader.NewElector(lock, id, cfg.LeaderTTL, log)
+}
+
+func retention(cfg *config.Config) storage.Retention {
+	return storage.Retention{Seen: cfg.RetentionSeen, Slots: cfg.RetentionSlots, Shadow: cfg.RetentionShadow}
+}
+
+// Close releases the Gemini client and flushes and closes the store.
+func (a *app) Close() {
+	if a.genr != nil {
+		a.genr.Close()
+	}
+	if a.store != nil {
+		_ = a.store.Sync()
+		_ = a.store.Close()
+	}
+}
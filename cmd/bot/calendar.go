@@ -0,0 +1,496 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/audit"
+	"github.com/UjjavalParmar/twitter-automation/internal/calendar"
+	"github.com/UjjavalParmar/twitter-automation/internal/config"
+	"github.com/UjjavalParmar/twitter-automation/internal/metrics"
+	"github.com/UjjavalParmar/twitter-automation/internal/shadow"
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+	"github.com/UjjavalParmar/twitter-automation/internal/xclient"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dueDrafts returns this account's scheduled drafts whose time has come.
+// Drafts already posted under their slot key, say by a write reconcile
+// recovered, are marked posted, and drafts past cfg.CalendarGrace are
+// marked missed.
+func dueDrafts(ctx context.Context, log zerolog.Logger, store storage.Store, cfg *config.Config, now time.Time) ([]storage.Draft, error) {
+	var due, changed []storage.Draft
+	err := store.ScanDrafts(ctx, time.Time{}, now, func(d storage.Draft) error {
+		if d.Status != calendar.StatusScheduled || !ownDraft(d, cfg) {
+			return nil
+		}
+		if done, err := store.WasPosted(ctx, calendar.SlotKey(d.ID)); err != nil {
+			return err
+		} else if done {
+			d.Status, d.Error, d.Updated = calendar.StatusPosted, "", time.Time{}
+			changed = append(changed, d)
+			return nil
+		}
+		ok, missed := calendar.Due(d.At, now, cfg.CalendarGrace)
+		switch {
+		case missed:
+			d.Status, d.Updated = calendar.StatusMissed, time.Time{}
+			d.Error = fmt.Sprintf("not posted within %s of its time", cfg.CalendarGrace)
+			changed = append(changed, d)
+		case ok:
+			due = append(due, d)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range changed {
+		if err := store.SaveDraft(ctx, d); err != nil {
+			return nil, err
+		}
+		log.Info().Str("draft", d.ID).Str("status", d.Status).Msg("calendar draft settled")
+	}
+	return due, nil
+}
+
+// ownDraft reports whether this bot publishes d; drafts without an account
+// belong to whichever bot reads them.
+func ownDraft(d storage.Draft, cfg *config.Config) bool {
+	return d.Account == "" || d.Account == cfg.Account
+}
+
+// doDraft publishes a due draft under its slot key, so a crash half way is
+// settled by reconcile like a generated slot.
+func doDraft(ctx context.Context, log zerolog.Logger, pub shadow.Publisher, store storage.Store, auditLog *audit.Log, met *metrics.Metrics, d storage.Draft) (err error) {
+	key := calendar.SlotKey(d.ID)
+	ctx, span := tracer.Start(ctx, "doDraft", trace.WithAttributes(attribute.String("slot", key)))
+	defer func() { endSpan(span, err) }()
+
+	if pending, err := store.HasIntent(ctx, "post", key); err != nil || pending {
+		return err
+	}
+	// the draft may have been edited, moved or deleted since it was listed
+	cur, err := store.GetDraft(ctx, d.ID)
+	if err != nil || cur == nil || cur.Status != calendar.StatusScheduled || !cur.At.Equal(d.At) {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := store.BeginIntent(ctx, storage.Intent{Kind: "post", Key: key, Text: cur.Text, Started: time.Now()}); err != nil {
+		return err
+	}
+	pctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+
+	id, err := pub.PostTweet(shadow.WithSlot(pctx, key), cur.Text)
+	auditLog.Record(pctx, postEntry(audit.ActorScheduler, key, cur.Text, id, err))
+	countPost(met, metrics.JobCalendar, id, err)
+	if err != nil {
+		met.Error(metrics.JobCalendar, errType(err))
+		if xclient.StatusCode(err) == 0 {
+			// maybe posted; reconcile decides and the next tick settles it
+			return err
+		}
+		_ = store.ClearIntent(pctx, "post", key)
+		cur.Error, cur.Updated = err.Error(), time.Time{}
+		if !xclient.Temporary(err) {
+			cur.Status = calendar.StatusFailed
+		}
+		// rate limits and X outages leave it scheduled for the next tick
+		// to retry, until it is more than calendar.grace late
+		return errors.Join(err, store.SaveDraft(pctx, *cur))
+	}
+	log.Info().Str("id", id).Str("draft", cur.ID).Bool("dry_run", shadow.IsID(id)).Msg("posted calendar draft")
+	if err := savePost(pctx, store, storage.Post{ID: id, Text: cur.Text, Slot: key}); err != nil {
+		return err
+	}
+	cur.Status, cur.PostID, cur.Error, cur.Updated = calendar.StatusPosted, id, "", time.Time{}
+	return store.SaveDraft(pctx, *cur)
+}
+
+// draftView is a draft as the calendar shows it: its time on the wall clock
+// of its zone and what it collides with.
+type draftView struct {
+	storage.Draft
+	Local     string   `json:"local"`
+	Own       bool     `json:"own"` // published by this bot
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// slotView is a generated slot on the calendar.
+type slotView struct {
+	Key    string    `json:"key"`
+	At     time.Time `json:"at"`
+	Posted bool      `json:"posted"`
+}
+
+// calendarItems lists the generated slots of the stored plans for days
+// [from, to) in loc, and the drafts due then, widened by margin so items
+// just outside the range still count as conflicts.
+func calendarItems(ctx context.Context, store storage.Store, loc *time.Location, from, to time.Time, margin time.Duration) ([]slotView, []storage.Draft, error) {
+	var slots []slotView
+	for d := midnight(from.Add(-margin), loc); d.Before(to.Add(margin)); d = d.AddDate(0, 0, 1) {
+		plan, err := loadSlots(ctx, store, d.Format("20060102"))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, s := range plan {
+			posted, err := store.WasPosted(ctx, s.Key)
+			if err != nil {
+				return nil, nil, err
+			}
+			slots = append(slots, slotView{Key: s.Key, At: s.Time, Posted: posted})
+		}
+	}
+	var drafts []storage.Draft
+	err := store.ScanDrafts(ctx, from.Add(-margin), to.Add(margin), func(d storage.Draft) error {
+		drafts = append(drafts, d)
+		return nil
+	})
+	return slots, drafts, err
+}
+
+// draftConflicts describes what d posts too close to. Only scheduled drafts
+// of this bot are checked, against generated slots not yet passed and the
+// bot's other scheduled or posted drafts.
+func draftConflicts(d storage.Draft, cfg *config.Config, slots []slotView, drafts []storage.Draft) []string {
+	if d.Status != calendar.StatusScheduled || !ownDraft(d, cfg) {
+		return nil
+	}
+	var others []calendar.Item
+	for _, s := range slots {
+		if !s.Posted {
+			others = append(others, calendar.Item{Kind: calendar.KindSlot, ID: s.Key, At: s.At})
+		}
+	}
+	for _, o := range drafts {
+		if ownDraft(o, cfg) && (o.Status == calendar.StatusScheduled || o.Status == calendar.StatusPosted) {
+			others = append(others, calendar.Item{Kind: calendar.KindDraft, ID: o.ID, At: o.At})
+		}
+	}
+	return calendar.Conflicts(d.At, d.ID, others, cfg.CalendarConflictWindow)
+}
+
+// calendarView is the calendar between from and to: generated slots, drafts
+// with their conflicts, and the posting window where slots not yet drawn
+// will land.
+func calendarView(ctx context.Context, store storage.Store, cfg *config.Config, loc *time.Location, from, to time.Time) (map[string]any, error) {
+	slots, drafts, err := calendarItems(ctx, store, loc, from, to, cfg.CalendarConflictWindow)
+	if err != nil {
+		return nil, err
+	}
+	views := []draftView{}
+	for _, d := range drafts {
+		if d.At.Before(from) || !d.At.Before(to) {
+			continue
+		}
+		views = append(views, draftView{
+			Draft:     d,
+			Local:     calendar.Local(d.At, d.TZ),
+			Own:       ownDraft(d, cfg),
+			Conflicts: draftConflicts(d, cfg, slots, drafts),
+		})
+	}
+	inRange := []slotView{}
+	for _, s := range slots {
+		if !s.At.Before(from) && s.At.Before(to) {
+			inRange = append(inRange, s)
+		}
+	}
+	return map[string]any{
+		"from":            from,
+		"to":              to,
+		"tz":              cfg.TZ,
+		"account":         cfg.Account,
+		"window":          map[string]string{"start": cfg.PostWindowStart, "end": cfg.PostWindowEnd},
+		"posts_per_day":   cfg.PostsPerDay,
+		"conflict_window": cfg.CalendarConflictWindow.String(),
+		"slots":           inRange,
+		"drafts":          views,
+	}, nil
+}
+
+// draftConflictsAt checks d against the calendar around its time.
+func draftConflictsAt(ctx context.Context, store storage.Store, cfg *config.Config, loc *time.Location, d storage.Draft) ([]string, error) {
+	slots, drafts, err := calendarItems(ctx, store, loc, d.At, d.At, cfg.CalendarConflictWindow)
+	if err != nil {
+		return nil, err
+	}
+	return draftConflicts(d, cfg, slots, drafts), nil
+}
+
+var (
+	errNoDraft     = errors.New("no such draft")
+	errDraftPosted = errors.New("draft already posted or being posted")
+)
+
+// editableDraft loads draft id for an edit, refusing drafts that are
+// posted or being posted.
+func editableDraft(ctx context.Context, store storage.Store, id string) (*storage.Draft, error) {
+	d, err := store.GetDraft(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, errNoDraft
+	}
+	pending, err := store.HasIntent(ctx, "post", calendar.SlotKey(d.ID))
+	if err != nil {
+		return nil, err
+	}
+	if pending || !calendar.Editable(d.Status) {
+		return nil, errDraftPosted
+	}
+	return d, nil
+}
+
+// scheduleDraft stores d as scheduled, audits the change against old (nil
+// for a new draft) and returns d with its conflicts.
+func scheduleDraft(ctx context.Context, store storage.Store, auditLog *audit.Log, cfg *config.Config, loc *time.Location, actor string, d storage.Draft, old *storage.Draft) (draftView, error) {
+	d.Status, d.Error, d.Updated = calendar.StatusScheduled, "", time.Now()
+	if d.Created.IsZero() {
+		d.Created = d.Updated
+	}
+	inputs := map[string]any{"id": d.ID, "text": d.Text, "at": d.At, "tz": d.TZ, "account": d.Account}
+	if old != nil && !old.At.Equal(d.At) {
+		inputs["moved_from"] = old.At
+	}
+	err := store.SaveDraft(ctx, d)
+	auditLog.Record(ctx, withErr(audit.Entry{
+		ActorKind: audit.ActorUser,
+		Actor:     actor,
+		Action:    audit.ActionSchedule,
+		Inputs:    inputs,
+	}, err))
+	if err != nil {
+		return draftView{}, err
+	}
+	conflicts, _ := draftConflictsAt(ctx, store, cfg, loc, d)
+	return draftView{Draft: d, Local: calendar.Local(d.At, d.TZ), Own: ownDraft(d, cfg), Conflicts: conflicts}, nil
+}
+
+// writeDraftErr answers an editableDraft error.
+func writeDraftErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNoDraft):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, errDraftPosted):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		err = errors.New("failed to read draft")
+	}
+	_, _ = w.Write([]byte(err.Error()))
+}
+
+// calendarHandler shows days (7 by default) of slots and drafts from the
+// yyyy-mm-dd day from, this week's Monday by default.
+func calendarHandler(store storage.Store, live *config.Live, loc *time.Location) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		// from is a yyyy-mm-dd day in the bot's zone, this week's Monday by
+		// default
+		from := midnight(time.Now(), loc)
+		from = from.AddDate(0, 0, -(int(from.Weekday())+6)%7)
+		if v := r.URL.Query().Get("from"); v != "" {
+			t, err := time.ParseInLocation("2006-01-02", v, loc)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte("from must be YYYY-MM-DD"))
+				return
+			}
+			from = t
+		}
+		days := 7
+		if v := r.URL.Query().Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 42 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte("days must be in [1,42]"))
+				return
+			}
+			days = n
+		}
+		view, err := calendarView(r.Context(), store, live.Get(), loc, from, from.AddDate(0, 0, days))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to read calendar"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(view)
+	}
+}
+
+// calendarSaveHandler schedules a new draft or edits one. mu serializes
+// calendar edits so two saves can't interleave.
+func calendarSaveHandler(mu *sync.Mutex, store storage.Store, auditLog *audit.Log, live *config.Live, loc *time.Location) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			ID      string `json:"id"` // empty for a new draft
+			Text    string `json:"text"`
+			Local   string `json:"local"` // YYYY-MM-DDTHH:MM in tz
+			TZ      string `json:"tz"`
+			Account string `json:"account"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid json"))
+			return
+		}
+		c := live.Get()
+		text := strings.TrimSpace(body.Text)
+		if body.TZ == "" {
+			body.TZ = c.TZ
+		}
+		if body.Account = strings.TrimSpace(body.Account); body.Account == "" {
+			body.Account = c.Account
+		}
+		at, err := calendar.ParseLocal(body.Local, body.TZ)
+		if err == nil {
+			err = calendar.CheckText(text)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		d := storage.Draft{ID: calendar.NewID(), Author: userActor(r)}
+		var old *storage.Draft
+		if body.ID != "" {
+			if old, err = editableDraft(r.Context(), store, body.ID); err != nil {
+				writeDraftErr(w, err)
+				return
+			}
+			d = *old
+		}
+		if !at.After(time.Now()) && (old == nil || !at.Equal(old.At)) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("time is in the past"))
+			return
+		}
+		d.Text, d.At, d.TZ, d.Account = text, at, body.TZ, body.Account
+		view, err := scheduleDraft(r.Context(), store, auditLog, c, loc, userActor(r), d, old)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to save draft"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(view)
+	}
+}
+
+// calendarMoveHandler reschedules by drag and drop: local is a time on the
+// calendar, in the bot's zone, and the draft keeps its own zone.
+func calendarMoveHandler(mu *sync.Mutex, store storage.Store, auditLog *audit.Log, live *config.Live, loc *time.Location) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			ID    string `json:"id"`
+			Local string `json:"local"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid json"))
+			return
+		}
+		c := live.Get()
+		at, err := calendar.ParseLocal(body.Local, c.TZ)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if !at.After(time.Now()) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("time is in the past"))
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		old, err := editableDraft(r.Context(), store, body.ID)
+		if err != nil {
+			writeDraftErr(w, err)
+			return
+		}
+		d := *old
+		d.At = at
+		view, err := scheduleDraft(r.Context(), store, auditLog, c, loc, userActor(r), d, old)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to save draft"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(view)
+	}
+}
+
+// calendarDeleteHandler removes a draft unless it is being posted.
+func calendarDeleteHandler(mu *sync.Mutex, store storage.Store, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid json"))
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		d, err := store.GetDraft(r.Context(), body.ID)
+		if err != nil || d == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("no such draft"))
+			return
+		}
+		if pending, _ := store.HasIntent(r.Context(), "post", calendar.SlotKey(d.ID)); pending {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte("draft is being posted"))
+			return
+		}
+		// deleting a posted draft only clears the calendar; the post stays
+		err = store.DeleteDraft(r.Context(), d.ID)
+		auditLog.Record(r.Context(), withErr(audit.Entry{
+			ActorKind: audit.ActorUser,
+			Actor:     userActor(r),
+			Action:    audit.ActionDelete,
+			Inputs:    map[string]any{"id": d.ID, "text": d.Text, "at": d.At, "status": d.Status},
+		}, err))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to delete draft"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
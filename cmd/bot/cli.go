@@ -0,0 +1,670 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/audit"
+	"github.com/UjjavalParmar/twitter-automation/internal/config"
+	"github.com/UjjavalParmar/twitter-automation/internal/gen"
+	"github.com/UjjavalParmar/twitter-automation/internal/inbox"
+	"github.com/UjjavalParmar/twitter-automation/internal/leader"
+	"github.com/UjjavalParmar/twitter-automation/internal/logging"
+	"github.com/UjjavalParmar/twitter-automation/internal/metrics"
+	"github.com/UjjavalParmar/twitter-automation/internal/rank"
+	"github.com/UjjavalParmar/twitter-automation/internal/selector"
+	"github.com/UjjavalParmar/twitter-automation/internal/shadow"
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+const usageText = `usage: bot [--config file] [--profile name] <command> [flags]
+
+commands:
+  run                          run the scheduler, reply scanner, mentions poller and
+                               dashboard (default)
+  post [--topic t] [--style s] [--dry-run]
+                               generate, rank and publish one tweet now
+  generate [--topic t] [--style s] [-n count]
+                               print ranked candidates without posting
+  reply-scan [--dry-run] [--explain]
+                               run one reply scan; --explain prints each score
+  mentions [--dry-run]         poll mentions once and print the inbox queue
+  slots                        print today's post slots and their status
+  calendar [-days n]           print content calendar drafts due in the next n days
+  library [-add post-id]       print the content library, best candidates to recycle
+                               first; -add marks a published post evergreen
+  stats                        print post/reply counts, engagement and Gemini usage
+  db export -o file [--format badger|jsonl]
+                               write a Badger backup stream or a JSONL export
+  db import -i file [--format badger|jsonl]
+                               load a Badger backup stream or a JSONL export
+  db backup [-dir d]           write a verified backup and prune old ones
+  db verify -i file            check a backup against its manifest
+  db restore -i file           verify a backup, then load it
+  db compact                   apply retention and reclaim space (Badger GC, SQLite VACUUM)
+  db intents [-clear kind/key [-posted id]]
+                               list interrupted X writes; -clear drops one so it is
+                               retried, or with -posted records it as that tweet
+  config validate              load the config and report every problem
+  lockd [-addr :7070]          run the stand-in lock service for leader.lock http
+
+Commands that open the database (all but config validate and db verify)
+need the bot stopped: Badger allows one process per data directory.
+backup, verify and restore need the badger backend; move data between
+backends with db export/import --format jsonl.
+`
+
+func main() {
+	global := flag.NewFlagSet("bot", flag.ExitOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, usageText) }
+	file := global.String("config", "", "config file (default $CONFIG_FILE)")
+	profile := global.String("profile", "", "config profile (default $CONFIG_PROFILE)")
+	_ = global.Parse(os.Args[1:])
+
+	cmd, args := "run", global.Args()
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+
+	// The root context is cancelled on SIGINT/SIGTERM; jobs watch it to stop
+	// picking up new work.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err := dispatch(ctx, cmd, args, *file, *profile)
+	stop()
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bot "+cmd+":", err)
+		os.Exit(1)
+	}
+}
+
+func dispatch(ctx context.Context, cmd string, args []string, file, profile string) error {
+	if cmd == "run" {
+		log := logging.New()
+		return withApp(ctx, log, file, profile, func(a *app) error { return runBot(ctx, a) })
+	}
+
+	log := logging.NewTo(os.Stderr)
+	switch cmd {
+	case "post":
+		return cmdPost(ctx, log, args, file, profile)
+	case "generate":
+		return cmdGenerate(ctx, log, args, file, profile)
+	case "reply-scan":
+		return cmdReplyScan(ctx, log, args, file, profile)
+	case "mentions":
+		return cmdMentions(ctx, log, args, file, profile)
+	case "slots":
+		return cmdSlots(ctx, log, file, profile)
+	case "calendar":
+		return cmdCalendar(ctx, log, args, file, profile)
+	case "library":
+		return cmdLibrary(ctx, log, args, file, profile)
+	case "stats":
+		return cmdStats(ctx, log, file, profile)
+	case "db":
+		return cmdDB(ctx, log, args, file, profile)
+	case "config":
+		return cmdConfig(args, file, profile)
+	case "lockd":
+		return cmdLockd(ctx, log, args)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usageText)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usageText)
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// withApp loads the config, builds the shared clients and runs fn.
+func withApp(ctx context.Context, log zerolog.Logger, file, profile string, fn func(*app) error) error {
+	cfg, err := loadConfig(log, file, profile)
+	if err != nil {
+		return err
+	}
+	a, err := newApp(ctx, log, cfg)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	return fn(a)
+}
+
+// withStore opens only the store, for commands that don't call X or Gemini.
+func withStore(log zerolog.Logger, file, profile string, fn func(*config.Config, storage.Store) error) error {
+	cfg, err := loadConfig(log, file, profile)
+	if err != nil {
+		return err
+	}
+	store, err := openStore(log, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return fn(cfg, store)
+}
+
+func newFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usageText) }
+	return fs
+}
+
+// pickTopic returns the --topic value as a topic set, or a random one.
+func pickTopic(topic, style string) ([]string, string) {
+	topics := selector.RandomTopicSet()
+	if topic != "" {
+		topics = []string{topic}
+	}
+	if style == "" {
+		style = selector.RandomStyle()
+	}
+	return topics, style
+}
+
+func cmdGenerate(ctx context.Context, log zerolog.Logger, args []string, file, profile string) error {
+	fs := newFlags("generate")
+	topic := fs.String("topic", "", "topic (default: random from the catalog)")
+	style := fs.String("style", "", "style (default: random from the catalog)")
+	n := fs.Int("n", 0, "candidates to generate (default rank.candidates)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return withApp(ctx, log, file, profile, func(a *app) error {
+		count := *n
+		if count <= 0 {
+			count = a.cfg.CandidateCount
+		}
+		topics, st := pickTopic(*topic, *style)
+		cands, err := composeRanked(gen.WithPurpose(ctx, gen.PurposeManual), a.genr, a.ranker, strings.Join(topics, ", "), st, count)
+		a.audit.Record(ctx, generateEntry(audit.ActorUser, "cli", topics, st, cands, err))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("topic: %s\nstyle: %s\n\n", strings.Join(topics, ", "), st)
+		for i, c := range cands {
+			mark := ""
+			if c.Rejected {
+				mark = " [rejected: " + c.Reason + "]"
+			}
+			fmt.Printf("%d. (%.2f)%s\n%s\n\n", i+1, c.Score, mark, c.Text)
+		}
+		return nil
+	})
+}
+
+func cmdPost(ctx context.Context, log zerolog.Logger, args []string, file, profile string) error {
+	fs := newFlags("post")
+	topic := fs.String("topic", "", "topic (default: random from the catalog)")
+	style := fs.String("style", "", "style (default: random from the catalog)")
+	dryRun := fs.Bool("dry-run", false, "record to the shadow timeline instead of posting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return withApp(ctx, log, file, profile, func(a *app) error {
+		topics, st := pickTopic(*topic, *style)
+		cands, err := composeRanked(gen.WithPurpose(ctx, gen.PurposeManual), a.genr, a.ranker, strings.Join(topics, ", "), st, a.cfg.CandidateCount)
+		a.audit.Record(ctx, generateEntry(audit.ActorUser, "cli", topics, st, cands, err))
+		if err != nil {
+			return err
+		}
+		best, ok := rank.Best(cands)
+		if !ok {
+			return errors.New("every candidate was rejected by policy")
+		}
+
+		var pub shadow.Publisher = a.pub
+		if *dryRun {
+			pub = a.recorder
+		}
+		id, err := pub.PostTweet(ctx, best.Text)
+		a.audit.Record(ctx, postEntry(audit.ActorUser, "cli", best.Text, id, err))
+		countPost(a.met, metrics.JobCLI, id, err)
+		if err != nil {
+			return fmt.Errorf("post: %w", err)
+		}
+		if err := savePost(ctx, a.store, storage.Post{ID: id, Text: best.Text, Topics: topics, Style: st}); err != nil {
+			return fmt.Errorf("save post: %w", err)
+		}
+		fmt.Printf("%s\n%s\n", id, best.Text)
+		return nil
+	})
+}
+
+func cmdMentions(ctx context.Context, log zerolog.Logger, args []string, file, profile string) error {
+	fs := newFlags("mentions")
+	dryRun := fs.Bool("dry-run", false, "record automatic replies to the shadow timeline instead of posting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return withApp(ctx, log, file, profile, func(a *app) error {
+		var pub shadow.Publisher = a.pub
+		if *dryRun {
+			pub = a.recorder
+		}
+		if err := doMentions(ctx, log, a.genr, a.x, pub, a.store, a.audit, a.met, a.cfg, a.loc); err != nil {
+			return err
+		}
+		return a.store.ScanMentions(ctx, time.Time{}, time.Time{}, func(m storage.Mention) error {
+			if m.Status != inbox.StatusQueued && m.Status != inbox.StatusFailed {
+				return nil
+			}
+			fmt.Printf("%s @%s [%s, %s] %s\n  draft: %s\n", m.ID, m.Author, m.Class, m.Status, m.Text, m.Draft)
+			return nil
+		})
+	})
+}
+
+func cmdReplyScan(ctx context.Context, log zerolog.Logger, args []string, file, profile string) error {
+	fs := newFlags("reply-scan")
+	dryRun := fs.Bool("dry-run", false, "record replies, quotes, likes and reposts to the shadow timeline instead of posting")
+	explain := fs.Bool("explain", false, "print every candidate's score and how it was reached")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return withApp(ctx, log, file, profile, func(a *app) error {
+		var pub shadow.Publisher = a.pub
+		if *dryRun {
+			pub = a.recorder
+		}
+		scan := &replyScan{}
+		err := doReplies(ctx, log, a.genr, a.x, pub, a.store, a.audit, a.met, a.cfg, a.loc, &followCache{x: a.x}, scan)
+		if *explain {
+			_, cands := scan.snapshot()
+			for i, c := range cands {
+				outcome := c.Outcome
+				if c.Action != nil {
+					outcome = c.Action.Kind + " (" + c.Action.Reason + "): " + outcome
+				}
+				fmt.Printf("%d. (%.2f) %s: %s\n%s\n%s\n\n", i+1, c.Score, c.Tweet.ID, outcome, c.Tweet.Text, c.Explain())
+			}
+		}
+		return err
+	})
+}
+
+func cmdSlots(ctx context.Context, log zerolog.Logger, file, profile string) error {
+	return withStore(log, file, profile, func(cfg *config.Config, store storage.Store) error {
+		loc, err := time.LoadLocation(cfg.TZ)
+		if err != nil {
+			return err
+		}
+		day := time.Now().In(loc).Format("20060102")
+		slots, err := loadSlots(ctx, store, day)
+		if err != nil {
+			return err
+		}
+		if slots == nil {
+			fmt.Printf("no plan stored for %s; the scheduler draws one when it starts\n", day)
+			return nil
+		}
+		now := time.Now()
+		for _, s := range slots {
+			status := "pending"
+			posted, err := store.WasPosted(ctx, s.Key)
+			if err != nil {
+				return err
+			}
+			pending, err := store.HasIntent(ctx, "post", s.Key)
+			if err != nil {
+				return err
+			}
+			switch {
+			case posted:
+				status = "posted"
+			case pending:
+				status = "in flight"
+			case s.Time.Before(now):
+				status = "missed"
+			}
+			fmt.Printf("%s  %s  %s\n", s.Time.In(loc).Format("15:04 MST"), s.Key, status)
+		}
+		return nil
+	})
+}
+
+func cmdCalendar(ctx context.Context, log zerolog.Logger, args []string, file, profile string) error {
+	fs := newFlags("calendar")
+	days := fs.Int("days", 7, "how many days ahead to list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return withStore(log, file, profile, func(cfg *config.Config, store storage.Store) error {
+		loc, err := time.LoadLocation(cfg.TZ)
+		if err != nil {
+			return err
+		}
+		from := midnight(time.Now(), loc)
+		slots, drafts, err := calendarItems(ctx, store, loc, from, from.AddDate(0, 0, *days), cfg.CalendarConflictWindow)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for _, d := range drafts {
+			if d.At.Before(from) || !d.At.Before(from.AddDate(0, 0, *days)) {
+				continue
+			}
+			n++
+			fmt.Printf("%s  %-9s  %-8s  %s  %q\n", d.At.In(loc).Format("Mon 02 Jan 15:04 MST"), d.Status, d.Account, d.ID, d.Text)
+			for _, c := range draftConflicts(d, cfg, slots, drafts) {
+				fmt.Printf("    conflict: %s\n", c)
+			}
+		}
+		if n == 0 {
+			fmt.Printf("no drafts in the next %d days\n", *days)
+		}
+		return nil
+	})
+}
+
+func cmdLibrary(ctx context.Context, log zerolog.Logger, args []string, file, profile string) error {
+	fs := newFlags("library")
+	add := fs.String("add", "", "ID of a published post to mark evergreen")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return withStore(log, file, profile, func(cfg *config.Config, store storage.Store) error {
+		if *add != "" {
+			e, err := evergreenFromPost(ctx, store, *add, "cli")
+			if err == nil {
+				err = store.SaveEvergreen(ctx, e)
+			}
+			audit.New(store, log).Record(ctx, evergreenEntry("cli", e, err))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("added %s\n", e.ID)
+		}
+		views, err := libraryViews(ctx, store, cfg, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, v := range views {
+			status := "eligible"
+			if v.Reason != "" {
+				status = v.Reason
+			}
+			fmt.Printf("%-18s  score %-5d  %s  %q\n", v.ID, v.Score, status, v.Text)
+			for _, r := range v.Recycled {
+				likes := "-"
+				if r.Metrics != nil {
+					likes = fmt.Sprint(r.Metrics.Likes)
+				}
+				fmt.Printf("    recycled %s as %s, %s likes\n", r.At.Format("2006-01-02"), r.PostID, likes)
+			}
+		}
+		if len(views) == 0 {
+			fmt.Println("the library is empty")
+		}
+		return nil
+	})
+}
+
+func cmdStats(ctx context.Context, log zerolog.Logger, file, profile string) error {
+	return withApp(ctx, log, file, profile, func(a *app) error {
+		sum, err := a.acct.Summary(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := refreshMetrics(ctx, a.store, a.x); err != nil {
+			log.Warn().Err(err).Msg("refresh post metrics; showing the last stored")
+		}
+		return printJSON(os.Stdout, map[string]any{
+			"posts": postStats(ctx, a.store),
+			"usage": sum,
+		})
+	})
+}
+
+func cmdDB(ctx context.Context, log zerolog.Logger, args []string, file, profile string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usageText)
+		return errors.New("db: want export, import, backup, restore, verify, compact or intents")
+	}
+	sub, args := args[0], args[1:]
+	fs := newFlags("db " + sub)
+	out := fs.String("o", "", "file to write (- for stdout)")
+	in := fs.String("i", "", "file to read (- for stdin)")
+	format := fs.String("format", "badger", "export/import format: badger or jsonl")
+	dir := fs.String("dir", "", "backup directory (default backup.dir)")
+	settle := fs.String("clear", "", "intent to settle, as kind/key")
+	posted := fs.String("posted", "", "tweet ID the cleared intent's write went out as")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "badger" && *format != "jsonl" {
+		return fmt.Errorf("unknown format %q, want badger or jsonl", *format)
+	}
+
+	// verify only reads the backup file, so it works while the bot runs
+	if sub == "verify" {
+		if *in == "" {
+			return errors.New("verify: -i is required")
+		}
+		keys, err := storage.VerifyBackup(*in)
+		if err != nil {
+			return fmt.Errorf("verify %s: %w", *in, err)
+		}
+		fmt.Printf("%s: ok, %d keys\n", *in, keys)
+		return nil
+	}
+
+	return withStore(log, file, profile, func(cfg *config.Config, store storage.Store) error {
+		switch sub {
+		case "export":
+			if *out == "" {
+				return errors.New("export: -o is required")
+			}
+			w := io.Writer(os.Stdout)
+			if *out != "-" {
+				f, err := os.Create(*out)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			if *format == "jsonl" {
+				counts, err := store.ExportJSONL(ctx, w)
+				if err != nil {
+					return fmt.Errorf("export: %w", err)
+				}
+				log.Info().Interface("records", counts).Msg("exported")
+				return nil
+			}
+			b, err := storage.AsBackuper(store)
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			if err := b.Backup(w); err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+		case "import":
+			if *in == "" {
+				return errors.New("import: -i is required")
+			}
+			r := io.Reader(os.Stdin)
+			if *in != "-" {
+				f, err := os.Open(*in)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			if *format == "jsonl" {
+				counts, err := store.ImportJSONL(ctx, r)
+				if err != nil {
+					return fmt.Errorf("import: %w", err)
+				}
+				log.Info().Interface("records", counts).Msg("imported")
+				return nil
+			}
+			b, err := storage.AsBackuper(store)
+			if err != nil {
+				return fmt.Errorf("import: %w", err)
+			}
+			if err := b.Load(r); err != nil {
+				return fmt.Errorf("import: %w", err)
+			}
+		case "backup":
+			d := *dir
+			if d == "" {
+				d = cfg.BackupDir
+			}
+			if d == "" {
+				return errors.New("backup: set -dir or backup.dir")
+			}
+			return backup(ctx, log, store, audit.New(store, log), "cli", d, cfg.BackupKeep)
+		case "restore":
+			if *in == "" {
+				return errors.New("restore: -i is required")
+			}
+			b, err := storage.AsBackuper(store)
+			if err != nil {
+				return fmt.Errorf("restore: %w", err)
+			}
+			keys, err := b.Restore(*in)
+			audit.New(store, log).Record(ctx, withErr(audit.Entry{
+				ActorKind: audit.ActorUser,
+				Actor:     "cli",
+				Action:    audit.ActionRestore,
+				Inputs:    map[string]any{"path": *in},
+				Outputs:   map[string]any{"keys": keys},
+			}, err))
+			if err != nil {
+				return err
+			}
+			log.Info().Str("path", *in).Int("keys", keys).Msg("restored")
+		case "compact":
+			res, err := store.Sweep(ctx)
+			if err != nil {
+				return fmt.Errorf("sweep: %w", err)
+			}
+			log.Info().Int("expired", res.Expired).Int("dated", res.Dated).Msg("retention applied")
+			if err := store.Compact(); err != nil {
+				return fmt.Errorf("compact: %w", err)
+			}
+			lsm, vlog := store.Size()
+			log.Info().Int64("lsm_bytes", lsm).Int64("vlog_bytes", vlog).Msg("compacted")
+		case "intents":
+			if *settle == "" {
+				return printIntents(ctx, store)
+			}
+			return settleIntent(ctx, log, store, *settle, *posted)
+		default:
+			return fmt.Errorf("unknown db command %q", sub)
+		}
+		return nil
+	})
+}
+
+func printIntents(ctx context.Context, store storage.Store) error {
+	intents, err := store.Intents(ctx)
+	if err != nil {
+		return err
+	}
+	for _, in := range intents {
+		state := "in flight"
+		if !in.Flagged.IsZero() {
+			state = "unsettled since " + in.Flagged.Format(time.RFC3339)
+		}
+		fmt.Printf("%s/%s  started %s  %s\n%s\n\n", in.Kind, in.Key, in.Started.Format(time.RFC3339), state, in.Text)
+	}
+	if len(intents) == 0 {
+		fmt.Println("no interrupted writes")
+	}
+	return nil
+}
+
+// settleIntent resolves the intent named kind/key by hand: it is recorded as
+// the tweet posted when that is set, else dropped so its write is retried.
+func settleIntent(ctx context.Context, log zerolog.Logger, store storage.Store, name, posted string) error {
+	kind, key, ok := strings.Cut(name, "/")
+	if !ok {
+		return fmt.Errorf("intents: -clear wants kind/key, got %q", name)
+	}
+	intents, err := store.Intents(ctx)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(intents, func(in storage.Intent) bool { return in.Kind == kind && in.Key == key })
+	if i < 0 {
+		return fmt.Errorf("intents: no intent %s", name)
+	}
+	if posted != "" {
+		err = recordIntent(ctx, store, intents[i], posted)
+	} else {
+		err = store.ClearIntent(ctx, kind, key)
+	}
+	audit.New(store, log).Record(ctx, withErr(audit.Entry{
+		ActorKind: audit.ActorUser,
+		Actor:     "cli",
+		Action:    audit.ActionRecover,
+		Inputs:    map[string]any{"kind": kind, "key": key, "text": intents[i].Text, "started": intents[i].Started},
+		Outputs:   map[string]any{"found": posted != "", "id": posted},
+	}, err))
+	return err
+}
+
+// cmdLockd serves leader leases from memory until interrupted.
+func cmdLockd(ctx context.Context, log zerolog.Logger, args []string) error {
+	fs := newFlags("lockd")
+	addr := fs.String("addr", ":7070", "listen address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	srv := &http.Server{Addr: *addr, Handler: leader.NewServer().Handler()}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(sctx)
+	}()
+	log.Info().Str("addr", *addr).Msg("lock service listening")
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func cmdConfig(args []string, file, profile string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprint(os.Stderr, usageText)
+		return errors.New("config: want validate")
+	}
+	cfg, err := config.Load(file, profile)
+	if err != nil {
+		var errs config.Errors
+		if errors.As(err, &errs) {
+			for _, e := range errs {
+				fmt.Fprintln(os.Stderr, "  -", e)
+			}
+			return fmt.Errorf("%d problem(s)", len(errs))
+		}
+		return err
+	}
+	fmt.Println("config ok")
+	return printJSON(os.Stdout, cfg.Summary())
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/audit"
+	"github.com/UjjavalParmar/twitter-automation/internal/config"
+	"github.com/UjjavalParmar/twitter-automation/internal/gen"
+	"github.com/UjjavalParmar/twitter-automation/internal/metrics"
+	"github.com/UjjavalParmar/twitter-automation/internal/rank"
+	"github.com/UjjavalParmar/twitter-automation/internal/selector"
+	"github.com/UjjavalParmar/twitter-automation/internal/shadow"
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+	"github.com/UjjavalParmar/twitter-automation/internal/usage"
+	"github.com/UjjavalParmar/twitter-automation/internal/xclient"
+)
+
+// generateHandler drafts a tweet, as ranked candidates, or a poll on the
+// given topics for review; nothing is posted.
+func generateHandler(genr *gen.Generator, ranker *rank.Ranker, auditLog *audit.Log, live *config.Live) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Topics []string `json:"topics"`
+			Style  string   `json:"style"`
+			Mode   string   `json:"mode"` // "tweet" (the default) or "poll"
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid json"))
+			return
+		}
+		if len(body.Topics) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("topics required"))
+			return
+		}
+		if body.Mode != "" && body.Mode != "tweet" && body.Mode != "poll" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("mode must be tweet or poll"))
+			return
+		}
+		if body.Style == "" {
+			body.Style = selector.RandomStyle()
+		}
+		actor := userActor(r)
+		gctx := gen.WithPurpose(r.Context(), gen.PurposeManual)
+		if body.Mode == "poll" {
+			poll, err := genr.ComposePoll(gctx, strings.Join(body.Topics, ", "), body.Style)
+			auditLog.Record(r.Context(), pollEntry(actor, body.Topics, body.Style, poll, err))
+			if errors.Is(err, usage.ErrBudgetExceeded) {
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte("generation budget exceeded"))
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte("failed to compose poll"))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"text": poll.Question,
+				"poll": xclient.NewPoll{Options: poll.Options, DurationMinutes: int(poll.Duration / time.Minute)},
+			})
+			return
+		}
+		cands, err := composeRanked(gctx, genr, ranker, strings.Join(body.Topics, ", "), body.Style, live.Get().CandidateCount)
+		auditLog.Record(r.Context(), generateEntry(audit.ActorUser, actor, body.Topics, body.Style, cands, err))
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("generation budget exceeded"))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to compose tweet"))
+			return
+		}
+		// "text" stays the single best draft for older clients
+		best, _ := rank.Best(cands)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"text": best.Text, "candidates": cands})
+	}
+}
+
+// postHandler posts reviewed text with its reply settings and poll, if any.
+func postHandler(pub shadow.Publisher, store storage.Store, auditLog *audit.Log, met *metrics.Metrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Text string `json:"text"`
+			xclient.TweetOptions
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid json"))
+			return
+		}
+		text := gen.CleanTweetText(strings.TrimSpace(body.Text))
+		if text == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("text required"))
+			return
+		}
+		opts := body.TweetOptions
+		if opts.ReplySettings == "everyone" {
+			opts.ReplySettings = xclient.ReplyEveryone
+		}
+		if opts.Poll != nil {
+			for i, o := range opts.Poll.Options {
+				opts.Poll.Options[i] = strings.TrimSpace(o)
+			}
+		}
+		if err := opts.Validate(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		inputs := postInputs(text, opts)
+		auditLog.Record(r.Context(), audit.Entry{
+			ActorKind: audit.ActorUser,
+			Actor:     userActor(r),
+			Action:    audit.ActionApprove,
+			Inputs:    inputs,
+			OK:        true,
+		})
+		id, err := pub.PostTweetWith(r.Context(), text, opts)
+		entry := postEntry(audit.ActorUser, userActor(r), text, id, err)
+		entry.Inputs = inputs
+		auditLog.Record(r.Context(), entry)
+		countPost(met, metrics.JobDashboard, id, err)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("failed to post tweet"))
+			return
+		}
+		p := storage.Post{ID: id, Text: text, ReplySettings: opts.ReplySettings}
+		if opts.Poll != nil {
+			p.Poll = &storage.Poll{Options: opts.Poll.Options, DurationMinutes: opts.Poll.DurationMinutes}
+		}
+		_ = savePost(r.Context(), store, p)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "dry_run": shadow.IsID(id)})
+	}
+}
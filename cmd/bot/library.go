@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/audit"
+	"github.com/UjjavalParmar/twitter-automation/internal/calendar"
+	"github.com/UjjavalParmar/twitter-automation/internal/config"
+	"github.com/UjjavalParmar/twitter-automation/internal/gen"
+	"github.com/UjjavalParmar/twitter-automation/internal/metrics"
+	"github.com/UjjavalParmar/twitter-automation/internal/rank"
+	"github.com/UjjavalParmar/twitter-automation/internal/recycle"
+	"github.com/UjjavalParmar/twitter-automation/internal/scheduler"
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+// recycleRules are the recycler's limits from cfg.
+func recycleRules(cfg *config.Config) recycle.Rules {
+	return recycle.Rules{
+		Share:    float64(cfg.RecycleShare),
+		MinAge:   cfg.RecycleMinAge,
+		Cooldown: cfg.RecycleCooldown,
+		MaxTimes: cfg.RecycleMaxTimes,
+		DailyCap: cfg.RecycleDailyCap,
+	}
+}
+
+// recycleSlot fills slot from the content library when the recycler is
+// offered it and an entry is due, returning the entry and its rephrased
+// text. A nil entry leaves the slot to the generator, as does a rephrasing
+// that fails or that the policy refuses.
+func recycleSlot(ctx context.Context, log zerolog.Logger, genr *gen.Generator, ranker *rank.Ranker, store storage.Store, auditLog *audit.Log, met *metrics.Metrics, cfg *config.Config, slot scheduler.Slot) (*storage.Evergreen, rank.Candidate) {
+	rules := recycleRules(cfg)
+	if !rules.Offered(slot.Key) {
+		return nil, rank.Candidate{}
+	}
+	e, err := recycle.Pick(ctx, store, rules, time.Now(), midnight(slot.Time, slot.Time.Location()))
+	if err != nil {
+		met.Recycled("error")
+		log.Error().Err(err).Str("slot", slot.Key).Msg("pick library entry")
+		return nil, rank.Candidate{}
+	}
+	if e == nil {
+		return nil, rank.Candidate{}
+	}
+
+	text, err := genr.Rephrase(gen.WithPurpose(ctx, gen.PurposeRecycle), e.Text, e.Style)
+	if err == nil && strings.EqualFold(strings.TrimSpace(text), strings.TrimSpace(e.Text)) {
+		err = errors.New("rephrased text is the same as the original")
+	}
+	var cands []rank.Candidate
+	if err == nil {
+		cands = ranker.Rank(ctx, []string{text})
+	}
+	auditLog.Record(ctx, recycleEntry(slot.Key, e, cands, err))
+	best, ok := rank.Best(cands)
+	if !ok {
+		if err == nil && len(cands) > 0 {
+			err = errors.New(cands[0].Reason)
+		}
+		met.Recycled("fallback")
+		log.Warn().Err(err).Str("slot", slot.Key).Str("evergreen", e.ID).Msg("recycle failed, generating a tweet instead")
+		return nil, rank.Candidate{}
+	}
+	return e, best
+}
+
+// recycleEntry audits rephrasing library entry e for slot.
+func recycleEntry(slot string, e *storage.Evergreen, cands []rank.Candidate, err error) audit.Entry {
+	ae := audit.Entry{
+		ActorKind: audit.ActorScheduler,
+		Actor:     slot,
+		Action:    audit.ActionRecycle,
+		Inputs:    map[string]any{"evergreen": e.ID, "original": e.Text, "recycles": len(e.Recycles)},
+	}
+	if err == nil {
+		ae.Outputs = map[string]any{"candidates": cands}
+	}
+	return withErr(ae, err)
+}
+
+// recycleView is a post made from a library entry with its engagement, to
+// compare with the original's.
+type recycleView struct {
+	storage.Recycle
+	Metrics *storage.PostMetrics `json:"metrics,omitempty"`
+}
+
+// libraryView is a library entry as the dashboard shows it.
+type libraryView struct {
+	recycle.Candidate
+	Recycled []recycleView `json:"recycled"`
+}
+
+// libraryViews ranks the library and looks up how each recycled post did.
+func libraryViews(ctx context.Context, store storage.Store, cfg *config.Config, now time.Time) ([]libraryView, error) {
+	cands, err := recycle.Rank(ctx, store, recycleRules(cfg), now)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]libraryView, 0, len(cands))
+	for _, c := range cands {
+		v := libraryView{Candidate: c, Recycled: []recycleView{}}
+		for _, r := range c.Recycles {
+			rv := recycleView{Recycle: r}
+			p, err := store.GetPost(ctx, r.PostID)
+			if err != nil {
+				return nil, err
+			}
+			if p != nil {
+				rv.Metrics = p.Metrics
+			}
+			v.Recycled = append(v.Recycled, rv)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+var errNoPost = errors.New("no such post; only published posts can be added")
+
+// evergreenFromPost makes a library entry from stored post id.
+func evergreenFromPost(ctx context.Context, store storage.Store, id, author string) (storage.Evergreen, error) {
+	p, err := store.GetPost(ctx, id)
+	if err != nil {
+		return storage.Evergreen{}, err
+	}
+	if p == nil {
+		return storage.Evergreen{}, errNoPost
+	}
+	if p.RecycledFrom != "" {
+		return storage.Evergreen{}, errors.New("post is itself recycled; add its original " + p.RecycledFrom)
+	}
+	return storage.Evergreen{ID: p.ID, PostID: p.ID, Text: p.Text, Topics: p.Topics, Style: p.Style, Author: author, At: p.At}, nil
+}
+
+// evergreenEntry audits adding e to the library.
+func evergreenEntry(actor string, e storage.Evergreen, err error) audit.Entry {
+	return withErr(audit.Entry{
+		ActorKind: audit.ActorUser,
+		Actor:     actor,
+		Action:    audit.ActionEvergreen,
+		Inputs:    map[string]any{"id": e.ID, "post_id": e.PostID, "text": e.Text},
+	}, err)
+}
+
+// libraryHandler lists the content library with the recycler's limits.
+func libraryHandler(store storage.Store, live *config.Live) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		c := live.Get()
+		entries, err := libraryViews(r.Context(), store, c, time.Now())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to read library"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		day := 24 * time.Hour
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"rules": map[string]any{
+				"share": c.RecycleShare, "min_age_days": int(c.RecycleMinAge / day), "cooldown_days": int(c.RecycleCooldown / day),
+				"max_times": c.RecycleMaxTimes, "daily_cap": c.RecycleDailyCap,
+			},
+			"entries": entries,
+		})
+	}
+}
+
+// libraryAddHandler marks a published post evergreen, or stores text written
+// for the library when post_id is empty.
+func libraryAddHandler(store storage.Store, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			PostID string   `json:"post_id"`
+			Text   string   `json:"text"`
+			Topics []string `json:"topics"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid json"))
+			return
+		}
+		var e storage.Evergreen
+		var err error
+		if id := strings.TrimSpace(body.PostID); id != "" {
+			if e, err = evergreenFromPost(r.Context(), store, id, userActor(r)); errors.Is(err, errNoPost) {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+		} else {
+			e = storage.Evergreen{ID: recycle.NewID(), Text: strings.TrimSpace(body.Text), Topics: body.Topics, Author: userActor(r), At: time.Now()}
+			err = calendar.CheckText(e.Text)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		err = store.SaveEvergreen(r.Context(), e)
+		auditLog.Record(r.Context(), evergreenEntry(userActor(r), e, err))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to save library entry"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(e)
+	}
+}
+
+// libraryRemoveHandler drops an entry from the library.
+func libraryRemoveHandler(store storage.Store, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid json"))
+			return
+		}
+		e, err := store.GetEvergreen(r.Context(), body.ID)
+		if err != nil || e == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("no such library entry"))
+			return
+		}
+		// posts recycled from it keep their link and count again if it
+		// is added back
+		err = store.DeleteEvergreen(r.Context(), e.ID)
+		auditLog.Record(r.Context(), withErr(audit.Entry{
+			ActorKind: audit.ActorUser,
+			Actor:     userActor(r),
+			Action:    audit.ActionDelete,
+			Inputs:    map[string]any{"evergreen": e.ID, "text": e.Text, "recycles": len(e.Recycles)},
+		}, err))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to remove library entry"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -3,24 +3,37 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
-	"os/signal"
 	"sort"
+	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
 
+	"github.com/UjjavalParmar/twitter-automation/internal/audit"
+	"github.com/UjjavalParmar/twitter-automation/internal/auth"
 	"github.com/UjjavalParmar/twitter-automation/internal/config"
 	"github.com/UjjavalParmar/twitter-automation/internal/gen"
-	"github.com/UjjavalParmar/twitter-automation/internal/logging"
+	"github.com/UjjavalParmar/twitter-automation/internal/health"
+	"github.com/UjjavalParmar/twitter-automation/internal/leader"
+	"github.com/UjjavalParmar/twitter-automation/internal/lifecycle"
+	"github.com/UjjavalParmar/twitter-automation/internal/metrics"
+	"github.com/UjjavalParmar/twitter-automation/internal/rank"
 	"github.com/UjjavalParmar/twitter-automation/internal/scheduler"
 	"github.com/UjjavalParmar/twitter-automation/internal/selector"
+	"github.com/UjjavalParmar/twitter-automation/internal/shadow"
 	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+	"github.com/UjjavalParmar/twitter-automation/internal/tracing"
+	"github.com/UjjavalParmar/twitter-automation/internal/usage"
 	"github.com/UjjavalParmar/twitter-automation/internal/xclient"
-	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const indexHTML = `<!doctype html>
@@ -37,10 +50,26 @@ label{display:block;margin:8px 0 4px}
 button{padding:8px 14px}
 .bad{color:#b00}
 .ok{color:#080}
+.cands{display:grid;grid-template-columns:repeat(auto-fit,minmax(240px,1fr));gap:10px;margin-top:10px}
+.cand{border:1px solid #ddd;border-radius:6px;padding:10px;font-size:14px}
+.cand .meta{color:#666;font-size:12px;margin:6px 0}
+.cal{display:grid;grid-template-columns:44px repeat(7,1fr);font-size:12px;margin-top:8px}
+.cal .cell{border-top:1px solid #eee;border-left:1px solid #eee;min-height:20px;padding:1px}
+.cal .win{background:#f5f9ff}
+.cal .over{background:#e0ecff}
+.chip{border-radius:4px;padding:1px 4px;margin:1px 0;overflow:hidden;white-space:nowrap;text-overflow:ellipsis}
+.chip.slot{background:#eee;color:#555}
+.chip.draft{background:#dff3e4;cursor:pointer}
+.chip.draft[draggable=true]{cursor:move}
+.chip.posted{background:#e8e8ff}
+.chip.failed,.chip.missed{background:#fde2e2}
+.chip.conflict{outline:2px solid #e69500}
 </style>
 </head>
 <body>
 <h2>Twitter Automation Dashboard</h2>
+<div id="whoami" style="margin-bottom:12px;color:#666"></div>
+<div id="replica" class="bad" style="margin-bottom:12px"></div>
 <div class="grid">
   <div class="card">
     <div>Posted Tweets</div>
@@ -50,6 +79,10 @@ button{padding:8px 14px}
     <div>Replies Sent</div>
     <div id="replies" class="stat">-</div>
   </div>
+  <div class="card">
+    <div>Quotes / Likes / Reposts</div>
+    <div id="engaged" class="stat">-</div>
+  </div>
   <div class="card">
     <div>Total Likes (recent)</div>
     <div id="likes" class="stat">-</div>
@@ -60,11 +93,43 @@ button{padding:8px 14px}
   </div>
 </div>
 
+<div class="card" id="polls_card" style="display:none">
+  <h3>Poll Results (recent)</h3>
+  <table id="poll_rows" style="border-collapse:collapse;font-size:13px;width:100%"></table>
+</div>
+
+<div class="card">
+  <h3>Gemini Usage</h3>
+  <div class="grid">
+    <div><div>Today</div><div id="cost_today" class="stat">-</div><div id="tok_today"></div></div>
+    <div><div>This Month</div><div id="cost_month" class="stat">-</div><div id="tok_month"></div></div>
+  </div>
+  <div id="cost_status" style="margin-top:8px"></div>
+  <table id="cost_breakdown" style="margin-top:8px;border-collapse:collapse"></table>
+</div>
+
 <div class="card">
   <h3>Compose Tweet</h3>
   <div id="topics"></div>
   <label for="style">Style</label>
   <select id="style"></select>
+  <label for="mode">Mode</label>
+  <select id="mode"><option value="tweet">Tweet</option><option value="poll">Poll</option></select>
+  <label for="reply_settings">Who can reply</label>
+  <select id="reply_settings">
+    <option value="">Everyone</option>
+    <option value="following">People you follow</option>
+    <option value="mentionedUsers">Only people you mention</option>
+  </select>
+  <div id="poll_box" style="display:none;margin-top:8px">
+    <div>Options (2–4, up to 25 characters each)</div>
+    <input class="poll_opt" maxlength="25" placeholder="Option 1"/>
+    <input class="poll_opt" maxlength="25" placeholder="Option 2"/>
+    <input class="poll_opt" maxlength="25" placeholder="Option 3 (optional)"/>
+    <input class="poll_opt" maxlength="25" placeholder="Option 4 (optional)"/>
+    <label for="poll_hours">Duration (hours)</label>
+    <input id="poll_hours" type="number" min="1" max="168" value="24" style="width:5em"/>
+  </div>
   <div style="margin-top:12px">
     <button id="generate">Generate</button>
     <button id="post" disabled>Post</button>
@@ -74,10 +139,123 @@ button{padding:8px 14px}
     <label for="preview">Preview</label>
     <textarea id="preview" rows="5" style="width:100%" placeholder="Generated tweet will appear here..." disabled></textarea>
   </div>
+  <div id="candidates" class="cands"></div>
   <div id="result" style="margin-top:10px"></div>
 </div>
 
+<div class="card">
+  <h3>Content Calendar</h3>
+  <div>
+    <button id="cal_prev">&larr;</button>
+    <button id="cal_this">This week</button>
+    <button id="cal_next">&rarr;</button>
+    <b id="cal_range" style="margin-left:8px"></b>
+  </div>
+  <div id="cal_summary" style="color:#666;font-size:13px;margin-top:6px"></div>
+  <div id="cal_grid" class="cal"></div>
+  <div style="margin-top:12px">
+    <b id="cal_heading">New draft</b>
+    <textarea id="cal_text" rows="3" style="width:100%;margin-top:6px" maxlength="280" placeholder="Write a tweet to schedule..."></textarea>
+    <div class="grid" style="grid-template-columns:repeat(3,1fr)">
+      <div><label for="cal_local">Time</label><input id="cal_local" type="datetime-local"/></div>
+      <div><label for="cal_tz">Time zone</label><input id="cal_tz"/></div>
+      <div><label for="cal_account">Account</label><input id="cal_account"/></div>
+    </div>
+    <div style="margin-top:8px">
+      <button id="cal_save">Schedule</button>
+      <button id="cal_new">New draft</button>
+      <button id="cal_delete" disabled>Delete</button>
+    </div>
+    <div id="cal_result" style="margin-top:8px"></div>
+  </div>
+</div>
+
+<div class="card">
+  <h3>Content Library</h3>
+  <div id="lib_summary" style="color:#666;font-size:13px"></div>
+  <div style="margin-top:8px">
+    <input id="lib_post" placeholder="ID of a published post" style="width:30%"/>
+    <button id="lib_add_post">Mark evergreen</button>
+  </div>
+  <textarea id="lib_text" rows="2" style="width:100%;margin-top:6px" maxlength="280" placeholder="Or write an evergreen tweet for the library..."></textarea>
+  <button id="lib_add_text">Add to library</button>
+  <div id="lib_result" style="margin-top:8px"></div>
+  <table id="lib_rows" style="margin-top:8px;border-collapse:collapse;font-size:13px;width:100%"></table>
+</div>
+
+<div class="card">
+  <h3>Shadow Timeline</h3>
+  <div id="shadow_mode"></div>
+  <div class="grid" style="grid-template-columns:1fr 1fr;margin-top:8px">
+    <div><b>Dry run (not published)</b><table id="shadow_rows" style="border-collapse:collapse;font-size:13px;width:100%"></table></div>
+    <div><b>Published</b><table id="live_rows" style="border-collapse:collapse;font-size:13px;width:100%"></table></div>
+  </div>
+</div>
+
+<div class="card">
+  <h3>Inbox</h3>
+  <div id="inbox_summary"></div>
+  <select id="inbox_status" style="margin-top:8px">
+    <option value="queued">queued</option><option value="failed">failed</option><option value="replied">replied</option>
+    <option value="ignored">ignored</option><option value="dismissed">dismissed</option><option value="">all</option>
+  </select>
+  <div id="inbox_rows" style="margin-top:8px"></div>
+</div>
+
+<div class="card">
+  <h3>Reply Candidates</h3>
+  <div id="reply_summary"></div>
+  <table id="reply_rows" style="margin-top:8px;border-collapse:collapse;font-size:13px;width:100%"></table>
+</div>
+
+<div class="card">
+  <h3>Storage</h3>
+  <div id="storage_summary"></div>
+  <table id="storage_rows" style="margin-top:8px;border-collapse:collapse;font-size:13px"></table>
+</div>
+
+<div class="card">
+  <h3>Diagnostics</h3>
+  <button id="diag_run">Run diagnostics</button>
+  <table id="diag_rows" style="margin-top:8px;border-collapse:collapse;font-size:13px"></table>
+</div>
+
+<div class="card">
+  <h3>Audit Log</h3>
+  <input id="audit_q" placeholder="Search text, IDs, users..." style="width:40%"/>
+  <select id="audit_action">
+    <option value="">all actions</option>
+    <option>generate</option><option>approve</option><option>post</option><option>reply</option>
+    <option>quote</option><option>like</option><option>repost</option><option>config</option><option>dismiss</option><option>schedule</option><option>delete</option><option>evergreen</option><option>recycle</option><option>login</option><option>logout</option>
+  </select>
+  <button id="audit_search">Search</button>
+  <a id="audit_export" href="/api/audit/export">Export JSONL</a>
+  <table id="audit_rows" style="margin-top:8px;border-collapse:collapse;width:100%;font-size:13px"></table>
+</div>
+
 <script>
+var me = {role: 'viewer', csrf_token: ''};
+var roleRank = {viewer: 1, editor: 2, publisher: 3};
+var leading = true;
+function can(role){ return roleRank[me.role] >= roleRank[role]; }
+// read-only replicas refuse writes whatever the role
+function canWrite(role){ return leading && can(role); }
+function apiPost(url, body){
+  var headers = {'Content-Type':'application/json'};
+  if (me.csrf_token) { headers['X-CSRF-Token'] = me.csrf_token; }
+  return fetch(url, {method:'POST', headers: headers, body: JSON.stringify(body)});
+}
+async function loadMe(){
+  const res = await fetch('/api/me');
+  if (res.status === 401) { window.location = '/login'; return; }
+  me = await res.json();
+  var html = 'Signed in as <b>' + esc(me.name) + '</b> (' + esc(me.role) + ')';
+  if (me.via !== 'disabled') {
+    html += ' <form method="post" action="/logout" style="display:inline"><input type="hidden" name="csrf_token" value="' + esc(me.csrf_token || '') + '"/><button type="submit">Sign out</button></form>';
+  }
+  document.getElementById('whoami').innerHTML = html;
+  document.getElementById('generate').disabled = !canWrite('editor');
+}
 async function loadMeta(){
   const res = await fetch('/api/topics');
   const data = await res.json();
@@ -104,10 +282,451 @@ async function loadStats(){
   const s = await res.json();
   document.getElementById('posted').textContent = s.posted_count;
   document.getElementById('replies').textContent = s.reply_count;
+  var e = s.engagements || {};
+  document.getElementById('engaged').textContent = (e.quote || 0) + ' / ' + (e.like || 0) + ' / ' + (e.repost || 0);
   document.getElementById('likes').textContent = s.likes_total;
   document.getElementById('replies_total').textContent = s.replies_total;
+  var polls = s.polls || [];
+  document.getElementById('polls_card').style.display = polls.length ? '' : 'none';
+  var rows = '<tr><th align="left">Question</th><th align="left">Results</th><th align="left">Status</th></tr>';
+  polls.forEach(function(p){
+    var total = (p.votes || []).reduce(function(a, b){ return a + b; }, 0);
+    var res = p.options.map(function(o, i){
+      var v = (p.votes || [])[i] || 0;
+      return esc(o) + ': ' + v + (total ? ' (' + Math.round(100*v/total) + '%)' : '');
+    }).join('<br/>');
+    rows += '<tr style="border-top:1px solid #eee"><td>' + esc(p.text) + '</td><td>' + res + '</td><td>' + esc(p.status || 'pending') + '</td></tr>';
+  });
+  document.getElementById('poll_rows').innerHTML = rows;
+}
+function usd(v){ return '$' + v.toFixed(v < 1 ? 4 : 2); }
+async function loadUsage(){
+  const res = await fetch('/api/usage');
+  if(!res.ok){ return; }
+  const u = await res.json();
+  document.getElementById('cost_today').textContent = usd(u.today.cost_usd);
+  document.getElementById('cost_month').textContent = usd(u.month.cost_usd);
+  document.getElementById('tok_today').textContent = u.today.calls + ' calls, ' + (u.today.prompt_tokens + u.today.completion_tokens) + ' tokens';
+  document.getElementById('tok_month').textContent = u.month.calls + ' calls, ' + (u.month.prompt_tokens + u.month.completion_tokens) + ' tokens';
+  var st = [];
+  if (u.budget_daily_usd > 0) { st.push('daily budget ' + usd(u.budget_daily_usd)); }
+  if (u.budget_monthly_usd > 0) { st.push('monthly budget ' + usd(u.budget_monthly_usd)); }
+  var html = st.length ? st.join(', ') : 'no budget set';
+  if (u.paused) { html = '<span class="bad">Generation paused: budget exceeded</span> (' + html + ')'; }
+  if (u.unpriced_models && u.unpriced_models.length) { html += '<br/><span class="bad">No price configured for: ' + u.unpriced_models.join(', ') + '</span>'; }
+  document.getElementById('cost_status').innerHTML = html;
+  var rows = '<tr><th align="left">Model / purpose</th><th>Calls</th><th>Prompt</th><th>Completion</th><th>Cost</th><th>Avg ms</th></tr>';
+  [u.by_model, u.by_purpose].forEach(function(m){
+    for (var k in m) {
+      var t = m[k];
+      rows += '<tr><td>' + esc(k) + '</td><td>' + t.calls + '</td><td>' + t.prompt_tokens + '</td><td>' + t.completion_tokens + '</td><td>' + usd(t.cost_usd) + '</td><td>' + t.avg_latency_ms + '</td></tr>';
+    }
+  });
+  document.getElementById('cost_breakdown').innerHTML = rows;
+}
+function auditParams(){
+  var p = new URLSearchParams();
+  var q = document.getElementById('audit_q').value.trim();
+  var a = document.getElementById('audit_action').value;
+  if (q) { p.set('q', q); }
+  if (a) { p.set('action', a); }
+  return p;
+}
+async function loadAudit(){
+  var p = auditParams();
+  document.getElementById('audit_export').href = '/api/audit/export?' + p.toString();
+  p.set('limit', '50');
+  const res = await fetch('/api/audit?' + p.toString());
+  if(!res.ok){ return; }
+  const data = await res.json();
+  var rows = '<tr><th align="left">Time</th><th align="left">Actor</th><th align="left">Action</th><th align="left">Result</th><th align="left">Details</th></tr>';
+  (data.entries || []).forEach(function(e){
+    var res = e.ok ? '<span class="ok">ok</span>' : '<span class="bad">' + esc(e.error || 'failed') + '</span>';
+    var codes = [];
+    if (e.x_status) { codes.push('X ' + e.x_status); }
+    if (e.gemini_code) { codes.push('Gemini ' + e.gemini_code); }
+    if (codes.length) { res += ' (' + codes.join(', ') + ')'; }
+    var details = JSON.stringify({inputs: e.inputs, outputs: e.outputs});
+    rows += '<tr style="border-top:1px solid #eee"><td>' + new Date(e.at).toLocaleString() + '</td><td>' + esc(e.actor_kind) + (e.actor && e.actor !== e.actor_kind ? ': ' + esc(e.actor) : '') +
+      '</td><td>' + esc(e.action) + '</td><td>' + res + '</td><td><code>' + esc(details.length > 200 ? details.slice(0, 200) + '...' : details) + '</code></td></tr>';
+  });
+  document.getElementById('audit_rows').innerHTML = rows;
+}
+// timelineRows lists posts and replies; live rows offer marking a post
+// evergreen
+function timelineRows(recs, live){
+  var rows = '<tr><th align="left">Time</th><th align="left">Kind</th><th align="left">Text</th></tr>';
+  (recs || []).forEach(function(r){
+    var where = r.kind === 'reply' ? 'reply to ' + esc(r.in_reply_to)
+      : r.tweet_id ? esc(r.kind) + ' of ' + esc(r.tweet_id) : (r.poll ? 'poll' : 'post') + (r.slot ? ' (slot ' + esc(r.slot) + ')' : '');
+    var text = esc(r.text);
+    if (r.poll) { text += '<br/><i>' + r.poll.options.map(esc).join(' / ') + ' · ' + r.poll.duration_minutes + ' min</i>'; }
+    if (r.reply_settings) { text += '<br/><i>replies: ' + esc(r.reply_settings) + '</i>'; }
+    if (live && r.kind === 'post' && canWrite('publisher')) {
+      text += ' <button class="evg" data-id="' + esc(r.id) + '" title="Add to the content library">&#9733; Evergreen</button>';
+    }
+    rows += '<tr style="border-top:1px solid #eee"><td>' + new Date(r.at).toLocaleString() + '</td><td>' + where + '</td><td>' + text + '</td></tr>';
+  });
+  return rows;
+}
+async function loadShadow(){
+  const res = await fetch('/api/shadow');
+  if(!res.ok){ return; }
+  const d = await res.json();
+  document.getElementById('shadow_mode').innerHTML = d.dry_run
+    ? '<span class="bad">Dry run is ON: posts and replies are recorded here, not published.</span>'
+    : '<span class="ok">Dry run is off: posts and replies are published.</span>';
+  document.getElementById('shadow_rows').innerHTML = timelineRows(d.shadow);
+  document.getElementById('live_rows').innerHTML = timelineRows(d.live, true);
+}
+var cal = {from: '', data: null, editing: null};
+// calParts reads an instant as a day, hour and minute on the wall clock of tz
+function calParts(at, tz){
+  var p = {};
+  new Intl.DateTimeFormat('en-CA', {timeZone: tz, year:'numeric', month:'2-digit', day:'2-digit', hour:'2-digit', minute:'2-digit', hourCycle:'h23'})
+    .formatToParts(new Date(at)).forEach(function(x){ p[x.type] = x.value; });
+  return {day: p.year + '-' + p.month + '-' + p.day, hour: Number(p.hour), minute: p.minute};
+}
+function calShift(day, n){
+  var d = new Date(day + 'T12:00:00Z');
+  d.setUTCDate(d.getUTCDate() + n);
+  return d.toISOString().slice(0, 10);
+}
+async function loadCalendar(){
+  const res = await fetch('/api/calendar' + (cal.from ? '?from=' + cal.from : ''));
+  if(!res.ok){ return; }
+  const d = await res.json();
+  var first = !cal.data;
+  cal.data = d;
+  cal.from = calParts(d.from, d.tz).day;
+  var days = [];
+  for (var i = 0; i < 7; i++) { days.push(calShift(cal.from, i)); }
+  document.getElementById('cal_range').textContent = days[0] + ' – ' + days[6] + ' (' + d.tz + ')';
+  var conflicts = d.drafts.filter(function(x){ return x.conflicts && x.conflicts.length; }).length;
+  document.getElementById('cal_summary').innerHTML = d.drafts.length + ' drafts, ' + d.slots.length + ' generated slots drawn. ' +
+    'Shaded hours are the posting window (' + esc(d.window.start) + '–' + esc(d.window.end) + '), where ' + d.posts_per_day +
+    ' generated slots a day land; days not drawn yet show none.' +
+    (conflicts ? ' <span class="bad">' + conflicts + ' drafts within ' + esc(d.conflict_window) + ' of another post.</span>' : '');
+  var ws = Number(d.window.start.slice(0, 2)), we = Number(d.window.end.slice(0, 2));
+  var cells = {};
+  var grid = document.getElementById('cal_grid');
+  grid.innerHTML = '<div></div>';
+  days.forEach(function(day){
+    var dt = new Date(day + 'T12:00:00Z');
+    grid.innerHTML += '<div class="cell"><b>' + dt.toLocaleDateString(undefined, {weekday:'short', day:'numeric', month:'short', timeZone:'UTC'}) + '</b></div>';
+  });
+  for (var h = 0; h < 24; h++) {
+    var label = document.createElement('div');
+    label.className = 'cell';
+    label.textContent = (h < 10 ? '0' : '') + h + ':00';
+    grid.appendChild(label);
+    days.forEach(function(day){
+      var c = document.createElement('div');
+      c.className = 'cell' + (h >= ws && h <= we ? ' win' : '');
+      c.dataset.day = day;
+      c.dataset.hour = h;
+      c.ondragover = function(e){ e.preventDefault(); c.classList.add('over'); };
+      c.ondragleave = function(){ c.classList.remove('over'); };
+      c.ondrop = function(e){ e.preventDefault(); c.classList.remove('over'); moveDraft(e.dataTransfer.getData('text/plain'), c.dataset.day, Number(c.dataset.hour)); };
+      cells[day + '/' + h] = c;
+      grid.appendChild(c);
+    });
+  }
+  d.slots.forEach(function(sl){
+    var p = calParts(sl.at, d.tz), c = cells[p.day + '/' + p.hour];
+    if (!c) { return; }
+    var chip = document.createElement('div');
+    chip.className = 'chip slot';
+    chip.textContent = (sl.posted ? '✓ ' : '') + String(p.hour).padStart(2, '0') + ':' + p.minute + ' generated';
+    chip.title = 'Generated slot ' + sl.key;
+    c.appendChild(chip);
+  });
+  d.drafts.forEach(function(dr){
+    var p = calParts(dr.at, d.tz), c = cells[p.day + '/' + p.hour];
+    if (!c) { return; }
+    var chip = document.createElement('div');
+    var conflict = dr.conflicts && dr.conflicts.length;
+    chip.className = 'chip draft ' + dr.status + (conflict ? ' conflict' : '');
+    chip.textContent = (conflict ? '⚠ ' : '') + String(p.hour).padStart(2, '0') + ':' + p.minute + ' ' + dr.text;
+    var tip = [dr.status + (dr.own ? '' : ' (account ' + dr.account + ')'), dr.local + ' ' + dr.tz, dr.text];
+    if (dr.error) { tip.push(dr.error); }
+    if (conflict) { tip.push('Conflicts: ' + dr.conflicts.join('; ')); }
+    chip.title = tip.join('\n');
+    if (dr.status !== 'posted' && canWrite('publisher')) {
+      chip.draggable = true;
+      chip.ondragstart = function(e){ e.dataTransfer.setData('text/plain', dr.id); };
+    }
+    chip.onclick = function(){ editDraft(dr); };
+    c.appendChild(chip);
+  });
+  if (first) { newDraft(); }
+}
+function newDraft(){
+  cal.editing = null;
+  document.getElementById('cal_heading').textContent = 'New draft';
+  document.getElementById('cal_text').value = '';
+  document.getElementById('cal_local').value = '';
+  document.getElementById('cal_tz').value = cal.data ? cal.data.tz : '';
+  document.getElementById('cal_account').value = cal.data ? cal.data.account : '';
+  document.getElementById('cal_delete').disabled = true;
+  document.getElementById('cal_save').textContent = 'Schedule';
+  document.getElementById('cal_save').disabled = !canWrite('publisher');
+}
+function editDraft(dr){
+  cal.editing = dr.id;
+  document.getElementById('cal_heading').textContent = 'Draft ' + dr.id + ' (' + dr.status + (dr.author ? ', by ' + dr.author : '') + ')';
+  document.getElementById('cal_text').value = dr.text;
+  document.getElementById('cal_local').value = dr.local;
+  document.getElementById('cal_tz').value = dr.tz;
+  document.getElementById('cal_account').value = dr.account;
+  document.getElementById('cal_delete').disabled = !canWrite('publisher');
+  document.getElementById('cal_save').textContent = dr.status === 'posted' ? 'Already posted' : 'Save';
+  document.getElementById('cal_save').disabled = dr.status === 'posted' || !canWrite('publisher');
+  calResult(dr);
+}
+function calResult(dr){
+  var el = document.getElementById('cal_result');
+  if (dr.conflicts && dr.conflicts.length) {
+    el.innerHTML = '<span class="bad">⚠ ' + dr.conflicts.map(esc).join('; ') + '</span>';
+  } else if (dr.error) {
+    el.innerHTML = '<span class="bad">' + esc(dr.error) + '</span>';
+  } else {
+    el.textContent = '';
+  }
+}
+async function calSend(url, body){
+  var res = await apiPost(url, body);
+  var el = document.getElementById('cal_result');
+  if(!res.ok){
+    el.innerHTML = '<span class="bad">Failed: ' + esc(await res.text()) + '</span>';
+    return null;
+  }
+  if (res.status === 204) { return {}; }
+  var dr = await res.json();
+  calResult(dr);
+  if (!(dr.conflicts && dr.conflicts.length)) { el.innerHTML = '<span class="ok">Scheduled for ' + esc(dr.local) + ' ' + esc(dr.tz) + '.</span>'; }
+  return dr;
+}
+async function saveDraft(){
+  var dr = await calSend('/api/calendar/save', {
+    id: cal.editing || '',
+    text: document.getElementById('cal_text').value,
+    local: document.getElementById('cal_local').value,
+    tz: document.getElementById('cal_tz').value,
+    account: document.getElementById('cal_account').value
+  });
+  if (dr) { cal.editing = dr.id; loadCalendar(); }
+}
+async function moveDraft(id, day, hour){
+  var dr = (cal.data.drafts || []).find(function(x){ return x.id === id; });
+  if (!dr) { return; }
+  var local = day + 'T' + String(hour).padStart(2, '0') + ':' + calParts(dr.at, cal.data.tz).minute;
+  var moved = await calSend('/api/calendar/move', {id: id, local: local});
+  if (moved) { editDraft(moved); loadCalendar(); }
+}
+async function deleteDraft(){
+  if (!cal.editing || !confirm('Delete this draft?')) { return; }
+  if (await calSend('/api/calendar/delete', {id: cal.editing})) {
+    newDraft();
+    document.getElementById('cal_result').textContent = 'Draft deleted.';
+    loadCalendar();
+  }
+}
+async function loadReplyCandidates(){
+  const res = await fetch('/api/replies/candidates');
+  if(!res.ok){ return; }
+  const d = await res.json();
+  var cands = d.candidates || [];
+  var w = [];
+  for (var k in d.weights) { w.push(k + ' ' + d.weights[k]); }
+  var html = 'Weights: ' + esc(w.join(', ')) + '. Minimum score ' + d.min_score + ', up to ' + d.max_per_scan + ' replies a scan.';
+  html += '<br/>Cooldowns: author ' + esc(d.cooldowns.author) + ', conversation ' + esc(d.cooldowns.conversation) + ' (0s is off). ' +
+    (d.daily_cap > 0 ? d.today_remaining + ' of ' + d.daily_cap + ' daily replies left.' : 'No daily cap.');
+  var acts = [];
+  ['quote', 'repost', 'like'].forEach(function(k){
+    var a = (d.actions || {})[k];
+    if (!a) { acts.push(k + ' off'); return; }
+    acts.push(k + ' from ' + a.min_score.toFixed(2) + (a.daily_cap > 0 ? ' (' + a.left + ' of ' + a.daily_cap + ' left today)' : ' (no cap)'));
+  });
+  html += '<br/>Other actions: ' + esc(acts.join(', ')) + '.';
+  html = (cands.length ? 'Last scan ' + new Date(d.at).toLocaleString() + ': ' + cands.length + ' tweets. '
+    : 'No reply scan has run on this replica yet. ') + html;
+  document.getElementById('reply_summary').innerHTML = html;
+  var rows = '<tr><th align="left">Tweet</th><th>Score</th><th align="left">Action</th><th align="left">Outcome</th><th align="left">Why</th></tr>';
+  cands.forEach(function(c){
+    var act = c.action ? '<b>' + esc(c.action.kind) + '</b><br/><small>' + esc(c.action.reason) + '</small>' : '-';
+    var t = c.tweet;
+    var why = c.rejected ? '<span class="bad">' + esc(c.reason) + '</span>'
+      : (c.factors || []).map(function(f){ return '<b>' + esc(f.name) + '</b> ' + f.value.toFixed(2) + '&times;' + f.weight + ' ' + esc(f.why); }).join('<br/>');
+    var who = t.author ? '@' + esc(t.author) : esc(t.author_id);
+    rows += '<tr style="border-top:1px solid #eee;vertical-align:top"><td><a href="https://x.com/i/web/status/' + esc(t.id) + '" target="_blank">' + who + '</a>: ' +
+      esc(t.text.length > 140 ? t.text.slice(0, 140) + '...' : t.text) + '</td><td>' + (c.rejected ? '-' : c.score.toFixed(2)) +
+      '</td><td>' + act + '</td><td>' + esc(c.outcome) + '</td><td>' + why + '</td></tr>';
+  });
+  document.getElementById('reply_rows').innerHTML = rows;
+}
+async function loadInbox(){
+  const res = await fetch('/api/inbox?status=' + encodeURIComponent(document.getElementById('inbox_status').value));
+  if(!res.ok){ return; }
+  const d = await res.json();
+  var counts = [];
+  for (var k in d.counts) { counts.push(d.counts[k] + ' ' + k); }
+  document.getElementById('inbox_summary').innerHTML = (d.enabled ? 'Polling mentions every ' + esc(d.interval) : 'Mentions poller off') +
+    '. Policy: ' + esc(d.policy) + '.' + (counts.length ? ' ' + esc(counts.join(', ')) + '.' : ' No mentions yet.');
+  var el = document.getElementById('inbox_rows');
+  el.innerHTML = '';
+  (d.mentions || []).forEach(function(m){
+    var div = document.createElement('div');
+    div.className = 'cand';
+    div.style.marginBottom = '8px';
+    var who = m.author ? '@' + esc(m.author) : esc(m.author_id);
+    div.innerHTML = '<div><a href="https://x.com/i/web/status/' + esc(m.id) + '" target="_blank">' + who + '</a>: ' + esc(m.text) + '</div>' +
+      '<div class="meta">' + esc(m.class || 'unclassified') + ' &middot; ' + esc(m.status) + ' &middot; ' + new Date(m.at).toLocaleString() +
+      (m.note ? ' &middot; <span class="bad">' + esc(m.note) + '</span>' : '') +
+      (m.reply_id ? ' &middot; reply ' + esc(m.reply_id) : '') + '</div>';
+    if (m.status === 'replied' || m.status === 'ignored' || m.status === 'dismissed') {
+      if (m.draft) { div.innerHTML += '<div class="meta">Reply: ' + esc(m.draft) + '</div>'; }
+      el.appendChild(div);
+      return;
+    }
+    var draft = document.createElement('textarea');
+    draft.rows = 3;
+    draft.style.width = '100%';
+    draft.value = m.draft || '';
+    div.appendChild(draft);
+    var send = document.createElement('button');
+    send.textContent = 'Approve & reply';
+    send.disabled = !canWrite('publisher');
+    send.onclick = function(){ inboxAction('/api/inbox/reply', {id: m.id, text: draft.value}); };
+    var drop = document.createElement('button');
+    drop.textContent = 'Dismiss';
+    drop.disabled = !canWrite('editor');
+    drop.onclick = function(){ inboxAction('/api/inbox/dismiss', {id: m.id}); };
+    div.appendChild(send);
+    div.appendChild(drop);
+    el.appendChild(div);
+  });
+}
+async function inboxAction(url, body){
+  var res = await apiPost(url, body);
+  if(!res.ok){ alert('Failed: ' + await res.text()); }
+  loadInbox();
+  loadStats();
+}
+function engagement(m){
+  return m ? m.likes + ' likes, ' + m.replies + ' replies, ' + (m.retweets + m.quotes) + ' reposts' : 'not measured';
+}
+async function loadLibrary(){
+  const res = await fetch('/api/library');
+  if(!res.ok){ return; }
+  const d = await res.json();
+  var r = d.rules;
+  document.getElementById('lib_summary').textContent = r.share > 0
+    ? 'About ' + Math.round(r.share * 100) + '% of slots go to the recycler, which posts the best performing entry older than ' + r.min_age_days +
+      ' days in new words: each entry at most ' + (r.max_times || 'any number of') + ' times, ' + r.cooldown_days + ' days apart, and ' +
+      (r.daily_cap ? 'at most ' + r.daily_cap + ' a day.' : 'no daily limit.')
+    : 'Recycling is off (recycle.share is 0); entries are kept for when it is on.';
+  var rows = '<tr><th align="left">Tweet</th><th align="left">Original</th><th>Score</th><th align="left">Recycled as</th><th align="left">Status</th><th></th></tr>';
+  (d.entries || []).forEach(function(e){
+    var orig = e.post_id
+      ? '<a href="https://x.com/i/web/status/' + esc(e.post_id) + '" target="_blank">' + new Date(e.at).toLocaleDateString() + '</a><br/><small>' + engagement(e.metrics) + '</small>'
+      : 'written ' + new Date(e.at).toLocaleDateString() + (e.author ? ' by ' + esc(e.author) : '');
+    var recycled = e.recycled.map(function(p){
+      return '<a href="https://x.com/i/web/status/' + esc(p.post_id) + '" target="_blank">' + new Date(p.at).toLocaleDateString() + '</a>: ' +
+        '<small>' + engagement(p.metrics) + '</small><br/><i>' + esc(p.text) + '</i>';
+    }).join('<br/>') || '-';
+    var status = e.reason ? '<span class="bad">' + esc(e.reason) + '</span>' : '<span class="ok">eligible</span>';
+    var rm = canWrite('publisher') ? '<button class="lib_rm" data-id="' + esc(e.id) + '">Remove</button>' : '';
+    rows += '<tr style="border-top:1px solid #eee;vertical-align:top"><td>' + esc(e.text) + '</td><td>' + orig + '</td><td align="center">' + e.score +
+      '</td><td>' + recycled + '</td><td>' + status + '</td><td>' + rm + '</td></tr>';
+  });
+  document.getElementById('lib_rows').innerHTML = (d.entries || []).length ? rows : '<tr><td>No evergreen tweets yet.</td></tr>';
+}
+async function libraryAction(url, body){
+  var out = document.getElementById('lib_result');
+  var res = await apiPost(url, body);
+  if(!res.ok){ out.innerHTML = '<span class="bad">' + esc(await res.text()) + '</span>'; return false; }
+  out.innerHTML = url.endsWith('/add') ? '<span class="ok">Added to the library.</span>' : 'Removed from the library.';
+  loadLibrary();
+  return true;
+}
+async function addEvergreenPost(id){
+  if (await libraryAction('/api/library/add', {post_id: id})) { document.getElementById('lib_post').value = ''; }
+}
+async function addEvergreenText(){
+  var t = document.getElementById('lib_text');
+  if (await libraryAction('/api/library/add', {text: t.value})) { t.value = ''; }
+}
+function bytes(n){
+  if (n < 1024) { return n + ' B'; }
+  if (n < 1048576) { return (n / 1024).toFixed(1) + ' KiB'; }
+  return (n / 1048576).toFixed(1) + ' MiB';
+}
+async function loadStorage(){
+  const res = await fetch('/api/storage');
+  if(!res.ok){ return; }
+  const d = await res.json();
+  var html = (d.backend === 'sqlite' ? 'SQLite database ' + bytes(d.lsm_bytes) + ', WAL ' + bytes(d.vlog_bytes)
+      : 'LSM ' + bytes(d.lsm_bytes) + ', value log ' + bytes(d.vlog_bytes)) +
+    '. Retention: seen ' + esc(d.retention.seen) + ', slots ' + esc(d.retention.slots) + ', shadow ' + esc(d.retention.shadow) + ' (0s keeps forever).';
+  if (d.last_gc) {
+    html += '<br/>Last GC ' + new Date(d.last_gc.at).toLocaleString() + ': ' + d.last_gc.expired + ' expired, ' +
+      d.last_gc.dated + ' dated, ' + d.last_gc.rewritten + ' value log files rewritten' +
+      (d.last_gc.error ? ' <span class="bad">' + esc(d.last_gc.error) + '</span>' : '');
+  }
+  document.getElementById('storage_summary').innerHTML = html;
+  var rows = '<tr><th align="left">' + (d.backend === 'sqlite' ? 'Table' : 'Prefix') + '</th><th>Keys</th><th>Expiring</th><th>Size</th></tr>';
+  d.prefixes.forEach(function(p){
+    rows += '<tr style="border-top:1px solid #eee"><td>' + esc(p.prefix) + '</td><td>' + p.keys + '</td><td>' + p.expiring + '</td><td>' + bytes(p.bytes) + '</td></tr>';
+  });
+  document.getElementById('storage_rows').innerHTML = rows;
+}
+async function loadDiagnostics(){
+  var el = document.getElementById('diag_rows');
+  el.innerHTML = '<tr><td>Running...</td></tr>';
+  const res = await fetch('/api/diagnostics');
+  if(!res.ok){ el.innerHTML = '<tr><td class="bad">Failed: ' + res.status + '</td></tr>'; return; }
+  const d = await res.json();
+  var rows = '<tr><th align="left">Check</th><th align="left">Status</th><th align="left">Detail</th><th>ms</th></tr>';
+  d.checks.checks.forEach(function(c){
+    rows += '<tr style="border-top:1px solid #eee"><td>' + esc(c.name) + '</td><td>' + (c.ok ? '<span class="ok">ok</span>' : '<span class="bad">fail</span>') +
+      (c.cached ? ' (cached)' : '') + '</td><td>' + esc(c.ok ? (c.detail || '') : c.error) + '</td><td>' + c.duration_ms + '</td></tr>';
+  });
+  rows += '<tr style="border-top:1px solid #eee"><td>generator breaker</td><td>' + esc(d.generator.state) + '</td><td>' + esc(d.generator.last_error || '') + '</td><td></td></tr>';
+  (d.slots || []).forEach(function(s){
+    rows += '<tr style="border-top:1px solid #eee"><td>slot ' + esc(s.key) + '</td><td></td><td>' + new Date(s.time).toLocaleString() + '</td><td></td></tr>';
+  });
+  el.innerHTML = rows;
 }
 let generated = '';
+function esc(s){ return String(s).replace(/&/g,'&amp;').replace(/</g,'&lt;'); }
+function renderCandidates(cands){
+  var el = document.getElementById('candidates');
+  el.innerHTML = '';
+  (cands || []).forEach(function(c, i){
+    var div = document.createElement('div');
+    div.className = 'cand';
+    var parts = [];
+    for (var k in c.scores) { parts.push(k + ' ' + c.scores[k].toFixed(2)); }
+    var meta = c.rejected ? '<span class="bad">rejected: ' + esc(c.reason) + '</span>'
+                          : 'score ' + c.score.toFixed(2) + (parts.length ? ' (' + parts.join(', ') + ')' : '');
+    div.innerHTML = '<div>' + esc(c.text) + '</div><div class="meta">#' + (i+1) + ' &middot; ' + meta + '</div>';
+    var btn = document.createElement('button');
+    btn.textContent = 'Use';
+    btn.disabled = c.rejected;
+    btn.onclick = function(){ useCandidate(c.text); };
+    div.appendChild(btn);
+    el.appendChild(div);
+  });
+}
+function useCandidate(text){
+  generated = text;
+  var preview = document.getElementById('preview');
+  preview.value = text;
+  preview.disabled = false;
+  document.getElementById('post').disabled = !text || !canWrite('publisher');
+  document.getElementById('discard').disabled = !text;
+}
 async function generateTweet(){
   var tops = document.querySelectorAll('input[name="topic"]:checked');
   var topics = [];
@@ -119,19 +738,23 @@ async function generateTweet(){
   var discardBtn = document.getElementById('discard');
   result.textContent = 'Generating...';
   try{
-    var res = await fetch('/api/generate', {method:'POST', headers:{'Content-Type':'application/json'}, body: JSON.stringify({topics: topics, style: style})});
+    var res = await apiPost('/api/generate', {topics: topics, style: style, mode: document.getElementById('mode').value});
     if(!res.ok){
       var t = await res.text();
       result.innerHTML = '<span class="bad">Failed: ' + t + '</span>';
       return;
     }
     var data = await res.json();
+    renderCandidates(data.candidates);
+    if(data.poll){ setPoll(data.poll.options, Math.max(1, Math.round(data.poll.duration_minutes/60))); }
     generated = data.text || '';
     preview.value = generated;
     preview.disabled = false;
-    postBtn.disabled = !generated;
+    postBtn.disabled = !generated || !canWrite('publisher');
     discardBtn.disabled = !generated;
-    result.innerHTML = '<span class="ok">Preview generated. Review and click Post to publish.</span>';
+    result.innerHTML = generated
+      ? '<span class="ok">Top-ranked draft loaded. Pick another option or click Post to publish.</span>'
+      : '<span class="bad">Every draft was rejected by policy.</span>';
   }catch(e){
     result.innerHTML = '<span class="bad">Error: ' + e + '</span>';
   }
@@ -143,26 +766,41 @@ async function postTweet(){
   if(!text){ result.innerHTML = '<span class="bad">Nothing to post.</span>'; return; }
   result.textContent = 'Posting...';
   try{
-    var res = await fetch('/api/post', {method:'POST', headers:{'Content-Type':'application/json'}, body: JSON.stringify({text: text})});
+    var body = {text: text, reply_settings: document.getElementById('reply_settings').value};
+    if(document.getElementById('mode').value === 'poll'){
+      var opts = [];
+      document.querySelectorAll('.poll_opt').forEach(function(o){ if(o.value.trim()){ opts.push(o.value.trim()); } });
+      body.poll = {options: opts, duration_minutes: Math.round(Number(document.getElementById('poll_hours').value)*60)};
+    }
+    var res = await apiPost('/api/post', body);
     if(!res.ok){
       var t = await res.text();
       result.innerHTML = '<span class="bad">Failed: ' + t + '</span>';
       return;
     }
     var data = await res.json();
-    result.innerHTML = '<span class="ok">Posted!</span> ID: ' + data.id + '<br/>Text: ' + String(text).replace(/</g,'&lt;');
+    result.innerHTML = (data.dry_run ? '<span class="ok">Recorded (dry run, not published).</span>' : '<span class="ok">Posted!</span>') + ' ID: ' + data.id + '<br/>Text: ' + esc(text);
     generated = '';
+    setPoll([], 24);
+    renderCandidates([]);
     preview.value = '';
     preview.disabled = true;
     document.getElementById('post').disabled = true;
     document.getElementById('discard').disabled = true;
     loadStats();
+    loadShadow();
   }catch(e){
     result.innerHTML = '<span class="bad">Error: ' + e + '</span>';
   }
 }
+function setPoll(options, hours){
+  document.querySelectorAll('.poll_opt').forEach(function(o, i){ o.value = options[i] || ''; });
+  document.getElementById('poll_hours').value = hours;
+}
 function discardTweet(){
   generated = '';
+  setPoll([], 24);
+  renderCandidates([]);
   var preview = document.getElementById('preview');
   preview.value = '';
   preview.disabled = true;
@@ -170,84 +808,162 @@ function discardTweet(){
   document.getElementById('discard').disabled = true;
   document.getElementById('result').textContent = 'Draft discarded.';
 }
+async function loadLeader(){
+  const res = await fetch('/api/status');
+  if(!res.ok){ return; }
+  const d = await res.json();
+  leading = d.leader.leader;
+  document.getElementById('replica').innerHTML = leading ? ''
+    : 'Read-only replica ' + esc(d.leader.id) + ': another replica is posting. Composing is disabled here.';
+  document.getElementById('generate').disabled = !canWrite('editor');
+  if (!leading) { document.getElementById('post').disabled = true; }
+}
+// the calendar's drag handles and library buttons depend on the role and
+// leadership
+loadMe().then(loadLeader).then(loadCalendar).then(loadLibrary);
 loadMeta();
 loadStats();
+loadUsage();
+loadAudit();
+loadShadow();
+loadStorage();
+loadReplyCandidates();
+loadInbox();
 setInterval(loadStats, 10000);
+setInterval(loadShadow, 30000);
+setInterval(loadStorage, 60000);
+setInterval(loadReplyCandidates, 60000);
+// don't redraw the inbox under a draft being edited
+setInterval(function(){ if (!document.getElementById('inbox_rows').contains(document.activeElement)) { loadInbox(); } }, 60000);
+document.getElementById('inbox_status').addEventListener('change', loadInbox);
+document.getElementById('mode').addEventListener('change', function(e){
+  document.getElementById('poll_box').style.display = e.target.value === 'poll' ? '' : 'none';
+  document.getElementById('preview').placeholder = e.target.value === 'poll' ? 'Poll question will appear here...' : 'Generated tweet will appear here...';
+});
+setInterval(loadUsage, 30000);
+// don't redraw the calendar under a drag
+setInterval(function(){ if (!document.querySelector('.cal .over')) { loadCalendar(); } }, 60000);
+setInterval(loadLibrary, 60000);
+setInterval(loadLeader, 15000);
 document.addEventListener('click', function(e){ 
   if(e.target && e.target.id==='generate'){ generateTweet(); }
   if(e.target && e.target.id==='post'){ postTweet(); }
   if(e.target && e.target.id==='discard'){ discardTweet(); }
+  if(e.target && e.target.id==='audit_search'){ loadAudit(); }
+  if(e.target && e.target.id==='diag_run'){ loadDiagnostics(); }
+  if(e.target && e.target.id==='cal_save'){ saveDraft(); }
+  if(e.target && e.target.id==='cal_new'){ newDraft(); document.getElementById('cal_result').textContent = ''; }
+  if(e.target && e.target.id==='cal_delete'){ deleteDraft(); }
+  if(e.target && e.target.id==='cal_prev'){ cal.from = calShift(cal.from, -7); loadCalendar(); }
+  if(e.target && e.target.id==='cal_next'){ cal.from = calShift(cal.from, 7); loadCalendar(); }
+  if(e.target && e.target.id==='cal_this'){ cal.from = ''; loadCalendar(); }
+  if(e.target && e.target.id==='lib_add_post'){ addEvergreenPost(document.getElementById('lib_post').value); }
+  if(e.target && e.target.id==='lib_add_text'){ addEvergreenText(); }
+  if(e.target && e.target.classList.contains('evg')){ addEvergreenPost(e.target.dataset.id); }
+  if(e.target && e.target.classList.contains('lib_rm')){ libraryAction('/api/library/remove', {id: e.target.dataset.id}); }
 });
 </script>
 </body>
 </html>`
 
-func main() {
-	err := godotenv.Load()
-	if err != nil {
-		panic("Error loading .env file")
-	}
-	log := logging.New()
-	cfg := config.Load()
+// runBot is the long-running mode: scheduler, reply scanner, mentions
+// poller, dashboard and probes until ctx is cancelled.
+func runBot(ctx context.Context, a *app) error {
+	log, cfg, live, loc := a.log, a.cfg, a.live, a.loc
+	store, met, auditLog, acct := a.store, a.met, a.audit, a.acct
+	genr, x, recorder, pub, ranker := a.genr, a.x, a.recorder, a.pub, a.ranker
 
-	loc, err := time.LoadLocation(cfg.TZ)
+	runner := lifecycle.NewRunner(ctx)
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Exporter:    cfg.TraceExporter,
+		ServiceName: "twitter-automation",
+		Account:     cfg.Account,
+		SampleRatio: float64(cfg.TraceSampleRatio),
+	})
 	if err != nil {
-		log.Fatal().Err(err).Msg("load TZ")
+		return fmt.Errorf("tracing: %w", err)
 	}
+	defer func() {
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = shutdownTracing(sctx)
+	}()
 
-	store, err := storage.Open(cfg.DataDir)
-	if err != nil {
-		log.Fatal().Err(err).Msg("open store")
-	}
-	defer store.Close()
-
-	ctx := context.Background()
-	genr, err := gen.New(ctx, gen.Options{
-		APIKey:      cfg.GeminiKey,
-		Model:       cfg.Model,
-		MaxTokens:   int32(cfg.MaxTokens), // match latest API type
-		Temperature: cfg.Temperature,
-		TopP:        cfg.TopP,
-		Lang:        cfg.Lang,
-	})
-	if err != nil {
-		log.Fatal().Err(err).Msg("genai client")
-	}
-	defer genr.Close()
-
-	// log.Info().Msg("Running immediate test post...")
-	//
-	// tweet, err := genr.ComposeTweet(
-	// 		context.Background(),
-	// 		"AI in DevOps and job openings in india", // topic
-	// 		"casual",       // style
-	// )
-	// if err != nil {
-	// 		log.Fatal().Err(err).Msg("failed to compose tweet")
-	// }
-	//
-	// log.Info().Str("tweet", tweet).Msg("Generated test tweet")
-
-	// Prepare Twitter client with creds
-	httpClient := &http.Client{}
-	x := xclient.NewWithCreds(httpClient, xclient.Creds{
-		APIKey:       cfg.XApiKey,
-		APISecret:    cfg.XApiSecret,
-		AccessToken:  cfg.XAccessToken,
-		AccessSecret: cfg.XAccessSecret,
+	auditLog.Record(ctx, audit.Entry{
+		ActorKind: audit.ActorSystem,
+		Actor:     "startup",
+		Action:    audit.ActionConfig,
+		Inputs:    cfg.Summary(),
+		OK:        true,
 	})
+
+	// a first try at the lock, so a single replica starts out as leader
+	el := newElector(log, cfg)
+	el.Try(ctx)
+	met.Leader(el.IsLeader())
+
 	// schedule today’s slots
-	slots, err := scheduler.DailyRandomSlots(loc, cfg.PostsPerDay, cfg.PostWindowStart, cfg.PostWindowEnd)
-	if err != nil {
-		log.Fatal().Err(err).Msg("schedule")
+	day := &plan{store: store, leading: el.IsLeader}
+	gc := &gcRun{}
+	follows, scan := &followCache{x: x}, &replyScan{}
+	if err := day.roll(log, loc, cfg); err != nil {
+		return fmt.Errorf("schedule: %w", err)
 	}
-	for _, s := range slots {
-		log.Info().Time("time", s.Time).Str("key", s.Key).Msg("post slot")
+
+	checks := health.New(
+		health.Check{Name: "storage", Liveness: true, Run: func(ctx context.Context) (string, error) {
+			return cfg.StorageBackend + " accepts writes", store.Ping(ctx)
+		}},
+		health.Check{Name: "leader", Run: func(context.Context) (string, error) {
+			st := el.Status()
+			if st.Leader {
+				return st.ID + " is leader", nil
+			}
+			return st.ID + " is a read-only replica", nil
+		}},
+		health.Check{Name: "scheduler", Liveness: true, Run: func(context.Context) (string, error) {
+			return day.checkTick(cfg.HealthTickMaxAge)
+		}},
+		health.Check{Name: "next_slot", Run: func(ctx context.Context) (string, error) {
+			return day.checkNext(ctx, store, loc)
+		}},
+		health.Check{Name: "x_credentials", CacheTTL: cfg.HealthExternalTTL, Timeout: 10 * time.Second, Run: func(ctx context.Context) (string, error) {
+			u, err := x.Me(ctx)
+			if err != nil {
+				return "", err
+			}
+			return "authenticated as @" + u.Username, nil
+		}},
+		health.Check{Name: "gemini", CacheTTL: cfg.HealthExternalTTL, Timeout: 10 * time.Second, Run: genr.Ping},
+	)
+
+	authz, err := buildAuth(cfg, auditLog)
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
 	}
 
 	// HTTP server for simple frontend
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/topics", func(w http.ResponseWriter, r *http.Request) {
+	authz.Routes(mux)
+	// scraped by Prometheus and kubelet, so these sit outside dashboard auth
+	mux.Handle("/metrics", met.Handler())
+	mux.HandleFunc("/healthz", checks.Handler(true))
+	mux.HandleFunc("/readyz", checks.Handler(false))
+	mux.HandleFunc("/api/diagnostics", authz.Require(auth.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		resp := map[string]any{
+			"checks":    checks.Run(r.Context(), false),
+			"generator": genr.Health(),
+			"slots":     day.snapshot(),
+			"config":    live.Get().Summary(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	mux.HandleFunc("/api/topics", authz.Require(auth.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
@@ -258,77 +974,146 @@ func main() {
 		}
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(resp)
-	})
-	mux.HandleFunc("/api/stats", func(w http.ResponseWriter, r *http.Request) {
+	}))
+	mux.HandleFunc("/api/stats", authz.Require(auth.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		postedCount, _ := store.CountPrefix("postedid:")
-		replyCount, _ := store.CountPrefix("replyid:")
-		// Aggregate metrics for recent posted tweets
-		ids, _ := store.ListIDs("postedid:", 50)
-		likes := 0
-		replies := 0
-		if len(ids) > 0 {
-			tweets, err := x.GetTweets(ids)
-			if err == nil {
-				for _, t := range tweets {
-					likes += t.PublicMetrics.LikeCount
-					replies += t.PublicMetrics.ReplyCount
-				}
-			}
+		resp := postStats(r.Context(), store)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	mux.HandleFunc("/api/status", authz.Require(auth.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
 		}
 		resp := map[string]any{
-			"posted_count":  postedCount,
-			"reply_count":   replyCount,
-			"likes_total":   likes,
-			"replies_total": replies,
+			"generator": genr.Health(),
+			"leader":    el.Status(),
 		}
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(resp)
-	})
-	// Legacy single-shot generate+post endpoint (kept for backward compatibility)
-	mux.HandleFunc("/api/tweet", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
+	}))
+	mux.HandleFunc("/api/usage", authz.Require(auth.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		var body struct {
-			Topics []string `json:"topics"`
-			Style  string   `json:"style"`
+		sum, err := acct.Summary(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to load usage"))
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			_, _ = w.Write([]byte("invalid json"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(sum)
+	}))
+	mux.HandleFunc("/api/audit", authz.Require(auth.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		if len(body.Topics) == 0 {
+		q, err := auditQuery(r)
+		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
-			_, _ = w.Write([]byte("topics required"))
+			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		if body.Style == "" {
-			body.Style = selector.RandomStyle()
+		if q.Limit == 0 {
+			q.Limit = 100
 		}
-		text, err := genr.ComposeTweet(r.Context(), strings.Join(body.Topics, ", "), body.Style)
+		entries, err := auditLog.Search(r.Context(), q)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
-			_, _ = w.Write([]byte("failed to compose tweet"))
+			_, _ = w.Write([]byte("failed to search audit log"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"entries": entries})
+	}))
+	mux.HandleFunc("/api/storage", authz.Require(auth.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		id, err := x.PostTweet(text)
+		prefixes, err := store.Usage(r.Context())
 		if err != nil {
-			w.WriteHeader(http.StatusBadGateway)
-			_, _ = w.Write([]byte("failed to post tweet"))
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to read storage usage"))
 			return
 		}
-		_ = store.AddPostedID(id)
+		lsm, vlog := store.Size()
+		rt := store.Retention()
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "text": text})
-	})
-	// New two-step compose flow: generate -> post
-	mux.HandleFunc("/api/generate", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"backend":    cfg.StorageBackend,
+			"lsm_bytes":  lsm,
+			"vlog_bytes": vlog,
+			"prefixes":   prefixes,
+			"retention": map[string]string{
+				"seen":   rt.Seen.String(),
+				"slots":  rt.Slots.String(),
+				"shadow": rt.Shadow.String(),
+			},
+			"last_gc": gc.snapshot(),
+		})
+	}))
+	mux.HandleFunc("/api/replies/candidates", authz.Require(auth.RoleViewer, candidatesHandler(store, live, loc, scan)))
+	mux.HandleFunc("/api/inbox", authz.Require(auth.RoleViewer, inboxHandler(store, live)))
+	// inboxMu serializes reviews so one mention can't be answered twice
+	var inboxMu sync.Mutex
+	mux.HandleFunc("/api/inbox/reply", authz.Require(auth.RolePublisher, leaderOnly(el, inboxReplyHandler(&inboxMu, pub, store, auditLog, met))))
+	mux.HandleFunc("/api/inbox/dismiss", authz.Require(auth.RoleEditor, leaderOnly(el, inboxDismissHandler(&inboxMu, store, auditLog))))
+	mux.HandleFunc("/api/shadow", authz.Require(auth.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		limit := 50
+		if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+			limit = v
+		}
+		since := time.Now().AddDate(0, 0, -7)
+		recs, err := recorder.Timeline(r.Context(), since, limit)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to read shadow timeline"))
+			return
+		}
+		published, err := liveHistory(r.Context(), auditLog, since, limit)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to read history"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"dry_run": live.Get().DryRunFor(cfg.Account),
+			"shadow":  recs,
+			"live":    published,
+		})
+	}))
+	mux.HandleFunc("/api/audit/export", authz.Require(auth.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		q, err := auditQuery(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		if err := auditLog.Export(r.Context(), w, q); err != nil {
+			log.Error().Err(err).Msg("audit export")
+		}
+	}))
+	// Legacy single-shot generate+post endpoint (kept for backward compatibility)
+	mux.HandleFunc("/api/tweet", authz.Require(auth.RolePublisher, leaderOnly(el, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
@@ -350,59 +1135,101 @@ func main() {
 		if body.Style == "" {
 			body.Style = selector.RandomStyle()
 		}
-		text, err := genr.ComposeTweet(r.Context(), strings.Join(body.Topics, ", "), body.Style)
+		actor := userActor(r)
+		cands, err := composeRanked(gen.WithPurpose(r.Context(), gen.PurposeManual), genr, ranker, strings.Join(body.Topics, ", "), body.Style, live.Get().CandidateCount)
+		auditLog.Record(r.Context(), generateEntry(audit.ActorUser, actor, body.Topics, body.Style, cands, err))
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("generation budget exceeded"))
+			return
+		}
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte("failed to compose tweet"))
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{"text": text})
-	})
-	mux.HandleFunc("/api/post", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
-		}
-		var body struct {
-			Text string `json:"text"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			_, _ = w.Write([]byte("invalid json"))
-			return
-		}
-		text := gen.CleanTweetText(strings.TrimSpace(body.Text))
-		if text == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			_, _ = w.Write([]byte("text required"))
+		best, ok := rank.Best(cands)
+		if !ok {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte("all drafts rejected by policy"))
 			return
 		}
-		id, err := x.PostTweet(text)
+		text := best.Text
+		id, err := pub.PostTweet(r.Context(), text)
+		auditLog.Record(r.Context(), postEntry(audit.ActorUser, actor, text, id, err))
+		countPost(met, metrics.JobDashboard, id, err)
 		if err != nil {
 			w.WriteHeader(http.StatusBadGateway)
 			_, _ = w.Write([]byte("failed to post tweet"))
 			return
 		}
-		_ = store.AddPostedID(id)
+		_ = savePost(r.Context(), store, storage.Post{ID: id, Text: text})
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{"id": id})
-	})
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "text": text, "dry_run": shadow.IsID(id)})
+	})))
+	// New two-step compose flow: generate -> post
+	mux.HandleFunc("/api/generate", authz.Require(auth.RoleEditor, leaderOnly(el, generateHandler(genr, ranker, auditLog, live))))
+	mux.HandleFunc("/api/post", authz.Require(auth.RolePublisher, leaderOnly(el, postHandler(pub, store, auditLog, met))))
+	mux.HandleFunc("/api/calendar", authz.Require(auth.RoleViewer, calendarHandler(store, live, loc)))
+	// calMu serializes calendar edits so two saves can't interleave
+	var calMu sync.Mutex
+	mux.HandleFunc("/api/calendar/save", authz.Require(auth.RolePublisher, leaderOnly(el, calendarSaveHandler(&calMu, store, auditLog, live, loc))))
+	mux.HandleFunc("/api/calendar/move", authz.Require(auth.RolePublisher, leaderOnly(el, calendarMoveHandler(&calMu, store, auditLog, live, loc))))
+	mux.HandleFunc("/api/calendar/delete", authz.Require(auth.RolePublisher, leaderOnly(el, calendarDeleteHandler(&calMu, store, auditLog))))
+	mux.HandleFunc("/api/library", authz.Require(auth.RoleViewer, libraryHandler(store, live)))
+	mux.HandleFunc("/api/library/add", authz.Require(auth.RolePublisher, leaderOnly(el, libraryAddHandler(store, auditLog))))
+	mux.HandleFunc("/api/library/remove", authz.Require(auth.RolePublisher, leaderOnly(el, libraryRemoveHandler(store, auditLog))))
+	mux.HandleFunc("/", authz.Require(auth.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		_, _ = fmt.Fprint(w, indexHTML)
-	})
+	}))
+	srv := &http.Server{
+		Addr: cfg.HTTPAddr,
+		Handler: otelhttp.NewHandler(mux, "dashboard", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		})),
+	}
 	go func() {
-		addr := ":8080"
-		log.Info().Str("addr", addr).Msg("starting frontend server")
-		if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Info().Str("addr", srv.Addr).Msg("starting frontend server")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Error().Err(err).Msg("http server stopped")
 		}
 	}()
 
-	// graceful shutdown
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	// settle writes cut off by the previous shutdown, or left by the previous
+	// leader, before posting again. A previous leader may still be finishing
+	// a write, so with replicas only stale intents are settled.
+	takeoverAge := time.Duration(0)
+	if cfg.LeaderLock != "none" {
+		takeoverAge = staleIntent
+	}
+	if el.IsLeader() {
+		runner.Go("reconcile", func(ctx context.Context) {
+			reconcile(ctx, log, x, store, auditLog, takeoverAge)
+		})
+	}
+	el.OnChange(func(leading bool) {
+		met.Leader(leading)
+		auditLog.Record(ctx, audit.Entry{
+			ActorKind: audit.ActorSystem,
+			Actor:     "leader",
+			Action:    audit.ActionLeader,
+			Inputs:    map[string]any{"replica": el.Status().ID},
+			Outputs:   map[string]any{"leader": leading},
+			OK:        true,
+		})
+		if !leading {
+			return
+		}
+		// take over the leader's stored plan
+		if err := day.roll(log, loc, live.Get()); err != nil {
+			log.Error().Err(err).Msg("schedule")
+		}
+		runner.Go("reconcile", func(ctx context.Context) {
+			reconcile(ctx, log, x, store, auditLog, takeoverAge)
+		})
+	})
+	runner.Go("leader", el.Run)
 
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
@@ -410,102 +1237,1070 @@ func main() {
 	replyTicker := time.NewTicker(cfg.ReplyScanInterval)
 	defer replyTicker.Stop()
 
+	mentionTicker := time.NewTicker(cfg.MentionsInterval)
+	defer mentionTicker.Stop()
+
+	// hot reload: SIGHUP or an edit to the config file
+	live.OnChange(func(old, cur *config.Config) {
+		selector.SetCatalog(cur.Topics, cur.Styles)
+		if cur.GeminiKey != old.GeminiKey {
+			if err := genr.Rotate(ctx, cur.GeminiKey); err != nil {
+				log.Error().Err(err).Msg("rotate gemini key")
+			} else {
+				log.Info().Msg("gemini key rotated")
+			}
+		}
+		if cur.XApiKey != old.XApiKey || cur.XApiSecret != old.XApiSecret || cur.XAccessToken != old.XAccessToken || cur.XAccessSecret != old.XAccessSecret {
+			x.SetCreds(xCreds(cur))
+			log.Info().Msg("x credentials rotated")
+		}
+		if retention(cur) != retention(old) {
+			store.SetRetention(retention(cur))
+		}
+		if cur.ReplyScanInterval != old.ReplyScanInterval {
+			replyTicker.Reset(cur.ReplyScanInterval)
+		}
+		if cur.MentionsInterval != old.MentionsInterval {
+			mentionTicker.Reset(cur.MentionsInterval)
+		}
+		if cur.PostsPerDay != old.PostsPerDay || cur.PostWindowStart != old.PostWindowStart || cur.PostWindowEnd != old.PostWindowEnd {
+			if err := day.replan(log, loc, cur); err != nil {
+				log.Error().Err(err).Msg("replan")
+			}
+		}
+	})
+	runner.Go("config-watch", func(ctx context.Context) {
+		live.Watch(ctx, 5*time.Second, func(applied, pending []string, err error) {
+			if err != nil {
+				log.Error().Err(err).Msg("config reload rejected")
+			} else if len(applied) > 0 || len(pending) > 0 {
+				log.Info().Strs("applied", applied).Strs("needs_restart", pending).Msg("config reloaded")
+			}
+			if err == nil && len(applied) == 0 {
+				return
+			}
+			auditLog.Record(ctx, withErr(audit.Entry{
+				ActorKind: audit.ActorSystem,
+				Actor:     "reload",
+				Action:    audit.ActionConfig,
+				Inputs:    live.Get().Summary(),
+				Outputs:   map[string]any{"applied": applied, "needs_restart": pending},
+			}, err))
+		})
+	})
+
+	if cfg.BackupDir != "" {
+		runner.Go("backups", func(ctx context.Context) {
+			t := time.NewTicker(cfg.BackupInterval)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					_ = backup(ctx, log, store, auditLog, "scheduler", cfg.BackupDir, cfg.BackupKeep)
+				}
+			}
+		})
+	}
+
+	runner.Go("metrics-refresh", func(ctx context.Context) {
+		t := time.NewTicker(cfg.StatsRefresh)
+		defer t.Stop()
+		for {
+			if el.IsLeader() {
+				start := time.Now()
+				n, err := refreshMetrics(ctx, store, x)
+				met.Job(metrics.JobMetrics, start, err)
+				if err != nil && ctx.Err() == nil {
+					log.Error().Err(err).Msg("refresh post metrics")
+				} else {
+					log.Debug().Int("posts", n).Msg("post metrics refreshed")
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	})
+
+	runner.Go("storage-gc", func(ctx context.Context) {
+		// a first pass dates keys migrated or restored without a TTL
+		if el.IsLeader() {
+			gc.run(ctx, log, store, float64(cfg.StorageGCRatio))
+		}
+		t := time.NewTicker(cfg.StorageGCInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if el.IsLeader() {
+					gc.run(ctx, log, store, float64(cfg.StorageGCRatio))
+				}
+			}
+		}
+	})
+
+	// a failed slot is retried with backoff and given up after a few tries,
+	// since each attempt spends a full round of drafts and judge calls
+	slotRetries := &scheduler.Retries{Base: slotRetryBase, Max: slotRetryMax, Attempts: slotAttempts}
+
 	for {
 		select {
-		case <-stop:
-			log.Info().Msg("shutting down")
-			return
+		case <-ctx.Done():
+			shutdown(log, srv, runner, store, auditLog, cfg.ShutdownTimeout)
+			// only now that in-flight posts are done may another replica lead
+			if err := el.Resign(context.Background()); err != nil {
+				log.Error().Err(err).Msg("release leader lock")
+			}
+			return nil
 
 		case <-ticker.C:
 			now := time.Now().In(loc)
+			if err := day.tick(log, loc, live.Get(), now); err != nil {
+				log.Error().Err(err).Msg("schedule")
+			}
+			leading := el.IsLeader()
+			if !leading {
+				// show the leader's plan, which it may have redrawn
+				if err := day.follow(ctx, loc); err != nil {
+					log.Error().Err(err).Msg("load plan")
+				}
+			}
+			pending, posted, failed := 0, 0, 0
+			slots := day.snapshot()
+			slotRetries.Forget(slots)
 			for _, s := range slots {
-				if now.After(s.Time) {
-					posted, _ := store.WasPosted(s.Key)
-					if posted {
-						continue
+				if !now.After(s.Time) {
+					pending++
+					continue
+				}
+				if done, _ := store.WasPosted(ctx, s.Key); done {
+					posted++
+					continue
+				}
+				if slotRetries.Failures(s.Key) > 0 {
+					failed++
+				} else {
+					pending++
+				}
+				if !leading || !slotRetries.Due(s.Key, now) {
+					continue
+				}
+				// generate & post; the runner skips slots already in flight
+				slot := s
+				runner.Go("post:"+slot.Key, func(ctx context.Context) {
+					start := time.Now()
+					err := doPost(ctx, log, genr, ranker, pub, store, auditLog, met, live.Get(), slot)
+					met.Job(metrics.JobScheduler, start, err)
+					if err != nil && ctx.Err() == nil {
+						met.SlotFailure()
+						if slotRetries.Failed(slot.Key, time.Now()) {
+							log.Error().Err(err).Str("slot", slot.Key).Int("attempts", slotAttempts).Msg("post failed; giving up on the slot")
+							return
+						}
+						log.Error().Err(err).Str("slot", slot.Key).Msg("post failed")
 					}
-					// generate & post
-					go func(slot scheduler.Slot) {
-						if err := doPost(ctx, log, genr, x, store, slot); err != nil {
-							log.Error().Err(err).Str("slot", slot.Key).Msg("post failed")
+				})
+			}
+			met.Slots(pending, posted, failed)
+			if leading {
+				drafts, err := dueDrafts(ctx, log, store, live.Get(), now)
+				if err != nil {
+					log.Error().Err(err).Msg("calendar")
+				}
+				for _, d := range drafts {
+					d := d
+					runner.Go("draft:"+d.ID, func(ctx context.Context) {
+						start := time.Now()
+						err := doDraft(ctx, log, pub, store, auditLog, met, d)
+						met.Job(metrics.JobCalendar, start, err)
+						if err != nil && ctx.Err() == nil {
+							log.Error().Err(err).Str("draft", d.ID).Msg("calendar post failed")
 						}
-					}(s)
+					})
 				}
+				runner.Go("reconcile", func(ctx context.Context) {
+					reconcile(ctx, log, x, store, auditLog, staleIntent)
+				})
 			}
 
 		case <-replyTicker.C:
-			go func() {
-				if err := doReplies(ctx, log, genr, x, store, cfg); err != nil {
+			if !el.IsLeader() {
+				continue
+			}
+			runner.Go("replies", func(ctx context.Context) {
+				start := time.Now()
+				err := doReplies(ctx, log, genr, x, pub, store, auditLog, met, live.Get(), loc, follows, scan)
+				met.Job(metrics.JobReplies, start, err)
+				if err != nil && ctx.Err() == nil {
+					met.Error(metrics.JobReplies, errType(err))
 					log.Error().Err(err).Msg("reply scan failed")
 				}
-			}()
+			})
+
+		case <-mentionTicker.C:
+			if !el.IsLeader() || !live.Get().MentionsEnabled {
+				continue
+			}
+			runner.Go("mentions", func(ctx context.Context) {
+				start := time.Now()
+				err := doMentions(ctx, log, genr, x, pub, store, auditLog, met, live.Get(), loc)
+				met.Job(metrics.JobMentions, start, err)
+				if err != nil && ctx.Err() == nil {
+					met.Error(metrics.JobMentions, errType(err))
+					log.Error().Err(err).Msg("mentions poll failed")
+				}
+			})
+		}
+	}
+}
+
+// gcRun is the outcome of the last retention sweep and value log GC.
+type gcRun struct {
+	mu sync.Mutex
+
+	At        time.Time
+	Expired   int
+	Dated     int
+	Rewritten int
+	Error     string
+}
+
+// run applies retention to keys without a TTL, then reclaims value log
+// space freed by expired and deleted keys.
+func (g *gcRun) run(ctx context.Context, log zerolog.Logger, store storage.Store, ratio float64) {
+	res, err := store.Sweep(ctx)
+	n := 0
+	if err == nil {
+		n, err = store.GC(ratio)
+	}
+	g.mu.Lock()
+	g.At, g.Expired, g.Dated, g.Rewritten, g.Error = time.Now(), res.Expired, res.Dated, n, ""
+	if err != nil {
+		g.Error = err.Error()
+	}
+	g.mu.Unlock()
+	if err != nil {
+		log.Error().Err(err).Msg("storage gc")
+		return
+	}
+	if res.Expired > 0 || res.Dated > 0 || n > 0 {
+		log.Info().Int("expired", res.Expired).Int("dated", res.Dated).Int("vlog_rewritten", n).Msg("storage gc")
+	}
+}
+
+func (g *gcRun) snapshot() map[string]any {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.At.IsZero() {
+		return nil
+	}
+	return map[string]any{"at": g.At, "expired": g.Expired, "dated": g.Dated, "rewritten": g.Rewritten, "error": g.Error}
+}
+
+// plan holds the day's post slots and the scheduler heartbeat. The main loop
+// writes it; health checks and handlers read it.
+type plan struct {
+	store storage.Store
+	// leading reports whether this replica may store the plans it draws;
+	// read-only replicas follow the leader's.
+	leading func() bool
+
+	mu       sync.Mutex
+	day      string
+	slots    []scheduler.Slot
+	lastTick time.Time
+}
+
+// roll loads today's plan, drawing and storing a fresh random one if none
+// was saved yet, so a restart keeps the same slots.
+func (p *plan) roll(log zerolog.Logger, loc *time.Location, cfg *config.Config) error {
+	day := time.Now().In(loc).Format("20060102")
+	slots, err := loadSlots(context.Background(), p.store, day)
+	if err != nil {
+		return err
+	}
+	if slots == nil {
+		if slots, err = scheduler.DailyRandomSlots(loc, cfg.PostsPerDay, cfg.PostWindowStart, cfg.PostWindowEnd); err != nil {
+			return err
+		}
+		if p.leading() {
+			if err := saveSlots(context.Background(), p.store, day, slots); err != nil {
+				return err
+			}
+		}
+	}
+	for _, s := range slots {
+		log.Info().Time("time", s.Time).Str("key", s.Key).Msg("post slot")
+	}
+	p.mu.Lock()
+	p.day = day
+	p.slots = slots
+	p.mu.Unlock()
+	return nil
+}
+
+// replan keeps the slots already due and redraws the rest of today under
+// cfg, so schedule edits apply without waiting for tomorrow.
+func (p *plan) replan(log zerolog.Logger, loc *time.Location, cfg *config.Config) error {
+	fresh, err := scheduler.DailyRandomSlots(loc, cfg.PostsPerDay, cfg.PostWindowStart, cfg.PostWindowEnd)
+	if err != nil {
+		return err
+	}
+	now := time.Now().In(loc)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var slots []scheduler.Slot
+	for _, s := range p.slots {
+		if !s.Time.After(now) {
+			slots = append(slots, s)
+		}
+	}
+	for _, s := range fresh {
+		if len(slots) >= cfg.PostsPerDay {
+			break
+		}
+		if s.Time.After(now) {
+			slots = append(slots, s)
+			log.Info().Time("time", s.Time).Str("key", s.Key).Msg("post slot")
+		}
+	}
+	p.slots = slots
+	if !p.leading() {
+		return nil
+	}
+	return saveSlots(context.Background(), p.store, p.day, slots)
+}
+
+// follow replaces today's plan with the stored one, if there is one.
+func (p *plan) follow(ctx context.Context, loc *time.Location) error {
+	day := time.Now().In(loc).Format("20060102")
+	slots, err := loadSlots(ctx, p.store, day)
+	if err != nil || slots == nil {
+		return err
+	}
+	p.mu.Lock()
+	p.day = day
+	p.slots = slots
+	p.mu.Unlock()
+	return nil
+}
+
+// loadSlots returns the stored plan for day (yyyymmdd), or nil if none.
+func loadSlots(ctx context.Context, store storage.Store, day string) ([]scheduler.Slot, error) {
+	p, err := store.LoadPlan(ctx, day)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	slots := make([]scheduler.Slot, len(p.Slots))
+	for i, s := range p.Slots {
+		slots[i] = scheduler.Slot{Time: s.At, Key: s.Key}
+	}
+	return slots, nil
+}
+
+func saveSlots(ctx context.Context, store storage.Store, day string, slots []scheduler.Slot) error {
+	p := storage.SlotPlan{Day: day}
+	for _, s := range slots {
+		p.Slots = append(p.Slots, storage.PlanSlot{Key: s.Key, At: s.Time})
+	}
+	return store.SavePlan(ctx, p)
+}
+
+// tick records a scheduler heartbeat and rolls the plan over at midnight.
+func (p *plan) tick(log zerolog.Logger, loc *time.Location, cfg *config.Config, now time.Time) error {
+	p.mu.Lock()
+	p.lastTick = now
+	stale := p.day != now.Format("20060102")
+	p.mu.Unlock()
+	if stale {
+		return p.roll(log, loc, cfg)
+	}
+	return nil
+}
+
+func (p *plan) snapshot() []scheduler.Slot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]scheduler.Slot(nil), p.slots...)
+}
+
+func (p *plan) checkTick(maxAge time.Duration) (string, error) {
+	p.mu.Lock()
+	last := p.lastTick
+	p.mu.Unlock()
+	if last.IsZero() {
+		return "waiting for first tick", nil
+	}
+	if age := time.Since(last); age > maxAge {
+		return "", fmt.Errorf("last scheduler tick %s ago", age.Round(time.Second))
+	}
+	return "last tick " + last.Format(time.RFC3339), nil
+}
+
+// checkNext passes when a slot is still ahead today, or when every slot of
+// the day has been posted and the plan is just waiting for midnight.
+func (p *plan) checkNext(ctx context.Context, store storage.Store, loc *time.Location) (string, error) {
+	now := time.Now().In(loc)
+	unposted := 0
+	for _, s := range p.snapshot() {
+		if s.Time.After(now) {
+			return "next slot " + s.Time.Format(time.RFC3339), nil
+		}
+		if done, _ := store.WasPosted(ctx, s.Key); !done {
+			unposted++
+		}
+	}
+	if unposted > 0 {
+		return "", fmt.Errorf("no future slot today and %d past slots unposted", unposted)
+	}
+	return "today's slots all posted; next plan at midnight", nil
+}
+
+// xCreds is the X credential set from cfg, as last resolved from the
+// secret providers.
+func xCreds(cfg *config.Config) xclient.Creds {
+	return xclient.Creds{
+		APIKey:       cfg.XApiKey,
+		APISecret:    cfg.XApiSecret,
+		AccessToken:  cfg.XAccessToken,
+		AccessSecret: cfg.XAccessSecret,
+	}
+}
+
+// buildAuth turns the AUTH_* settings into a dashboard auth manager.
+func buildAuth(cfg *config.Config, auditLog *audit.Log) (*auth.Manager, error) {
+	opts := auth.Options{
+		Disabled:     cfg.AuthDisabled,
+		Token:        cfg.AuthToken,
+		SessionTTL:   cfg.AuthSessionTTL,
+		CookieSecure: cfg.AuthCookieSecure,
+		OnEvent: func(r *http.Request, action, name, via string, err error) {
+			auditLog.Record(r.Context(), withErr(audit.Entry{
+				ActorKind: audit.ActorUser,
+				Actor:     name,
+				Action:    action,
+				Inputs:    map[string]any{"via": via, "remote": r.RemoteAddr},
+			}, err))
+		},
+	}
+	var err error
+	if opts.TokenRole, err = auth.ParseRole(cfg.AuthTokenRole); err != nil {
+		return nil, fmt.Errorf("AUTH_TOKEN_ROLE: %w", err)
+	}
+	if opts.Users, err = auth.ParseUsers(cfg.AuthUsers); err != nil {
+		return nil, fmt.Errorf("AUTH_USERS: %w", err)
+	}
+	if cfg.AuthUsersFile != "" {
+		fileUsers, err := auth.LoadUsers(cfg.AuthUsersFile)
+		if err != nil {
+			return nil, fmt.Errorf("AUTH_USERS_FILE: %w", err)
+		}
+		opts.Users = append(opts.Users, fileUsers...)
+	}
+	if cfg.OIDCIssuer != "" {
+		roles, err := auth.ParseRoleMap(cfg.OIDCRoles)
+		if err != nil {
+			return nil, fmt.Errorf("AUTH_OIDC_ROLES: %w", err)
+		}
+		o := &auth.OIDCOptions{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			RoleClaim:    cfg.OIDCRoleClaim,
+			Roles:        roles,
+			CookieSecure: cfg.AuthCookieSecure,
+		}
+		if cfg.OIDCDefaultRole != "" {
+			if o.DefaultRole, err = auth.ParseRole(cfg.OIDCDefaultRole); err != nil {
+				return nil, fmt.Errorf("AUTH_OIDC_DEFAULT_ROLE: %w", err)
+			}
 		}
+		opts.OIDC = o
 	}
+	return auth.New(opts)
 }
 
-func doPost(ctx context.Context, log zerolog.Logger, genr *gen.Generator, x *xclient.Client, store *storage.Store, slot scheduler.Slot) error {
+func doPost(ctx context.Context, log zerolog.Logger, genr *gen.Generator, ranker *rank.Ranker, pub shadow.Publisher, store storage.Store, auditLog *audit.Log, met *metrics.Metrics, cfg *config.Config, slot scheduler.Slot) (err error) {
+	ctx, span := tracer.Start(ctx, "doPost", trace.WithAttributes(attribute.String("slot", slot.Key)))
+	defer func() { endSpan(span, err) }()
+
+	if pending, err := store.HasIntent(ctx, "post", slot.Key); err != nil || pending {
+		// a previous attempt may have posted; wait for reconcile to decide
+		return err
+	}
+
 	topicSet := selector.RandomTopicSet()
 	style := selector.RandomStyle()
 
-	text, err := genr.ComposeTweet(ctx, strings.Join(topicSet, ", "), style)
-	if err != nil {
+	// some slots go to a rephrased evergreen tweet from the content library
+	var from string
+	orig, best := recycleSlot(ctx, log, genr, ranker, store, auditLog, met, cfg, slot)
+	cands := []rank.Candidate{best}
+	if orig != nil {
+		from, topicSet, style = orig.ID, orig.Topics, orig.Style
+	} else {
+		cands, err = composeRanked(gen.WithPurpose(ctx, gen.PurposePost), genr, ranker, strings.Join(topicSet, ", "), style, cfg.CandidateCount)
+		auditLog.Record(ctx, generateEntry(audit.ActorScheduler, slot.Key, topicSet, style, cands, err))
+		if err != nil {
+			met.Error(metrics.JobScheduler, errType(err))
+			return err
+		}
+		var ok bool
+		if best, ok = rank.Best(cands); !ok {
+			met.Error(metrics.JobScheduler, "policy")
+			return fmt.Errorf("all %d drafts rejected by policy", len(cands))
+		}
+	}
+
+	// From here on the write must not be abandoned half way: record the
+	// intent, and let a shutdown wait for the post rather than cancel it.
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := store.BeginIntent(ctx, storage.Intent{Kind: "post", Key: slot.Key, Text: best.Text, Started: time.Now(), Topics: topicSet, Style: style, RecycledFrom: from}); err != nil {
 		return err
 	}
+	pctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
 
-	id, err := x.PostTweet(text)
+	id, err := pub.PostTweet(shadow.WithSlot(pctx, slot.Key), best.Text)
+	auditLog.Record(pctx, postEntry(audit.ActorScheduler, slot.Key, best.Text, id, err))
+	countPost(met, metrics.JobScheduler, id, err)
+	switch {
+	case from != "" && err != nil:
+		met.Recycled("error")
+	case from != "":
+		met.Recycled("posted")
+	}
 	if err != nil {
+		if xclient.StatusCode(err) != 0 {
+			// X answered with an error, so nothing was posted
+			_ = store.ClearIntent(pctx, "post", slot.Key)
+		}
+		met.Error(metrics.JobScheduler, errType(err))
 		return err
 	}
 
-	log.Info().Str("id", id).Msg("posted tweet")
-	_ = store.AddPostedID(id)
-	return store.MarkPosted(slot.Key)
+	log.Info().Str("id", id).Float64("score", best.Score).Int("candidates", len(cands)).Str("recycled_from", from).Bool("dry_run", shadow.IsID(id)).Msg("posted tweet")
+	return savePost(pctx, store, storage.Post{ID: id, Text: best.Text, Topics: topicSet, Style: style, Slot: slot.Key, RecycledFrom: from})
+}
+
+var tracer = otel.Tracer("github.com/UjjavalParmar/twitter-automation/cmd/bot")
+
+const (
+	// writeTimeout bounds an X write that has been started; shutdown waits
+	// for it instead of cancelling.
+	writeTimeout = 90 * time.Second
+	// staleIntent is how old an intent must be before the periodic reconcile
+	// treats it as abandoned rather than still in flight.
+	staleIntent = 10 * time.Minute
+	// slotRetryBase, slotRetryMax and slotAttempts space out and bound the
+	// attempts at a slot whose post keeps failing.
+	slotRetryBase = 2 * time.Minute
+	slotRetryMax  = 30 * time.Minute
+	slotAttempts  = 5
+	// reconcileWindow is how many of the account's latest tweets reconcile
+	// looks through, the most X returns in one page.
+	reconcileWindow = 100
+)
+
+// leaderOnly refuses dashboard writes on a read-only replica.
+func leaderOnly(el *leader.Elector, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !el.IsLeader() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("read-only replica: use the leader's dashboard"))
+			return
+		}
+		h(w, r)
+	}
 }
 
-func doReplies(ctx context.Context, log zerolog.Logger, genr *gen.Generator, x *xclient.Client, store *storage.Store, cfg *config.Config) error {
-	ts, err := x.SearchDevOpsRecent(100)
+// backup writes an online backup to dir, prunes all but the newest keep and
+// audits the outcome.
+func backup(ctx context.Context, log zerolog.Logger, store storage.Store, auditLog *audit.Log, actor, dir string, keep int) error {
+	var (
+		path   string
+		m      storage.Manifest
+		pruned []string
+	)
+	b, err := storage.AsBackuper(store)
+	if err == nil {
+		path, m, err = b.BackupTo(ctx, dir)
+	}
+	if err == nil {
+		pruned, err = storage.PruneBackups(dir, keep)
+	}
+	auditLog.Record(ctx, withErr(audit.Entry{
+		ActorKind: audit.ActorSystem,
+		Actor:     actor,
+		Action:    audit.ActionBackup,
+		Inputs:    map[string]any{"dir": dir, "keep": keep},
+		Outputs:   map[string]any{"path": path, "keys": m.Keys, "bytes": m.Bytes, "pruned": len(pruned)},
+	}, err))
 	if err != nil {
+		log.Error().Err(err).Str("dir", dir).Msg("backup failed")
 		return err
 	}
+	log.Info().Str("path", path).Int("keys", m.Keys).Int64("bytes", m.Bytes).Int("pruned", len(pruned)).Msg("backup written")
+	return nil
+}
 
-	sort.Slice(ts, func(i, j int) bool {
-		a := ts[i].PublicMetrics.LikeCount + 2*ts[i].PublicMetrics.RetweetCount + ts[i].PublicMetrics.ReplyCount
-		b := ts[j].PublicMetrics.LikeCount + 2*ts[j].PublicMetrics.RetweetCount + ts[j].PublicMetrics.ReplyCount
-		return a > b
-	})
+// shutdown stops the HTTP server, waits for running jobs up to timeout and
+// flushes the store. Jobs that didn't finish leave their intents behind for
+// reconcile on the next start.
+func shutdown(log zerolog.Logger, srv *http.Server, runner *lifecycle.Runner, store storage.Store, auditLog *audit.Log, timeout time.Duration) {
+	log.Info().Strs("jobs", runner.Running()).Dur("timeout", timeout).Msg("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	count := 0
-	for _, t := range ts {
-		if count >= cfg.ReplyMaxPerScan {
-			break
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("http shutdown")
+	}
+	err := runner.Drain(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("drain")
+	}
+	auditLog.Record(context.Background(), withErr(audit.Entry{
+		ActorKind: audit.ActorSystem,
+		Actor:     "shutdown",
+		Action:    audit.ActionShutdown,
+		Outputs:   map[string]any{"cut_off": runner.Running()},
+	}, err))
+	if err := store.Sync(); err != nil {
+		log.Error().Err(err).Msg("store sync")
+	}
+	log.Info().Msg("shutdown complete")
+}
+
+// reconcile resolves intents older than minAge against the account's recent
+// tweets. A reply or quote is found by the tweet it references, a post by
+// its text as X renders it; either way the write went through and is
+// recorded. An intent is dropped, so the slot or tweet is retried, only when
+// the tweets fetched reach back past its start and none matches. Otherwise
+// the write can't be ruled out: the intent is flagged and audited once for
+// a human to settle, and it keeps holding back a retry. Intents are kept
+// while X can't be asked.
+func reconcile(ctx context.Context, log zerolog.Logger, x *xclient.Client, store storage.Store, auditLog *audit.Log, minAge time.Duration) {
+	intents, err := store.Intents(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("list intents")
+		return
+	}
+	var stale []storage.Intent
+	for _, in := range intents {
+		if time.Since(in.Started) >= minAge {
+			stale = append(stale, in)
 		}
-		if t.PublicMetrics.LikeCount < cfg.ReplyMinLikes || t.PublicMetrics.RetweetCount < cfg.ReplyMinRetweets {
-			continue
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	me, err := x.Me(ctx)
+	if err != nil {
+		log.Error().Err(err).Int("intents", len(stale)).Msg("reconcile: look up account")
+		return
+	}
+	recent, err := x.UserTweets(ctx, me.ID, reconcileWindow)
+	if err != nil {
+		log.Error().Err(err).Int("intents", len(stale)).Msg("reconcile: fetch recent tweets")
+		return
+	}
+	byText := map[string]string{}
+	byRef := map[string]string{}
+	for _, t := range recent {
+		if text := xclient.PlainText(t.Text); text != "" {
+			byText[text] = t.ID
 		}
-		seen, _ := store.IsSeen(t.ID)
-		if seen {
-			continue
+		for _, ref := range t.ReferencedTweets {
+			byRef[ref.Type+":"+ref.ID] = t.ID
 		}
+	}
+	// reaches tells whether recent goes back past t, so a write started at
+	// t would be among them; a short page is the whole timeline
+	reaches := func(t time.Time) bool {
+		return len(recent) < reconcileWindow || recent[len(recent)-1].CreatedAt.Before(t.Add(-time.Minute))
+	}
 
-		reply, err := genr.ComposeReply(ctx, t.Text, "author")
-		if err != nil {
-			log.Error().Err(err).Msg("gen reply")
+	for _, in := range stale {
+		var id string
+		var found bool
+		switch in.Kind {
+		case "reply":
+			id, found = byRef[xclient.RefRepliedTo+":"+in.Key]
+		case "quote":
+			id, found = byRef[xclient.RefQuoted+":"+in.Key]
+		}
+		if !found {
+			id, found = byText[xclient.PlainText(in.Text)]
+		}
+		unsettled := false
+		switch {
+		case found:
+			err = recordIntent(ctx, store, in, id)
+		case reaches(in.Started):
+			err = store.ClearIntent(ctx, in.Kind, in.Key)
+		case !in.Flagged.IsZero():
+			continue // already reported
+		default:
+			unsettled = true
+			in.Flagged = time.Now()
+			err = store.BeginIntent(ctx, in)
+		}
+		auditLog.Record(ctx, withErr(audit.Entry{
+			ActorKind: audit.ActorSystem,
+			Actor:     "reconcile",
+			Action:    audit.ActionRecover,
+			Inputs:    map[string]any{"kind": in.Kind, "key": in.Key, "text": in.Text, "started": in.Started},
+			Outputs:   map[string]any{"found": found, "id": id, "unsettled": unsettled},
+		}, err))
+		if unsettled {
+			log.Warn().Str("kind", in.Kind).Str("key", in.Key).Msg("interrupted write neither found nor ruled out; settle it with bot db intents")
 			continue
 		}
+		log.Info().Str("kind", in.Kind).Str("key", in.Key).Bool("found", found).Msg("reconciled interrupted write")
+	}
+}
+
+// recordIntent stores the write in describes as done under the tweet id,
+// which resolves the intent.
+func recordIntent(ctx context.Context, store storage.Store, in storage.Intent, id string) error {
+	switch in.Kind {
+	case "post":
+		return store.RecordPost(ctx, storage.Post{ID: id, Text: in.Text, Topics: in.Topics, Style: in.Style, Slot: in.Key, RecycledFrom: in.RecycledFrom})
+	case "reply":
+		return store.RecordReply(ctx, storage.Reply{ID: id, InReplyTo: in.Key, AuthorID: in.AuthorID, ConversationID: in.ConversationID, Text: in.Text})
+	case "quote":
+		return store.RecordEngagement(ctx, storage.Engagement{Kind: "quote", TweetID: in.Key, ResultID: id, AuthorID: in.AuthorID, ConversationID: in.ConversationID, Text: in.Text})
+	}
+	return fmt.Errorf("unknown intent kind %q", in.Kind)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// errType buckets an error for the bot_errors_total metric.
+func errType(err error) string {
+	switch {
+	case errors.Is(err, usage.ErrBudgetExceeded):
+		return "budget"
+	case errors.Is(err, gen.ErrCircuitOpen):
+		return "circuit_open"
+	case xclient.StatusCode(err) != 0:
+		return "x_api"
+	case gen.ErrorCode(err) != "":
+		return "gemini"
+	}
+	return "other"
+}
+
+// userActor names the dashboard user behind r for audit entries.
+func userActor(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p.Name
+	}
+	return "unknown"
+}
+
+// withErr marks e as succeeded or failed, pulling X and Gemini status codes
+// out of err where available.
+func withErr(e audit.Entry, err error) audit.Entry {
+	e.OK = err == nil
+	if err != nil {
+		e.Error = err.Error()
+		e.XStatus = xclient.StatusCode(err)
+		if e.XStatus == 0 {
+			e.GeminiCode = gen.ErrorCode(err)
+		}
+	}
+	return e
+}
+
+func generateEntry(kind, actor string, topics []string, style string, cands []rank.Candidate, err error) audit.Entry {
+	e := audit.Entry{
+		ActorKind: kind,
+		Actor:     actor,
+		Action:    audit.ActionGenerate,
+		Inputs:    map[string]any{"topics": topics, "style": style},
+	}
+	if err == nil {
+		e.Outputs = map[string]any{"candidates": cands}
+	}
+	return withErr(e, err)
+}
+
+// pollEntry audits a drafted poll.
+func pollEntry(actor string, topics []string, style string, poll gen.Poll, err error) audit.Entry {
+	e := audit.Entry{
+		ActorKind: audit.ActorUser,
+		Actor:     actor,
+		Action:    audit.ActionGenerate,
+		Inputs:    map[string]any{"topics": topics, "style": style, "mode": "poll"},
+	}
+	if err == nil {
+		e.Outputs = map[string]any{"question": poll.Question, "options": poll.Options, "duration": poll.Duration.String()}
+	}
+	return withErr(e, err)
+}
+
+// postInputs are the audit inputs of a post with opts.
+func postInputs(text string, opts xclient.TweetOptions) map[string]any {
+	in := map[string]any{"text": text}
+	if opts.Poll != nil {
+		in["poll"] = opts.Poll
+	}
+	if opts.ReplySettings != "" {
+		in["reply_settings"] = opts.ReplySettings
+	}
+	return in
+}
+
+func postEntry(kind, actor, text, id string, err error) audit.Entry {
+	e := audit.Entry{
+		ActorKind: kind,
+		Actor:     actor,
+		Action:    audit.ActionPost,
+		Inputs:    map[string]any{"text": text},
+	}
+	if err == nil {
+		e.Outputs = map[string]any{"tweet_id": id}
+		if shadow.IsID(id) {
+			e.Outputs["dry_run"] = true
+		}
+	}
+	return withErr(e, err)
+}
 
-		rid, err := x.Reply(t.ID, reply)
+// liveHistory rebuilds the published posts, replies, quotes, likes and
+// reposts since t from the audit log, newest first, for comparison with the
+// shadow timeline. Each action's search stops after limit entries.
+func liveHistory(ctx context.Context, auditLog *audit.Log, since time.Time, limit int) ([]shadow.Record, error) {
+	str := func(m map[string]any, k string) string { v, _ := m[k].(string); return v }
+	var out []shadow.Record
+	for _, action := range []string{audit.ActionPost, audit.ActionReply, audit.ActionQuote, audit.ActionLike, audit.ActionRepost} {
+		entries, err := auditLog.Search(ctx, audit.Query{Action: action, Since: since, Limit: limit})
 		if err != nil {
-			log.Error().Err(err).Str("tid", t.ID).Msg("reply failed")
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.OK || e.Outputs["dry_run"] == true {
+				continue
+			}
+			switch e.Action {
+			case audit.ActionPost:
+				rec := shadow.Record{ID: str(e.Outputs, "tweet_id"), At: e.At, Kind: "post", Text: str(e.Inputs, "text")}
+				if e.ActorKind == audit.ActorScheduler {
+					rec.Slot = e.Actor
+				}
+				out = append(out, rec)
+			case audit.ActionReply:
+				out = append(out, shadow.Record{ID: str(e.Outputs, "reply_id"), At: e.At, Kind: "reply",
+					InReplyTo: str(e.Inputs, "tweet_id"), Text: str(e.Outputs, "text")})
+			default:
+				out = append(out, shadow.Record{ID: str(e.Outputs, "id"), At: e.At, Kind: e.Action,
+					TweetID: str(e.Inputs, "tweet_id"), Text: str(e.Outputs, "text")})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.After(out[j].At) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// countPost records a post attempt, counting dry-run writes separately.
+func countPost(met *metrics.Metrics, job, id string, err error) {
+	if err == nil && shadow.IsID(id) {
+		met.Shadow(job, "post")
+		return
+	}
+	met.Post(job, err)
+}
+
+// savePost records a published post. A dry-run post only fills its slot, so
+// shadow IDs never reach the stats or metrics lookups.
+func savePost(ctx context.Context, store storage.Store, p storage.Post) error {
+	if !shadow.IsID(p.ID) {
+		return store.RecordPost(ctx, p)
+	}
+	if p.Slot == "" {
+		return nil
+	}
+	if err := store.MarkPosted(ctx, p.Slot, p.ID); err != nil {
+		return err
+	}
+	return store.ClearIntent(ctx, "post", p.Slot)
+}
+
+// saveReply records a reply; a dry-run reply only marks the tweet seen.
+func saveReply(ctx context.Context, store storage.Store, r storage.Reply) error {
+	if !shadow.IsID(r.ID) {
+		return store.RecordReply(ctx, r)
+	}
+	if err := store.MarkSeen(ctx, r.InReplyTo); err != nil {
+		return err
+	}
+	return store.ClearIntent(ctx, "reply", r.InReplyTo)
+}
+
+// saveEngagement records a quote, like or repost; a dry run, whose id is a
+// shadow ID, only marks the tweet seen.
+func saveEngagement(ctx context.Context, store storage.Store, id string, e storage.Engagement) error {
+	if !shadow.IsID(id) {
+		return store.RecordEngagement(ctx, e)
+	}
+	if err := store.MarkSeen(ctx, e.TweetID); err != nil {
+		return err
+	}
+	return store.ClearIntent(ctx, e.Kind, e.TweetID)
+}
+
+// auditMaxLimit caps the entries one audit search or export returns.
+const auditMaxLimit = 1000
+
+// auditQuery reads search filters from the query string. A limit above
+// auditMaxLimit is lowered to it.
+func auditQuery(r *http.Request) (audit.Query, error) {
+	v := r.URL.Query()
+	q := audit.Query{Text: v.Get("q"), Action: v.Get("action"), Actor: v.Get("actor")}
+	var err error
+	if s := v.Get("since"); s != "" {
+		if q.Since, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("since: want RFC3339")
+		}
+	}
+	if s := v.Get("until"); s != "" {
+		if q.Until, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("until: want RFC3339")
+		}
+	}
+	if s := v.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 {
+			return q, fmt.Errorf("limit: want a number from 1")
+		}
+		q.Limit = min(q.Limit, auditMaxLimit)
+	}
+	return q, nil
+}
+
+// statsRecent is how many of the newest posts the stats cover and the
+// metrics refresh measures.
+const statsRecent = 50
+
+// postStats counts posts and replies and sums engagement on the 50 most
+// recent posts from their last stored snapshot, which refreshMetrics keeps
+// current.
+func postStats(ctx context.Context, store storage.Store) map[string]any {
+	postedCount, _ := store.CountPosts(ctx)
+	replyCount, _ := store.CountReplies(ctx)
+	engaged, _ := engagementsSince(ctx, store, time.Time{})
+	posts, _ := store.RecentPosts(ctx, statsRecent)
+	likes := 0
+	replies := 0
+	var polls []map[string]any
+	for _, p := range posts {
+		if p.Metrics == nil {
 			continue
 		}
-		_ = store.SeenTweet(t.ID)
-		_ = store.AddReplyID(rid)
-		log.Info().Str("tid", t.ID).Str("rid", rid).Msg("replied")
-		count++
+		likes += p.Metrics.Likes
+		replies += p.Metrics.Replies
+		if p.Poll != nil && p.Metrics.PollVotes != nil {
+			polls = append(polls, map[string]any{"id": p.ID, "text": p.Text, "options": p.Poll.Options, "votes": p.Metrics.PollVotes, "status": p.Metrics.PollStatus})
+		}
+	}
+	return map[string]any{
+		"posted_count":  postedCount,
+		"reply_count":   replyCount,
+		"engagements":   engaged,
+		"likes_total":   likes,
+		"replies_total": replies,
+		"polls":         polls,
 	}
+}
 
-	if count > 0 {
-		log.Info().Int("replies", count).Msg("reply pass")
+// refreshMetrics saves an engagement snapshot of the newest posts and of
+// every content library original and recycled post, which the recycler
+// ranks by however old they are. It returns how many posts X reported on.
+func refreshMetrics(ctx context.Context, store storage.Store, x *xclient.Client) (int, error) {
+	posts, err := store.RecentPosts(ctx, statsRecent)
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	var ids []string
+	seen := map[string]bool{}
+	add := func(id string) {
+		if id != "" && !seen[id] && !shadow.IsID(id) {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, p := range posts {
+		add(p.ID)
+	}
+	err = store.ScanEvergreen(ctx, time.Time{}, time.Time{}, func(e storage.Evergreen) error {
+		add(e.PostID)
+		for _, r := range e.Recycles {
+			add(r.PostID)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	tweets, err := x.GetTweets(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	for _, t := range tweets {
+		m := storage.PostMetrics{
+			Likes:    t.PublicMetrics.LikeCount,
+			Replies:  t.PublicMetrics.ReplyCount,
+			Retweets: t.PublicMetrics.RetweetCount,
+			Quotes:   t.PublicMetrics.QuoteCount,
+			Updated:  now,
+		}
+		if t.Poll != nil {
+			_, m.PollVotes = pollResults(t.Poll)
+			m.PollStatus = t.Poll.VotingStatus
+		}
+		if err := store.SetPostMetrics(ctx, t.ID, m); err != nil {
+			return 0, err
+		}
+	}
+	return len(tweets), nil
+}
+
+// pollResults lists a poll's options and their votes in option order.
+func pollResults(p *xclient.Poll) (labels []string, votes []int) {
+	opts := append([]xclient.PollOption(nil), p.Options...)
+	sort.Slice(opts, func(i, j int) bool { return opts[i].Position < opts[j].Position })
+	for _, o := range opts {
+		labels = append(labels, o.Label)
+		votes = append(votes, o.Votes)
+	}
+	return labels, votes
+}
+
+// composeRanked drafts n candidates and returns them ranked best first.
+func composeRanked(ctx context.Context, genr *gen.Generator, ranker *rank.Ranker, topic, style string, n int) ([]rank.Candidate, error) {
+	drafts, err := genr.ComposeCandidates(ctx, topic, style, n)
+	if err != nil {
+		return nil, err
+	}
+	return ranker.Rank(ctx, drafts), nil
 }
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/audit"
+	"github.com/UjjavalParmar/twitter-automation/internal/config"
+	"github.com/UjjavalParmar/twitter-automation/internal/gen"
+	"github.com/UjjavalParmar/twitter-automation/internal/inbox"
+	"github.com/UjjavalParmar/twitter-automation/internal/metrics"
+	"github.com/UjjavalParmar/twitter-automation/internal/shadow"
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+	"github.com/UjjavalParmar/twitter-automation/internal/throttle"
+	"github.com/UjjavalParmar/twitter-automation/internal/xclient"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// mentionsCheckpoint names the stored since_id of the mentions poller.
+	mentionsCheckpoint = "mentions"
+	// mentionsMax bounds one poll, so a long outage doesn't turn into a
+	// burst of drafts.
+	mentionsMax = 200
+)
+
+// doMentions reads mentions of our account newer than the stored checkpoint,
+// classifies each and queues, answers or ignores it by the inbox policy.
+// The first poll only sets the checkpoint, so old mentions aren't answered.
+func doMentions(ctx context.Context, log zerolog.Logger, genr *gen.Generator, x *xclient.Client, pub shadow.Publisher, store storage.Store, auditLog *audit.Log, met *metrics.Metrics, cfg *config.Config, loc *time.Location) (err error) {
+	ctx, span := tracer.Start(ctx, "doMentions")
+	defer func() { endSpan(span, err) }()
+
+	policy, err := inbox.ParsePolicy(cfg.MentionsPolicy)
+	if err != nil {
+		return err
+	}
+	me, err := x.Me(ctx)
+	if err != nil {
+		return err
+	}
+	since, err := store.Checkpoint(ctx, mentionsCheckpoint)
+	if err != nil {
+		return err
+	}
+	if since == "" {
+		ts, err := x.Mentions(ctx, me.ID, "", 1)
+		if err != nil || len(ts) == 0 {
+			return err
+		}
+		log.Info().Str("since_id", ts[0].ID).Msg("mentions checkpoint set")
+		return store.SetCheckpoint(ctx, mentionsCheckpoint, ts[0].ID)
+	}
+	ts, err := x.Mentions(ctx, me.ID, since, mentionsMax)
+	if err != nil {
+		return err
+	}
+
+	// advance the checkpoint past every mention handled, even if a later
+	// one fails, so nothing is handled twice
+	newest := since
+	defer func() {
+		if newest != since {
+			if serr := store.SetCheckpoint(context.WithoutCancel(ctx), mentionsCheckpoint, newest); serr != nil && err == nil {
+				err = serr
+			}
+		}
+	}()
+
+	th := throttle.New(store, throttle.Rules{DailyCap: cfg.ReplyDailyCap, Deny: cfg.ReplyDeny}, loc)
+	counts := map[string]int{}
+	// X returns newest first; answer in the order they came in
+	for i := len(ts) - 1; i >= 0 && ctx.Err() == nil; i-- {
+		t := ts[i]
+		if t.AuthorID == me.ID {
+			newest = t.ID
+			continue
+		}
+		if old, err := store.GetMention(ctx, t.ID); err != nil {
+			return err
+		} else if old != nil {
+			newest = t.ID
+			continue
+		}
+		m := handleMention(ctx, log, genr, pub, store, auditLog, met, th, policy, t)
+		if err := store.SaveMention(ctx, m); err != nil {
+			return err
+		}
+		newest = t.ID
+		counts[m.Status]++
+	}
+	if len(counts) > 0 {
+		log.Info().Interface("mentions", counts).Msg("mentions pass")
+	}
+	return nil
+}
+
+// handleMention classifies t and applies policy, returning the mention to
+// store.
+func handleMention(ctx context.Context, log zerolog.Logger, genr *gen.Generator, pub shadow.Publisher, store storage.Store, auditLog *audit.Log, met *metrics.Metrics, th *throttle.Throttle, policy inbox.Policy, t xclient.Tweet) storage.Mention {
+	m := storage.Mention{
+		ID:             t.ID,
+		AuthorID:       t.AuthorID,
+		ConversationID: t.ConversationID,
+		Text:           t.Text,
+		At:             t.CreatedAt,
+	}
+	if t.Author != nil {
+		m.Author = t.Author.Username
+	}
+	if m.At.IsZero() {
+		m.At = time.Now()
+	}
+	gctx := gen.WithPurpose(ctx, gen.PurposeMention)
+	class, err := inbox.Classify(gctx, genr.ClassifyMention, t.Text)
+	if err != nil {
+		log.Warn().Err(err).Str("tid", t.ID).Msg("classify mention; using keywords")
+		m.Note = "classified by keywords"
+	}
+	m.Class = class
+	action := policy.Action(class)
+	met.Mention(class, action)
+	if action == inbox.Ignore {
+		m.Status = inbox.StatusIgnored
+		return m
+	}
+
+	author := "author"
+	if m.Author != "" {
+		author = "@" + m.Author
+	}
+	draft, err := genr.ComposeReply(gctx, t.Text, author)
+	if err != nil {
+		met.Error(metrics.JobMentions, errType(err))
+		log.Error().Err(err).Str("tid", t.ID).Msg("draft mention reply")
+		m.Status, m.Note = inbox.StatusFailed, "draft failed: "+err.Error()
+		return m
+	}
+	m.Status, m.Draft = inbox.StatusQueued, draft
+	if action != inbox.Auto {
+		return m
+	}
+
+	// a reply already sent or still in flight, say from before a crash,
+	// is not sent again
+	if seen, _ := store.IsSeen(ctx, t.ID); seen || inFlight(ctx, store, t.ID) {
+		m.Note = "not sent automatically: already answered or in flight"
+		return m
+	}
+	// automatic replies share the reply scanner's daily cap and deny list;
+	// anything they refuse waits for review instead
+	target := throttle.Target{AuthorID: m.AuthorID, Author: m.Author, ConversationID: m.ConversationID}
+	if err := th.Check(ctx, target); err != nil {
+		var refusal *throttle.Refusal
+		if errors.As(err, &refusal) {
+			met.Throttled(metrics.JobMentions, refusal.Rule)
+		}
+		m.Note = "not sent automatically: " + err.Error()
+		return m
+	}
+	// the mention is stored as queued before the reply goes out, so a poll
+	// after a crash finds it handled rather than answering it again
+	m.Note = "sending automatically"
+	if err := store.SaveMention(ctx, m); err != nil {
+		log.Error().Err(err).Str("tid", t.ID).Msg("save mention before reply")
+		m.Note = "not sent automatically: " + err.Error()
+		return m
+	}
+	entry := audit.Entry{
+		ActorKind: audit.ActorInbox,
+		Actor:     audit.ActorInbox,
+		Action:    audit.ActionReply,
+		Inputs: map[string]any{"tweet_id": t.ID, "author_id": m.AuthorID, "conversation_id": m.ConversationID,
+			"tweet_text": t.Text, "class": class},
+	}
+	rid, err := publishReply(ctx, pub, store, auditLog, met, metrics.JobMentions, entry,
+		storage.Reply{InReplyTo: t.ID, AuthorID: m.AuthorID, ConversationID: m.ConversationID, Text: draft})
+	if err != nil {
+		log.Error().Err(err).Str("tid", t.ID).Msg("mention reply failed")
+		m.Note = "automatic reply failed: " + err.Error()
+		return m
+	}
+	th.Sent(target)
+	m.Status, m.ReplyID, m.Note = inbox.StatusReplied, rid, ""
+	log.Info().Str("tid", t.ID).Str("rid", rid).Str("class", class).Msg("answered mention")
+	return m
+}
+
+// inboxHandler lists up to 200 mentions, optionally of one status, with the
+// count of each status and the inbox settings.
+func inboxHandler(store storage.Store, live *config.Live) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		status := r.URL.Query().Get("status")
+		counts := map[string]int{}
+		mentions := []storage.Mention{}
+		err := store.ScanMentions(r.Context(), time.Time{}, time.Time{}, func(m storage.Mention) error {
+			counts[m.Status]++
+			if (status == "" || m.Status == status) && len(mentions) < 200 {
+				mentions = append(mentions, m)
+			}
+			return nil
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to read inbox"))
+			return
+		}
+		c := live.Get()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"enabled":  c.MentionsEnabled,
+			"interval": c.MentionsInterval.String(),
+			"policy":   c.MentionsPolicy,
+			"counts":   counts,
+			"mentions": mentions,
+		})
+	}
+}
+
+// inboxReplyHandler posts a reviewed reply to a mention. mu is shared with
+// inboxDismissHandler so one mention can't be answered twice.
+func inboxReplyHandler(mu *sync.Mutex, pub shadow.Publisher, store storage.Store, auditLog *audit.Log, met *metrics.Metrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			ID   string `json:"id"`
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid json"))
+			return
+		}
+		text := gen.CleanTweetText(strings.TrimSpace(body.Text))
+		if text == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("text required"))
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		m, err := store.GetMention(r.Context(), body.ID)
+		if err != nil || m == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("no such mention"))
+			return
+		}
+		if pending, _ := store.HasIntent(r.Context(), "reply", m.ID); m.Status == inbox.StatusReplied || pending {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte("mention already answered"))
+			return
+		}
+		auditLog.Record(r.Context(), audit.Entry{
+			ActorKind: audit.ActorUser,
+			Actor:     userActor(r),
+			Action:    audit.ActionApprove,
+			Inputs:    map[string]any{"tweet_id": m.ID, "text": text, "draft": m.Draft},
+			OK:        true,
+		})
+		entry := audit.Entry{
+			ActorKind: audit.ActorUser,
+			Actor:     userActor(r),
+			Action:    audit.ActionReply,
+			Inputs: map[string]any{"tweet_id": m.ID, "author_id": m.AuthorID, "conversation_id": m.ConversationID,
+				"tweet_text": m.Text, "class": m.Class},
+		}
+		rid, err := publishReply(r.Context(), pub, store, auditLog, met, metrics.JobDashboard, entry,
+			storage.Reply{InReplyTo: m.ID, AuthorID: m.AuthorID, ConversationID: m.ConversationID, Text: text})
+		if err != nil {
+			m.Status, m.Note = inbox.StatusFailed, "reply failed: "+err.Error()
+			_ = store.SaveMention(context.WithoutCancel(r.Context()), *m)
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("failed to post reply"))
+			return
+		}
+		m.Status, m.Draft, m.ReplyID, m.Note, m.Updated = inbox.StatusReplied, text, rid, "", time.Time{}
+		_ = store.SaveMention(context.WithoutCancel(r.Context()), *m)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": rid, "dry_run": shadow.IsID(rid)})
+	}
+}
+
+// inboxDismissHandler closes a mention without answering it.
+func inboxDismissHandler(mu *sync.Mutex, store storage.Store, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid json"))
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		m, err := store.GetMention(r.Context(), body.ID)
+		if err != nil || m == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("no such mention"))
+			return
+		}
+		if m.Status == inbox.StatusReplied {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte("mention already answered"))
+			return
+		}
+		m.Status, m.Updated = inbox.StatusDismissed, time.Time{}
+		err = store.SaveMention(r.Context(), *m)
+		auditLog.Record(r.Context(), withErr(audit.Entry{
+			ActorKind: audit.ActorUser,
+			Actor:     userActor(r),
+			Action:    audit.ActionDismiss,
+			Inputs:    map[string]any{"tweet_id": m.ID, "class": m.Class, "draft": m.Draft},
+		}, err))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to save mention"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/audit"
+	"github.com/UjjavalParmar/twitter-automation/internal/config"
+	"github.com/UjjavalParmar/twitter-automation/internal/engage"
+	"github.com/UjjavalParmar/twitter-automation/internal/gen"
+	"github.com/UjjavalParmar/twitter-automation/internal/metrics"
+	"github.com/UjjavalParmar/twitter-automation/internal/replyscore"
+	"github.com/UjjavalParmar/twitter-automation/internal/selector"
+	"github.com/UjjavalParmar/twitter-automation/internal/shadow"
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+	"github.com/UjjavalParmar/twitter-automation/internal/throttle"
+	"github.com/UjjavalParmar/twitter-automation/internal/xclient"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// followTTL is how long the list of accounts we follow is trusted; it
+	// changes slowly and each page of it is a rate-limited call.
+	followTTL = 6 * time.Hour
+	// followRetry spaces attempts after the list failed to load.
+	followRetry = 10 * time.Minute
+	followMax   = 5000
+)
+
+// followCache holds the IDs of the accounts we follow, for the following
+// signal.
+type followCache struct {
+	x *xclient.Client
+
+	mu  sync.Mutex
+	ids map[string]bool
+	err error
+	at  time.Time
+}
+
+// get returns the cached IDs, refreshing them when stale. After a failed
+// refresh it keeps serving the last good list, if any.
+func (f *followCache) get(ctx context.Context) (map[string]bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ttl := followTTL
+	if f.err != nil {
+		ttl = followRetry
+	}
+	if !f.at.IsZero() && time.Since(f.at) < ttl {
+		return f.ids, f.err
+	}
+	f.at = time.Now()
+	me, err := f.x.Me(ctx)
+	var ids []string
+	if err == nil {
+		ids, err = f.x.Following(ctx, me.ID, followMax)
+	}
+	if f.err = err; err != nil {
+		if f.ids != nil {
+			return f.ids, nil
+		}
+		return nil, err
+	}
+	f.ids = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		f.ids[id] = true
+	}
+	return f.ids, nil
+}
+
+// scanCandidate is a scored tweet from a reply scan, the action chosen for
+// it and what became of it.
+type scanCandidate struct {
+	replyscore.Scored
+	Action   *engage.Decision `json:"action,omitempty"`
+	Outcome  string           `json:"outcome"`
+	ResultID string           `json:"result_id,omitempty"`
+}
+
+// replyScan is the outcome of the last reply scan, for the dashboard.
+type replyScan struct {
+	mu         sync.Mutex
+	at         time.Time
+	candidates []scanCandidate
+}
+
+func (s *replyScan) set(at time.Time, cs []scanCandidate) {
+	s.mu.Lock()
+	s.at, s.candidates = at, cs
+	s.mu.Unlock()
+}
+
+func (s *replyScan) snapshot() (time.Time, []scanCandidate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.at, s.candidates
+}
+
+// newReplyEngine builds the reply scorer from cfg's current weights. It
+// rejects tweets below every action's threshold.
+func newReplyEngine(cfg *config.Config, follows *followCache) *replyscore.Engine {
+	return replyscore.New(
+		replyscore.Limits{
+			MinLikes:    cfg.ReplyMinLikes,
+			MinRetweets: cfg.ReplyMinRetweets,
+			MaxAge:      cfg.ReplyMaxAge,
+			MinScore:    engagePolicy(cfg).Floor(),
+		},
+		replyscore.Weighted{Signal: replyscore.Freshness{HalfLife: cfg.ReplyHalfLife}, Weight: float64(cfg.ReplyWeightFreshness)},
+		replyscore.Weighted{Signal: replyscore.Velocity{Target: float64(cfg.ReplyVelocityTarget)}, Weight: float64(cfg.ReplyWeightVelocity)},
+		replyscore.Weighted{Signal: replyscore.Audience{Min: cfg.ReplyFollowersMin, Max: cfg.ReplyFollowersMax}, Weight: float64(cfg.ReplyWeightAudience)},
+		replyscore.Weighted{Signal: replyscore.Saturation{Limit: cfg.ReplySaturation}, Weight: float64(cfg.ReplyWeightSaturation)},
+		replyscore.Weighted{Signal: replyscore.Relevance{Keywords: topicKeywords}, Weight: float64(cfg.ReplyWeightRelevance)},
+		replyscore.Weighted{Signal: replyscore.Following{IDs: follows.get}, Weight: float64(cfg.ReplyWeightFollowing)},
+	)
+}
+
+// topicKeywords flattens the topic catalog for the relevance signal.
+func topicKeywords() []string {
+	var out []string
+	for _, g := range selector.AllTopics() {
+		out = append(out, g...)
+	}
+	return out
+}
+
+// replyTweet converts a search result for scoring.
+func replyTweet(t xclient.Tweet) replyscore.Tweet {
+	rt := replyscore.Tweet{
+		ID:             t.ID,
+		Text:           t.Text,
+		AuthorID:       t.AuthorID,
+		ConversationID: t.ConversationID,
+		Followers:      -1,
+		CreatedAt:      t.CreatedAt,
+		Likes:          t.PublicMetrics.LikeCount,
+		Retweets:       t.PublicMetrics.RetweetCount,
+		Replies:        t.PublicMetrics.ReplyCount,
+		Quotes:         t.PublicMetrics.QuoteCount,
+	}
+	if t.Author != nil {
+		rt.Author = t.Author.Username
+		rt.Followers = t.Author.PublicMetrics.FollowersCount
+	}
+	return rt
+}
+
+// replyRules are cfg's interaction limits.
+func replyRules(cfg *config.Config) throttle.Rules {
+	return throttle.Rules{
+		AuthorCooldown:       cfg.ReplyAuthorCooldown,
+		ConversationCooldown: cfg.ReplyConversationCooldown,
+		DailyCap:             cfg.ReplyDailyCap,
+		Allow:                cfg.ReplyAllow,
+		Deny:                 cfg.ReplyDeny,
+	}
+}
+
+// engagePolicy is cfg's threshold and daily cap for each action. The reply
+// cap is left to the throttle, which also counts the inbox's replies.
+func engagePolicy(cfg *config.Config) engage.Policy {
+	p := engage.Policy{engage.Reply: {MinScore: float64(cfg.ReplyMinScore)}}
+	for kind, r := range map[string]engage.Rule{
+		engage.Quote:  {MinScore: float64(cfg.EngageQuoteMinScore), DailyCap: cfg.EngageQuoteDailyCap},
+		engage.Repost: {MinScore: float64(cfg.EngageRepostMinScore), DailyCap: cfg.EngageRepostDailyCap},
+		engage.Like:   {MinScore: float64(cfg.EngageLikeMinScore), DailyCap: cfg.EngageLikeDailyCap},
+	} {
+		if r.MinScore > 0 {
+			p[kind] = r
+		}
+	}
+	return p
+}
+
+// engagementsSince counts quotes, likes and reposts made since t, by kind.
+func engagementsSince(ctx context.Context, store storage.Store, t time.Time) (map[string]int, error) {
+	n := map[string]int{}
+	err := store.ScanEngagements(ctx, t, time.Time{}, func(e storage.Engagement) error {
+		n[e.Kind]++
+		return nil
+	})
+	return n, err
+}
+
+// midnight is the start of t's day in loc.
+func midnight(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// interactionBlocks asks th which actions it rules out for t, and returns
+// the refusal behind them for the metrics. A deny or allow list refusal
+// rules out every action and is also returned as the error.
+// The daily cap only holds back replies; cooldowns hold back replies and
+// quotes, which both address the author in public.
+func interactionBlocks(ctx context.Context, th *throttle.Throttle, t throttle.Target) (map[string]string, *throttle.Refusal, error) {
+	err := th.Check(ctx, t)
+	if err == nil {
+		return nil, nil, nil
+	}
+	var refusal *throttle.Refusal
+	if !errors.As(err, &refusal) {
+		return nil, nil, err
+	}
+	switch refusal.Rule {
+	case "deny", "allow":
+		return nil, refusal, refusal
+	case "daily_cap":
+		blocked := map[string]string{engage.Reply: refusal.Reason}
+		if err := th.Cooldown(ctx, t); err != nil {
+			var cd *throttle.Refusal
+			if !errors.As(err, &cd) {
+				return nil, nil, err
+			}
+			blocked[engage.Quote] = cd.Reason
+		}
+		return blocked, refusal, nil
+	}
+	return map[string]string{engage.Reply: refusal.Reason, engage.Quote: refusal.Reason}, refusal, nil
+}
+
+// doReplies scores recent tweets and quotes, reposts, replies to or likes
+// the best few, as the score, daily caps and interaction limits allow,
+// recording the ranking and each decision in scan.
+func doReplies(ctx context.Context, log zerolog.Logger, genr *gen.Generator, x *xclient.Client, pub shadow.Publisher, store storage.Store, auditLog *audit.Log, met *metrics.Metrics, cfg *config.Config, loc *time.Location, follows *followCache, scan *replyScan) (err error) {
+	ctx, span := tracer.Start(ctx, "doReplies")
+	defer func() { endSpan(span, err) }()
+
+	ts, err := x.SearchDevOpsRecent(ctx, 100)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tweets := make([]replyscore.Tweet, len(ts))
+	for i, t := range ts {
+		tweets[i] = replyTweet(t)
+	}
+	ranked := newReplyEngine(cfg, follows).Rank(ctx, tweets, now)
+	results := make([]scanCandidate, len(ranked))
+	for i, s := range ranked {
+		results[i] = scanCandidate{Scored: s, Outcome: "not reached"}
+		if s.Rejected {
+			results[i].Outcome = "rejected"
+		}
+	}
+	defer scan.set(now, results)
+
+	th := throttle.New(store, replyRules(cfg), loc)
+	used, err := engagementsSince(ctx, store, midnight(now, loc))
+	if err != nil {
+		return err
+	}
+	dec := engage.New(engagePolicy(cfg), used)
+	counts := map[string]int{}
+	visible := 0 // replies, quotes and reposts, bounded by max_per_scan
+	for i, s := range ranked {
+		if ctx.Err() != nil || (visible >= cfg.ReplyMaxPerScan && dec.Left(engage.Like) == 0) {
+			break
+		}
+		if s.Rejected {
+			// rejected candidates sort last
+			break
+		}
+		res, t := &results[i], s.Tweet
+		seen, _ := store.IsSeen(ctx, t.ID)
+		if seen {
+			res.Outcome = "already handled"
+			continue
+		}
+		if inFlight(ctx, store, t.ID) {
+			res.Outcome = "in flight"
+			continue
+		}
+		// refuse before generating, so a throttled tweet costs no tokens
+		target := throttle.Target{AuthorID: t.AuthorID, Author: t.Author, ConversationID: t.ConversationID}
+		blocked, refusal, err := interactionBlocks(ctx, th, target)
+		if refusal != nil {
+			met.Throttled(metrics.JobReplies, refusal.Rule)
+		}
+		if err != nil {
+			if refusal == nil {
+				return err
+			}
+			res.Outcome = "throttled: " + refusal.Reason
+			continue
+		}
+		if visible >= cfg.ReplyMaxPerScan {
+			if blocked == nil {
+				blocked = map[string]string{}
+			}
+			for _, k := range []string{engage.Quote, engage.Repost, engage.Reply} {
+				if blocked[k] == "" {
+					blocked[k] = "scan limit reached"
+				}
+			}
+		}
+		d := dec.Decide(s.Score, blocked)
+		res.Action = &d
+		met.Decision(d.Kind)
+		if d.Kind == engage.Skip {
+			res.Outcome = "skipped"
+			continue
+		}
+
+		inputs := map[string]any{"tweet_id": t.ID, "author_id": t.AuthorID, "conversation_id": t.ConversationID,
+			"tweet_text": t.Text, "score": s.Score, "factors": s.Factors, "reason": d.Reason}
+		var id string
+		switch d.Kind {
+		case engage.Reply:
+			id, err = replyTo(ctx, genr, pub, store, auditLog, met, s, inputs)
+		case engage.Quote:
+			id, err = quote(ctx, genr, pub, store, auditLog, met, s, inputs)
+		default:
+			e := storage.Engagement{Kind: d.Kind, TweetID: t.ID, AuthorID: t.AuthorID, ConversationID: t.ConversationID, Score: s.Score}
+			id, err = publishEngagement(ctx, pub, store, auditLog, met, metrics.JobReplies, engageEntry(audit.ActorReplier, d.Kind, inputs), e)
+		}
+		if errors.Is(err, errIntent) {
+			return err
+		}
+		if err != nil {
+			res.Outcome = "failed: " + err.Error()
+			log.Error().Err(err).Str("tid", t.ID).Str("action", d.Kind).Msg("engagement failed")
+			continue
+		}
+		if d.Kind == engage.Reply {
+			th.Sent(target)
+		}
+		if d.Kind != engage.Like {
+			visible++
+		}
+		dec.Done(d.Kind)
+		counts[d.Kind]++
+		res.Outcome, res.ResultID = "done", id
+		if shadow.IsID(id) {
+			res.Outcome = "recorded (dry run)"
+		}
+		log.Info().Str("tid", t.ID).Str("id", id).Str("action", d.Kind).Float64("score", s.Score).Msg("engaged")
+	}
+
+	if len(counts) > 0 {
+		log.Info().Interface("actions", counts).Msg("reply pass")
+	}
+	return nil
+}
+
+// inFlight reports whether a reply or quote of id has a pending intent.
+func inFlight(ctx context.Context, store storage.Store, id string) bool {
+	for _, kind := range []string{"reply", "quote"} {
+		if pending, _ := store.HasIntent(ctx, kind, id); pending {
+			return true
+		}
+	}
+	return false
+}
+
+// engageEntry starts the audit entry of an action on a scanned tweet.
+func engageEntry(actor, kind string, inputs map[string]any) audit.Entry {
+	actions := map[string]string{engage.Reply: audit.ActionReply, engage.Quote: audit.ActionQuote,
+		engage.Like: audit.ActionLike, engage.Repost: audit.ActionRepost}
+	return audit.Entry{ActorKind: actor, Actor: actor, Action: actions[kind], Inputs: inputs}
+}
+
+// replyTo drafts and sends a reply to a scanned tweet.
+func replyTo(ctx context.Context, genr *gen.Generator, pub shadow.Publisher, store storage.Store, auditLog *audit.Log, met *metrics.Metrics, s replyscore.Scored, inputs map[string]any) (string, error) {
+	t := s.Tweet
+	entry := engageEntry(audit.ActorReplier, engage.Reply, inputs)
+	reply, err := genr.ComposeReply(gen.WithPurpose(ctx, gen.PurposeReply), t.Text, "author")
+	if err != nil {
+		auditLog.Record(ctx, withErr(entry, err))
+		met.Error(metrics.JobReplies, errType(err))
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return publishReply(ctx, pub, store, auditLog, met, metrics.JobReplies, entry,
+		storage.Reply{InReplyTo: t.ID, AuthorID: t.AuthorID, ConversationID: t.ConversationID, Text: reply})
+}
+
+// quote drafts commentary on a scanned tweet and quotes it.
+func quote(ctx context.Context, genr *gen.Generator, pub shadow.Publisher, store storage.Store, auditLog *audit.Log, met *metrics.Metrics, s replyscore.Scored, inputs map[string]any) (string, error) {
+	t := s.Tweet
+	entry := engageEntry(audit.ActorReplier, engage.Quote, inputs)
+	author := "author"
+	if t.Author != "" {
+		author = "@" + t.Author
+	}
+	text, err := genr.ComposeQuote(gen.WithPurpose(ctx, gen.PurposeReply), t.Text, author)
+	if err != nil {
+		auditLog.Record(ctx, withErr(entry, err))
+		met.Error(metrics.JobReplies, errType(err))
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return publishEngagement(ctx, pub, store, auditLog, met, metrics.JobReplies, entry,
+		storage.Engagement{Kind: engage.Quote, TweetID: t.ID, AuthorID: t.AuthorID, ConversationID: t.ConversationID, Text: text, Score: s.Score})
+}
+
+// publishEngagement quotes, likes or reposts e.TweetID, audits the outcome
+// in entry and stores the engagement. Quotes are written under an intent
+// like replies; likes and reposts are idempotent on X and need none. It
+// returns the quote's ID, the tweet acted on for likes and reposts, or a
+// shadow ID on dry runs.
+func publishEngagement(ctx context.Context, pub shadow.Publisher, store storage.Store, auditLog *audit.Log, met *metrics.Metrics, job string, entry audit.Entry, e storage.Engagement) (string, error) {
+	if e.Kind == engage.Quote {
+		if err := store.BeginIntent(ctx, storage.Intent{Kind: e.Kind, Key: e.TweetID, Text: e.Text, Started: time.Now(), AuthorID: e.AuthorID, ConversationID: e.ConversationID}); err != nil {
+			return "", fmt.Errorf("%w: %w", errIntent, err)
+		}
+	}
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+	var id string
+	var err error
+	switch e.Kind {
+	case engage.Quote:
+		id, err = pub.QuoteTweet(rctx, e.TweetID, e.Text)
+	case engage.Like:
+		id, err = pub.Like(rctx, e.TweetID)
+	case engage.Repost:
+		id, err = pub.Repost(rctx, e.TweetID)
+	default:
+		return "", fmt.Errorf("unknown engagement %q", e.Kind)
+	}
+	entry.Outputs = map[string]any{"id": id}
+	if e.Text != "" {
+		entry.Outputs["text"] = e.Text
+	}
+	if shadow.IsID(id) {
+		entry.Outputs["dry_run"] = true
+		met.Shadow(job, e.Kind)
+	} else {
+		met.Engagement(job, e.Kind, err)
+	}
+	auditLog.Record(rctx, withErr(entry, err))
+	if err != nil {
+		if e.Kind == engage.Quote && xclient.StatusCode(err) != 0 {
+			_ = store.ClearIntent(rctx, e.Kind, e.TweetID)
+		}
+		met.Error(job, errType(err))
+		return "", err
+	}
+	if e.Kind == engage.Quote {
+		e.ResultID = id
+	}
+	_ = saveEngagement(rctx, store, id, e)
+	return id, nil
+}
+
+// errIntent marks a reply that was never sent because its write intent
+// could not be stored.
+var errIntent = errors.New("record reply intent")
+
+// publishReply sends r.Text in reply to r.InReplyTo under a write intent,
+// audits the outcome in entry and stores the reply. It returns the reply's
+// ID, a shadow ID on dry runs.
+func publishReply(ctx context.Context, pub shadow.Publisher, store storage.Store, auditLog *audit.Log, met *metrics.Metrics, job string, entry audit.Entry, r storage.Reply) (string, error) {
+	if err := store.BeginIntent(ctx, storage.Intent{Kind: "reply", Key: r.InReplyTo, Text: r.Text, Started: time.Now(), AuthorID: r.AuthorID, ConversationID: r.ConversationID}); err != nil {
+		return "", fmt.Errorf("%w: %w", errIntent, err)
+	}
+	// once sent, the reply is recorded even if ctx ends meanwhile
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+	rid, err := pub.Reply(rctx, r.InReplyTo, r.Text)
+	entry.Outputs = map[string]any{"text": r.Text, "reply_id": rid}
+	if shadow.IsID(rid) {
+		entry.Outputs["dry_run"] = true
+		met.Shadow(job, "reply")
+	} else {
+		met.Reply(job, err)
+	}
+	auditLog.Record(rctx, withErr(entry, err))
+	if err != nil {
+		if xclient.StatusCode(err) != 0 {
+			_ = store.ClearIntent(rctx, "reply", r.InReplyTo)
+		}
+		met.Error(job, errType(err))
+		return "", err
+	}
+	r.ID = rid
+	_ = saveReply(rctx, store, r)
+	return rid, nil
+}
+
+// candidatesHandler shows the last reply scan's candidates with the reply
+// and engagement limits they were weighed against and what is left of today's
+// caps.
+func candidatesHandler(store storage.Store, live *config.Live, loc *time.Location, scan *replyScan) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		at, cands := scan.snapshot()
+		c := live.Get()
+		left, err := throttle.New(store, replyRules(c), loc).Remaining(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to count today's replies"))
+			return
+		}
+		used, err := engagementsSince(r.Context(), store, midnight(time.Now(), loc))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("failed to count today's engagements"))
+			return
+		}
+		policy := engagePolicy(c)
+		dec := engage.New(policy, used)
+		actions := map[string]any{}
+		for kind, rule := range policy {
+			if kind != engage.Reply {
+				actions[kind] = map[string]any{"min_score": rule.MinScore, "daily_cap": rule.DailyCap, "today": used[kind], "left": dec.Left(kind)}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"daily_cap":       c.ReplyDailyCap,
+			"today_remaining": left,
+			"cooldowns": map[string]string{
+				"author":       c.ReplyAuthorCooldown.String(),
+				"conversation": c.ReplyConversationCooldown.String(),
+			},
+			"at":           at,
+			"candidates":   cands,
+			"min_score":    c.ReplyMinScore,
+			"max_per_scan": c.ReplyMaxPerScan,
+			"actions":      actions,
+			"weights": map[string]float32{
+				"freshness":  c.ReplyWeightFreshness,
+				"velocity":   c.ReplyWeightVelocity,
+				"audience":   c.ReplyWeightAudience,
+				"saturation": c.ReplyWeightSaturation,
+				"relevance":  c.ReplyWeightRelevance,
+				"following":  c.ReplyWeightFollowing,
+			},
+		})
+	}
+}
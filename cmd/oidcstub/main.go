@@ -0,0 +1,48 @@
+// Command oidcstub serves a stand-in OpenID Connect provider for local runs
+// of the dashboard's single sign-on. Point the bot at it with:
+//
+//	AUTH_OIDC_ISSUER=http://127.0.0.1:9000 AUTH_OIDC_CLIENT_ID=bot \
+//	  AUTH_OIDC_CLIENT_SECRET=dev \
+//	  AUTH_OIDC_REDIRECT_URL=http://localhost:8080/auth/oidc/callback
+//
+// Users come from a JSON file of name to groups, or default to alice
+// (bot-publishers), bob (bot-editors) and carol (no groups).
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/auth"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9000", "listen address")
+	clientID := flag.String("client-id", "bot", "accepted client ID")
+	clientSecret := flag.String("client-secret", "dev", "accepted client secret")
+	seed := flag.String("seed", "", `JSON file of users, e.g. {"alice":["bot-publishers"]}`)
+	login := flag.String("login", "", "sign every request in as this user instead of asking")
+	flag.Parse()
+
+	users := map[string][]string{"alice": {"bot-publishers"}, "bob": {"bot-editors"}, "carol": nil}
+	if *seed != "" {
+		b, err := os.ReadFile(*seed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		users = map[string][]string{}
+		if err := json.Unmarshal(b, &users); err != nil {
+			log.Fatalf("seed: %v", err)
+		}
+	}
+	idp := auth.NewStubIdP(*clientID, *clientSecret)
+	idp.AutoLogin = *login
+	for name, groups := range users {
+		idp.AddUser(name, groups...)
+	}
+	log.Printf("oidc stub on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, idp))
+}
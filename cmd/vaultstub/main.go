@@ -0,0 +1,40 @@
+// Command vaultstub serves a tiny Vault-compatible KV store for local runs of
+// the bot with VAULT_ADDR. Seed it from a JSON file of name/value pairs, then
+// rotate with:
+//
+//	curl -X POST -H "X-Vault-Token: dev" -d '{"data":{"X_API_KEY":"..."}}' \
+//	  http://127.0.0.1:8200/v1/secret/data/twitter-automation
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/secrets"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8200", "listen address")
+	token := flag.String("token", "dev", "required X-Vault-Token")
+	path := flag.String("path", "secret/data/twitter-automation", "KV path to seed")
+	seed := flag.String("seed", "", "JSON file with initial secrets")
+	flag.Parse()
+
+	stub := secrets.NewStub(*token)
+	if *seed != "" {
+		b, err := os.ReadFile(*seed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		data := map[string]string{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			log.Fatalf("seed: %v", err)
+		}
+		stub.Put(*path, data)
+	}
+	log.Printf("vault stub on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, stub))
+}
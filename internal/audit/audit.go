@@ -0,0 +1,157 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+// Actions recorded in the log.
+const (
+	ActionGenerate  = "generate"
+	ActionApprove   = "approve"
+	ActionPost      = "post"
+	ActionReply     = "reply"
+	ActionConfig    = "config"
+	ActionLogin     = "login"
+	ActionLogout    = "logout"
+	ActionShutdown  = "shutdown"
+	ActionRecover   = "recover"
+	ActionBackup    = "backup"
+	ActionRestore   = "restore"
+	ActionLeader    = "leader"
+	ActionDismiss   = "dismiss"
+	ActionQuote     = "quote"
+	ActionLike      = "like"
+	ActionRepost    = "repost"
+	ActionSchedule  = "schedule" // a calendar draft saved or moved
+	ActionDelete    = "delete"
+	ActionEvergreen = "evergreen" // a tweet added to the content library
+	ActionRecycle   = "recycle"   // a library entry rephrased for a slot
+)
+
+// Actor kinds. Users carry their login name in Entry.Actor.
+const (
+	ActorScheduler = "scheduler"
+	ActorReplier   = "reply-scanner"
+	ActorInbox     = "inbox"
+	ActorUser      = "user"
+	ActorSystem    = "system"
+)
+
+// Entry is one audited action.
+type Entry struct {
+	ID         string         `json:"id"`
+	At         time.Time      `json:"at"`
+	ActorKind  string         `json:"actor_kind"`
+	Actor      string         `json:"actor"`
+	Action     string         `json:"action"`
+	Inputs     map[string]any `json:"inputs,omitempty"`
+	Outputs    map[string]any `json:"outputs,omitempty"`
+	OK         bool           `json:"ok"`
+	Error      string         `json:"error,omitempty"`
+	XStatus    int            `json:"x_status,omitempty"`
+	GeminiCode string         `json:"gemini_code,omitempty"`
+}
+
+// Log is an append-only audit trail in storage.
+type Log struct {
+	store storage.Store
+	log   zerolog.Logger
+}
+
+func New(store storage.Store, log zerolog.Logger) *Log {
+	return &Log{store: store, log: log}
+}
+
+// Record appends e, filling ID and time. Failures are logged, not returned:
+// an audit write should never abort the action it describes.
+func (l *Log) Record(ctx context.Context, e Entry) {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	if e.ID == "" {
+		b := make([]byte, 8)
+		_, _ = rand.Read(b)
+		e.ID = hex.EncodeToString(b)
+	}
+	v, err := json.Marshal(e)
+	if err == nil {
+		err = l.store.AppendAudit(ctx, e.At, v)
+	}
+	if err != nil {
+		l.log.Error().Err(err).Str("action", e.Action).Msg("audit write failed")
+	}
+}
+
+// Query filters a search. Zero values match everything.
+type Query struct {
+	Text   string // case-insensitive match against the whole entry
+	Action string
+	Actor  string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+func (q Query) match(e Entry, raw []byte) bool {
+	if q.Action != "" && e.Action != q.Action {
+		return false
+	}
+	if q.Actor != "" && e.Actor != q.Actor && e.ActorKind != q.Actor {
+		return false
+	}
+	if q.Text != "" && !strings.Contains(strings.ToLower(string(raw)), strings.ToLower(q.Text)) {
+		return false
+	}
+	return true
+}
+
+// Search returns matching entries, newest first.
+func (l *Log) Search(ctx context.Context, q Query) ([]Entry, error) {
+	var out []Entry
+	err := l.scan(ctx, q, func(e Entry, _ []byte) error {
+		out = append(out, e)
+		return nil
+	})
+	return out, err
+}
+
+// Export writes matching entries as JSON lines, newest first.
+func (l *Log) Export(ctx context.Context, w io.Writer, q Query) error {
+	return l.scan(ctx, q, func(_ Entry, raw []byte) error {
+		if _, err := w.Write(raw); err != nil {
+			return err
+		}
+		_, err := w.Write([]byte("\n"))
+		return err
+	})
+}
+
+func (l *Log) scan(ctx context.Context, q Query, fn func(Entry, []byte) error) error {
+	n := 0
+	return l.store.ScanAudit(ctx, q.Since, q.Until, func(v []byte) error {
+		var e Entry
+		if err := json.Unmarshal(v, &e); err != nil {
+			return nil // skip unreadable entries rather than hiding the rest
+		}
+		if !q.match(e, v) {
+			return nil
+		}
+		if err := fn(e, v); err != nil {
+			return err
+		}
+		n++
+		if q.Limit > 0 && n >= q.Limit {
+			return storage.ErrStop
+		}
+		return nil
+	})
+}
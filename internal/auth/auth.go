@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role orders what a dashboard user may do. Higher roles include lower ones.
+type Role int
+
+const (
+	RoleViewer    Role = iota + 1 // read stats and history
+	RoleEditor                    // generate drafts
+	RolePublisher                 // post to X
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleEditor:
+		return "editor"
+	case RolePublisher:
+		return "publisher"
+	}
+	return "none"
+}
+
+// ParseRole accepts viewer, editor or publisher.
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "viewer":
+		return RoleViewer, nil
+	case "editor":
+		return RoleEditor, nil
+	case "publisher":
+		return RolePublisher, nil
+	}
+	return 0, fmt.Errorf("unknown role %q", s)
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Name string `json:"name"`
+	Role Role   `json:"-"`
+	Via  string `json:"via"` // password, token, oidc or disabled
+}
+
+type principalKey struct{}
+
+// FromContext returns the principal set by Require, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// User is a local account with a bcrypt password hash.
+type User struct {
+	Name string
+	Role Role
+	Hash []byte
+}
+
+// ParseUsers parses "name:role:bcrypthash" entries separated by commas or
+// newlines. Blank lines and lines starting with # are ignored.
+func ParseUsers(s string) ([]User, error) {
+	var out []User
+	for _, line := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("user entry %q: want name:role:hash", line)
+		}
+		role, err := ParseRole(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", parts[0], err)
+		}
+		if _, err := bcrypt.Cost([]byte(parts[2])); err != nil {
+			return nil, fmt.Errorf("user %s: password is not a bcrypt hash", parts[0])
+		}
+		out = append(out, User{Name: parts[0], Role: role, Hash: []byte(parts[2])})
+	}
+	return out, nil
+}
+
+// LoadUsers reads ParseUsers entries from a file.
+func LoadUsers(path string) ([]User, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseUsers(string(b))
+}
+
+// HashPassword returns a bcrypt hash suitable for a user entry.
+func HashPassword(pw string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
+	return string(h), err
+}
+
+type Options struct {
+	// Disabled lets every request through as an anonymous publisher. It must
+	// be chosen explicitly; an unconfigured Manager rejects everything.
+	Disabled bool
+
+	Users []User
+	// Token is a shared secret accepted as a bearer token or at the login form.
+	Token     string
+	TokenRole Role
+
+	OIDC *OIDCOptions
+
+	SessionTTL   time.Duration
+	CookieSecure bool
+
+	// OnEvent, if set, is told about every login ("login") and logout
+	// ("logout") attempt. err is nil on success.
+	OnEvent func(r *http.Request, action, name, via string, err error)
+}
+
+type session struct {
+	principal Principal
+	csrf      string
+	expires   time.Time
+}
+
+// Manager authenticates dashboard requests and owns login sessions.
+type Manager struct {
+	opts  Options
+	users map[string]User
+	oidc  *oidcProvider
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+const (
+	sessionCookie   = "bot_session"
+	loginCSRFCookie = "bot_login_csrf"
+	csrfHeader      = "X-CSRF-Token"
+	csrfField       = "csrf_token"
+)
+
+func New(opts Options) (*Manager, error) {
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = 12 * time.Hour
+	}
+	if opts.Token != "" && opts.TokenRole == 0 {
+		opts.TokenRole = RolePublisher
+	}
+	if !opts.Disabled && len(opts.Users) == 0 && opts.Token == "" && opts.OIDC == nil {
+		return nil, fmt.Errorf("no login method configured: set users, a shared token or OIDC, or disable auth explicitly")
+	}
+	m := &Manager{opts: opts, users: map[string]User{}, sessions: map[string]*session{}}
+	for _, u := range opts.Users {
+		m.users[u.Name] = u
+	}
+	if opts.OIDC != nil {
+		m.oidc = newOIDCProvider(*opts.OIDC)
+	}
+	return m, nil
+}
+
+// Require wraps h so only principals with at least role reach it. Mutating
+// requests authenticated by session cookie must carry the session CSRF token.
+func (m *Manager) Require(role Role, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, sess := m.authenticate(r)
+		if p == nil {
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				http.Error(w, "authentication required", http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		if p.Role < role {
+			http.Error(w, "forbidden: requires "+role.String(), http.StatusForbidden)
+			return
+		}
+		if sess != nil && !safeMethod(r.Method) {
+			got := r.Header.Get(csrfHeader)
+			if got == "" {
+				got = r.FormValue(csrfField)
+			}
+			if subtle.ConstantTimeCompare([]byte(got), []byte(sess.csrf)) != 1 {
+				http.Error(w, "invalid csrf token", http.StatusForbidden)
+				return
+			}
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	}
+}
+
+// authenticate resolves the caller from a bearer token or session cookie.
+func (m *Manager) authenticate(r *http.Request) (*Principal, *session) {
+	if m.opts.Disabled {
+		return &Principal{Name: "anonymous", Role: RolePublisher, Via: "disabled"}, nil
+	}
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") && m.opts.Token != "" {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(h, "Bearer ")), []byte(m.opts.Token)) == 1 {
+			return &Principal{Name: "token", Role: m.opts.TokenRole, Via: "token"}, nil
+		}
+		return nil, nil
+	}
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[c.Value]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(s.expires) {
+		delete(m.sessions, c.Value)
+		return nil, nil
+	}
+	p := s.principal
+	return &p, s
+}
+
+func (m *Manager) startSession(w http.ResponseWriter, p Principal) {
+	id, csrf := randomToken(), randomToken()
+	m.mu.Lock()
+	now := time.Now()
+	for k, s := range m.sessions {
+		if now.After(s.expires) {
+			delete(m.sessions, k)
+		}
+	}
+	m.sessions[id] = &session{principal: p, csrf: csrf, expires: now.Add(m.opts.SessionTTL)}
+	m.mu.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		Expires:  now.Add(m.opts.SessionTTL),
+		HttpOnly: true,
+		Secure:   m.opts.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (m *Manager) endSession(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		m.mu.Lock()
+		delete(m.sessions, c.Value)
+		m.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, Secure: m.opts.CookieSecure, SameSite: http.SameSiteLaxMode})
+}
+
+// checkPassword verifies a local user or, with an empty user name, the
+// shared token.
+func (m *Manager) checkPassword(name, pw string) (*Principal, bool) {
+	if name == "" && m.opts.Token != "" {
+		if subtle.ConstantTimeCompare([]byte(pw), []byte(m.opts.Token)) == 1 {
+			return &Principal{Name: "token", Role: m.opts.TokenRole, Via: "token"}, true
+		}
+		return nil, false
+	}
+	u, ok := m.users[name]
+	if !ok {
+		// burn comparable time so unknown users aren't distinguishable
+		_ = bcrypt.CompareHashAndPassword([]byte("$2a$10$7EqJtq98hPqEX7fNZaFWoOhi5BWX4Z3ZbV1bXb0bCH5N6yCkHnFyK"), []byte(pw))
+		return nil, false
+	}
+	if bcrypt.CompareHashAndPassword(u.Hash, []byte(pw)) != nil {
+		return nil, false
+	}
+	return &Principal{Name: u.Name, Role: u.Role, Via: "password"}, true
+}
+
+func (m *Manager) event(r *http.Request, action, name, via string, err error) {
+	if m.opts.OnEvent != nil {
+		m.opts.OnEvent(r, action, name, via, err)
+	}
+}
+
+func safeMethod(m string) bool {
+	return m == http.MethodGet || m == http.MethodHead || m == http.MethodOptions
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
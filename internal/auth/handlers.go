@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+)
+
+const loginHTML = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8"/>
+<title>Sign in - Twitter Automation Dashboard</title>
+<style>
+body{font-family:Arial,sans-serif;margin:24px;}
+.card{border:1px solid #ddd;border-radius:8px;padding:16px;max-width:360px}
+label{display:block;margin:8px 0 4px}
+input{width:100%%;padding:6px;box-sizing:border-box}
+button{padding:8px 14px;margin-top:12px}
+.bad{color:#b00}
+</style>
+</head>
+<body>
+<h2>Twitter Automation Dashboard</h2>
+<div class="card">
+  <form method="post" action="/login">
+    %s
+    <input type="hidden" name="csrf_token" value="%s"/>
+    <label for="username">Username (leave empty to use the shared token)</label>
+    <input id="username" name="username" autocomplete="username"/>
+    <label for="password">Password or token</label>
+    <input id="password" name="password" type="password" autocomplete="current-password"/>
+    <button type="submit">Sign in</button>
+  </form>
+  %s
+</div>
+</body>
+</html>`
+
+// Routes registers the login, logout, session and OIDC endpoints.
+func (m *Manager) Routes(mux *http.ServeMux) {
+	mux.HandleFunc("/login", m.handleLogin)
+	mux.HandleFunc("/logout", m.Require(RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		p, _ := FromContext(r.Context())
+		m.endSession(w, r)
+		m.event(r, "logout", p.Name, p.Via, nil)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	}))
+	mux.HandleFunc("/api/me", m.Require(RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		p, _ := FromContext(r.Context())
+		_, sess := m.authenticate(r)
+		resp := map[string]any{"name": p.Name, "role": p.Role.String(), "via": p.Via}
+		if sess != nil {
+			resp["csrf_token"] = sess.csrf
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	if m.oidc != nil {
+		mux.HandleFunc("/auth/oidc/login", m.oidc.handleLogin)
+		mux.HandleFunc("/auth/oidc/callback", func(w http.ResponseWriter, r *http.Request) {
+			p, err := m.oidc.handleCallback(r)
+			m.oidc.clearState(w)
+			if err != nil {
+				m.event(r, "login", "", "oidc", err)
+				m.renderLogin(w, http.StatusUnauthorized, "Single sign-on failed: "+err.Error())
+				return
+			}
+			m.startSession(w, *p)
+			m.event(r, "login", p.Name, p.Via, nil)
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		})
+	}
+}
+
+func (m *Manager) handleLogin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m.renderLogin(w, http.StatusOK, "")
+	case http.MethodPost:
+		if m.opts.Disabled {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		// the form's token must match the cookie set with it, so another
+		// site can't sign the browser in to an account of its choosing
+		c, err := r.Cookie(loginCSRFCookie)
+		if err != nil || c.Value == "" || subtle.ConstantTimeCompare([]byte(r.FormValue(csrfField)), []byte(c.Value)) != 1 {
+			m.renderLogin(w, http.StatusForbidden, "The sign-in form expired; try again.")
+			return
+		}
+		name := strings.TrimSpace(r.FormValue("username"))
+		p, ok := m.checkPassword(name, r.FormValue("password"))
+		if !ok {
+			via := "password"
+			if name == "" {
+				via = "token"
+			}
+			m.event(r, "login", name, via, errors.New("invalid credentials"))
+			m.renderLogin(w, http.StatusUnauthorized, "Invalid credentials.")
+			return
+		}
+		m.startSession(w, *p)
+		m.event(r, "login", p.Name, p.Via, nil)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *Manager) renderLogin(w http.ResponseWriter, status int, msg string) {
+	var errHTML, ssoHTML string
+	if msg != "" {
+		errHTML = `<div class="bad">` + html.EscapeString(msg) + `</div>`
+	}
+	if m.oidc != nil {
+		ssoHTML = `<p><a href="/auth/oidc/login">Sign in with single sign-on</a></p>`
+	}
+	token := randomToken()
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCSRFCookie,
+		Value:    token,
+		Path:     "/login",
+		MaxAge:   3600,
+		HttpOnly: true,
+		Secure:   m.opts.CookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, loginHTML, errHTML, token, ssoHTML)
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// newTestServer serves m's routes and a root page that needs a viewer.
+func newTestServer(t *testing.T, m *Manager) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	m.Routes(mux)
+	mux.HandleFunc("/", m.Require(RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		p, _ := FromContext(r.Context())
+		_, _ = w.Write([]byte("hello " + p.Name))
+	}))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newClient returns a browser-like client with a cookie jar.
+func newClient(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Jar: jar}
+}
+
+// readBody reads and closes resp's body.
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+var reLoginToken = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+// loginToken loads the login form and returns its CSRF token.
+func loginToken(t *testing.T, c *http.Client, base string) string {
+	t.Helper()
+	resp, err := c.Get(base + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := reLoginToken.FindStringSubmatch(readBody(t, resp))
+	if m == nil {
+		t.Fatal("login form has no csrf token")
+	}
+	return m[1]
+}
+
+func TestPasswordLoginCSRF(t *testing.T) {
+	hash, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	m, err := New(Options{
+		Users: []User{{Name: "ana", Role: RoleEditor, Hash: []byte(hash)}},
+		OnEvent: func(_ *http.Request, action, name, _ string, err error) {
+			outcome := "ok"
+			if err != nil {
+				outcome = "failed"
+			}
+			events = append(events, action+" "+name+" "+outcome)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestServer(t, m)
+	form := url.Values{"username": {"ana"}, "password": {"s3cret"}}
+
+	// a cross-site form post carries neither the cookie nor a valid token
+	resp, err := newClient(t).PostForm(srv.URL+"/login", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("login without a token = %d, want 403", resp.StatusCode)
+	}
+
+	c := newClient(t)
+	loginToken(t, c, srv.URL)
+	form.Set("csrf_token", "forged")
+	if resp, err = c.PostForm(srv.URL+"/login", form); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("login with a wrong token = %d, want 403", resp.StatusCode)
+	}
+
+	// every rendered form comes with a fresh token
+	form.Set("csrf_token", loginToken(t, c, srv.URL))
+	form.Set("password", "wrong")
+	if resp, err = c.PostForm(srv.URL+"/login", form); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("login with a wrong password = %d, want 401", resp.StatusCode)
+	}
+
+	form.Set("csrf_token", loginToken(t, c, srv.URL))
+	form.Set("password", "s3cret")
+	if resp, err = c.PostForm(srv.URL+"/login", form); err != nil {
+		t.Fatal(err)
+	}
+	if body := readBody(t, resp); resp.StatusCode != http.StatusOK || body != "hello ana" {
+		t.Fatalf("login = %d %q, want the dashboard for ana", resp.StatusCode, body)
+	}
+	if want := []string{"login ana failed", "login ana ok"}; strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %q, want %q", events, want)
+	}
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// OIDCOptions configures the authorization-code login. Claims are read from
+// the userinfo endpoint, so ID token signatures are not needed.
+type OIDCOptions struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// RoleClaim names the userinfo claim holding group names (string or list).
+	RoleClaim string
+	// Roles maps claim values to dashboard roles; the highest match wins.
+	Roles map[string]Role
+	// DefaultRole applies when nothing matches; zero rejects the login.
+	DefaultRole  Role
+	CookieSecure bool
+}
+
+type discovery struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+type oidcProvider struct {
+	opts OIDCOptions
+
+	mu   sync.Mutex
+	conf *oauth2.Config
+	info string
+}
+
+const stateCookie = "bot_oidc_state"
+
+func newOIDCProvider(opts OIDCOptions) *oidcProvider {
+	if opts.RoleClaim == "" {
+		opts.RoleClaim = "groups"
+	}
+	return &oidcProvider{opts: opts}
+}
+
+// config fetches discovery lazily so an unreachable IdP doesn't block startup.
+func (o *oidcProvider) config(ctx context.Context) (*oauth2.Config, string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.conf != nil {
+		return o.conf, o.info, nil
+	}
+	url := strings.TrimSuffix(o.opts.Issuer, "/") + "/.well-known/openid-configuration"
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("oidc discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("oidc discovery: %s", resp.Status)
+	}
+	var d discovery
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, "", fmt.Errorf("oidc discovery: %w", err)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.UserinfoEndpoint == "" {
+		return nil, "", fmt.Errorf("oidc discovery: issuer is missing authorization, token or userinfo endpoint")
+	}
+	o.conf = &oauth2.Config{
+		ClientID:     o.opts.ClientID,
+		ClientSecret: o.opts.ClientSecret,
+		RedirectURL:  o.opts.RedirectURL,
+		Endpoint:     oauth2.Endpoint{AuthURL: d.AuthorizationEndpoint, TokenURL: d.TokenEndpoint},
+		Scopes:       []string{"openid", "profile", "email"},
+	}
+	o.info = d.UserinfoEndpoint
+	return o.conf, o.info, nil
+}
+
+func (o *oidcProvider) handleLogin(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := o.config(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	state := randomToken()
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state,
+		Path:     "/auth/oidc/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   o.opts.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, conf.AuthCodeURL(state), http.StatusFound)
+}
+
+// clearState expires the state cookie once a callback has used it, so it
+// can't be replayed.
+func (o *oidcProvider) clearState(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Value: "", Path: "/auth/oidc/", MaxAge: -1, HttpOnly: true, Secure: o.opts.CookieSecure, SameSite: http.SameSiteLaxMode})
+}
+
+func (o *oidcProvider) handleCallback(r *http.Request) (*Principal, error) {
+	c, err := r.Cookie(stateCookie)
+	if err != nil || c.Value == "" || c.Value != r.URL.Query().Get("state") {
+		return nil, fmt.Errorf("state mismatch")
+	}
+	if e := r.URL.Query().Get("error"); e != "" {
+		return nil, fmt.Errorf("%s", e)
+	}
+	conf, infoURL, err := o.config(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	tok, err := conf.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		return nil, fmt.Errorf("code exchange: %w", err)
+	}
+	resp, err := conf.Client(r.Context(), tok).Get(infoURL)
+	if err != nil {
+		return nil, fmt.Errorf("userinfo: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("userinfo: %s", resp.Status)
+	}
+	var claims map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("userinfo: %w", err)
+	}
+
+	name := firstString(claims, "preferred_username", "email", "sub")
+	if name == "" {
+		return nil, fmt.Errorf("userinfo has no subject")
+	}
+	role := o.opts.DefaultRole
+	for _, g := range claimValues(claims[o.opts.RoleClaim]) {
+		if r, ok := o.opts.Roles[g]; ok && r > role {
+			role = r
+		}
+	}
+	if role == 0 {
+		return nil, fmt.Errorf("%s has no dashboard role", name)
+	}
+	return &Principal{Name: name, Role: role, Via: "oidc"}, nil
+}
+
+func firstString(claims map[string]any, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := claims[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func claimValues(v any) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var out []string
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// ParseRoleMap parses "group=role,group=role" for OIDCOptions.Roles.
+func ParseRoleMap(s string) (map[string]Role, error) {
+	out := map[string]Role{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		g, r, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("role mapping %q: want group=role", part)
+		}
+		role, err := ParseRole(r)
+		if err != nil {
+			return nil, err
+		}
+		out[strings.TrimSpace(g)] = role
+	}
+	return out, nil
+}
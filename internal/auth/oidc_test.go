@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newOIDCServer runs the stub IdP and a dashboard that signs in through it.
+func newOIDCServer(t *testing.T, defaultRole Role) (*StubIdP, *httptest.Server) {
+	t.Helper()
+	idp := NewStubIdP("bot", "dev")
+	idp.AddUser("alice", "bot-publishers", "staff")
+	idp.AddUser("bob", "bot-editors")
+	idp.AddUser("carol")
+	idpSrv := httptest.NewServer(idp)
+	t.Cleanup(idpSrv.Close)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	m, err := New(Options{OIDC: &OIDCOptions{
+		Issuer:       idpSrv.URL,
+		ClientID:     "bot",
+		ClientSecret: "dev",
+		RedirectURL:  srv.URL + "/auth/oidc/callback",
+		Roles:        map[string]Role{"bot-publishers": RolePublisher, "bot-editors": RoleEditor},
+		DefaultRole:  defaultRole,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Routes(mux)
+	mux.HandleFunc("/", m.Require(RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		p, _ := FromContext(r.Context())
+		_, _ = w.Write([]byte("hello " + p.Name))
+	}))
+	return idp, srv
+}
+
+func TestOIDCLogin(t *testing.T) {
+	tests := []struct {
+		user        string
+		defaultRole Role
+		status      int
+		role        string // from /api/me after a successful login
+		body        string
+	}{
+		{user: "alice", status: http.StatusOK, role: "publisher", body: "hello alice"},
+		{user: "bob", status: http.StatusOK, role: "editor", body: "hello bob"},
+		{user: "carol", defaultRole: RoleViewer, status: http.StatusOK, role: "viewer", body: "hello carol"},
+		{user: "carol", status: http.StatusUnauthorized, body: "carol has no dashboard role"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			idp, srv := newOIDCServer(t, tt.defaultRole)
+			idp.AutoLogin = tt.user
+			c := newClient(t)
+
+			resp, err := c.Get(srv.URL + "/auth/oidc/login")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body := readBody(t, resp); resp.StatusCode != tt.status || !strings.Contains(body, tt.body) {
+				t.Fatalf("login = %d %q, want %d containing %q", resp.StatusCode, body, tt.status, tt.body)
+			}
+			u, _ := url.Parse(srv.URL + "/auth/oidc/callback")
+			for _, ck := range c.Jar.Cookies(u) {
+				if ck.Name == stateCookie {
+					t.Fatal("state cookie kept after the callback")
+				}
+			}
+			if tt.role == "" {
+				return
+			}
+
+			resp, err = c.Get(srv.URL + "/api/me")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var me struct{ Name, Role, Via, CSRFToken string }
+			if err := json.Unmarshal([]byte(readBody(t, resp)), &me); err != nil {
+				t.Fatal(err)
+			}
+			if me.Name != tt.user || me.Role != tt.role || me.Via != "oidc" {
+				t.Fatalf("/api/me = %+v, want %s as %s via oidc", me, tt.user, tt.role)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackState(t *testing.T) {
+	idp, srv := newOIDCServer(t, RoleViewer)
+	idp.AutoLogin = "alice"
+	c := newClient(t)
+	// stop at the IdP's redirect back, to replay and tamper with it
+	c.CheckRedirect = func(req *http.Request, _ []*http.Request) error {
+		if req.URL.Path == "/auth/oidc/callback" {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+	resp, err := c.Get(srv.URL + "/auth/oidc/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, resp)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || callback.Query().Get("code") == "" {
+		t.Fatalf("IdP redirected to %q, want the callback with a code", resp.Header.Get("Location"))
+	}
+
+	forged := *callback
+	q := forged.Query()
+	q.Set("state", "forged")
+	forged.RawQuery = q.Encode()
+	for i, u := range []string{forged.String(), callback.String()} {
+		resp, err := c.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the forged state fails, and so does the real one after it: the
+		// failed callback already expired the state cookie
+		if body := readBody(t, resp); resp.StatusCode != http.StatusUnauthorized || !strings.Contains(body, "state mismatch") {
+			t.Fatalf("callback %d = %d %q, want a state mismatch", i, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StubIdP is a minimal OpenID Connect provider for local runs and tests. It
+// serves discovery, an authorization endpoint that signs a known user in
+// without a password, the code exchange and userinfo with a groups claim.
+type StubIdP struct {
+	ClientID     string
+	ClientSecret string
+	// AutoLogin signs every authorization request in as this user; empty
+	// shows a page to pick one.
+	AutoLogin string
+
+	mu     sync.Mutex
+	users  map[string][]string // name to groups
+	codes  map[string]stubGrant
+	tokens map[string]string // access token to user
+}
+
+type stubGrant struct {
+	user     string
+	redirect string
+	expires  time.Time
+}
+
+func NewStubIdP(clientID, clientSecret string) *StubIdP {
+	return &StubIdP{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		users:        map[string][]string{},
+		codes:        map[string]stubGrant{},
+		tokens:       map[string]string{},
+	}
+}
+
+// AddUser adds or replaces a user and the groups userinfo reports for them.
+func (s *StubIdP) AddUser(name string, groups ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[name] = groups
+}
+
+func (s *StubIdP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		issuer := "http://" + r.Host
+		if r.TLS != nil {
+			issuer = "https://" + r.Host
+		}
+		stubJSON(w, http.StatusOK, map[string]any{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/authorize",
+			"token_endpoint":         issuer + "/token",
+			"userinfo_endpoint":      issuer + "/userinfo",
+		})
+	case "/authorize":
+		s.authorize(w, r)
+	case "/token":
+		s.token(w, r)
+	case "/userinfo":
+		s.userinfo(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *StubIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if q.Get("response_type") != "code" || q.Get("client_id") != s.ClientID || err != nil || redirect.Host == "" {
+		http.Error(w, "want response_type=code, this client's id and a redirect_uri", http.StatusBadRequest)
+		return
+	}
+	user := q.Get("user")
+	if user == "" {
+		user = s.AutoLogin
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user == "" {
+		names := make([]string, 0, len(s.users))
+		for name := range s.users {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<!doctype html><h2>Stub IdP: sign in as</h2><ul>")
+		for _, name := range names {
+			q.Set("user", name)
+			fmt.Fprintf(w, `<li><a href="/authorize?%s">%s</a> %s</li>`, html.EscapeString(q.Encode()), html.EscapeString(name), html.EscapeString(strings.Join(s.users[name], ", ")))
+		}
+		fmt.Fprint(w, "</ul>")
+		return
+	}
+	if _, ok := s.users[user]; !ok {
+		http.Error(w, "unknown user "+user, http.StatusBadRequest)
+		return
+	}
+	code := randomToken()
+	s.codes[code] = stubGrant{user: user, redirect: redirect.String(), expires: time.Now().Add(time.Minute)}
+	back := redirect.Query()
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *StubIdP) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		// RFC 6749 form-encodes the credentials before basic auth
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.FormValue("client_id"), r.FormValue("client_secret")
+	}
+	if id != s.ClientID || secret != s.ClientSecret {
+		stubJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.codes[r.FormValue("code")]
+	delete(s.codes, r.FormValue("code"))
+	if r.FormValue("grant_type") != "authorization_code" || !ok || time.Now().After(g.expires) || g.redirect != r.FormValue("redirect_uri") {
+		stubJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	tok := randomToken()
+	s.tokens[tok] = g.user
+	stubJSON(w, http.StatusOK, map[string]any{"access_token": tok, "token_type": "Bearer", "expires_in": 3600})
+}
+
+func (s *StubIdP) userinfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	user, ok := s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	groups := s.users[user]
+	s.mu.Unlock()
+	if !ok {
+		stubJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	if groups == nil {
+		groups = []string{}
+	}
+	stubJSON(w, http.StatusOK, map[string]any{"sub": user, "preferred_username": user, "groups": groups})
+}
+
+func stubJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,141 @@
+// Package calendar is the content calendar: hand-written tweets with a
+// target time, time zone and account, which the scheduler publishes next to
+// the generated slots. It checks drafts as they are planned and reports
+// when one lands close to a generated slot or another draft.
+package calendar
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Statuses of a stored draft.
+const (
+	StatusScheduled = "scheduled" // waiting for its time, or to retry a 429 or 5xx
+	StatusPosted    = "posted"    // published, or recorded in a dry run
+	StatusFailed    = "failed"    // X refused it; edit or reschedule to retry
+	StatusMissed    = "missed"    // the bot was down past the grace period
+)
+
+// LocalLayout is how target times are entered and shown: a wall-clock time
+// in the draft's zone.
+const LocalLayout = "2006-01-02T15:04"
+
+// MaxLength is the longest tweet text X accepts.
+const MaxLength = 280
+
+// slotPrefix marks the slot keys drafts post under, keeping them apart from
+// the generated yyyymmdd-HHMM keys.
+const slotPrefix = "draft-"
+
+// NewID returns a random draft ID.
+func NewID() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// SlotKey is the slot key draft id posts under, so publishing it goes
+// through the same intent, dedupe marker and reconcile as a generated slot.
+func SlotKey(id string) string { return slotPrefix + id }
+
+// Editable reports whether a draft with status may still be changed. A
+// posted draft is history.
+func Editable(status string) bool { return status != StatusPosted }
+
+// ParseLocal reads a wall-clock time in LocalLayout in the zone tz.
+func ParseLocal(local, tz string) (time.Time, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time zone %q: %w", tz, err)
+	}
+	t, err := time.ParseInLocation(LocalLayout, local, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time %q: want YYYY-MM-DDTHH:MM", local)
+	}
+	return t, nil
+}
+
+// Local formats at as a wall-clock time in tz, falling back to UTC for a
+// zone that no longer loads.
+func Local(at time.Time, tz string) string {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		loc = time.UTC
+	}
+	return at.In(loc).Format(LocalLayout)
+}
+
+// CheckText refuses text X wouldn't post.
+func CheckText(text string) error {
+	switch n := utf8.RuneCountInString(text); {
+	case strings.TrimSpace(text) == "":
+		return errors.New("text required")
+	case n > MaxLength:
+		return fmt.Errorf("text is %d characters, over %d", n, MaxLength)
+	}
+	return nil
+}
+
+// Due reports what to do with a scheduled draft at now: publish it once its
+// time has come, or give up on it as missed once it is more than grace
+// late, so a long outage doesn't post stale tweets. A zero grace never
+// gives up.
+func Due(at, now time.Time, grace time.Duration) (due, missed bool) {
+	if now.Before(at) {
+		return false, false
+	}
+	if grace > 0 && now.Sub(at) > grace {
+		return false, true
+	}
+	return true, false
+}
+
+// Kinds of calendar item.
+const (
+	KindSlot  = "slot"  // a generated slot from a day's plan
+	KindDraft = "draft" // a draft on the calendar
+)
+
+// Item is something that posts at a time: a generated slot or a draft.
+type Item struct {
+	Kind string
+	ID   string
+	At   time.Time
+}
+
+// Conflicts describes the items in others that post within window of at,
+// skipping self (a draft's own ID). An empty result means no conflict.
+func Conflicts(at time.Time, self string, others []Item, window time.Duration) []string {
+	if window <= 0 {
+		return nil
+	}
+	var out []string
+	for _, o := range others {
+		if o.Kind == KindDraft && o.ID == self {
+			continue
+		}
+		d := o.At.Sub(at)
+		if d < 0 {
+			d = -d
+		}
+		if d >= window {
+			continue
+		}
+		what := "generated slot " + o.ID
+		if o.Kind == KindDraft {
+			what = "draft " + o.ID
+		}
+		if d < time.Minute {
+			out = append(out, "same time as "+what)
+			continue
+		}
+		out = append(out, fmt.Sprintf("%d min from %s", int(d/time.Minute), what))
+	}
+	return out
+}
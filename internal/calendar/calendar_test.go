@@ -0,0 +1,103 @@
+package calendar
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDue(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		now         time.Time
+		grace       time.Duration
+		due, missed bool
+	}{
+		{"early", at.Add(-time.Second), time.Hour, false, false},
+		{"on time", at, time.Hour, true, false},
+		{"late within grace", at.Add(30 * time.Minute), time.Hour, true, false},
+		{"exactly grace late", at.Add(time.Hour), time.Hour, true, false},
+		{"past grace", at.Add(time.Hour + time.Second), time.Hour, false, true},
+		{"zero grace never gives up", at.Add(72 * time.Hour), 0, true, false},
+	}
+	for _, tt := range tests {
+		due, missed := Due(at, tt.now, tt.grace)
+		if due != tt.due || missed != tt.missed {
+			t.Errorf("%s: Due = %v, %v; want %v, %v", tt.name, due, missed, tt.due, tt.missed)
+		}
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	items := []Item{
+		{Kind: KindDraft, ID: "me", At: at},
+		{Kind: KindSlot, ID: "20240301-1200", At: at.Add(30 * time.Second)},
+		{Kind: KindDraft, ID: "d1", At: at.Add(-20 * time.Minute)},
+		{Kind: KindSlot, ID: "20240301-1230", At: at.Add(30 * time.Minute)},
+		{Kind: KindSlot, ID: "20240301-1300", At: at.Add(time.Hour)},
+	}
+	tests := []struct {
+		name   string
+		self   string
+		window time.Duration
+		want   []string
+	}{
+		{"skips itself", "me", 30 * time.Minute, []string{
+			"same time as generated slot 20240301-1200",
+			"20 min from draft d1",
+		}},
+		{"others at the same time", "new", 30 * time.Minute, []string{
+			"same time as draft me",
+			"same time as generated slot 20240301-1200",
+			"20 min from draft d1",
+		}},
+		{"a slot with the draft's ID is not itself", "20240301-1200", time.Minute, []string{
+			"same time as draft me",
+			"same time as generated slot 20240301-1200",
+		}},
+		{"window edge excluded", "me", 30*time.Minute + time.Nanosecond, []string{
+			"same time as generated slot 20240301-1200",
+			"20 min from draft d1",
+			"30 min from generated slot 20240301-1230",
+		}},
+		{"no window", "me", 0, nil},
+	}
+	for _, tt := range tests {
+		if got := Conflicts(at, tt.self, items, tt.window); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Conflicts = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseLocal(t *testing.T) {
+	tests := []struct {
+		local, tz string
+		want      time.Time
+		wantErr   bool
+	}{
+		{"2024-03-01T09:30", "UTC", time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC), false},
+		{"2024-03-01T09:30", "Asia/Kolkata", time.Date(2024, 3, 1, 4, 0, 0, 0, time.UTC), false},
+		// New York moves to daylight time on 2024-03-10 and back on 2024-11-03
+		{"2024-03-09T09:00", "America/New_York", time.Date(2024, 3, 9, 14, 0, 0, 0, time.UTC), false},
+		{"2024-03-11T09:00", "America/New_York", time.Date(2024, 3, 11, 13, 0, 0, 0, time.UTC), false},
+		{"2024-11-04T09:00", "America/New_York", time.Date(2024, 11, 4, 14, 0, 0, 0, time.UTC), false},
+		{"2024-03-31T09:00", "Europe/Berlin", time.Date(2024, 3, 31, 7, 0, 0, 0, time.UTC), false},
+		{"2024-03-01T09:30", "Mars/Olympus", time.Time{}, true},
+		{"2024-03-01 09:30", "UTC", time.Time{}, true},
+		{"2024-02-30T09:30", "UTC", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLocal(tt.local, tt.tz)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("ParseLocal(%q, %q) = %v, %v; want %v", tt.local, tt.tz, got, err, tt.want)
+			continue
+		}
+		if !tt.wantErr {
+			if back := Local(got, tt.tz); back != tt.local {
+				t.Errorf("Local(ParseLocal(%q, %q)) = %q", tt.local, tt.tz, back)
+			}
+		}
+	}
+}
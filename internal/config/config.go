@@ -1,105 +1,271 @@
 package config
 
 import (
-	"log"
-	"os"
-	"strconv"
 	"time"
-
-	"github.com/joho/godotenv"
 )
 
+// Config is the bot's settings. Each field is addressed by its `key` in the
+// config file and can be overridden by its `env` variable; `default` applies
+// when neither sets it. Durations given as bare numbers in env vars are read
+// in `unit`. Fields tagged `hot` are applied on reload without a restart.
 type Config struct {
-	GeminiKey   string
-	Model       string
-	MaxTokens   int
-	Temperature float32
-	TopP        float32
-
-	XApiKey       string
-	XApiSecret    string
-	XAccessToken  string
-	XAccessSecret string
-
-	TZ              string
-	PostsPerDay     int
-	PostWindowStart string
-	PostWindowEnd   string
-
-	ReplyScanInterval time.Duration
-	ReplyMinLikes     int
-	ReplyMinRetweets  int
-	ReplyMaxPerScan   int
-
-	Lang    string
-	DataDir string
-}
+	GeminiKey   string  `key:"gemini.api_key" env:"GEMINI_API_KEY" secret:"true" hot:"true"`
+	Model       string  `key:"gemini.model" env:"MODEL" default:"gemini-2.0-flash"`
+	MaxTokens   int     `key:"gemini.max_tokens" env:"MAX_TOKENS" default:"256"`
+	Temperature float32 `key:"gemini.temperature" env:"TEMPERATURE" default:"0.9"`
+	TopP        float32 `key:"gemini.top_p" env:"TOP_P" default:"0.9"`
 
-func mustInt(key string, def int) int {
-	if v := os.Getenv(key); v != "" {
-		i, err := strconv.Atoi(v)
-		if err != nil {
-			log.Fatalf("env %s invalid int: %v", key, err)
-		}
-		return i
-	}
-	return def
-}
+	GenFallbackModels   []string      `key:"gemini.fallback_models" env:"GEN_FALLBACK_MODELS" default:"gemini-2.0-flash-lite"`
+	GenTimeout          time.Duration `key:"gemini.timeout" env:"GEN_TIMEOUT_SEC" unit:"s" default:"30s"`
+	GenMaxRetries       int           `key:"gemini.max_retries" env:"GEN_MAX_RETRIES" default:"3"`
+	GenBreakerThreshold int           `key:"gemini.breaker_threshold" env:"GEN_BREAKER_THRESHOLD" default:"5"`
+	GenBreakerCooldown  time.Duration `key:"gemini.breaker_cooldown" env:"GEN_BREAKER_COOLDOWN_SEC" unit:"s" default:"2m"`
+	GenPrices           string        `key:"gemini.prices" env:"GEN_PRICES" default:"gemini-2.0-flash=0.10/0.40,gemini-2.0-flash-lite=0.075/0.30"`
+	GenBudgetDaily      float32       `key:"gemini.budget_daily_usd" env:"GEN_BUDGET_DAILY_USD" default:"0"`
+	GenBudgetMonthly    float32       `key:"gemini.budget_monthly_usd" env:"GEN_BUDGET_MONTHLY_USD" default:"0"`
 
-func mustFloat32(key string, def float32) float32 {
-	if v := os.Getenv(key); v != "" {
-		f, err := strconv.ParseFloat(v, 32)
-		if err != nil {
-			log.Fatalf("env %s invalid float: %v", key, err)
-		}
-		return float32(f)
-	}
-	return def
-}
+	XApiKey       string `key:"x.api_key" env:"X_API_KEY" secret:"true" hot:"true"`
+	XApiSecret    string `key:"x.api_secret" env:"X_API_SECRET" secret:"true" hot:"true"`
+	XAccessToken  string `key:"x.access_token" env:"X_ACCESS_TOKEN" secret:"true" hot:"true"`
+	XAccessSecret string `key:"x.access_secret" env:"X_ACCESS_SECRET" secret:"true" hot:"true"`
 
-func Load() *Config {
-	_ = godotenv.Load()
-
-	cfg := &Config{
-		GeminiKey:   os.Getenv("GEMINI_API_KEY"),
-		Model:       envOr("MODEL", "gemini-2.0-flash"),
-		MaxTokens:   mustInt("MAX_TOKENS", 256),
-		Temperature: mustFloat32("TEMPERATURE", 0.9),
-		TopP:        mustFloat32("TOP_P", 0.9),
-
-		XApiKey:       os.Getenv("X_API_KEY"),
-		XApiSecret:    os.Getenv("X_API_SECRET"),
-		XAccessToken:  os.Getenv("X_ACCESS_TOKEN"),
-		XAccessSecret: os.Getenv("X_ACCESS_SECRET"),
-
-		TZ:              envOr("TZ", "Asia/Kolkata"),
-		PostsPerDay:     mustInt("POSTS_PER_DAY", 5),
-		PostWindowStart: envOr("POST_WINDOW_START", "09:00"),
-		PostWindowEnd:   envOr("POST_WINDOW_END", "22:00"),
-
-		ReplyScanInterval: time.Duration(mustInt("REPLY_SCAN_INTERVAL_MIN", 60)) * time.Minute,
-		ReplyMinLikes:     mustInt("REPLY_MIN_LIKES", 50),
-		ReplyMinRetweets:  mustInt("REPLY_MIN_RETWEETS", 10),
-		ReplyMaxPerScan:   mustInt("REPLY_MAX_PER_SCAN", 3),
-
-		Lang:    envOr("LANG", "en"),
-		DataDir: envOr("DATA_DIR", "./data"),
-	}
+	TZ              string `key:"schedule.tz" env:"TZ" default:"Asia/Kolkata"`
+	PostsPerDay     int    `key:"schedule.posts_per_day" env:"POSTS_PER_DAY" default:"5" hot:"true"`
+	PostWindowStart string `key:"schedule.window_start" env:"POST_WINDOW_START" default:"09:00" hot:"true"`
+	PostWindowEnd   string `key:"schedule.window_end" env:"POST_WINDOW_END" default:"22:00" hot:"true"`
+
+	ReplyScanInterval time.Duration `key:"replies.scan_interval" env:"REPLY_SCAN_INTERVAL_MIN" unit:"m" default:"1h" hot:"true"`
+	ReplyMinLikes     int           `key:"replies.min_likes" env:"REPLY_MIN_LIKES" default:"50" hot:"true"`
+	ReplyMinRetweets  int           `key:"replies.min_retweets" env:"REPLY_MIN_RETWEETS" default:"10" hot:"true"`
+	ReplyMaxPerScan   int           `key:"replies.max_per_scan" env:"REPLY_MAX_PER_SCAN" default:"3" hot:"true"`
+
+	// Reply candidates passing the thresholds above are scored by weighted
+	// signals and answered best first while they score at least
+	// ReplyMinScore. A weight of 0 turns a signal off.
+	ReplyMaxAge           time.Duration `key:"replies.max_age" env:"REPLY_MAX_AGE_HOURS" unit:"h" default:"24h" hot:"true"`
+	ReplyMinScore         float32       `key:"replies.min_score" env:"REPLY_MIN_SCORE" default:"0.3" hot:"true"`
+	ReplyHalfLife         time.Duration `key:"replies.half_life" env:"REPLY_HALF_LIFE_HOURS" unit:"h" default:"6h" hot:"true"`
+	ReplyVelocityTarget   float32       `key:"replies.velocity_target" env:"REPLY_VELOCITY_TARGET" default:"50" hot:"true"`
+	ReplyFollowersMin     int           `key:"replies.followers_min" env:"REPLY_FOLLOWERS_MIN" default:"1000" hot:"true"`
+	ReplyFollowersMax     int           `key:"replies.followers_max" env:"REPLY_FOLLOWERS_MAX" default:"200000" hot:"true"`
+	ReplySaturation       int           `key:"replies.saturation" env:"REPLY_SATURATION" default:"50" hot:"true"`
+	ReplyWeightFreshness  float32       `key:"replies.weight_freshness" env:"REPLY_WEIGHT_FRESHNESS" default:"1" hot:"true"`
+	ReplyWeightVelocity   float32       `key:"replies.weight_velocity" env:"REPLY_WEIGHT_VELOCITY" default:"1" hot:"true"`
+	ReplyWeightAudience   float32       `key:"replies.weight_audience" env:"REPLY_WEIGHT_AUDIENCE" default:"1" hot:"true"`
+	ReplyWeightSaturation float32       `key:"replies.weight_saturation" env:"REPLY_WEIGHT_SATURATION" default:"1" hot:"true"`
+	ReplyWeightRelevance  float32       `key:"replies.weight_relevance" env:"REPLY_WEIGHT_RELEVANCE" default:"1.5" hot:"true"`
+	ReplyWeightFollowing  float32       `key:"replies.weight_following" env:"REPLY_WEIGHT_FOLLOWING" default:"0.5" hot:"true"`
+
+	// Interaction limits, checked before a reply is generated: one reply per
+	// author every ReplyAuthorCooldown and per conversation every
+	// ReplyConversationCooldown, and ReplyDailyCap replies a day across scans
+	// (0 turns each off). ReplyDeny authors are never answered; when
+	// ReplyAllow is set only its authors are. Both take IDs or @usernames.
+	ReplyAuthorCooldown       time.Duration `key:"replies.author_cooldown" env:"REPLY_AUTHOR_COOLDOWN_DAYS" unit:"d" default:"7d" hot:"true"`
+	ReplyConversationCooldown time.Duration `key:"replies.conversation_cooldown" env:"REPLY_CONVERSATION_COOLDOWN_DAYS" unit:"d" default:"30d" hot:"true"`
+	ReplyDailyCap             int           `key:"replies.daily_cap" env:"REPLY_DAILY_CAP" default:"10" hot:"true"`
+	ReplyAllow                []string      `key:"replies.allow" env:"REPLY_ALLOW" hot:"true"`
+	ReplyDeny                 []string      `key:"replies.deny" env:"REPLY_DENY" hot:"true"`
+
+	// Besides replying, the scanner can quote a candidate with commentary,
+	// repost it or like it. Each candidate gets the most visible action its
+	// score reaches whose daily cap isn't spent: quote, repost, reply (at
+	// replies.min_score), then like. A min score of 0 turns that action off
+	// and a cap of 0 lifts it. Likes don't count towards
+	// replies.max_per_scan.
+	EngageQuoteMinScore  float32 `key:"engage.quote_min_score" env:"ENGAGE_QUOTE_MIN_SCORE" default:"0.75" hot:"true"`
+	EngageQuoteDailyCap  int     `key:"engage.quote_daily_cap" env:"ENGAGE_QUOTE_DAILY_CAP" default:"3" hot:"true"`
+	EngageRepostMinScore float32 `key:"engage.repost_min_score" env:"ENGAGE_REPOST_MIN_SCORE" default:"0" hot:"true"`
+	EngageRepostDailyCap int     `key:"engage.repost_daily_cap" env:"ENGAGE_REPOST_DAILY_CAP" default:"2" hot:"true"`
+	EngageLikeMinScore   float32 `key:"engage.like_min_score" env:"ENGAGE_LIKE_MIN_SCORE" default:"0.2" hot:"true"`
+	EngageLikeDailyCap   int     `key:"engage.like_daily_cap" env:"ENGAGE_LIKE_DAILY_CAP" default:"30" hot:"true"`
+
+	// The mentions poller reads tweets mentioning our account every
+	// MentionsInterval. MentionsPolicy maps each class (question, praise,
+	// spam, hostile) to queue, auto or ignore; automatic replies count
+	// towards replies.daily_cap and skip replies.deny authors.
+	MentionsEnabled  bool          `key:"mentions.enabled" env:"MENTIONS_ENABLED" default:"true" hot:"true"`
+	MentionsInterval time.Duration `key:"mentions.interval" env:"MENTIONS_INTERVAL_MIN" unit:"m" default:"15m" hot:"true"`
+	MentionsPolicy   string        `key:"mentions.policy" env:"MENTIONS_POLICY" default:"question=queue,praise=queue,spam=ignore,hostile=ignore" hot:"true"`
+
+	// Content calendar drafts post at their own time next to the generated
+	// slots. A draft within CalendarConflictWindow of a slot or another
+	// draft is flagged; one more than CalendarGrace overdue, say after an
+	// outage, is marked missed rather than posted late (0 posts it anyway).
+	CalendarConflictWindow time.Duration `key:"calendar.conflict_window" env:"CALENDAR_CONFLICT_WINDOW_MIN" unit:"m" default:"30m" hot:"true"`
+	CalendarGrace          time.Duration `key:"calendar.grace" env:"CALENDAR_GRACE_HOURS" unit:"h" default:"6h" hot:"true"`
+
+	// The recycler offers about RecycleShare of the scheduled slots to the
+	// content library, which fills one with its best performing evergreen
+	// entry, rephrased; entries made from posts wait until the post is
+	// RecycleMinAge old. An entry comes back no sooner than RecycleCooldown
+	// after its last recycle and at most RecycleMaxTimes times, and at most
+	// RecycleDailyCap recycled posts go out a day (0 lifts either limit). A
+	// share of 0 turns recycling off.
+	RecycleShare    float32       `key:"recycle.share" env:"RECYCLE_SHARE" default:"0.25" hot:"true"`
+	RecycleMinAge   time.Duration `key:"recycle.min_age" env:"RECYCLE_MIN_AGE_DAYS" unit:"d" default:"90d" hot:"true"`
+	RecycleCooldown time.Duration `key:"recycle.cooldown" env:"RECYCLE_COOLDOWN_DAYS" unit:"d" default:"60d" hot:"true"`
+	RecycleMaxTimes int           `key:"recycle.max_times" env:"RECYCLE_MAX_TIMES" default:"3" hot:"true"`
+	RecycleDailyCap int           `key:"recycle.daily_cap" env:"RECYCLE_DAILY_CAP" default:"1" hot:"true"`
+	// StatsRefresh is how often the leader reads engagement on the newest
+	// posts and the library's originals back from X, for the dashboard's
+	// stats and the recycler's ranking.
+	StatsRefresh time.Duration `key:"stats.refresh" env:"STATS_REFRESH_MIN" unit:"m" default:"1h"`
+
+	CandidateCount  int      `key:"rank.candidates" env:"CANDIDATE_COUNT" default:"3" hot:"true"`
+	RankIdealLength int      `key:"rank.ideal_length" env:"RANK_IDEAL_LENGTH" default:"180"`
+	RankWeightLen   float32  `key:"rank.weight_length" env:"RANK_WEIGHT_LENGTH" default:"1"`
+	RankWeightHook  float32  `key:"rank.weight_hook" env:"RANK_WEIGHT_HOOK" default:"1"`
+	RankWeightNovel float32  `key:"rank.weight_novelty" env:"RANK_WEIGHT_NOVELTY" default:"1"`
+	BannedWords     []string `key:"policy.banned_words" env:"POLICY_BANNED_WORDS"`
+	MaxHashtags     int      `key:"policy.max_hashtags" env:"POLICY_MAX_HASHTAGS" default:"2"`
+
+	// Topics and Styles replace the built-in catalogs when set. In env vars
+	// topic groups are separated by "|" and styles by ";".
+	Topics [][]string `key:"catalog.topics" env:"CATALOG_TOPICS" hot:"true"`
+	Styles []string   `key:"catalog.styles" env:"CATALOG_STYLES" sep:";" hot:"true"`
+
+	// AuthCookieSecure marks login cookies HTTPS-only. The dashboard itself
+	// serves plain HTTP, so turn it on only behind a TLS-terminating proxy;
+	// otherwise browsers drop the session cookie and logins never stick.
+	AuthDisabled     bool          `key:"auth.disabled" env:"AUTH_DISABLED" default:"false"`
+	AuthUsers        string        `key:"auth.users" env:"AUTH_USERS" secret:"true"`
+	AuthUsersFile    string        `key:"auth.users_file" env:"AUTH_USERS_FILE"`
+	AuthToken        string        `key:"auth.token" env:"AUTH_TOKEN" secret:"true"`
+	AuthTokenRole    string        `key:"auth.token_role" env:"AUTH_TOKEN_ROLE" default:"publisher"`
+	AuthSessionTTL   time.Duration `key:"auth.session_ttl" env:"AUTH_SESSION_TTL_HOURS" unit:"h" default:"12h"`
+	AuthCookieSecure bool          `key:"auth.cookie_secure" env:"AUTH_COOKIE_SECURE" default:"false"`
+	OIDCIssuer       string        `key:"auth.oidc.issuer" env:"AUTH_OIDC_ISSUER"`
+	OIDCClientID     string        `key:"auth.oidc.client_id" env:"AUTH_OIDC_CLIENT_ID"`
+	OIDCClientSecret string        `key:"auth.oidc.client_secret" env:"AUTH_OIDC_CLIENT_SECRET" secret:"true"`
+	OIDCRedirectURL  string        `key:"auth.oidc.redirect_url" env:"AUTH_OIDC_REDIRECT_URL"`
+	OIDCRoleClaim    string        `key:"auth.oidc.role_claim" env:"AUTH_OIDC_ROLE_CLAIM" default:"groups"`
+	OIDCRoles        string        `key:"auth.oidc.roles" env:"AUTH_OIDC_ROLES"`
+	OIDCDefaultRole  string        `key:"auth.oidc.default_role" env:"AUTH_OIDC_DEFAULT_ROLE"`
+
+	HealthTickMaxAge  time.Duration `key:"health.tick_max_age" env:"HEALTH_TICK_MAX_AGE_SEC" unit:"s" default:"2m"`
+	HealthExternalTTL time.Duration `key:"health.external_ttl" env:"HEALTH_EXTERNAL_TTL_SEC" unit:"s" default:"5m"`
+
+	TraceExporter    string  `key:"tracing.exporter" env:"TRACING_EXPORTER" default:"none"`
+	TraceSampleRatio float32 `key:"tracing.sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1"`
+
+	// DryRun sends posts and replies to the shadow recorder instead of X, for
+	// every account or just those listed in DryRunAccounts.
+	DryRun         bool     `key:"dry_run.enabled" env:"DRY_RUN" default:"false" hot:"true"`
+	DryRunAccounts []string `key:"dry_run.accounts" env:"DRY_RUN_ACCOUNTS" hot:"true"`
+
+	// Secret fields are looked up by their env name in NAME, then the file
+	// named by NAME_FILE, then Vault when VaultAddr is set, before falling back
+	// to the config file. SecretsRefresh is how often they are re-read.
+	VaultAddr      string        `key:"secrets.vault.addr" env:"VAULT_ADDR"`
+	VaultToken     string        `key:"secrets.vault.token" env:"VAULT_TOKEN" secret:"true"`
+	VaultPath      string        `key:"secrets.vault.path" env:"VAULT_SECRET_PATH" default:"secret/data/twitter-automation"`
+	SecretsRefresh time.Duration `key:"secrets.refresh" env:"SECRETS_REFRESH_SEC" unit:"s" default:"1m"`
+
+	ShutdownTimeout time.Duration `key:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT_SEC" unit:"s" default:"60s"`
+
+	// Retention expires dedupe and dry-run keys; 0 keeps them forever. Posts,
+	// replies, usage and audit records are analytics and never expire.
+	RetentionSeen   time.Duration `key:"retention.seen" env:"RETENTION_SEEN_DAYS" unit:"d" default:"90d" hot:"true"`
+	RetentionSlots  time.Duration `key:"retention.slots" env:"RETENTION_SLOTS_DAYS" unit:"d" default:"30d" hot:"true"`
+	RetentionShadow time.Duration `key:"retention.shadow" env:"RETENTION_SHADOW_DAYS" unit:"d" default:"30d" hot:"true"`
+	// StorageBackend picks the database: badger (default) or sqlite. Move
+	// data between them with db export/import --format jsonl.
+	StorageBackend    string        `key:"storage.backend" env:"STORAGE_BACKEND" default:"badger"`
+	StorageGCInterval time.Duration `key:"storage.gc_interval" env:"STORAGE_GC_INTERVAL_MIN" unit:"m" default:"15m"`
+	StorageGCRatio    float32       `key:"storage.gc_discard_ratio" env:"STORAGE_GC_DISCARD_RATIO" default:"0.5"`
+
+	// BackupDir enables online backups every BackupInterval, keeping the
+	// newest BackupKeep.
+	BackupDir      string        `key:"backup.dir" env:"BACKUP_DIR"`
+	BackupInterval time.Duration `key:"backup.interval" env:"BACKUP_INTERVAL_HOURS" unit:"h" default:"24h"`
+	BackupKeep     int           `key:"backup.keep" env:"BACKUP_KEEP" default:"7"`
+
+	// HTTPAddr is where the dashboard, metrics and health endpoints listen;
+	// replicas on one host need one each.
+	HTTPAddr string `key:"http.addr" env:"HTTP_ADDR" default:":8080"`
+
+	// LeaderLock elects the one replica that posts and replies: none for a
+	// single replica, file for an flock on LeaderFile (replicas on one host)
+	// or http for a lease on the lock service at LeaderURL. Other replicas
+	// serve the dashboard read-only, so all must share one SQLite store.
+	LeaderLock string        `key:"leader.lock" env:"LEADER_LOCK" default:"none"`
+	LeaderFile string        `key:"leader.file" env:"LEADER_FILE"`
+	LeaderURL  string        `key:"leader.url" env:"LEADER_URL"`
+	LeaderTTL  time.Duration `key:"leader.ttl" env:"LEADER_TTL_SEC" unit:"s" default:"15s"`
+	ReplicaID  string        `key:"leader.id" env:"REPLICA_ID"`
+
+	Account string `key:"account" env:"ACCOUNT" default:"default"`
+	Lang    string `key:"lang" env:"LANG" default:"en"`
+	DataDir string `key:"data_dir" env:"DATA_DIR" default:"./data"`
+
+	// File and Profile record where the settings came from.
+	File    string
+	Profile string
+}
 
-	if cfg.GeminiKey == "" {
-		log.Fatal("GEMINI_API_KEY required")
+// DryRunFor reports whether account runs in dry-run mode.
+func (c *Config) DryRunFor(account string) bool {
+	if c.DryRun {
+		return true
 	}
-	for _, k := range []string{"X_API_KEY", "X_API_SECRET", "X_ACCESS_TOKEN", "X_ACCESS_SECRET"} {
-		if os.Getenv(k) == "" {
-			log.Fatalf("%s required", k)
+	for _, a := range c.DryRunAccounts {
+		if a == account {
+			return true
 		}
 	}
-	return cfg
+	return false
 }
 
-func envOr(k, d string) string {
-	if v := os.Getenv(k); v != "" {
-		return v
+// Summary returns the non-secret settings, for audit and diagnostics.
+func (c *Config) Summary() map[string]any {
+	return map[string]any{
+		"config_file":         c.File,
+		"profile":             c.Profile,
+		"model":               c.Model,
+		"fallback_models":     c.GenFallbackModels,
+		"max_tokens":          c.MaxTokens,
+		"temperature":         c.Temperature,
+		"top_p":               c.TopP,
+		"tz":                  c.TZ,
+		"posts_per_day":       c.PostsPerDay,
+		"post_window":         c.PostWindowStart + "-" + c.PostWindowEnd,
+		"reply_scan_interval": c.ReplyScanInterval.String(),
+		"reply_min_likes":     c.ReplyMinLikes,
+		"reply_min_retweets":  c.ReplyMinRetweets,
+		"reply_max_per_scan":  c.ReplyMaxPerScan,
+		"reply_min_score":     c.ReplyMinScore,
+		"reply_max_age":       c.ReplyMaxAge.String(),
+		"reply_daily_cap":     c.ReplyDailyCap,
+		"reply_cooldowns":     map[string]string{"author": c.ReplyAuthorCooldown.String(), "conversation": c.ReplyConversationCooldown.String()},
+		"engage": map[string]any{
+			"quote":  map[string]any{"min_score": c.EngageQuoteMinScore, "daily_cap": c.EngageQuoteDailyCap},
+			"repost": map[string]any{"min_score": c.EngageRepostMinScore, "daily_cap": c.EngageRepostDailyCap},
+			"like":   map[string]any{"min_score": c.EngageLikeMinScore, "daily_cap": c.EngageLikeDailyCap},
+		},
+		"mentions_enabled":  c.MentionsEnabled,
+		"mentions_interval": c.MentionsInterval.String(),
+		"mentions_policy":   c.MentionsPolicy,
+		"calendar":          map[string]string{"conflict_window": c.CalendarConflictWindow.String(), "grace": c.CalendarGrace.String()},
+		"recycle": map[string]any{
+			"share": c.RecycleShare, "min_age": c.RecycleMinAge.String(), "cooldown": c.RecycleCooldown.String(),
+			"max_times": c.RecycleMaxTimes, "daily_cap": c.RecycleDailyCap,
+		},
+		"stats_refresh":      c.StatsRefresh.String(),
+		"candidate_count":    c.CandidateCount,
+		"budget_daily_usd":   c.GenBudgetDaily,
+		"budget_monthly_usd": c.GenBudgetMonthly,
+		"auth_disabled":      c.AuthDisabled,
+		"oidc_enabled":       c.OIDCIssuer != "",
+		"account":            c.Account,
+		"dry_run":            c.DryRunFor(c.Account),
+		"tracing_exporter":   c.TraceExporter,
+		"vault_enabled":      c.VaultAddr != "",
+		"lang":               c.Lang,
+		"data_dir":           c.DataDir,
+		"storage_backend":    c.StorageBackend,
+		"backup_dir":         c.BackupDir,
+		"leader_lock":        c.LeaderLock,
+		"retention":          map[string]string{"seen": c.RetentionSeen.String(), "slots": c.RetentionSlots.String(), "shadow": c.RetentionShadow.String()},
 	}
-	return d
 }
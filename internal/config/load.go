@@ -0,0 +1,349 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/UjjavalParmar/twitter-automation/internal/secrets"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Errors is every problem found while loading, so a bad config is fixed in
+// one pass instead of one restart per mistake.
+type Errors []string
+
+func (e Errors) Error() string {
+	return "invalid config:\n  - " + strings.Join(e, "\n  - ")
+}
+
+// Load builds the config from, in increasing priority: field defaults, the
+// YAML or TOML file (CONFIG_FILE when file is empty), the named profile
+// inside it (CONFIG_PROFILE when profile is empty) and environment variables,
+// including any found in .env. String secrets may be written as
+// "file:/path" to read the value from a file. All problems are returned
+// together as Errors.
+func Load(file, profile string) (*Config, error) {
+	_ = godotenv.Load() // optional; containers usually inject env directly
+
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+	if profile == "" {
+		profile = os.Getenv("CONFIG_PROFILE")
+	}
+
+	var errs Errors
+	vals := map[string]any{}
+	switch {
+	case file != "":
+		raw, err := readFile(file)
+		if err != nil {
+			return nil, Errors{err.Error()}
+		}
+		profiles, _ := raw["profiles"].(map[string]any)
+		delete(raw, "profiles")
+		if profile != "" {
+			p, ok := profiles[profile].(map[string]any)
+			if !ok {
+				errs = append(errs, fmt.Sprintf("profile %q is not defined in %s", profile, file))
+			}
+			merge(raw, p)
+		}
+		flatten(raw, "", vals, &errs)
+	case profile != "":
+		errs = append(errs, fmt.Sprintf("profile %q needs a config file (CONFIG_FILE)", profile))
+	}
+
+	cfg := &Config{File: file, Profile: profile}
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := f.Tag.Get("key")
+		if key == "" {
+			continue
+		}
+		name := key
+		var src any
+		if d, ok := f.Tag.Lookup("default"); ok {
+			src = d
+		}
+		if fv, ok := vals[key]; ok {
+			src = fv
+		}
+		if env := f.Tag.Get("env"); env != "" {
+			name = key + " (" + env + ")"
+			if ev, ok := os.LookupEnv(env); ok && ev != "" {
+				src = ev
+			}
+		}
+		if src == nil {
+			continue
+		}
+		if err := assign(v.Field(i), f, src); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if f.Tag.Get("secret") == "true" {
+			if err := resolveSecret(v.Field(i)); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+	}
+	errs = append(errs, cfg.lookupSecrets()...)
+
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return cfg, nil
+}
+
+// readFile decodes a YAML (.yaml, .yml) or TOML (.toml) config file.
+func readFile(path string) (map[string]any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &raw)
+	case ".toml":
+		err = toml.Unmarshal(b, &raw)
+	default:
+		return nil, fmt.Errorf("config %s: unsupported extension, want .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return raw, nil
+}
+
+// merge overlays src onto dst, descending into nested sections.
+func merge(dst, src map[string]any) {
+	for k, sv := range src {
+		sm, sok := sv.(map[string]any)
+		dm, dok := dst[k].(map[string]any)
+		if sok && dok {
+			merge(dm, sm)
+			continue
+		}
+		dst[k] = sv
+	}
+}
+
+var knownKeys = func() map[string]bool {
+	m := map[string]bool{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if k := t.Field(i).Tag.Get("key"); k != "" {
+			m[k] = true
+		}
+	}
+	return m
+}()
+
+// flatten turns nested sections into dotted keys, reporting unknown ones.
+func flatten(m map[string]any, prefix string, out map[string]any, errs *Errors) {
+	for k, v := range m {
+		key := prefix + k
+		if knownKeys[key] {
+			out[key] = v
+			continue
+		}
+		if sub, ok := v.(map[string]any); ok {
+			flatten(sub, key+".", out, errs)
+			continue
+		}
+		*errs = append(*errs, fmt.Sprintf("%s: unknown setting", key))
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// assign parses src, a string from env/defaults or a decoded file value,
+// into the field.
+func assign(fv reflect.Value, f reflect.StructField, src any) error {
+	s, isString := src.(string)
+	if !isString {
+		s = fmt.Sprint(src)
+	}
+	switch {
+	case f.Type == durationType:
+		d, err := time.ParseDuration(s)
+		if days, ok := strings.CutSuffix(s, "d"); err != nil && ok {
+			// time.ParseDuration has no day unit; accept "90d"
+			if n, derr := strconv.ParseFloat(days, 64); derr == nil {
+				d, err = time.Duration(n*float64(24*time.Hour)), nil
+			}
+		}
+		if err != nil {
+			n, nerr := strconv.ParseFloat(s, 64)
+			if nerr != nil {
+				return fmt.Errorf("invalid duration %q", s)
+			}
+			d = time.Duration(n * float64(unitOf(f)))
+		}
+		fv.SetInt(int64(d))
+	case f.Type.Kind() == reflect.String:
+		fv.SetString(s)
+	case f.Type.Kind() == reflect.Int:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid int %q", s)
+		}
+		fv.SetInt(int64(i))
+	case f.Type.Kind() == reflect.Float32:
+		x, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		fv.SetFloat(x)
+	case f.Type.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", s)
+		}
+		fv.SetBool(b)
+	case f.Type == reflect.TypeOf([]string(nil)):
+		sep := f.Tag.Get("sep")
+		if sep == "" {
+			sep = ","
+		}
+		list, err := stringList(src, sep)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(list))
+	case f.Type == reflect.TypeOf([][]string(nil)):
+		var groups [][]string
+		if isString {
+			for _, g := range strings.Split(s, "|") {
+				if l := splitList(g, ","); len(l) > 0 {
+					groups = append(groups, l)
+				}
+			}
+		} else {
+			items, ok := src.([]any)
+			if !ok {
+				return fmt.Errorf("want a list of lists")
+			}
+			for _, it := range items {
+				l, err := stringList(it, ",")
+				if err != nil {
+					return err
+				}
+				groups = append(groups, l)
+			}
+		}
+		fv.Set(reflect.ValueOf(groups))
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type)
+	}
+	return nil
+}
+
+func unitOf(f reflect.StructField) time.Duration {
+	switch f.Tag.Get("unit") {
+	case "m":
+		return time.Minute
+	case "h":
+		return time.Hour
+	case "d":
+		return 24 * time.Hour
+	default:
+		return time.Second
+	}
+}
+
+func stringList(src any, sep string) ([]string, error) {
+	switch v := src.(type) {
+	case string:
+		return splitList(v, sep), nil
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, it := range v {
+			out = append(out, fmt.Sprint(it))
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("want a list")
+	}
+}
+
+// lookupSecrets fills secret fields from the provider chain. The local
+// providers come first so the Vault token itself can be mounted as a file.
+func (c *Config) lookupSecrets() Errors {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var errs Errors
+	found := map[string]bool{}
+	// shared reports one error for the provider instead of one per field
+	resolve := func(p secrets.Provider, shared bool) {
+		v := reflect.ValueOf(c).Elem()
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			env := f.Tag.Get("env")
+			if f.Tag.Get("secret") != "true" || env == "" || found[env] {
+				continue
+			}
+			s, err := p.Get(ctx, env)
+			if errors.Is(err, secrets.ErrNotFound) {
+				continue
+			}
+			if err != nil && shared {
+				errs = append(errs, fmt.Sprintf("secrets.%s: %v", p.Name(), err))
+				return
+			}
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s (%s): %v", f.Tag.Get("key"), env, err))
+				continue
+			}
+			v.Field(i).SetString(s)
+			found[env] = true
+		}
+	}
+
+	resolve(secrets.Chain{secrets.Env{}, secrets.File{}}, false)
+	if c.VaultAddr != "" {
+		found["VAULT_TOKEN"] = true
+		resolve(&secrets.Vault{Addr: c.VaultAddr, Token: c.VaultToken, Path: c.VaultPath}, true)
+	}
+	return errs
+}
+
+// resolveSecret replaces a "file:/path" reference with the file's contents.
+func resolveSecret(fv reflect.Value) error {
+	path, ok := strings.CutPrefix(fv.String(), "file:")
+	if !ok {
+		return nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("secret file: %w", err)
+	}
+	fv.SetString(strings.TrimSpace(string(b)))
+	return nil
+}
+
+func splitList(v, sep string) []string {
+	var out []string
+	for _, p := range strings.Split(v, sep) {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
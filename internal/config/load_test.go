@@ -0,0 +1,240 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setEnv sets the required secrets so Load only fails on what a test writes.
+func setEnv(t *testing.T) {
+	t.Helper()
+	for k, v := range map[string]string{
+		"GEMINI_API_KEY":  "g",
+		"X_API_KEY":       "a",
+		"X_API_SECRET":    "b",
+		"X_ACCESS_TOKEN":  "c",
+		"X_ACCESS_SECRET": "d",
+		"AUTH_DISABLED":   "true",
+		"CONFIG_FILE":     "",
+		"CONFIG_PROFILE":  "",
+		"TZ":              "",
+		"POSTS_PER_DAY":   "",
+		"MAX_TOKENS":      "",
+	} {
+		t.Setenv(k, v)
+	}
+}
+
+func writeFile(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	const yamlFile = `
+schedule:
+  posts_per_day: 7
+  window_start: "08:00"
+gemini:
+  max_tokens: 128
+profiles:
+  quiet:
+    schedule:
+      posts_per_day: 2
+`
+	tests := []struct {
+		name    string
+		file    string // file name and body, empty for none
+		body    string
+		profile string
+		env     map[string]string
+		check   func(*Config) string
+	}{
+		{
+			name: "defaults",
+			check: func(c *Config) string {
+				if c.PostsPerDay != 5 || c.Model != "gemini-2.0-flash" || c.ReplyScanInterval != time.Hour {
+					return "defaults not applied"
+				}
+				return ""
+			},
+		},
+		{
+			name: "yaml",
+			file: "bot.yaml", body: yamlFile,
+			check: func(c *Config) string {
+				if c.PostsPerDay != 7 || c.PostWindowStart != "08:00" || c.MaxTokens != 128 {
+					return "file values not applied"
+				}
+				return ""
+			},
+		},
+		{
+			name: "toml",
+			file: "bot.toml", body: "[schedule]\nposts_per_day = 3\n",
+			check: func(c *Config) string {
+				if c.PostsPerDay != 3 {
+					return "toml value not applied"
+				}
+				return ""
+			},
+		},
+		{
+			name: "profile overrides a nested key",
+			file: "bot.yaml", body: yamlFile, profile: "quiet",
+			check: func(c *Config) string {
+				if c.PostsPerDay != 2 || c.PostWindowStart != "08:00" {
+					return "profile not merged over the file"
+				}
+				return ""
+			},
+		},
+		{
+			name: "env overrides the profile",
+			file: "bot.yaml", body: yamlFile, profile: "quiet",
+			env: map[string]string{"POSTS_PER_DAY": "9"},
+			check: func(c *Config) string {
+				if c.PostsPerDay != 9 {
+					return "env did not win"
+				}
+				return ""
+			},
+		},
+		{
+			name: "profile from env",
+			file: "bot.yaml", body: yamlFile,
+			env: map[string]string{"CONFIG_PROFILE": "quiet"},
+			check: func(c *Config) string {
+				if c.Profile != "quiet" || c.PostsPerDay != 2 {
+					return "CONFIG_PROFILE not used"
+				}
+				return ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			file := ""
+			if tt.file != "" {
+				file = writeFile(t, tt.file, tt.body)
+			}
+			cfg, err := Load(file, tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg := tt.check(cfg); msg != "" {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestLoadDurations(t *testing.T) {
+	tests := []struct {
+		env, value string
+		get        func(*Config) time.Duration
+		want       time.Duration
+	}{
+		{"REPLY_SCAN_INTERVAL_MIN", "2h", func(c *Config) time.Duration { return c.ReplyScanInterval }, 2 * time.Hour},
+		{"REPLY_SCAN_INTERVAL_MIN", "90", func(c *Config) time.Duration { return c.ReplyScanInterval }, 90 * time.Minute},
+		{"REPLY_SCAN_INTERVAL_MIN", "1.5", func(c *Config) time.Duration { return c.ReplyScanInterval }, 90 * time.Second},
+		{"GEN_TIMEOUT_SEC", "45", func(c *Config) time.Duration { return c.GenTimeout }, 45 * time.Second},
+		{"RETENTION_SEEN_DAYS", "90d", func(c *Config) time.Duration { return c.RetentionSeen }, 90 * 24 * time.Hour},
+		{"RETENTION_SEEN_DAYS", "10", func(c *Config) time.Duration { return c.RetentionSeen }, 10 * 24 * time.Hour},
+		{"STATS_REFRESH_MIN", "30", func(c *Config) time.Duration { return c.StatsRefresh }, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env+"="+tt.value, func(t *testing.T) {
+			setEnv(t)
+			t.Setenv(tt.env, tt.value)
+			cfg, err := Load("", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.get(cfg); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		body    string
+		profile string
+		env     map[string]string
+		want    []string
+	}{
+		{
+			name: "unknown key",
+			file: "bot.yaml", body: "schedule:\n  posts_per_hour: 3\n",
+			want: []string{"schedule.posts_per_hour: unknown setting"},
+		},
+		{
+			name: "undefined profile",
+			file: "bot.yaml", body: "schedule:\n  posts_per_day: 3\n", profile: "loud",
+			want: []string{`profile "loud" is not defined in`},
+		},
+		{
+			name: "profile without a file", profile: "quiet",
+			want: []string{`profile "quiet" needs a config file (CONFIG_FILE)`},
+		},
+		{
+			name: "unsupported extension",
+			file: "bot.ini", body: "x=1",
+			want: []string{"unsupported extension"},
+		},
+		{
+			name: "every problem at once",
+			file: "bot.yaml", body: "schedule:\n  posts_per_day: many\n  window_start: \"23:00\"\nnope: 1\n",
+			env: map[string]string{"GEMINI_API_KEY": "", "REPLY_SCAN_INTERVAL_MIN": "soon"},
+			want: []string{
+				`schedule.posts_per_day (POSTS_PER_DAY): invalid int "many"`,
+				`replies.scan_interval (REPLY_SCAN_INTERVAL_MIN): invalid duration "soon"`,
+				"nope: unknown setting",
+				"gemini.api_key (GEMINI_API_KEY) is required",
+				"schedule.window_end must be after window_start",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			file := ""
+			if tt.file != "" {
+				file = writeFile(t, tt.file, tt.body)
+			}
+			_, err := Load(file, tt.profile)
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("got %v, want Errors", err)
+			}
+			for _, w := range tt.want {
+				if !slices.ContainsFunc(errs, func(e string) bool { return strings.Contains(e, w) }) {
+					t.Errorf("missing %q in %q", w, errs)
+				}
+			}
+			if len(tt.want) > 1 && len(errs) < len(tt.want) {
+				t.Errorf("got %d errors, want at least %d", len(errs), len(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// validate checks ranges and cross-field rules, returning every violation.
+func (c *Config) validate() Errors {
+	var errs Errors
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.GeminiKey != "", "gemini.api_key (GEMINI_API_KEY) is required")
+	check(c.XApiKey != "", "x.api_key (X_API_KEY) is required")
+	check(c.XApiSecret != "", "x.api_secret (X_API_SECRET) is required")
+	check(c.XAccessToken != "", "x.access_token (X_ACCESS_TOKEN) is required")
+	check(c.XAccessSecret != "", "x.access_secret (X_ACCESS_SECRET) is required")
+
+	check(c.Model != "", "gemini.model must be set")
+	check(c.MaxTokens > 0, "gemini.max_tokens must be positive, got %d", c.MaxTokens)
+	check(c.Temperature >= 0 && c.Temperature <= 2, "gemini.temperature must be in [0,2], got %v", c.Temperature)
+	check(c.TopP > 0 && c.TopP <= 1, "gemini.top_p must be in (0,1], got %v", c.TopP)
+	check(c.GenTimeout > 0, "gemini.timeout must be positive")
+	check(c.GenMaxRetries >= 0, "gemini.max_retries must not be negative")
+	check(c.GenBreakerThreshold > 0, "gemini.breaker_threshold must be positive")
+	check(c.GenBudgetDaily >= 0 && c.GenBudgetMonthly >= 0, "gemini budgets must not be negative")
+
+	if _, err := time.LoadLocation(c.TZ); err != nil {
+		errs = append(errs, fmt.Sprintf("schedule.tz: %v", err))
+	}
+	check(c.PostsPerDay >= 0, "schedule.posts_per_day must not be negative")
+	start, serr := time.Parse("15:04", c.PostWindowStart)
+	check(serr == nil, "schedule.window_start: want HH:MM, got %q", c.PostWindowStart)
+	end, eerr := time.Parse("15:04", c.PostWindowEnd)
+	check(eerr == nil, "schedule.window_end: want HH:MM, got %q", c.PostWindowEnd)
+	if serr == nil && eerr == nil {
+		check(end.After(start), "schedule.window_end must be after window_start")
+	}
+
+	check(c.ReplyScanInterval > 0, "replies.scan_interval must be positive")
+	check(c.ReplyMinLikes >= 0 && c.ReplyMinRetweets >= 0, "replies thresholds must not be negative")
+	check(c.ReplyMaxPerScan >= 0, "replies.max_per_scan must not be negative")
+	check(c.ReplyMaxAge >= 0, "replies.max_age must not be negative")
+	check(c.ReplyMinScore >= 0 && c.ReplyMinScore <= 1, "replies.min_score must be in [0,1], got %v", c.ReplyMinScore)
+	check(c.ReplyHalfLife > 0, "replies.half_life must be positive")
+	check(c.ReplyVelocityTarget > 0, "replies.velocity_target must be positive")
+	check(c.ReplyFollowersMin >= 0 && c.ReplyFollowersMax > c.ReplyFollowersMin,
+		"replies.followers_max must be above followers_min, got %d-%d", c.ReplyFollowersMin, c.ReplyFollowersMax)
+	check(c.ReplySaturation > 0, "replies.saturation must be positive")
+	check(c.ReplyWeightFreshness >= 0 && c.ReplyWeightVelocity >= 0 && c.ReplyWeightAudience >= 0 &&
+		c.ReplyWeightSaturation >= 0 && c.ReplyWeightRelevance >= 0 && c.ReplyWeightFollowing >= 0,
+		"replies weights must not be negative")
+	check(c.ReplyAuthorCooldown >= 0 && c.ReplyConversationCooldown >= 0, "replies cooldowns must not be negative")
+	check(c.ReplyDailyCap >= 0, "replies.daily_cap must not be negative")
+	for _, e := range []struct {
+		name string
+		min  float32
+		cap  int
+	}{{"quote", c.EngageQuoteMinScore, c.EngageQuoteDailyCap}, {"repost", c.EngageRepostMinScore, c.EngageRepostDailyCap}, {"like", c.EngageLikeMinScore, c.EngageLikeDailyCap}} {
+		check(e.min >= 0 && e.min <= 1, "engage.%s_min_score must be in [0,1], got %v", e.name, e.min)
+		check(e.cap >= 0, "engage.%s_daily_cap must not be negative", e.name)
+	}
+	check(c.MentionsInterval > 0, "mentions.interval must be positive")
+	if err := validMentionPolicy(c.MentionsPolicy); err != nil {
+		errs = append(errs, "mentions.policy: "+err.Error())
+	}
+	check(c.CalendarConflictWindow >= 0, "calendar.conflict_window must not be negative")
+	check(c.CalendarGrace >= 0, "calendar.grace must not be negative")
+	check(c.RecycleShare >= 0 && c.RecycleShare <= 1, "recycle.share must be in [0,1], got %v", c.RecycleShare)
+	check(c.RecycleMinAge >= 0 && c.RecycleCooldown >= 0, "recycle.min_age and cooldown must not be negative")
+	check(c.RecycleMaxTimes >= 0, "recycle.max_times must not be negative")
+	check(c.RecycleDailyCap >= 0, "recycle.daily_cap must not be negative")
+	check(c.StatsRefresh > 0, "stats.refresh must be positive")
+
+	check(c.CandidateCount >= 1 && c.CandidateCount <= 8, "rank.candidates must be in [1,8], got %d", c.CandidateCount)
+	check(c.RankIdealLength > 0 && c.RankIdealLength <= 280, "rank.ideal_length must be in [1,280], got %d", c.RankIdealLength)
+	check(c.RankWeightLen >= 0 && c.RankWeightHook >= 0 && c.RankWeightNovel >= 0, "rank weights must not be negative")
+	check(c.MaxHashtags >= 0, "policy.max_hashtags must not be negative")
+	for i, g := range c.Topics {
+		check(len(g) > 0, "catalog.topics[%d] is empty", i)
+	}
+
+	check(c.AuthDisabled || c.AuthUsers != "" || c.AuthUsersFile != "" || c.AuthToken != "" || c.OIDCIssuer != "",
+		"auth: configure users, users_file, token or oidc, or set auth.disabled")
+	check(validRole(c.AuthTokenRole), "auth.token_role: unknown role %q", c.AuthTokenRole)
+	check(c.OIDCDefaultRole == "" || validRole(c.OIDCDefaultRole), "auth.oidc.default_role: unknown role %q", c.OIDCDefaultRole)
+	if c.OIDCIssuer != "" {
+		check(c.OIDCClientID != "", "auth.oidc.client_id is required with auth.oidc.issuer")
+		check(c.OIDCRedirectURL != "", "auth.oidc.redirect_url is required with auth.oidc.issuer")
+		check(!c.AuthCookieSecure || !strings.HasPrefix(c.OIDCRedirectURL, "http://"),
+			"auth.cookie_secure needs the dashboard behind https, but auth.oidc.redirect_url is %s", c.OIDCRedirectURL)
+	}
+	check(c.AuthSessionTTL > 0, "auth.session_ttl must be positive")
+
+	check(c.HealthTickMaxAge > 0 && c.HealthExternalTTL > 0, "health durations must be positive")
+	switch c.TraceExporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Sprintf("tracing.exporter must be none, stdout or otlp, got %q", c.TraceExporter))
+	}
+	check(c.TraceSampleRatio >= 0 && c.TraceSampleRatio <= 1, "tracing.sample_ratio must be in [0,1]")
+	check(c.ShutdownTimeout > 0, "shutdown_timeout must be positive")
+	// recent search reaches back 7 days; forgetting a tweet sooner could
+	// reply to it twice
+	check(c.RetentionSeen == 0 || c.RetentionSeen >= 7*24*time.Hour, "retention.seen must be 0 or at least 7d, got %v", c.RetentionSeen)
+	check(c.RetentionSlots == 0 || c.RetentionSlots >= 48*time.Hour, "retention.slots must be 0 or at least 2d, got %v", c.RetentionSlots)
+	check(c.RetentionShadow >= 0, "retention.shadow must not be negative")
+	check(c.StorageBackend == "badger" || c.StorageBackend == "sqlite", "storage.backend must be badger or sqlite, got %q", c.StorageBackend)
+	// online backups copy Badger's files; SQLite stores use db export
+	check(c.BackupDir == "" || c.StorageBackend == "badger", "backup.dir needs storage.backend badger; use db export with sqlite")
+	check(c.StorageGCInterval > 0, "storage.gc_interval must be positive")
+	check(c.StorageGCRatio > 0 && c.StorageGCRatio < 1, "storage.gc_discard_ratio must be in (0,1), got %v", c.StorageGCRatio)
+	check(c.BackupInterval > 0, "backup.interval must be positive")
+	check(c.BackupKeep >= 1, "backup.keep must be at least 1, got %d", c.BackupKeep)
+	switch c.LeaderLock {
+	case "none", "file":
+	case "http":
+		check(c.LeaderURL != "", "leader.url must be set for leader.lock http")
+	default:
+		errs = append(errs, fmt.Sprintf("leader.lock must be none, file or http, got %q", c.LeaderLock))
+	}
+	// a standby with its own store would see today's slots as unposted and
+	// post them again on takeover
+	check(c.LeaderLock == "none" || c.StorageBackend == "sqlite", "leader.lock %s needs storage.backend sqlite shared by every replica", c.LeaderLock)
+	check(c.LeaderTTL >= 3*time.Second, "leader.ttl must be at least 3s, got %v", c.LeaderTTL)
+	check(c.Account != "", "account must be set")
+	check(c.DataDir != "", "data_dir must be set")
+	return errs
+}
+
+// validMentionPolicy checks the class=action pairs the inbox package reads.
+func validMentionPolicy(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		class, action, ok := strings.Cut(pair, "=")
+		switch strings.ToLower(strings.TrimSpace(class)) {
+		case "question", "praise", "spam", "hostile":
+		default:
+			return fmt.Errorf("unknown class in %q, want question, praise, spam or hostile", pair)
+		}
+		switch strings.ToLower(strings.TrimSpace(action)) {
+		case "queue", "auto", "ignore":
+		default:
+			if ok {
+				return fmt.Errorf("unknown action in %q, want queue, auto or ignore", pair)
+			}
+			return fmt.Errorf("want class=action, got %q", pair)
+		}
+	}
+	return nil
+}
+
+func validRole(r string) bool {
+	return r == "viewer" || r == "editor" || r == "publisher"
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Live holds the current config and swaps in a new one on reload. Only
+// fields tagged `hot` change; the rest keep their startup values until a
+// restart.
+type Live struct {
+	cur atomic.Pointer[Config]
+
+	mu       sync.Mutex
+	onChange []func(old, cur *Config)
+	pending  string // last reported restart-only keys, to report them once
+}
+
+func NewLive(cfg *Config) *Live {
+	l := &Live{}
+	l.cur.Store(cfg)
+	return l
+}
+
+// Get returns the current config. Callers must not modify it.
+func (l *Live) Get() *Config { return l.cur.Load() }
+
+// OnChange registers fn to run after a reload that changed hot settings.
+func (l *Live) OnChange(fn func(old, cur *Config)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onChange = append(l.onChange, fn)
+}
+
+// Reload re-reads the file, environment and secret providers. It returns the
+// keys of hot settings that changed and of restart-only settings that differ
+// but were not applied; the latter are reported once until they change
+// again. An invalid config is rejected whole and the current one kept.
+func (l *Live) Reload() (applied, pending []string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	old := l.cur.Load()
+	next, err := Load(old.File, old.Profile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merged := *old
+	ov := reflect.ValueOf(old).Elem()
+	nv := reflect.ValueOf(next).Elem()
+	mv := reflect.ValueOf(&merged).Elem()
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := f.Tag.Get("key")
+		if key == "" || reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+		if f.Tag.Get("hot") != "true" {
+			pending = append(pending, key)
+			continue
+		}
+		mv.Field(i).Set(nv.Field(i))
+		applied = append(applied, key)
+	}
+	if p := strings.Join(pending, ","); p == l.pending {
+		pending = nil
+	} else {
+		l.pending = p
+	}
+	if len(applied) == 0 {
+		return nil, pending, nil
+	}
+	l.cur.Store(&merged)
+	for _, fn := range l.onChange {
+		fn(old, &merged)
+	}
+	return applied, pending, nil
+}
+
+// Watch reloads on SIGHUP, whenever the config file's modification time
+// changes (polled every interval) and every SecretsRefresh so rotated
+// secrets are picked up. report is called with each outcome, except that an
+// error is reported once until it changes or a reload succeeds, so an
+// unreachable secret store isn't logged every refresh; SIGHUP always reports.
+func (l *Live) Watch(ctx context.Context, interval time.Duration, report func(applied, pending []string, err error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	file := l.Get().File
+	mtime := modTime(file)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	var refresh <-chan time.Time
+	if every := l.Get().SecretsRefresh; every > 0 {
+		t := time.NewTicker(every)
+		defer t.Stop()
+		refresh = t.C
+	}
+
+	var lastErr string
+	for {
+		asked := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			asked = true
+		case <-refresh:
+		case <-tick.C:
+			if file == "" {
+				continue
+			}
+			m := modTime(file)
+			if m.Equal(mtime) {
+				continue
+			}
+			mtime = m
+		}
+		applied, pending, err := l.Reload()
+		if err != nil {
+			if err.Error() == lastErr && !asked {
+				continue
+			}
+			lastErr = err.Error()
+		} else {
+			lastErr = ""
+		}
+		report(applied, pending, err)
+	}
+}
+
+func modTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return st.ModTime()
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestLiveReload(t *testing.T) {
+	setEnv(t)
+	file := writeFile(t, "bot.yaml", "schedule:\n  posts_per_day: 5\n")
+	cfg, err := Load(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := NewLive(cfg)
+	var changes [][2]int
+	live.OnChange(func(old, cur *Config) {
+		changes = append(changes, [2]int{old.PostsPerDay, cur.PostsPerDay})
+	})
+
+	// each step rewrites the file, reloads and checks the outcome
+	steps := []struct {
+		name    string
+		body    string
+		applied []string
+		pending []string
+		wantErr bool
+		posts   int
+	}{
+		{name: "unchanged", body: "schedule:\n  posts_per_day: 5\n", posts: 5},
+		{name: "hot key applied", body: "schedule:\n  posts_per_day: 6\n", applied: []string{"schedule.posts_per_day"}, posts: 6},
+		{name: "restart-only key pending", body: "schedule:\n  posts_per_day: 6\n  tz: UTC\n", pending: []string{"schedule.tz"}, posts: 6},
+		{name: "pending reported once", body: "schedule:\n  posts_per_day: 6\n  tz: UTC\n", posts: 6},
+		{name: "invalid config kept out", body: "schedule:\n  posts_per_day: -1\n", wantErr: true, posts: 6},
+		{name: "both at once", body: "gemini:\n  model: gemini-pro\nschedule:\n  posts_per_day: 4\n  tz: UTC\n", applied: []string{"schedule.posts_per_day"}, pending: []string{"gemini.model", "schedule.tz"}, posts: 4},
+	}
+	for _, s := range steps {
+		if err := os.WriteFile(file, []byte(s.body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		applied, pending, err := live.Reload()
+		if (err != nil) != s.wantErr {
+			t.Fatalf("%s: err = %v", s.name, err)
+		}
+		if !slices.Equal(applied, s.applied) || !slices.Equal(pending, s.pending) {
+			t.Errorf("%s: applied %v pending %v, want %v %v", s.name, applied, pending, s.applied, s.pending)
+		}
+		if got := live.Get(); got.PostsPerDay != s.posts || got.TZ != cfg.TZ {
+			t.Errorf("%s: posts_per_day %d tz %s, want %d %s", s.name, got.PostsPerDay, got.TZ, s.posts, cfg.TZ)
+		}
+	}
+	if want := [][2]int{{5, 6}, {6, 4}}; !slices.Equal(changes, want) {
+		t.Errorf("OnChange saw %v, want %v", changes, want)
+	}
+}
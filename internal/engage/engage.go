@@ -0,0 +1,113 @@
+// Package engage decides what the reply scanner does with a scored tweet:
+// quote it with commentary, repost it, reply to it, like it, or skip it.
+// Each action has its own score threshold and daily cap, so the strongest
+// tweets get the most visible treatment while its budget lasts and weaker
+// ones still get a cheap like.
+package engage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Actions, and Skip for none.
+const (
+	Quote  = "quote"
+	Repost = "repost"
+	Reply  = "reply"
+	Like   = "like"
+	Skip   = "skip"
+)
+
+// Kinds lists the actions most visible first, the order Decide tries them.
+var Kinds = []string{Quote, Repost, Reply, Like}
+
+// Rule gates one action: a tweet must score at least MinScore, and at most
+// DailyCap of the action go out a day (0 for no cap).
+type Rule struct {
+	MinScore float64
+	DailyCap int
+}
+
+// Policy holds the rule for each action. Actions it leaves out are never
+// chosen.
+type Policy map[string]Rule
+
+// Floor is the lowest score any action accepts; tweets below it can be
+// rejected outright.
+func (p Policy) Floor() float64 {
+	floor, set := 1.0, false
+	for _, r := range p {
+		if !set || r.MinScore < floor {
+			floor, set = r.MinScore, true
+		}
+	}
+	return floor
+}
+
+// Decision is the action chosen for a tweet and why.
+type Decision struct {
+	Kind   string `json:"kind"`
+	Reason string `json:"reason"`
+}
+
+// Decider chooses actions for one scan, tracking what the daily caps still
+// allow as actions go out.
+type Decider struct {
+	policy Policy
+	used   map[string]int
+}
+
+// New returns a Decider for policy, given how many of each action were
+// already taken today.
+func New(policy Policy, usedToday map[string]int) *Decider {
+	used := make(map[string]int, len(usedToday))
+	for k, n := range usedToday {
+		used[k] = n
+	}
+	return &Decider{policy: policy, used: used}
+}
+
+// Left is how many more of kind today's cap allows, or -1 without a cap.
+func (d *Decider) Left(kind string) int {
+	r, ok := d.policy[kind]
+	if !ok {
+		return 0
+	}
+	if r.DailyCap <= 0 {
+		return -1
+	}
+	return max(r.DailyCap-d.used[kind], 0)
+}
+
+// Decide picks the most visible action that score qualifies for, that has
+// budget left and that blocked doesn't rule out. blocked maps an action to
+// the reason it can't be taken for this tweet, such as a cooldown.
+func (d *Decider) Decide(score float64, blocked map[string]string) Decision {
+	var passed []string
+	for _, kind := range Kinds {
+		r, ok := d.policy[kind]
+		if !ok {
+			continue
+		}
+		switch {
+		case score < r.MinScore:
+			passed = append(passed, fmt.Sprintf("%s needs %.2f", kind, r.MinScore))
+		case blocked[kind] != "":
+			passed = append(passed, kind+": "+blocked[kind])
+		case d.Left(kind) == 0:
+			passed = append(passed, fmt.Sprintf("%s: daily cap of %d reached", kind, r.DailyCap))
+		default:
+			return Decision{Kind: kind, Reason: fmt.Sprintf("score %.2f ≥ %.2f", score, r.MinScore)}
+		}
+	}
+	if len(passed) == 0 {
+		return Decision{Kind: Skip, Reason: "no action enabled"}
+	}
+	return Decision{Kind: Skip, Reason: fmt.Sprintf("score %.2f; %s", score, strings.Join(passed, "; "))}
+}
+
+// Done counts an action taken, against its daily cap.
+func (d *Decider) Done(kind string) {
+	d.used[kind]++
+}
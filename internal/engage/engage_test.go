@@ -0,0 +1,87 @@
+package engage
+
+import "testing"
+
+var policy = Policy{
+	Quote:  {MinScore: 0.8, DailyCap: 1},
+	Repost: {MinScore: 0.9, DailyCap: 0},
+	Reply:  {MinScore: 0.6, DailyCap: 2},
+	Like:   {MinScore: 0.3, DailyCap: 5},
+}
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		used    map[string]int
+		score   float64
+		blocked map[string]string
+		kind    string
+		reason  string
+	}{
+		{"most visible first", policy, nil, 0.95, nil, Quote, "score 0.95 ≥ 0.80"},
+		{"quote cap spent, repost has none", policy, map[string]int{Quote: 1}, 0.95, nil, Repost, "score 0.95 ≥ 0.90"},
+		{"below repost falls to reply", policy, map[string]int{Quote: 1}, 0.85, nil, Reply, "score 0.85 ≥ 0.60"},
+		{"blocked quote falls through", policy, nil, 0.85, map[string]string{Quote: "author cooldown"}, Reply, "score 0.85 ≥ 0.60"},
+		{"only a like", policy, nil, 0.5, nil, Like, "score 0.50 ≥ 0.30"},
+		{"threshold is inclusive", policy, nil, 0.6, nil, Reply, "score 0.60 ≥ 0.60"},
+		{"caps spent", policy, map[string]int{Reply: 2, Like: 7}, 0.7, nil, Skip,
+			"score 0.70; quote needs 0.80; repost needs 0.90; reply: daily cap of 2 reached; like: daily cap of 5 reached"},
+		{"too weak", policy, nil, 0.1, map[string]string{Like: "deny list"}, Skip,
+			"score 0.10; quote needs 0.80; repost needs 0.90; reply needs 0.60; like needs 0.30"},
+		{"blocked everywhere", policy, nil, 0.95, map[string]string{Quote: "deny list", Repost: "deny list", Reply: "deny list", Like: "deny list"}, Skip,
+			"score 0.95; quote: deny list; repost: deny list; reply: deny list; like: deny list"},
+		{"actions left out are never chosen", Policy{Like: {MinScore: 0.2}}, nil, 0.99, nil, Like, "score 0.99 ≥ 0.20"},
+		{"empty policy", Policy{}, nil, 1, nil, Skip, "no action enabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(tt.policy, tt.used).Decide(tt.score, tt.blocked)
+			if got.Kind != tt.kind || got.Reason != tt.reason {
+				t.Fatalf("Decide(%.2f) = %s %q, want %s %q", tt.score, got.Kind, got.Reason, tt.kind, tt.reason)
+			}
+		})
+	}
+}
+
+func TestDoneSpendsCaps(t *testing.T) {
+	used := map[string]int{Reply: 1}
+	d := New(policy, used)
+	if got := d.Decide(0.85, nil).Kind; got != Quote {
+		t.Fatalf("first = %s, want quote", got)
+	}
+	d.Done(Quote)
+	if got := d.Decide(0.85, nil).Kind; got != Reply {
+		t.Fatalf("after the quote = %s, want reply", got)
+	}
+	d.Done(Reply)
+	if got := d.Decide(0.85, nil).Kind; got != Like {
+		t.Fatalf("after the reply cap = %s, want like", got)
+	}
+	if used[Reply] != 1 {
+		t.Fatal("Done changed the caller's usage map")
+	}
+
+	for kind, want := range map[string]int{Quote: 0, Repost: -1, Reply: 0, Like: 5, "boost": 0} {
+		if got := d.Left(kind); got != want {
+			t.Errorf("Left(%s) = %d, want %d", kind, got, want)
+		}
+	}
+}
+
+func TestFloor(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		want   float64
+	}{
+		{policy, 0.3},
+		{Policy{Reply: {MinScore: 0.6}}, 0.6},
+		{Policy{Like: {MinScore: 0}}, 0},
+		{Policy{}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Floor(); got != tt.want {
+			t.Errorf("Floor(%v) = %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
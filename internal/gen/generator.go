@@ -3,17 +3,32 @@ package gen
 import (
 	"context"
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode/utf8"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
 type Generator struct {
-	client *genai.Client
-	model  *genai.GenerativeModel
+	opts Options
+
+	mu         sync.RWMutex // guards client and models, swapped by Rotate
+	client     *genai.Client
+	models     []*genai.GenerativeModel
+	modelNames []string
+
+	timeout    time.Duration
+	maxRetries int
+	breaker    *breaker
+	meter      Meter
+	onCall     func(Usage, error)
 }
 
 type Options struct {
@@ -23,6 +38,21 @@ type Options struct {
 	Temperature float32
 	TopP        float32
 	Lang        string
+
+	// FallbackModels are tried in order when Model is exhausted or unavailable.
+	FallbackModels []string
+	// Timeout bounds each individual Gemini call.
+	Timeout time.Duration
+	// MaxRetries is the number of retries per model on transient errors.
+	MaxRetries int
+	// BreakerThreshold consecutive failures open the circuit for BreakerCooldown.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Meter, if set, gates and records every call for cost accounting.
+	Meter Meter
+	// OnCall, if set, sees every attempt including failed ones (for metrics).
+	OnCall func(Usage, error)
 }
 
 func New(ctx context.Context, opts Options) (*Generator, error) {
@@ -30,47 +60,338 @@ func New(ctx context.Context, opts Options) (*Generator, error) {
 		return nil, errors.New("missing Gemini API key")
 	}
 
-	// Create Gemini API client with API key
-	client, err := genai.NewClient(ctx, option.WithAPIKey(opts.APIKey))
-	if err != nil {
+	g := &Generator{
+		opts:       opts,
+		timeout:    opts.Timeout,
+		maxRetries: opts.MaxRetries,
+		breaker:    newBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
+		meter:      opts.Meter,
+		onCall:     opts.OnCall,
+	}
+	if g.timeout <= 0 {
+		g.timeout = 30 * time.Second
+	}
+	var err error
+	if g.client, g.models, err = g.connect(ctx, opts.APIKey); err != nil {
 		return nil, err
 	}
+	g.modelNames = append([]string{opts.Model}, opts.FallbackModels...)
+	return g, nil
+}
 
-	model := client.GenerativeModel(opts.Model)
-	if opts.MaxTokens > 0 {
-		model.GenerationConfig = genai.GenerationConfig{
-			MaxOutputTokens: ptrInt32(opts.MaxTokens),
-			Temperature:     ptrFloat32(opts.Temperature),
-			TopP:            ptrFloat32(opts.TopP),
+// connect creates a Gemini client for apiKey and the configured models.
+func (g *Generator) connect(ctx context.Context, apiKey string) (*genai.Client, []*genai.GenerativeModel, error) {
+	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	var models []*genai.GenerativeModel
+	for _, name := range append([]string{g.opts.Model}, g.opts.FallbackModels...) {
+		model := client.GenerativeModel(name)
+		if g.opts.MaxTokens > 0 {
+			model.GenerationConfig = genai.GenerationConfig{
+				MaxOutputTokens: ptrInt32(g.opts.MaxTokens),
+				Temperature:     ptrFloat32(g.opts.Temperature),
+				TopP:            ptrFloat32(g.opts.TopP),
+			}
 		}
+		models = append(models, model)
 	}
+	return client, models, nil
+}
 
-	return &Generator{
-		client: client,
-		model:  model,
-	}, nil
+// Rotate switches to a new API key. Calls already in flight finish on the
+// old client, which is closed once they can no longer be running.
+func (g *Generator) Rotate(ctx context.Context, apiKey string) error {
+	if apiKey == "" {
+		return errors.New("missing Gemini API key")
+	}
+	client, models, err := g.connect(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	old := g.client
+	g.client, g.models = client, models
+	g.mu.Unlock()
+
+	grace := g.timeout * time.Duration((g.maxRetries+1)*len(models)+1)
+	time.AfterFunc(grace, func() { old.Close() })
+	return nil
+}
+
+func (g *Generator) model(i int) *genai.GenerativeModel {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.models[i]
 }
 
 func (g *Generator) Close() {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	if g.client != nil {
 		g.client.Close()
 	}
 }
 
+// Ping lists the models visible to the API key and checks the primary model
+// is among them.
+func (g *Generator) Ping(ctx context.Context) (string, error) {
+	g.mu.RLock()
+	it := g.client.ListModels(ctx)
+	g.mu.RUnlock()
+	n := 0
+	found := false
+	for {
+		m, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		n++
+		if strings.TrimPrefix(m.Name, "models/") == g.modelNames[0] {
+			found = true
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("model %s not available to this key (%d models listed)", g.modelNames[0], n)
+	}
+	return fmt.Sprintf("%d models listed, %s available", n, g.modelNames[0]), nil
+}
+
 func (g *Generator) ComposeTweet(ctx context.Context, topic, style string) (string, error) {
-	resp, err := g.model.GenerateContent(ctx, genai.Text(
+	ctx, span := tracer.Start(ctx, "gen.ComposeTweet")
+	defer span.End()
+	resp, err := g.generate(ctx,
 		"Write a short, engaging tweet about "+topic+" in a "+style+" style.",
-	))
+	)
+	if err != nil {
+		return "", err
+	}
+	return CleanTweetText(extractText(resp)), nil
+}
+
+// Rephrase rewrites one of our earlier tweets to post again: the same point
+// in new words, so it reads fresh to followers who saw the original.
+func (g *Generator) Rephrase(ctx context.Context, text, style string) (string, error) {
+	ctx, span := tracer.Start(ctx, "gen.Rephrase")
+	defer span.End()
+	if style == "" {
+		style = "the original's"
+	}
+	resp, err := g.generate(ctx,
+		"Rewrite the following tweet of ours so we can post it again months later. Keep its point and any facts, change the wording and opening line, and write it in "+style+" style:\n\n"+text,
+	)
 	if err != nil {
 		return "", err
 	}
 	return CleanTweetText(extractText(resp)), nil
 }
 
+// Poll is a drafted poll: a question, 2 to 4 short options and how long
+// voting stays open.
+type Poll struct {
+	Question string        `json:"question"`
+	Options  []string      `json:"options"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Bounds ComposePoll holds a draft to; they match X's poll limits.
+const (
+	pollMaxOptions  = 4
+	pollOptionMax   = 25
+	pollMinDuration = 5 * time.Minute
+	pollMaxDuration = 7 * 24 * time.Hour
+	pollDefault     = 24 * time.Hour
+)
+
+// ComposePoll drafts a poll about topic in style.
+func (g *Generator) ComposePoll(ctx context.Context, topic, style string) (Poll, error) {
+	ctx, span := tracer.Start(ctx, "gen.ComposePoll")
+	defer span.End()
+	resp, err := g.generate(ctx,
+		"Write a Twitter poll about "+topic+" in a "+style+" style that DevOps engineers would want to vote on. "+
+			"Answer in exactly this form, with 2 to 4 Option lines of at most 25 characters each:\n"+
+			"Question: <the question>\nOption: <answer>\nOption: <answer>\nDuration: <hours voting stays open, 1 to 168>",
+	)
+	if err != nil {
+		return Poll{}, err
+	}
+	return parsePoll(extractText(resp))
+}
+
+var (
+	reOptionMark = regexp.MustCompile(`^([-*•]|\d+[.)]|[a-dA-D][.)])\s*`)
+	reHours      = regexp.MustCompile(`(\d+)\s*(m|min|minutes?|h|hours?|d|days?)?\b`)
+)
+
+// parsePoll reads a poll in the form ComposePoll asks for. Long options are
+// cut to fit, a missing or odd duration becomes a day, and fewer than two
+// options is an error.
+func parsePoll(s string) (Poll, error) {
+	p := Poll{Duration: pollDefault}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "*", ""))
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		switch k := strings.ToLower(strings.TrimSpace(key)); {
+		case k == "question":
+			p.Question = CleanTweetText(val)
+		case strings.HasPrefix(k, "option"):
+			val = strings.Trim(strings.TrimSpace(reOptionMark.ReplaceAllString(val, "")), `"`)
+			if r := []rune(val); len(r) > pollOptionMax {
+				val = strings.TrimSpace(string(r[:pollOptionMax]))
+			}
+			if val != "" && len(p.Options) < pollMaxOptions && !contains(p.Options, val) {
+				p.Options = append(p.Options, val)
+			}
+		case k == "duration":
+			if m := reHours.FindStringSubmatch(strings.ToLower(val)); m != nil {
+				n, _ := strconv.Atoi(m[1])
+				unit := time.Hour
+				switch {
+				case strings.HasPrefix(m[2], "m"):
+					unit = time.Minute
+				case strings.HasPrefix(m[2], "d"):
+					unit = 24 * time.Hour
+				}
+				p.Duration = min(max(time.Duration(n)*unit, pollMinDuration), pollMaxDuration)
+			}
+		}
+	}
+	if p.Question == "" || len(p.Options) < 2 {
+		return Poll{}, fmt.Errorf("poll needs a question and 2 options, got %q", s)
+	}
+	return p, nil
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// ComposeCandidates drafts n tweets for the same topic and style using
+// parallel calls (Gemini only returns one candidate per request). Empty and
+// failed drafts are dropped; an error is returned only if none succeed.
+func (g *Generator) ComposeCandidates(ctx context.Context, topic, style string, n int) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "gen.ComposeCandidates")
+	defer span.End()
+	if n < 1 {
+		n = 1
+	}
+	out := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			out[i], errs[i] = g.ComposeTweet(ctx, topic, style)
+		}(i)
+	}
+	wg.Wait()
+
+	var drafts []string
+	for i, t := range out {
+		if errs[i] == nil && t != "" {
+			drafts = append(drafts, t)
+		}
+	}
+	if len(drafts) == 0 {
+		if err := errors.Join(errs...); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("no candidates generated")
+	}
+	return drafts, nil
+}
+
+// JudgeHook asks the model to rate how strongly the opening of a tweet grabs
+// attention, returning a score in [0,1].
+func (g *Generator) JudgeHook(ctx context.Context, text string) (float64, error) {
+	ctx, span := tracer.Start(ctx, "gen.JudgeHook")
+	defer span.End()
+	resp, err := g.generate(ctx,
+		"Rate the hook strength of the following tweet from 0 to 10, where 10 means the first line makes a DevOps engineer stop scrolling. Answer with a single number only.\n\n"+text,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return parseScore(extractText(resp))
+}
+
+var reNumber = regexp.MustCompile(`\d+(\.\d+)?`)
+
+func parseScore(s string) (float64, error) {
+	m := reNumber.FindString(s)
+	if m == "" {
+		return 0, fmt.Errorf("judge returned no score: %q", s)
+	}
+	v, err := strconv.ParseFloat(m, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v > 10 {
+		v = 10
+	}
+	return v / 10, nil
+}
+
+// ClassifyMention asks the model which of classes best describes a tweet
+// mentioning us, returning the first class named in its answer.
+func (g *Generator) ClassifyMention(ctx context.Context, text string, classes []string) (string, error) {
+	ctx, span := tracer.Start(ctx, "gen.ClassifyMention")
+	defer span.End()
+	resp, err := g.generate(ctx,
+		"Classify the following tweet, which mentions our DevOps account, as exactly one of: "+strings.Join(classes, ", ")+". Answer with the single word only.\n\n"+text,
+	)
+	if err != nil {
+		return "", err
+	}
+	return parseClass(extractText(resp), classes)
+}
+
+func parseClass(s string, classes []string) (string, error) {
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return r < 'a' || r > 'z' }) {
+		for _, c := range classes {
+			if w == c {
+				return c, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("classifier returned no class: %q", s)
+}
+
 func (g *Generator) ComposeReply(ctx context.Context, tweetText, author string) (string, error) {
-	resp, err := g.model.GenerateContent(ctx, genai.Text(
+	ctx, span := tracer.Start(ctx, "gen.ComposeReply")
+	defer span.End()
+	resp, err := g.generate(ctx,
 		"Reply to the following tweet by "+author+" in a friendly and concise manner:\n\n"+tweetText,
-	))
+	)
+	if err != nil {
+		return "", err
+	}
+	return CleanTweetText(extractText(resp)), nil
+}
+
+// ComposeQuote writes commentary to post above a quote of tweetText. Unlike
+// a reply it speaks to our own followers, so it adds a take rather than
+// answering the author.
+func (g *Generator) ComposeQuote(ctx context.Context, tweetText, author string) (string, error) {
+	ctx, span := tracer.Start(ctx, "gen.ComposeQuote")
+	defer span.End()
+	resp, err := g.generate(ctx,
+		"Write a short comment to share the following tweet by "+author+" with our followers. Add a concrete insight or opinion of your own; do not just summarize it or address the author:\n\n"+tweetText,
+	)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,305 @@
+package gen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCircuitOpen is returned without calling Gemini while the breaker is open.
+var ErrCircuitOpen = errors.New("gemini circuit breaker open")
+
+// errKind says what to do after a failed call.
+type errKind int
+
+const (
+	errFatal     errKind = iota // bad request, auth: give up
+	errRetry                    // transient: back off and retry the same model
+	errNextModel                // model exhausted for now: move to the fallback
+)
+
+// classify maps a Gemini error to a retry decision and an optional
+// server-suggested delay (from RetryInfo on 429s).
+func classify(err error) (errKind, time.Duration) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errRetry, 0
+	}
+	var delay time.Duration
+	if ae, ok := apierror.FromError(err); ok {
+		d := ae.Details()
+		if d.RetryInfo != nil && d.RetryInfo.GetRetryDelay() != nil {
+			delay = d.RetryInfo.GetRetryDelay().AsDuration()
+		}
+		// daily quotas won't recover by waiting a few seconds
+		if d.QuotaFailure != nil {
+			for _, v := range d.QuotaFailure.GetViolations() {
+				if strings.Contains(v.GetSubject()+v.GetDescription(), "PerDay") {
+					return errNextModel, 0
+				}
+			}
+		}
+		switch ae.HTTPCode() {
+		case 429, 500, 502, 503, 504:
+			return errRetry, delay
+		case 404:
+			return errNextModel, 0
+		}
+	}
+	switch status.Code(err) {
+	case codes.ResourceExhausted, codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Aborted:
+		return errRetry, delay
+	case codes.NotFound:
+		return errNextModel, 0
+	}
+	return errFatal, 0
+}
+
+// ErrorCode summarizes a Gemini failure as an HTTP status or gRPC code name
+// for audit and metrics. It returns "" for nil and for errors that did not
+// come from Gemini.
+func ErrorCode(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	if ae, ok := apierror.FromError(err); ok && ae.HTTPCode() > 0 {
+		return fmt.Sprint(ae.HTTPCode())
+	}
+	if c := status.Code(err); c != codes.Unknown {
+		return c.String()
+	}
+	return ""
+}
+
+// backoff returns the wait before retry attempt n (0-based) with full jitter.
+func backoff(n int, base, max time.Duration) time.Duration {
+	d := base << n
+	if d <= 0 || d > max {
+		d = max
+	}
+	return time.Duration(rand.Int63n(int64(d))) + base/2
+}
+
+// Health is a point-in-time view of the generator for status endpoints.
+type Health struct {
+	State               string    `json:"state"`
+	Models              []string  `json:"models"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastErrorAt         time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt       time.Time `json:"last_success_at,omitempty"`
+	LastModel           string    `json:"last_model,omitempty"`
+	OpenUntil           time.Time `json:"open_until,omitempty"`
+}
+
+// breaker is a consecutive-failure circuit breaker. After threshold failures
+// it rejects calls for cooldown, then lets a single probe through.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	failures  int
+	openUntil time.Time
+	probing   bool
+
+	lastErr   string
+	lastErrAt time.Time
+	lastOKAt  time.Time
+	lastModel string
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if cooldown <= 0 {
+		cooldown = 2 * time.Minute
+	}
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success(model string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	b.lastOKAt = time.Now()
+	b.lastModel = model
+}
+
+// abandon ends a call whose failure says nothing about Gemini's
+// availability, such as a refused prompt or a caller that gave up. It frees
+// the probe without counting toward the threshold.
+func (b *breaker) abandon(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	b.lastErr = err.Error()
+	b.lastErrAt = time.Now()
+}
+
+// failure counts a transient or availability failure toward the threshold.
+func (b *breaker) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	b.lastErr = err.Error()
+	b.lastErrAt = time.Now()
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+func (b *breaker) health() Health {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := Health{
+		State:               "closed",
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastErr,
+		LastErrorAt:         b.lastErrAt,
+		LastSuccessAt:       b.lastOKAt,
+		LastModel:           b.lastModel,
+	}
+	if b.failures >= b.threshold {
+		h.State = "half-open"
+		if time.Now().Before(b.openUntil) {
+			h.State = "open"
+			h.OpenUntil = b.openUntil
+		}
+	}
+	return h
+}
+
+// generate runs a prompt through the model chain with per-call timeouts,
+// backoff on transient errors and fallback to the next model when one is
+// exhausted. Every Gemini call in the package goes through here.
+func (g *Generator) generate(ctx context.Context, prompt string) (_ *genai.GenerateContentResponse, err error) {
+	purpose := PurposeFrom(ctx)
+	ctx, span := tracer.Start(ctx, "gen.generate", trace.WithAttributes(attribute.String("gen.purpose", purpose)))
+	defer func() { endSpan(span, err) }()
+
+	if g.meter != nil {
+		if err := g.meter.Allow(ctx, purpose); err != nil {
+			return nil, err
+		}
+	}
+	if !g.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+	var lastErr error
+	for mi := range g.modelNames {
+		for attempt := 0; attempt <= g.maxRetries; attempt++ {
+			resp, err := g.attempt(ctx, mi, attempt, purpose, prompt)
+			if err == nil {
+				g.breaker.success(g.modelNames[mi])
+				return resp, nil
+			}
+			lastErr = fmt.Errorf("%s: %w", g.modelNames[mi], err)
+			// a caller that gave up or a request Gemini refuses outright
+			// isn't an outage, so neither opens the circuit for everyone
+			if ctx.Err() != nil {
+				g.breaker.abandon(lastErr)
+				return nil, lastErr
+			}
+			kind, delay := classify(err)
+			if kind == errFatal {
+				g.breaker.abandon(lastErr)
+				return nil, lastErr
+			}
+			if kind == errNextModel || attempt == g.maxRetries {
+				break
+			}
+			if delay <= 0 {
+				delay = backoff(attempt, time.Second, 30*time.Second)
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				g.breaker.abandon(lastErr)
+				return nil, ctx.Err()
+			}
+		}
+	}
+	g.breaker.failure(lastErr)
+	return nil, lastErr
+}
+
+// attempt makes one bounded call to model mi and records its usage.
+func (g *Generator) attempt(ctx context.Context, mi, n int, purpose, prompt string) (*genai.GenerateContentResponse, error) {
+	model := g.modelNames[mi]
+	ctx, span := tracer.Start(ctx, "gemini.GenerateContent",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("gen_ai.request.model", model), attribute.Int("gen.attempt", n)))
+	callCtx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := g.model(mi).GenerateContent(callCtx, genai.Text(prompt))
+	u := Usage{Model: model, Purpose: purpose, Latency: time.Since(start)}
+	if err == nil && resp.UsageMetadata != nil {
+		u.PromptTokens = resp.UsageMetadata.PromptTokenCount
+		u.CompletionTokens = resp.UsageMetadata.CandidatesTokenCount
+		span.SetAttributes(
+			attribute.Int("gen_ai.usage.input_tokens", int(u.PromptTokens)),
+			attribute.Int("gen_ai.usage.output_tokens", int(u.CompletionTokens)),
+		)
+	}
+	if g.onCall != nil {
+		g.onCall(u, err)
+	}
+	if err == nil && g.meter != nil {
+		g.meter.Record(ctx, u)
+	}
+	endSpan(span, err)
+	return resp, err
+}
+
+var tracer = otel.Tracer("github.com/UjjavalParmar/twitter-automation/internal/gen")
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Health reports breaker state and recent outcomes.
+func (g *Generator) Health() Health {
+	h := g.breaker.health()
+	h.Models = g.modelNames
+	return h
+}
@@ -0,0 +1,77 @@
+package gen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errKind
+	}{
+		{"timeout", fmt.Errorf("call: %w", context.DeadlineExceeded), errRetry},
+		{"unavailable", status.Error(codes.Unavailable, "down"), errRetry},
+		{"rate limited", status.Error(codes.ResourceExhausted, "slow down"), errRetry},
+		{"model gone", status.Error(codes.NotFound, "no model"), errNextModel},
+		{"bad request", status.Error(codes.InvalidArgument, "bad"), errFatal},
+		{"safety block", &genai.BlockedError{}, errFatal},
+		{"cancelled", context.Canceled, errFatal},
+	}
+	for _, tt := range tests {
+		if got, _ := classify(tt.err); got != tt.want {
+			t.Errorf("%s: classify = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBreakerCountsOnlyOutages(t *testing.T) {
+	b := newBreaker(2, time.Hour)
+	refused := errors.New("blocked")
+	for i := 0; i < 5; i++ {
+		if !b.allow() {
+			t.Fatalf("call %d rejected after refused prompts", i)
+		}
+		b.abandon(refused)
+	}
+	if h := b.health(); h.State != "closed" || h.ConsecutiveFailures != 0 || h.LastError != "blocked" {
+		t.Fatalf("after refusals health = %+v, want closed with the last error kept", h)
+	}
+
+	b.failure(errors.New("503"))
+	b.failure(errors.New("503"))
+	if b.allow() {
+		t.Fatal("breaker allowed a call after reaching the threshold")
+	}
+	if h := b.health(); h.State != "open" {
+		t.Fatalf("state = %s, want open", h.State)
+	}
+}
+
+func TestBreakerAbandonFreesProbe(t *testing.T) {
+	b := newBreaker(1, time.Nanosecond)
+	b.failure(errors.New("503"))
+	time.Sleep(time.Millisecond)
+	if !b.allow() {
+		t.Fatal("no probe after the cooldown")
+	}
+	if b.allow() {
+		t.Fatal("second probe allowed while the first is out")
+	}
+	b.abandon(context.Canceled)
+	if !b.allow() {
+		t.Fatal("an abandoned probe kept the breaker from probing again")
+	}
+	b.success("m")
+	if h := b.health(); h.State != "closed" {
+		t.Fatalf("state after a good probe = %s, want closed", h.State)
+	}
+}
@@ -0,0 +1,46 @@
+package gen
+
+import (
+	"context"
+	"time"
+)
+
+// Purposes tag each Gemini call so usage can be broken down by caller.
+const (
+	PurposePost    = "post"
+	PurposeReply   = "reply"
+	PurposeManual  = "manual"
+	PurposeMention = "mention"
+	PurposeRecycle = "recycle"
+)
+
+type purposeKey struct{}
+
+// WithPurpose tags ctx so calls made with it are accounted under purpose.
+func WithPurpose(ctx context.Context, purpose string) context.Context {
+	return context.WithValue(ctx, purposeKey{}, purpose)
+}
+
+// PurposeFrom returns the purpose set by WithPurpose, or "unknown".
+func PurposeFrom(ctx context.Context) string {
+	if p, ok := ctx.Value(purposeKey{}).(string); ok && p != "" {
+		return p
+	}
+	return "unknown"
+}
+
+// Usage describes a single successful Gemini call.
+type Usage struct {
+	Model            string
+	Purpose          string
+	PromptTokens     int32
+	CompletionTokens int32
+	Latency          time.Duration
+}
+
+// Meter is consulted before every Gemini call and told about each one that
+// succeeds. Allow returning an error stops the call (e.g. budget exhausted).
+type Meter interface {
+	Allow(ctx context.Context, purpose string) error
+	Record(ctx context.Context, u Usage)
+}
@@ -0,0 +1,126 @@
+// Package health runs the probes behind /healthz and /readyz: liveness is
+// whether the process is working at all, readiness whether it can also
+// reach what it depends on.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check is a single named probe. Checks with a CacheTTL reuse their last
+// result for that long, which keeps rate-limited external APIs from being
+// hit on every probe.
+type Check struct {
+	Name     string
+	Liveness bool // also part of /healthz; every check is part of /readyz
+	CacheTTL time.Duration
+	Timeout  time.Duration
+	Run      func(ctx context.Context) (detail string, err error)
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name       string    `json:"name"`
+	OK         bool      `json:"ok"`
+	Detail     string    `json:"detail,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Cached     bool      `json:"cached,omitempty"`
+}
+
+// Report is the combined outcome of a set of checks.
+type Report struct {
+	OK     bool     `json:"ok"`
+	Checks []Result `json:"checks"`
+}
+
+// Checker runs a fixed set of checks and caches the results of those with a
+// CacheTTL.
+type Checker struct {
+	checks []Check
+
+	mu    sync.Mutex
+	cache map[string]Result
+}
+
+// New returns a Checker for checks. Names must be unique; they key the cache.
+func New(checks ...Check) *Checker {
+	return &Checker{checks: checks, cache: map[string]Result{}}
+}
+
+// Run executes the selected checks concurrently: the liveness checks only,
+// or every check for readiness. The report is OK when all of them pass.
+func (c *Checker) Run(ctx context.Context, livenessOnly bool) Report {
+	var sel []Check
+	for _, ch := range c.checks {
+		if !livenessOnly || ch.Liveness {
+			sel = append(sel, ch)
+		}
+	}
+	results := make([]Result, len(sel))
+	var wg sync.WaitGroup
+	for i, ch := range sel {
+		wg.Add(1)
+		go func(i int, ch Check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, ch)
+		}(i, ch)
+	}
+	wg.Wait()
+
+	rep := Report{OK: true, Checks: results}
+	for _, r := range results {
+		if !r.OK {
+			rep.OK = false
+		}
+	}
+	return rep
+}
+
+func (c *Checker) run(ctx context.Context, ch Check) Result {
+	if ch.CacheTTL > 0 {
+		c.mu.Lock()
+		r, ok := c.cache[ch.Name]
+		c.mu.Unlock()
+		if ok && time.Since(r.CheckedAt) < ch.CacheTTL {
+			r.Cached = true
+			return r
+		}
+	}
+	timeout := ch.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := ch.Run(cctx)
+	r := Result{Name: ch.Name, OK: err == nil, Detail: detail, DurationMS: time.Since(start).Milliseconds(), CheckedAt: time.Now()}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if ch.CacheTTL > 0 {
+		c.mu.Lock()
+		c.cache[ch.Name] = r
+		c.mu.Unlock()
+	}
+	return r
+}
+
+// Handler serves a report as JSON with 200 when healthy and 503 otherwise.
+func (c *Checker) Handler(livenessOnly bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rep := c.Run(r.Context(), livenessOnly)
+		w.Header().Set("Content-Type", "application/json")
+		if !rep.OK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(rep)
+	}
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var upstreamCalls atomic.Int32
+	c := New(
+		Check{Name: "process", Liveness: true, Run: func(context.Context) (string, error) {
+			return "up", nil
+		}},
+		Check{Name: "upstream", CacheTTL: time.Minute, Run: func(context.Context) (string, error) {
+			upstreamCalls.Add(1)
+			return "reachable", nil
+		}},
+		Check{Name: "store", Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", errors.New("ping timed out")
+		}},
+	)
+
+	tests := []struct {
+		name   string
+		live   bool
+		ok     bool
+		checks []string
+		cached bool // whether upstream comes from the cache
+	}{
+		{"liveness", true, true, []string{"process"}, false},
+		{"readiness", false, false, []string{"process", "upstream", "store"}, false},
+		{"readiness again", false, false, []string{"process", "upstream", "store"}, true},
+	}
+	for _, tt := range tests {
+		rep := c.Run(context.Background(), tt.live)
+		if rep.OK != tt.ok {
+			t.Errorf("%s: OK = %v, want %v", tt.name, rep.OK, tt.ok)
+		}
+		if len(rep.Checks) != len(tt.checks) {
+			t.Fatalf("%s: got %d checks, want %v", tt.name, len(rep.Checks), tt.checks)
+		}
+		for i, r := range rep.Checks {
+			if r.Name != tt.checks[i] {
+				t.Errorf("%s: check %d is %s, want %s", tt.name, i, r.Name, tt.checks[i])
+			}
+			switch r.Name {
+			case "upstream":
+				if !r.OK || r.Detail != "reachable" || r.Cached != tt.cached {
+					t.Errorf("%s: upstream = %+v, want cached %v", tt.name, r, tt.cached)
+				}
+			case "store":
+				if r.OK || r.Error != "ping timed out" || r.Cached {
+					t.Errorf("%s: store = %+v, want a fresh failure", tt.name, r)
+				}
+			}
+		}
+	}
+	if n := upstreamCalls.Load(); n != 1 {
+		t.Errorf("upstream ran %d times, want 1 with the cache", n)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c := New(
+		Check{Name: "process", Liveness: true, Run: func(context.Context) (string, error) { return "", nil }},
+		Check{Name: "store", Run: func(context.Context) (string, error) { return "", errors.New("down") }},
+	)
+	for _, tt := range []struct {
+		live bool
+		code int
+	}{{true, http.StatusOK}, {false, http.StatusServiceUnavailable}} {
+		w := httptest.NewRecorder()
+		c.Handler(tt.live)(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != tt.code {
+			t.Errorf("liveness %v: status %d, want %d", tt.live, w.Code, tt.code)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package inbox sorts tweets that mention our account. Each mention is
+// classified as a question, praise, spam or hostile, and a policy maps the
+// class to an action: queue a drafted reply for review, reply at once, or
+// ignore it.
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Classes of mention.
+const (
+	Question = "question"
+	Praise   = "praise"
+	Spam     = "spam"
+	Hostile  = "hostile"
+)
+
+// Classes lists every class, in the order they are offered to the model.
+var Classes = []string{Question, Praise, Spam, Hostile}
+
+// Actions a policy can take on a class.
+const (
+	Queue  = "queue"  // draft a reply for review in the dashboard
+	Auto   = "auto"   // reply without review
+	Ignore = "ignore" // store the mention and do nothing
+)
+
+// Statuses of a stored mention.
+const (
+	StatusQueued    = "queued"    // a draft awaits review
+	StatusReplied   = "replied"   // we replied, by hand or automatically
+	StatusIgnored   = "ignored"   // the policy ignored it
+	StatusDismissed = "dismissed" // a reviewer dropped the draft
+	StatusFailed    = "failed"    // drafting or replying failed; retry from the inbox
+)
+
+// Policy maps classes to actions. Classes it leaves out are queued, so
+// nothing is answered unreviewed by accident.
+type Policy map[string]string
+
+// DefaultPolicy is the policy when none is configured.
+const DefaultPolicy = "question=queue,praise=queue,spam=ignore,hostile=ignore"
+
+// ParsePolicy reads "class=action" pairs separated by commas.
+func ParsePolicy(s string) (Policy, error) {
+	p := Policy{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		class, action, ok := strings.Cut(pair, "=")
+		class, action = strings.ToLower(strings.TrimSpace(class)), strings.ToLower(strings.TrimSpace(action))
+		if !ok {
+			return nil, fmt.Errorf("policy %q: want class=action", pair)
+		}
+		if !known(Classes, class) {
+			return nil, fmt.Errorf("policy %q: unknown class %q, want one of %s", pair, class, strings.Join(Classes, ", "))
+		}
+		if !known([]string{Queue, Auto, Ignore}, action) {
+			return nil, fmt.Errorf("policy %q: unknown action %q, want queue, auto or ignore", pair, action)
+		}
+		p[class] = action
+	}
+	return p, nil
+}
+
+// Action is what p does with a mention of class.
+func (p Policy) Action(class string) string {
+	if a, ok := p[class]; ok {
+		return a
+	}
+	return Queue
+}
+
+// String renders p in the form ParsePolicy reads.
+func (p Policy) String() string {
+	parts := make([]string, 0, len(p))
+	for c, a := range p {
+		parts = append(parts, c+"="+a)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// Model classifies text as one of classes, such as gen.ClassifyMention.
+type Model func(ctx context.Context, text string, classes []string) (string, error)
+
+// Classify asks model for the class of a mention, falling back to keyword
+// rules when there is no model or it fails. The error, if any, is the
+// model's; the class is always usable.
+func Classify(ctx context.Context, model Model, text string) (string, error) {
+	if model == nil {
+		return Heuristic(text), nil
+	}
+	class, err := model(ctx, text, Classes)
+	if err != nil {
+		return Heuristic(text), err
+	}
+	return class, nil
+}
+
+var (
+	reLink    = regexp.MustCompile(`https?://\S+`)
+	reHandle  = regexp.MustCompile(`@\w+`)
+	spamWords = []string{"follow back", "dm me", "giveaway", "airdrop", "crypto", "promo", "check my", "earn $", "free followers"}
+	rudeWords = []string{"idiot", "stupid", "trash", "garbage", "clown", "shut up", "scam", "moron", "pathetic"}
+	kindWords = []string{"thank", "great", "love", "awesome", "helpful", "nice", "brilliant", "well said", "+1", "🙌", "🔥", "❤"}
+	askWords  = []string{"how ", "what ", "why ", "when ", "where ", "which ", "who "}
+	askAny    = []string{"can you", "could you", "any idea", "anyone know", "is there a way"}
+)
+
+// Heuristic classifies a mention by keywords. It is a fallback for when the
+// model is unavailable and errs towards Question, which is queued by
+// default.
+func Heuristic(text string) string {
+	t := strings.ToLower(text)
+	body := strings.TrimSpace(reHandle.ReplaceAllString(t, ""))
+	switch {
+	case containsAny(t, spamWords) || len(reLink.FindAllString(t, -1)) >= 2:
+		return Spam
+	case containsAny(t, rudeWords):
+		return Hostile
+	case strings.Contains(body, "?") || hasPrefixAny(body, askWords) || containsAny(body, askAny):
+		return Question
+	case containsAny(t, kindWords):
+		return Praise
+	}
+	return Question
+}
+
+func containsAny(s string, words []string) bool {
+	for _, w := range words {
+		if strings.Contains(s, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrefixAny(s string, words []string) bool {
+	for _, w := range words {
+		if strings.HasPrefix(s, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func known(set []string, v string) bool {
+	for _, s := range set {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
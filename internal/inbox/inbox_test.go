@@ -0,0 +1,91 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // Policy.String, or the error substring
+		err  bool
+	}{
+		{DefaultPolicy, "hostile=ignore,praise=queue,question=queue,spam=ignore", false},
+		{" Question = AUTO , ,spam=ignore,", "question=auto,spam=ignore", false},
+		{"", "", false},
+		{"question=queue,question=auto", "question=auto", false}, // the last pair wins
+		{"question", `want class=action`, true},
+		{"complaint=queue", `unknown class "complaint"`, true},
+		{"spam=delete", `unknown action "delete"`, true},
+	}
+	for _, tt := range tests {
+		p, err := ParsePolicy(tt.in)
+		switch {
+		case tt.err && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("ParsePolicy(%q) error = %v, want %q", tt.in, err, tt.want)
+		case !tt.err && (err != nil || p.String() != tt.want):
+			t.Errorf("ParsePolicy(%q) = %q, %v, want %q", tt.in, p.String(), err, tt.want)
+		}
+	}
+}
+
+func TestPolicyAction(t *testing.T) {
+	p, err := ParsePolicy("praise=auto,spam=ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for class, want := range map[string]string{Praise: Auto, Spam: Ignore, Question: Queue, Hostile: Queue, "unknown": Queue} {
+		if got := p.Action(class); got != want {
+			t.Errorf("Action(%s) = %s, want %s (unlisted classes are queued)", class, got, want)
+		}
+	}
+}
+
+func TestHeuristic(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"@bot how do you handle terraform drift", Question},
+		{"@bot any idea why my pods restart", Question},
+		{"@bot is this still true in 2024?", Question},
+		{"@bot thanks, this was really helpful", Praise},
+		{"@bot 🔥🔥", Praise},
+		{"@bot thanks! but why does it need root?", Question}, // a question beats praise
+		{"@bot Follow back and join our giveaway", Spam},
+		{"@bot see https://a.example and https://b.example", Spam},
+		{"@bot you absolute clown", Hostile},
+		{"@bot great thread, but what a scam", Hostile},
+		{"@bot ok", Question}, // unknown falls back to a reviewed class
+		{"@whoknows", Question},
+	}
+	for _, tt := range tests {
+		if got := Heuristic(tt.text); got != tt.want {
+			t.Errorf("Heuristic(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	ctx := context.Background()
+	praise := "@bot thank you"
+	if got, err := Classify(ctx, nil, praise); got != Praise || err != nil {
+		t.Errorf("without a model = %s, %v, want praise from the heuristic", got, err)
+	}
+	model := func(_ context.Context, _ string, classes []string) (string, error) {
+		if len(classes) != len(Classes) {
+			t.Errorf("model offered %v, want %v", classes, Classes)
+		}
+		return Spam, nil
+	}
+	if got, err := Classify(ctx, model, praise); got != Spam || err != nil {
+		t.Errorf("with a model = %s, %v, want the model's spam", got, err)
+	}
+	failing := func(context.Context, string, []string) (string, error) { return "", errors.New("quota") }
+	if got, err := Classify(ctx, failing, praise); got != Praise || err == nil {
+		t.Errorf("with a failing model = %s, %v, want praise and the model's error", got, err)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package leader
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// FileLock needs flock, which this platform lacks; use the HTTP lock.
+type FileLock struct{}
+
+func NewFileLock(string) *FileLock { return &FileLock{} }
+
+func (*FileLock) Acquire(context.Context, time.Duration) (bool, error) {
+	return false, errors.New("file lock is not supported on this platform")
+}
+
+func (*FileLock) Release(context.Context) error { return nil }
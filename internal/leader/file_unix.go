@@ -0,0 +1,61 @@
+//go:build unix
+
+package leader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FileLock is a Lock on one host: an exclusive flock on a file. The kernel
+// drops it when the process exits, so it needs no TTL.
+type FileLock struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+func NewFileLock(path string) *FileLock { return &FileLock{path: path} }
+
+func (l *FileLock) Acquire(context.Context, time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		return true, nil
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return false, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
+		return false, err
+	}
+	// record the holder for whoever inspects the file
+	_ = f.Truncate(0)
+	_, _ = f.WriteAt([]byte(DefaultID()+"\n"), 0)
+	l.f = f
+	return true, nil
+}
+
+func (l *FileLock) Release(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
@@ -0,0 +1,167 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPLock is a Lock held as a lease on a lock service, for replicas on
+// different hosts. The protocol is the one Server speaks:
+//
+//	PUT    /locks/<name>  {"holder": id, "ttl_ms": n}  200 granted, 409 held
+//	DELETE /locks/<name>?holder=<id>                   204 released
+//	GET    /locks/<name>                               200 lease, 404 free
+type HTTPLock struct {
+	Addr   string // e.g. http://127.0.0.1:7070
+	Name   string // lock name, one per account
+	Holder string // this replica's ID
+	HTTP   *http.Client
+}
+
+// Lease is a lock's current holder and when its hold runs out.
+type Lease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+func (l *HTTPLock) url() string {
+	return strings.TrimRight(l.Addr, "/") + "/locks/" + url.PathEscape(l.Name)
+}
+
+func (l *HTTPLock) do(req *http.Request) (*http.Response, error) {
+	hc := l.HTTP
+	if hc == nil {
+		hc = &http.Client{Timeout: 5 * time.Second}
+	}
+	return hc.Do(req)
+}
+
+func (l *HTTPLock) Acquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	body, _ := json.Marshal(map[string]any{"holder": l.Holder, "ttl_ms": ttl.Milliseconds()})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, l.url(), bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := l.do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusConflict:
+		return false, nil
+	default:
+		return false, fmt.Errorf("acquire %s: %s", l.Name, resp.Status)
+	}
+}
+
+func (l *HTTPLock) Release(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, l.url()+"?holder="+url.QueryEscape(l.Holder), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := l.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusConflict {
+		return fmt.Errorf("release %s: %s", l.Name, resp.Status)
+	}
+	return nil
+}
+
+// Server is a stand-in lock service keeping leases in memory. It is enough
+// for several replicas on a network to agree on a leader; a restart of the
+// service frees every lock, and the next renewal takes it back.
+type Server struct {
+	mu     sync.Mutex
+	leases map[string]Lease
+	now    func() time.Time
+}
+
+func NewServer() *Server {
+	return &Server{leases: map[string]Lease{}, now: time.Now}
+}
+
+// Handler routes the lock protocol.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /locks/{name}", s.acquire)
+	mux.HandleFunc("DELETE /locks/{name}", s.release)
+	mux.HandleFunc("GET /locks/{name}", s.get)
+	return mux
+}
+
+// current returns name's lease, dropping it if expired. Callers hold s.mu.
+func (s *Server) current(name string) (Lease, bool) {
+	l, ok := s.leases[name]
+	if ok && !s.now().Before(l.Expires) {
+		delete(s.leases, name)
+		return Lease{}, false
+	}
+	return l, ok
+}
+
+func (s *Server) acquire(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Holder string `json:"holder"`
+		TTLMS  int64  `json:"ttl_ms"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Holder == "" || body.TTLMS <= 0 {
+		http.Error(w, "want holder and ttl_ms", http.StatusBadRequest)
+		return
+	}
+	name := r.PathValue("name")
+	s.mu.Lock()
+	l, held := s.current(name)
+	status := http.StatusConflict
+	if !held || l.Holder == body.Holder {
+		l = Lease{Holder: body.Holder, Expires: s.now().Add(time.Duration(body.TTLMS) * time.Millisecond)}
+		s.leases[name] = l
+		status = http.StatusOK
+	}
+	s.mu.Unlock()
+	writeLease(w, status, l)
+}
+
+func (s *Server) release(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.mu.Lock()
+	l, held := s.current(name)
+	if held && l.Holder != r.URL.Query().Get("holder") {
+		s.mu.Unlock()
+		writeLease(w, http.StatusConflict, l)
+		return
+	}
+	delete(s.leases, name)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	l, held := s.current(r.PathValue("name"))
+	s.mu.Unlock()
+	if !held {
+		http.Error(w, "not held", http.StatusNotFound)
+		return
+	}
+	writeLease(w, http.StatusOK, l)
+}
+
+func writeLease(w http.ResponseWriter, status int, l Lease) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(l)
+}
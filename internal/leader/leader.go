@@ -0,0 +1,150 @@
+// Package leader decides which replica of the bot does the writing. Every
+// replica serves the dashboard, but only the one holding the lock runs the
+// scheduler and reply scanner, so running two never posts a slot twice.
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Lock is a mutual-exclusion lease that at most one holder owns at a time.
+type Lock interface {
+	// Acquire takes the lock, or extends our hold on it, for ttl. It reports
+	// false without an error when another holder has it.
+	Acquire(ctx context.Context, ttl time.Duration) (bool, error)
+	// Release gives the lock up if we hold it.
+	Release(ctx context.Context) error
+}
+
+// Single is the Lock of a deployment with one replica: always held.
+type Single struct{}
+
+func (Single) Acquire(context.Context, time.Duration) (bool, error) { return true, nil }
+func (Single) Release(context.Context) error                        { return nil }
+
+// DefaultID names this replica by host and process.
+func DefaultID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Status is an elector's view of leadership for the dashboard.
+type Status struct {
+	ID     string    `json:"id"`
+	Leader bool      `json:"leader"`
+	Since  time.Time `json:"since"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Elector keeps trying to acquire a Lock and renews it while held. It steps
+// down as soon as a renewal fails, since the lease may lapse before the next
+// attempt and another replica take over.
+type Elector struct {
+	lock Lock
+	id   string
+	ttl  time.Duration
+	log  zerolog.Logger
+
+	mu       sync.Mutex
+	leader   bool
+	since    time.Time
+	err      string
+	onChange []func(leading bool)
+}
+
+// NewElector returns an elector for lock. ttl is the lease length; it tries
+// or renews every third of it.
+func NewElector(lock Lock, id string, ttl time.Duration, log zerolog.Logger) *Elector {
+	return &Elector{lock: lock, id: id, ttl: ttl, log: log, since: time.Now()}
+}
+
+// OnChange registers fn to run when this replica gains or loses leadership.
+// Callbacks run on the elector's goroutine and should not block.
+func (e *Elector) OnChange(fn func(leading bool)) {
+	e.mu.Lock()
+	e.onChange = append(e.onChange, fn)
+	e.mu.Unlock()
+}
+
+// IsLeader reports whether this replica currently holds the lock.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+func (e *Elector) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return Status{ID: e.id, Leader: e.leader, Since: e.since, Error: e.err}
+}
+
+// Run tries for the lock until ctx is done. It leaves the lock held on
+// return so in-flight jobs can finish; call Resign once they have.
+func (e *Elector) Run(ctx context.Context) {
+	t := time.NewTicker(e.ttl / 3)
+	defer t.Stop()
+	for {
+		e.Try(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Try makes one attempt to take or renew the lock, running OnChange
+// callbacks if leadership changed.
+func (e *Elector) Try(ctx context.Context) {
+	ok, err := e.lock.Acquire(ctx, e.ttl)
+	if ctx.Err() != nil {
+		return
+	}
+	e.mu.Lock()
+	e.err = ""
+	if err != nil {
+		e.err = err.Error()
+	}
+	changed := ok != e.leader
+	if changed {
+		e.leader = ok
+		e.since = time.Now()
+	}
+	fns := append([]func(bool){}, e.onChange...)
+	e.mu.Unlock()
+
+	if err != nil {
+		e.log.Warn().Err(err).Str("replica", e.id).Msg("leader lock")
+	}
+	if !changed {
+		return
+	}
+	if ok {
+		e.log.Info().Str("replica", e.id).Msg("became leader")
+	} else {
+		e.log.Warn().Str("replica", e.id).Msg("lost leadership; serving read-only")
+	}
+	for _, fn := range fns {
+		fn(ok)
+	}
+}
+
+// Resign releases the lock so another replica can take over without waiting
+// for the lease to expire.
+func (e *Elector) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	was := e.leader
+	e.leader = false
+	e.mu.Unlock()
+	if !was {
+		return nil
+	}
+	return e.lock.Release(ctx)
+}
@@ -0,0 +1,148 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// scripted is a Lock whose Acquire returns the next scripted outcome.
+type scripted struct {
+	results  []error // nil grants the lock, errHeld refuses it
+	released int
+}
+
+var errHeld = errors.New("held elsewhere")
+
+func (l *scripted) Acquire(context.Context, time.Duration) (bool, error) {
+	err := l.results[0]
+	l.results = l.results[1:]
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, errHeld):
+		return false, nil
+	}
+	return false, err
+}
+
+func (l *scripted) Release(context.Context) error {
+	l.released++
+	return nil
+}
+
+func TestElectorStepsDown(t *testing.T) {
+	lock := &scripted{results: []error{errHeld, nil, nil, errors.New("lock service down"), errHeld, nil}}
+	e := NewElector(lock, "r1", time.Second, zerolog.Nop())
+	var changes []bool
+	e.OnChange(func(leading bool) { changes = append(changes, leading) })
+
+	want := []struct {
+		leader bool
+		err    string
+	}{
+		{false, ""},                  // another replica holds it
+		{true, ""},                   // acquired
+		{true, ""},                   // renewed
+		{false, "lock service down"}, // a failed renewal steps down at once
+		{false, ""},                  // someone else took over meanwhile
+		{true, ""},                   // and we win it back
+	}
+	for i, w := range want {
+		e.Try(context.Background())
+		st := e.Status()
+		if e.IsLeader() != w.leader || st.Leader != w.leader || st.Error != w.err {
+			t.Fatalf("try %d: leader=%v err=%q, want leader=%v err=%q", i, st.Leader, st.Error, w.leader, w.err)
+		}
+	}
+	if wantChanges := []bool{true, false, true}; !reflect.DeepEqual(changes, wantChanges) {
+		t.Fatalf("OnChange saw %v, want %v", changes, wantChanges)
+	}
+
+	if err := e.Resign(context.Background()); err != nil || lock.released != 1 || e.IsLeader() {
+		t.Fatalf("Resign: err=%v released=%d leader=%v, want one release and no leadership", err, lock.released, e.IsLeader())
+	}
+	if err := e.Resign(context.Background()); err != nil || lock.released != 1 {
+		t.Fatalf("second Resign released again (%d)", lock.released)
+	}
+}
+
+func TestElectorIgnoresCancelledAttempt(t *testing.T) {
+	lock := &scripted{results: []error{nil, context.Canceled}}
+	e := NewElector(lock, "r1", time.Second, zerolog.Nop())
+	e.Try(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.Try(ctx)
+	if !e.IsLeader() {
+		t.Fatal("a shutdown's cancelled renewal gave up leadership")
+	}
+}
+
+// clock is a settable time source for Server.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestServerLeases(t *testing.T) {
+	c := &clock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewServer()
+	s.now = c.Now
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	ctx := context.Background()
+	a := &HTTPLock{Addr: srv.URL, Name: "main", Holder: "a"}
+	b := &HTTPLock{Addr: srv.URL, Name: "main", Holder: "b"}
+	other := &HTTPLock{Addr: srv.URL, Name: "other", Holder: "b"}
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		lock    *HTTPLock
+		release bool
+		want    bool
+	}{
+		{"a takes the free lock", 0, a, false, true},
+		{"b is refused while a holds it", 0, b, false, false},
+		{"locks are per name", 0, other, false, true},
+		{"a renews before expiry", 9 * time.Second, a, false, true},
+		{"the renewal extended the lease", 9 * time.Second, b, false, false},
+		{"b takes it once the lease expires", 10 * time.Second, b, false, true},
+		{"a's stale release leaves b's lease", 0, a, true, false},
+		{"b still holds it", 0, a, false, false},
+		{"b releases", 0, b, true, false},
+		{"a takes the released lock", 0, a, false, true},
+	}
+	for _, st := range steps {
+		c.Add(st.advance)
+		if st.release {
+			if err := st.lock.Release(ctx); err != nil {
+				t.Fatalf("%s: %v", st.name, err)
+			}
+			continue
+		}
+		got, err := st.lock.Acquire(ctx, 10*time.Second)
+		if err != nil || got != st.want {
+			t.Fatalf("%s: Acquire = %v, %v, want %v", st.name, got, err, st.want)
+		}
+	}
+}
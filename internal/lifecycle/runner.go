@@ -0,0 +1,83 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Runner starts background jobs under a shared root context and tracks them
+// so shutdown can wait for in-flight work. A job name can only run once at a
+// time, which stops the scheduler from stacking duplicate attempts at the
+// same slot.
+type Runner struct {
+	ctx context.Context
+	wg  sync.WaitGroup
+
+	mu       sync.Mutex
+	running  map[string]time.Time
+	draining bool
+}
+
+func NewRunner(ctx context.Context) *Runner {
+	return &Runner{ctx: ctx, running: map[string]time.Time{}}
+}
+
+// Go runs fn in a goroutine unless a job with the same name is still running
+// or the runner is draining. It reports whether the job was started.
+func (r *Runner) Go(name string, fn func(ctx context.Context)) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.draining {
+		return false
+	}
+	if _, busy := r.running[name]; busy {
+		return false
+	}
+	r.running[name] = time.Now()
+	r.wg.Add(1)
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.running, name)
+			r.mu.Unlock()
+			r.wg.Done()
+		}()
+		fn(r.ctx)
+	}()
+	return true
+}
+
+// Running lists the jobs currently in flight.
+func (r *Runner) Running() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]string, 0, len(r.running))
+	for n := range r.running {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Drain stops new jobs from starting and waits for running ones until ctx is
+// done. On timeout it returns an error naming the jobs that were cut off.
+func (r *Runner) Drain(ctx context.Context) error {
+	r.mu.Lock()
+	r.draining = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs still running at shutdown deadline: %v", r.Running())
+	}
+}
@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"io"
 	"os"
 	"time"
 
@@ -8,7 +9,13 @@ import (
 )
 
 func New() zerolog.Logger {
-	l := zerolog.New(os.Stdout).With().Timestamp().Logger()
+	return NewTo(os.Stdout)
+}
+
+// NewTo logs to w. One-off commands log to stderr so their output on stdout
+// stays clean for pipes.
+func NewTo(w io.Writer) zerolog.Logger {
+	l := zerolog.New(w).With().Timestamp().Logger()
 	zerolog.TimeFieldFormat = time.RFC3339
 	return l
 }
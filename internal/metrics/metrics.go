@@ -0,0 +1,260 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Job label values.
+const (
+	JobScheduler = "scheduler"
+	JobReplies   = "reply-scanner"
+	JobMentions  = "mentions"
+	JobCalendar  = "calendar"
+	JobDashboard = "dashboard"
+	JobMetrics   = "metrics-refresh"
+	JobCLI       = "cli"
+)
+
+// Metrics owns the bot's Prometheus collectors. Every series carries an
+// account label so several bots can share one Prometheus.
+type Metrics struct {
+	account string
+	reg     *prometheus.Registry
+
+	posts        *prometheus.CounterVec
+	replies      *prometheus.CounterVec
+	lastPost     *prometheus.GaugeVec
+	jobRuns      *prometheus.CounterVec
+	jobDuration  *prometheus.HistogramVec
+	genCalls     *prometheus.CounterVec
+	genLatency   *prometheus.HistogramVec
+	genTokens    *prometheus.CounterVec
+	errors       *prometheus.CounterVec
+	xRequests    *prometheus.CounterVec
+	xLatency     *prometheus.HistogramVec
+	xRateLimit   *prometheus.GaugeVec
+	slots        *prometheus.GaugeVec
+	slotFailures *prometheus.CounterVec
+	shadow       *prometheus.CounterVec
+	throttled    *prometheus.CounterVec
+	mentions     *prometheus.CounterVec
+	engagements  *prometheus.CounterVec
+	decisions    *prometheus.CounterVec
+	recycled     *prometheus.CounterVec
+	leader       *prometheus.GaugeVec
+}
+
+func New(account string) *Metrics {
+	m := &Metrics{account: account, reg: prometheus.NewRegistry()}
+	m.posts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_posts_total", Help: "Tweets posted, by job and result.",
+	}, []string{"account", "job", "result"})
+	m.replies = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_replies_total", Help: "Replies posted, by job and result.",
+	}, []string{"account", "job", "result"})
+	m.lastPost = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bot_last_post_timestamp_seconds", Help: "Unix time of the last successful post, by job.",
+	}, []string{"account", "job"})
+	m.jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_job_runs_total", Help: "Background job runs, by result.",
+	}, []string{"account", "job", "result"})
+	m.jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "bot_job_duration_seconds", Help: "Background job run time.",
+		Buckets: []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"account", "job"})
+	m.genCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_generator_calls_total", Help: "Gemini calls, by model, purpose and result code.",
+	}, []string{"account", "model", "purpose", "code"})
+	m.genLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "bot_generator_latency_seconds", Help: "Gemini call latency.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"account", "model", "purpose"})
+	m.genTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_generator_tokens_total", Help: "Gemini tokens, by model, purpose and kind (prompt or completion).",
+	}, []string{"account", "model", "purpose", "kind"})
+	m.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_errors_total", Help: "Errors, by job and type.",
+	}, []string{"account", "job", "type"})
+	m.xRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_x_requests_total", Help: "X API requests, by operation and HTTP status (0 for transport errors).",
+	}, []string{"account", "op", "status"})
+	m.xLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "bot_x_request_duration_seconds", Help: "X API request latency.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"account", "op"})
+	m.xRateLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bot_x_rate_limit_remaining", Help: "Last x-rate-limit-remaining seen, by operation.",
+	}, []string{"account", "op"})
+	m.slots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bot_slots", Help: "Today's post slots, by state (pending, posted, failed).",
+	}, []string{"account", "state"})
+	m.slotFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_slot_failures_total", Help: "Failed attempts to fill a post slot.",
+	}, []string{"account"})
+
+	m.shadow = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_shadow_writes_total", Help: "Posts and replies recorded in dry-run mode instead of published, by job and kind.",
+	}, []string{"account", "job", "kind"})
+
+	m.throttled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_replies_throttled_total", Help: "Reply candidates refused before generation, by job and rule.",
+	}, []string{"account", "job", "rule"})
+
+	m.mentions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_mentions_total", Help: "Mentions of our account read by the poller, by class and action.",
+	}, []string{"account", "class", "action"})
+
+	m.engagements = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_engagements_total", Help: "Quotes, likes and reposts of others' tweets, by job, kind and result.",
+	}, []string{"account", "job", "kind", "result"})
+	m.decisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_engage_decisions_total", Help: "Actions chosen for scored reply candidates (quote, repost, reply, like or skip).",
+	}, []string{"account", "kind"})
+
+	m.recycled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_recycled_posts_total", Help: "Slots given to the recycler, by result (posted, fallback to a generated tweet, or error).",
+	}, []string{"account", "result"})
+
+	m.leader = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bot_leader", Help: "1 while this replica holds the leader lock and runs the scheduler.",
+	}, []string{"account"})
+
+	m.reg.MustRegister(
+		m.posts, m.replies, m.lastPost, m.jobRuns, m.jobDuration,
+		m.genCalls, m.genLatency, m.genTokens, m.errors,
+		m.xRequests, m.xLatency, m.xRateLimit, m.slots, m.slotFailures, m.shadow, m.throttled, m.mentions,
+		m.engagements, m.decisions, m.recycled, m.leader,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the registry in Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{})
+}
+
+// RegisterStorageSize exposes the store's main and log file sizes, as
+// reported by storage.Store.Size.
+func (m *Metrics) RegisterStorageSize(size func() (main, log int64)) {
+	m.reg.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "bot_storage_main_bytes", Help: "Storage main file size (Badger LSM tree, SQLite database).", ConstLabels: prometheus.Labels{"account": m.account},
+		}, func() float64 { n, _ := size(); return float64(n) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "bot_storage_log_bytes", Help: "Storage log file size (Badger value log, SQLite WAL).", ConstLabels: prometheus.Labels{"account": m.account},
+		}, func() float64 { _, n := size(); return float64(n) }),
+	)
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// Post counts a post attempt by job.
+func (m *Metrics) Post(job string, err error) {
+	m.posts.WithLabelValues(m.account, job, result(err)).Inc()
+	if err == nil {
+		m.lastPost.WithLabelValues(m.account, job).SetToCurrentTime()
+	}
+}
+
+// Reply counts a reply attempt by job.
+func (m *Metrics) Reply(job string, err error) {
+	m.replies.WithLabelValues(m.account, job, result(err)).Inc()
+}
+
+// Engagement counts a quote, like or repost attempt by job.
+func (m *Metrics) Engagement(job, kind string, err error) {
+	m.engagements.WithLabelValues(m.account, job, kind, result(err)).Inc()
+}
+
+// Decision counts the action chosen for a reply candidate.
+func (m *Metrics) Decision(kind string) {
+	m.decisions.WithLabelValues(m.account, kind).Inc()
+}
+
+// Shadow counts a dry-run write ("post", "reply", "quote", "like" or
+// "repost") by job.
+func (m *Metrics) Shadow(job, kind string) {
+	m.shadow.WithLabelValues(m.account, job, kind).Inc()
+}
+
+// Throttled counts a reply refused by an interaction rule.
+func (m *Metrics) Throttled(job, rule string) {
+	m.throttled.WithLabelValues(m.account, job, rule).Inc()
+}
+
+// Mention counts a mention the poller classified and what it did with it.
+func (m *Metrics) Mention(class, action string) {
+	m.mentions.WithLabelValues(m.account, class, action).Inc()
+}
+
+// Recycled counts a slot given to the recycler: "posted", "fallback" when
+// a generated tweet took its place, or "error".
+func (m *Metrics) Recycled(result string) {
+	m.recycled.WithLabelValues(m.account, result).Inc()
+}
+
+// Job records one run of a background job.
+func (m *Metrics) Job(job string, start time.Time, err error) {
+	m.jobRuns.WithLabelValues(m.account, job, result(err)).Inc()
+	m.jobDuration.WithLabelValues(m.account, job).Observe(time.Since(start).Seconds())
+}
+
+// Error counts an error of a given type (e.g. gemini, x_api, policy, storage).
+func (m *Metrics) Error(job, typ string) {
+	m.errors.WithLabelValues(m.account, job, typ).Inc()
+}
+
+// GeneratorCall records a single Gemini attempt. code is "ok" on success.
+func (m *Metrics) GeneratorCall(model, purpose, code string, latency time.Duration) {
+	m.genCalls.WithLabelValues(m.account, model, purpose, code).Inc()
+	m.genLatency.WithLabelValues(m.account, model, purpose).Observe(latency.Seconds())
+}
+
+// GeneratorTokens adds token counts for a successful call.
+func (m *Metrics) GeneratorTokens(model, purpose string, prompt, completion int) {
+	m.genTokens.WithLabelValues(m.account, model, purpose, "prompt").Add(float64(prompt))
+	m.genTokens.WithLabelValues(m.account, model, purpose, "completion").Add(float64(completion))
+}
+
+// XRequest records one X API response. remaining < 0 means the header was absent.
+func (m *Metrics) XRequest(op string, status int, latency time.Duration, remaining int) {
+	m.xRequests.WithLabelValues(m.account, op, strconv.Itoa(status)).Inc()
+	m.xLatency.WithLabelValues(m.account, op).Observe(latency.Seconds())
+	if remaining >= 0 {
+		m.xRateLimit.WithLabelValues(m.account, op).Set(float64(remaining))
+	}
+}
+
+// Slots sets the current slot counts.
+func (m *Metrics) Slots(pending, posted, failed int) {
+	m.slots.WithLabelValues(m.account, "pending").Set(float64(pending))
+	m.slots.WithLabelValues(m.account, "posted").Set(float64(posted))
+	m.slots.WithLabelValues(m.account, "failed").Set(float64(failed))
+}
+
+// SlotFailure counts a failed attempt to fill a slot.
+func (m *Metrics) SlotFailure() {
+	m.slotFailures.WithLabelValues(m.account).Inc()
+}
+
+// Leader records whether this replica is the leader.
+func (m *Metrics) Leader(leading bool) {
+	v := 0.0
+	if leading {
+		v = 1
+	}
+	m.leader.WithLabelValues(m.account).Set(v)
+}
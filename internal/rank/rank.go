@@ -0,0 +1,209 @@
+package rank
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Scorer rates a single draft. Scores are expected in [0,1].
+type Scorer interface {
+	Name() string
+	Score(ctx context.Context, text string) (float64, error)
+}
+
+// Weighted pairs a scorer with its contribution to the total score.
+type Weighted struct {
+	Scorer
+	Weight float64
+}
+
+// Candidate is a ranked draft with its per-scorer breakdown.
+type Candidate struct {
+	Text     string             `json:"text"`
+	Score    float64            `json:"score"`
+	Scores   map[string]float64 `json:"scores"`
+	Rejected bool               `json:"rejected"`
+	Reason   string             `json:"reason,omitempty"`
+}
+
+type Ranker struct {
+	policy  Policy
+	scorers []Weighted
+}
+
+func New(policy Policy, scorers ...Weighted) *Ranker {
+	return &Ranker{policy: policy, scorers: scorers}
+}
+
+// Rank scores every draft and returns them best first. Drafts that fail the
+// policy check are kept (so the UI can show why) but sorted last.
+func (r *Ranker) Rank(ctx context.Context, texts []string) []Candidate {
+	out := make([]Candidate, 0, len(texts))
+	for _, t := range texts {
+		c := Candidate{Text: t, Scores: map[string]float64{}}
+		if err := r.policy.Check(t); err != nil {
+			c.Rejected = true
+			c.Reason = err.Error()
+			out = append(out, c)
+			continue
+		}
+		var total, weights float64
+		for _, s := range r.scorers {
+			if s.Weight <= 0 {
+				continue
+			}
+			v, err := s.Score(ctx, t)
+			if err != nil {
+				// a failing scorer shouldn't sink the draft; skip its weight
+				continue
+			}
+			c.Scores[s.Name()] = v
+			total += v * s.Weight
+			weights += s.Weight
+		}
+		if weights > 0 {
+			c.Score = total / weights
+		}
+		out = append(out, c)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Rejected != out[j].Rejected {
+			return !out[i].Rejected
+		}
+		return out[i].Score > out[j].Score
+	})
+	return out
+}
+
+// Best returns the top non-rejected candidate.
+func Best(cs []Candidate) (Candidate, bool) {
+	for _, c := range cs {
+		if !c.Rejected {
+			return c, true
+		}
+	}
+	return Candidate{}, false
+}
+
+// Length prefers drafts close to an ideal rune count.
+type Length struct {
+	Ideal int
+}
+
+func (Length) Name() string { return "length" }
+
+func (l Length) Score(_ context.Context, text string) (float64, error) {
+	ideal := l.Ideal
+	if ideal <= 0 {
+		ideal = 180
+	}
+	d := utf8.RuneCountInString(text) - ideal
+	if d < 0 {
+		d = -d
+	}
+	return clamp01(1 - float64(d)/float64(ideal)), nil
+}
+
+// Hook delegates to an LLM judge that rates the opening line.
+type Hook struct {
+	Judge func(ctx context.Context, text string) (float64, error)
+}
+
+func (Hook) Name() string { return "hook" }
+
+func (h Hook) Score(ctx context.Context, text string) (float64, error) {
+	v, err := h.Judge(ctx, text)
+	if err != nil {
+		return 0, err
+	}
+	return clamp01(v), nil
+}
+
+// Novelty penalizes drafts that resemble something we already posted.
+type Novelty struct {
+	Past func(ctx context.Context) []string
+}
+
+func (Novelty) Name() string { return "novelty" }
+
+func (n Novelty) Score(ctx context.Context, text string) (float64, error) {
+	words := wordSet(text)
+	maxSim := 0.0
+	for _, p := range n.Past(ctx) {
+		if s := jaccard(words, wordSet(p)); s > maxSim {
+			maxSim = s
+		}
+	}
+	return 1 - maxSim, nil
+}
+
+// Policy holds hard rules; a draft that breaks one is never posted.
+type Policy struct {
+	BannedWords []string
+	MaxHashtags int
+	AllowLinks  bool
+}
+
+var (
+	reHashtag = regexp.MustCompile(`(^|\s)#\w+`)
+	reLink    = regexp.MustCompile(`https?://`)
+)
+
+func (p Policy) Check(text string) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("empty draft")
+	}
+	if utf8.RuneCountInString(text) > 280 {
+		return fmt.Errorf("longer than 280 characters")
+	}
+	lower := strings.ToLower(text)
+	for _, w := range p.BannedWords {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" && strings.Contains(lower, w) {
+			return fmt.Errorf("contains banned word %q", w)
+		}
+	}
+	if p.MaxHashtags >= 0 && len(reHashtag.FindAllString(text, -1)) > p.MaxHashtags {
+		return fmt.Errorf("more than %d hashtags", p.MaxHashtags)
+	}
+	if !p.AllowLinks && reLink.MatchString(text) {
+		return fmt.Errorf("contains a link")
+	}
+	return nil
+}
+
+var reWord = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+func wordSet(s string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, w := range reWord.FindAllString(strings.ToLower(s), -1) {
+		set[w] = struct{}{}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for w := range a {
+		if _, ok := b[w]; ok {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,154 @@
+package rank
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	p := Policy{BannedWords: []string{" Crypto "}, MaxHashtags: 2}
+	tests := []struct {
+		name   string
+		policy Policy
+		text   string
+		want   string // substring of the error, "" for none
+	}{
+		{"ok", p, "Ship small changes often. #devops", ""},
+		{"empty", p, "  \n", "empty draft"},
+		{"too long", p, strings.Repeat("a", 281), "longer than 280"},
+		{"exactly 280 runes", p, strings.Repeat("é", 280), ""},
+		{"banned word any case", p, "Why CRYPTO needs SRE", `banned word "crypto"`},
+		{"blank banned word ignored", Policy{BannedWords: []string{" "}, MaxHashtags: -1}, "fine", ""},
+		{"two hashtags", p, "#k8s and #gitops", ""},
+		{"three hashtags", p, "#k8s #gitops #sre", "more than 2 hashtags"},
+		{"fragment is not a hashtag", p, "see issue#12 #a #b", ""},
+		{"negative max lifts the limit", Policy{MaxHashtags: -1}, "#a #b #c #d", ""},
+		{"link", p, "read https://example.com", "contains a link"},
+		{"link allowed", Policy{MaxHashtags: 2, AllowLinks: true}, "read https://example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.text)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("Check(%q) = %v, want nil", tt.text, err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Fatalf("Check(%q) = %v, want %q", tt.text, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		ideal int
+		text  string
+		want  float64
+	}{
+		{10, strings.Repeat("a", 10), 1},
+		{10, strings.Repeat("a", 5), 0.5},
+		{10, strings.Repeat("a", 15), 0.5},
+		{10, strings.Repeat("a", 30), 0},
+		{10, strings.Repeat("ü", 10), 1}, // runes, not bytes
+		{0, strings.Repeat("a", 180), 1}, // default ideal
+	}
+	for _, tt := range tests {
+		got, err := Length{Ideal: tt.ideal}.Score(context.Background(), tt.text)
+		if err != nil || !near(got, tt.want) {
+			t.Errorf("Length{%d}.Score(%d runes) = %v, %v, want %v", tt.ideal, len([]rune(tt.text)), got, err, tt.want)
+		}
+	}
+}
+
+func TestNovelty(t *testing.T) {
+	past := func(context.Context) []string {
+		return []string{"Kubernetes operators are just controllers", "Write the runbook first"}
+	}
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{"kubernetes operators are just controllers!", 0},
+		{"Write the runbook first, then automate", 1 - 4.0/6},
+		{"Terraform state belongs in a locked backend", 1},
+	}
+	for _, tt := range tests {
+		got, err := Novelty{Past: past}.Score(context.Background(), tt.text)
+		if err != nil || !near(got, tt.want) {
+			t.Errorf("Novelty.Score(%q) = %v, %v, want %v", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestHookClamps(t *testing.T) {
+	for _, tt := range []struct{ judged, want float64 }{{1.7, 1}, {-0.2, 0}, {0.4, 0.4}} {
+		h := Hook{Judge: func(context.Context, string) (float64, error) { return tt.judged, nil }}
+		if got, _ := h.Score(context.Background(), "x"); !near(got, tt.want) {
+			t.Errorf("Hook judged %v = %v, want %v", tt.judged, got, tt.want)
+		}
+	}
+}
+
+// fixed scores every draft by a lookup, failing for drafts it doesn't know.
+type fixed struct {
+	name   string
+	scores map[string]float64
+}
+
+func (f fixed) Name() string { return f.name }
+
+func (f fixed) Score(_ context.Context, text string) (float64, error) {
+	v, ok := f.scores[text]
+	if !ok {
+		return 0, errors.New("no score")
+	}
+	return v, nil
+}
+
+func TestRank(t *testing.T) {
+	a := fixed{"a", map[string]float64{"low": 0.2, "high": 0.9, "half": 1}}
+	b := fixed{"b", map[string]float64{"low": 0.2, "high": 0.9}}
+	r := New(Policy{BannedWords: []string{"spam"}, MaxHashtags: -1},
+		Weighted{a, 1}, Weighted{b, 3}, Weighted{fixed{"off", nil}, 0})
+
+	got := r.Rank(context.Background(), []string{"spam it", "low", "half", "high"})
+
+	want := []struct {
+		text     string
+		score    float64
+		rejected bool
+	}{
+		{"half", 1, false}, // b fails, so only a's weight counts
+		{"high", 0.9, false},
+		{"low", 0.2, false},
+		{"spam it", 0, true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Rank returned %d candidates, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		c := got[i]
+		if c.Text != w.text || !near(c.Score, w.score) || c.Rejected != w.rejected {
+			t.Errorf("candidate %d = %q %v rejected=%v, want %q %v rejected=%v", i, c.Text, c.Score, c.Rejected, w.text, w.score, w.rejected)
+		}
+	}
+	if _, ok := got[0].Scores["off"]; ok {
+		t.Error("a zero-weight scorer was run")
+	}
+	if got[3].Reason == "" {
+		t.Error("rejected candidate has no reason")
+	}
+
+	best, ok := Best(got)
+	if !ok || best.Text != "half" {
+		t.Errorf("Best = %q %v, want half", best.Text, ok)
+	}
+	if _, ok := Best(got[3:]); ok {
+		t.Error("Best picked a rejected candidate")
+	}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
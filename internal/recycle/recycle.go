@@ -0,0 +1,153 @@
+// Package recycle picks content library entries to post again. A share of
+// the scheduled slots is offered to the library, and each goes to the best
+// performing evergreen tweet old enough to have been forgotten, within
+// limits on how soon and how often one entry comes back and how many
+// recycled posts go out a day.
+package recycle
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+)
+
+// Rules are the limits the recycler applies. Zero MaxTimes and DailyCap
+// lift those limits; a zero Share offers it no slots.
+type Rules struct {
+	Share    float64       // fraction of slots offered to the recycler
+	MinAge   time.Duration // how old an entry's original must be
+	Cooldown time.Duration // gap between two recycles of one entry
+	MaxTimes int           // recycles per entry
+	DailyCap int           // recycled posts per day
+}
+
+// NewID returns a random ID for an entry written for the library; entries
+// made from posts use the post ID.
+func NewID() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return "lib-" + hex.EncodeToString(b[:])
+}
+
+// Offered reports whether slot goes to the recycler. The choice is a hash
+// of the slot key, so a retried slot gets the same answer.
+func (r Rules) Offered(slot string) bool {
+	if r.Share <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(slot))
+	return float64(h.Sum32()%1000) < r.Share*1000
+}
+
+// Check returns why e may not be recycled at now, or "" if it may. MinAge
+// applies to entries made from posts; a hand-written one has no original to
+// be remembered.
+func (r Rules) Check(e storage.Evergreen, now time.Time) string {
+	if age := now.Sub(e.At); e.PostID != "" && age < r.MinAge {
+		return fmt.Sprintf("only %d days old, needs %d", days(age), days(r.MinAge))
+	}
+	if r.MaxTimes > 0 && len(e.Recycles) >= r.MaxTimes {
+		return fmt.Sprintf("recycled %d times already", len(e.Recycles))
+	}
+	if n := len(e.Recycles); n > 0 {
+		if since := now.Sub(e.Recycles[n-1].At); since < r.Cooldown {
+			return fmt.Sprintf("recycled %d days ago, cooldown is %d", days(since), days(r.Cooldown))
+		}
+	}
+	return ""
+}
+
+func days(d time.Duration) int { return int(d / (24 * time.Hour)) }
+
+// Candidate is a library entry with how its original performed and whether
+// it may be recycled now.
+type Candidate struct {
+	storage.Evergreen
+	// Metrics is the original post's last snapshot, nil for hand-written
+	// entries and posts never measured.
+	Metrics *storage.PostMetrics `json:"metrics,omitempty"`
+	Score   int                  `json:"score"`
+	Reason  string               `json:"reason,omitempty"` // why it is held back
+}
+
+// Score rates an original post by its engagement; hand-written entries and
+// posts never measured score 0.
+func Score(m *storage.PostMetrics) int {
+	if m == nil {
+		return 0
+	}
+	return m.Likes + 2*m.Replies + 3*(m.Retweets+m.Quotes)
+}
+
+// Rank lists the library with the entries that may be recycled first, best
+// performing first, and entries recycled longer ago first among equals.
+func Rank(ctx context.Context, store storage.Store, rules Rules, now time.Time) ([]Candidate, error) {
+	var out []Candidate
+	err := store.ScanEvergreen(ctx, time.Time{}, time.Time{}, func(e storage.Evergreen) error {
+		c := Candidate{Evergreen: e, Reason: rules.Check(e, now)}
+		if e.PostID != "" {
+			p, err := store.GetPost(ctx, e.PostID)
+			if err != nil {
+				return err
+			}
+			if p != nil {
+				c.Metrics = p.Metrics
+			}
+		}
+		c.Score = Score(c.Metrics)
+		out = append(out, c)
+		return nil
+	})
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if (a.Reason == "") != (b.Reason == "") {
+			return a.Reason == ""
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return last(a.Evergreen).Before(last(b.Evergreen))
+	})
+	return out, err
+}
+
+// last is when e was last recycled, zero if never.
+func last(e storage.Evergreen) time.Time {
+	if len(e.Recycles) == 0 {
+		return time.Time{}
+	}
+	return e.Recycles[len(e.Recycles)-1].At
+}
+
+// Pick returns the entry to recycle at now, or nil when none may be or the
+// recycled posts since dayStart have reached the daily cap.
+func Pick(ctx context.Context, store storage.Store, rules Rules, now, dayStart time.Time) (*storage.Evergreen, error) {
+	cands, err := Rank(ctx, store, rules, now)
+	if err != nil {
+		return nil, err
+	}
+	if rules.DailyCap > 0 {
+		today := 0
+		for _, c := range cands {
+			for _, r := range c.Recycles {
+				if !r.At.Before(dayStart) {
+					today++
+				}
+			}
+		}
+		if today >= rules.DailyCap {
+			return nil, nil
+		}
+	}
+	if len(cands) == 0 || cands[0].Reason != "" {
+		return nil, nil
+	}
+	return &cands[0].Evergreen, nil
+}
@@ -0,0 +1,185 @@
+package recycle
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+)
+
+var now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func daysAgo(n int) time.Time { return now.Add(-time.Duration(n) * 24 * time.Hour) }
+
+func TestOffered(t *testing.T) {
+	for _, share := range []float64{0, -1} {
+		if (Rules{Share: share}).Offered("20240601-0900") {
+			t.Errorf("share %v offered a slot", share)
+		}
+	}
+	offered := 0
+	for i := range 1000 {
+		slot := fmt.Sprintf("202406%02d-%04d", i%28+1, i)
+		if !(Rules{Share: 1}).Offered(slot) {
+			t.Fatalf("share 1 kept %s", slot)
+		}
+		if (Rules{Share: 0.25}).Offered(slot) {
+			offered++
+		}
+		if (Rules{Share: 0.25}).Offered(slot) != (Rules{Share: 0.25}).Offered(slot) {
+			t.Fatalf("%s got two answers", slot)
+		}
+	}
+	if offered < 200 || offered > 300 {
+		t.Errorf("share 0.25 offered %d of 1000 slots", offered)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	rules := Rules{MinAge: 90 * 24 * time.Hour, Cooldown: 60 * 24 * time.Hour, MaxTimes: 2}
+	recycled := func(ages ...int) []storage.Recycle {
+		var rs []storage.Recycle
+		for _, a := range ages {
+			rs = append(rs, storage.Recycle{PostID: "r", At: daysAgo(a)})
+		}
+		return rs
+	}
+	tests := []struct {
+		name  string
+		rules Rules
+		e     storage.Evergreen
+		want  string
+	}{
+		{"post too young", rules, storage.Evergreen{PostID: "p", At: daysAgo(30)}, "only 30 days old, needs 90"},
+		{"post old enough", rules, storage.Evergreen{PostID: "p", At: daysAgo(90)}, ""},
+		{"hand-written need not wait", rules, storage.Evergreen{At: daysAgo(1)}, ""},
+		{"max times reached", rules, storage.Evergreen{At: daysAgo(400), Recycles: recycled(300, 200)}, "recycled 2 times already"},
+		{"no max times", Rules{Cooldown: rules.Cooldown}, storage.Evergreen{At: daysAgo(400), Recycles: recycled(300, 200, 100)}, ""},
+		{"in cooldown", rules, storage.Evergreen{At: daysAgo(400), Recycles: recycled(10)}, "recycled 10 days ago, cooldown is 60"},
+		{"cooldown counts from the last recycle", rules, storage.Evergreen{At: daysAgo(400), Recycles: recycled(61)}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.rules.Check(tt.e, now); got != tt.want {
+			t.Errorf("%s: Check = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// newLibrary returns a store holding, by recycling score:
+//
+//	e2  from p2, 120 days old, 2 likes and 5 replies    12
+//	e1  from p1, 120 days old, 10 likes                 10
+//	e3  hand-written, never recycled                     0
+//	e5  hand-written, recycled 100 days ago              0
+//	e4  from p4, only 30 days old, 100 likes           held back
+func newLibrary(t *testing.T) storage.Store {
+	t.Helper()
+	ctx := context.Background()
+	store, err := storage.Open("sqlite", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	posts := []struct {
+		p storage.Post
+		m storage.PostMetrics
+	}{
+		{storage.Post{ID: "p1", Text: "one", At: daysAgo(120)}, storage.PostMetrics{Likes: 10}},
+		{storage.Post{ID: "p2", Text: "two", At: daysAgo(120)}, storage.PostMetrics{Likes: 2, Replies: 5}},
+		{storage.Post{ID: "p4", Text: "four", At: daysAgo(30)}, storage.PostMetrics{Likes: 100}},
+		{storage.Post{ID: "r5", Text: "five again", At: daysAgo(100), RecycledFrom: "e5"}, storage.PostMetrics{}},
+	}
+	for _, p := range posts {
+		if err := store.RecordPost(ctx, p.p); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetPostMetrics(ctx, p.p.ID, p.m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range []storage.Evergreen{
+		{ID: "e1", PostID: "p1", Text: "one", At: daysAgo(120)},
+		{ID: "e2", PostID: "p2", Text: "two", At: daysAgo(120)},
+		{ID: "e3", Text: "three", At: daysAgo(5)},
+		{ID: "e4", PostID: "p4", Text: "four", At: daysAgo(30)},
+		{ID: "e5", Text: "five", At: daysAgo(200)},
+	} {
+		if err := store.SaveEvergreen(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestRank(t *testing.T) {
+	rules := Rules{MinAge: 90 * 24 * time.Hour, Cooldown: 60 * 24 * time.Hour}
+	cands, err := Rank(context.Background(), newLibrary(t), rules, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		id    string
+		score int
+		held  bool
+	}{{"e2", 12, false}, {"e1", 10, false}, {"e3", 0, false}, {"e5", 0, false}, {"e4", 100, true}}
+	if len(cands) != len(want) {
+		t.Fatalf("Rank returned %d entries, want %d", len(cands), len(want))
+	}
+	for i, w := range want {
+		c := cands[i]
+		if c.ID != w.id || c.Score != w.score || (c.Reason != "") != w.held {
+			t.Errorf("#%d = %s score %d reason %q, want %s score %d held %v", i, c.ID, c.Score, c.Reason, w.id, w.score, w.held)
+		}
+	}
+	if cands[2].Metrics != nil {
+		t.Errorf("hand-written entry has metrics %+v", cands[2].Metrics)
+	}
+}
+
+func TestPick(t *testing.T) {
+	ctx := context.Background()
+	store := newLibrary(t)
+	rules := Rules{MinAge: 90 * 24 * time.Hour, Cooldown: 60 * 24 * time.Hour, DailyCap: 1}
+	dayStart := now.Truncate(24 * time.Hour)
+
+	e, err := Pick(ctx, store, rules, now, dayStart)
+	if err != nil || e == nil || e.ID != "e2" {
+		t.Fatalf("Pick = %+v, %v, want e2", e, err)
+	}
+
+	// e1 recycled this morning spends the day's cap
+	if err := store.RecordPost(ctx, storage.Post{ID: "r1", Text: "one again", At: now.Add(-time.Hour), RecycledFrom: "e1"}); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := Pick(ctx, store, rules, now, dayStart); err != nil || e != nil {
+		t.Fatalf("past the daily cap Pick = %+v, %v, want none", e, err)
+	}
+	rules.DailyCap = 0
+	if e, err := Pick(ctx, store, rules, now, dayStart); err != nil || e == nil || e.ID != "e2" {
+		t.Fatalf("without a cap Pick = %+v, %v, want e2", e, err)
+	}
+	// a recycle before dayStart counts toward yesterday
+	rules.DailyCap = 1
+	if e, err := Pick(ctx, store, rules, now, now); err != nil || e == nil || e.ID != "e2" {
+		t.Fatalf("with yesterday's recycle Pick = %+v, %v, want e2", e, err)
+	}
+
+	rules.MaxTimes = 1 // e5's and e1's single recycle retire them
+	rules.MinAge = 365 * 24 * time.Hour
+	if e, err := Pick(ctx, store, rules, now, now); err != nil || e == nil || e.ID != "e3" {
+		t.Fatalf("with the originals too young Pick = %+v, %v, want the hand-written e3", e, err)
+	}
+}
+
+func TestPickEmpty(t *testing.T) {
+	store, err := storage.Open("sqlite", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if e, err := Pick(context.Background(), store, Rules{Share: 1}, now, now); err != nil || e != nil {
+		t.Fatalf("empty library Pick = %+v, %v, want none", e, err)
+	}
+}
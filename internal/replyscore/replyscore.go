@@ -0,0 +1,329 @@
+// Package replyscore ranks tweets the reply scanner could answer. Each
+// signal rates one aspect of a tweet in [0,1] and says why, so the dashboard
+// can show how a score came about; the total is their weighted average.
+package replyscore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tweet is what the signals know about a candidate.
+type Tweet struct {
+	ID             string    `json:"id"`
+	Text           string    `json:"text"`
+	AuthorID       string    `json:"author_id"`
+	Author         string    `json:"author,omitempty"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Followers      int       `json:"followers"` // -1 when unknown
+	CreatedAt      time.Time `json:"created_at"`
+	Likes          int       `json:"likes"`
+	Retweets       int       `json:"retweets"`
+	Replies        int       `json:"replies"`
+	Quotes         int       `json:"quotes"`
+}
+
+// Age is how long ago t was posted, or 0 if X didn't say.
+func (t Tweet) Age(now time.Time) time.Duration {
+	if t.CreatedAt.IsZero() || now.Before(t.CreatedAt) {
+		return 0
+	}
+	return now.Sub(t.CreatedAt)
+}
+
+// Signal rates one aspect of a tweet in [0,1], with a short explanation.
+type Signal interface {
+	Name() string
+	Score(ctx context.Context, t Tweet, now time.Time) (float64, string, error)
+}
+
+// Weighted pairs a signal with its contribution to the total score.
+type Weighted struct {
+	Signal
+	Weight float64
+}
+
+// Factor is one signal's part in a score.
+type Factor struct {
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+	Weight float64 `json:"weight"`
+	Why    string  `json:"why"`
+}
+
+// Scored is a ranked tweet with the factors behind its score.
+type Scored struct {
+	Tweet    Tweet    `json:"tweet"`
+	Score    float64  `json:"score"`
+	Factors  []Factor `json:"factors"`
+	Rejected bool     `json:"rejected"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// Limits are hard filters; a tweet that fails one is never replied to.
+type Limits struct {
+	MinLikes    int
+	MinRetweets int
+	MaxAge      time.Duration // 0 for no limit
+	MinScore    float64
+}
+
+func (l Limits) check(t Tweet, now time.Time) error {
+	if t.Likes < l.MinLikes {
+		return fmt.Errorf("%d likes, want %d", t.Likes, l.MinLikes)
+	}
+	if t.Retweets < l.MinRetweets {
+		return fmt.Errorf("%d retweets, want %d", t.Retweets, l.MinRetweets)
+	}
+	if age := t.Age(now); l.MaxAge > 0 && age > l.MaxAge {
+		return fmt.Errorf("posted %s ago, max %s", roundAge(age), roundAge(l.MaxAge))
+	}
+	return nil
+}
+
+// Engine ranks tweets by the weighted average of its signals, after the
+// limits have ruled some out.
+type Engine struct {
+	limits  Limits
+	signals []Weighted
+}
+
+// New returns an engine applying limits and signals. Signals with a weight
+// of zero or less are not consulted.
+func New(limits Limits, signals ...Weighted) *Engine {
+	return &Engine{limits: limits, signals: signals}
+}
+
+// Rank scores every tweet and returns them best first. Tweets that fail the
+// limits are kept (so the UI can show why) but sorted last.
+func (e *Engine) Rank(ctx context.Context, ts []Tweet, now time.Time) []Scored {
+	out := make([]Scored, 0, len(ts))
+	for _, t := range ts {
+		s := Scored{Tweet: t}
+		if err := e.limits.check(t, now); err != nil {
+			s.Rejected = true
+			s.Reason = err.Error()
+			out = append(out, s)
+			continue
+		}
+		var total, weights float64
+		for _, sig := range e.signals {
+			if sig.Weight <= 0 {
+				continue
+			}
+			v, why, err := sig.Score(ctx, t, now)
+			if err != nil {
+				// a failing signal shouldn't sink the tweet; skip its weight
+				s.Factors = append(s.Factors, Factor{Name: sig.Name(), Why: "unavailable: " + err.Error()})
+				continue
+			}
+			v = clamp01(v)
+			s.Factors = append(s.Factors, Factor{Name: sig.Name(), Value: v, Weight: sig.Weight, Why: why})
+			total += v * sig.Weight
+			weights += sig.Weight
+		}
+		if weights > 0 {
+			s.Score = total / weights
+		}
+		if s.Score < e.limits.MinScore {
+			s.Rejected = true
+			s.Reason = fmt.Sprintf("score %.2f below %.2f", s.Score, e.limits.MinScore)
+		}
+		out = append(out, s)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Rejected != out[j].Rejected {
+			return !out[i].Rejected
+		}
+		return out[i].Score > out[j].Score
+	})
+	return out
+}
+
+// Explain renders a score's factors on one line, for logs and the CLI.
+func (s Scored) Explain() string {
+	parts := make([]string, 0, len(s.Factors)+1)
+	if s.Rejected {
+		parts = append(parts, "rejected: "+s.Reason)
+	}
+	for _, f := range s.Factors {
+		parts = append(parts, fmt.Sprintf("%s %.2f×%g (%s)", f.Name, f.Value, f.Weight, f.Why))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Freshness halves a tweet's score every HalfLife: replies to old threads
+// are rarely seen.
+type Freshness struct {
+	HalfLife time.Duration
+}
+
+func (Freshness) Name() string { return "freshness" }
+
+func (f Freshness) Score(_ context.Context, t Tweet, now time.Time) (float64, string, error) {
+	if t.CreatedAt.IsZero() {
+		return 0.5, "posting time unknown", nil
+	}
+	age := t.Age(now)
+	return math.Exp2(-age.Hours() / f.HalfLife.Hours()), "posted " + roundAge(age) + " ago", nil
+}
+
+// Velocity prefers tweets gaining engagement fast, saturating around
+// Target interactions an hour.
+type Velocity struct {
+	Target float64
+}
+
+func (Velocity) Name() string { return "velocity" }
+
+func (v Velocity) Score(_ context.Context, t Tweet, now time.Time) (float64, string, error) {
+	eng := float64(t.Likes + 2*t.Retweets + t.Replies + t.Quotes)
+	// a tweet under ten minutes old hasn't had time to show a rate
+	hours := math.Max(t.Age(now).Hours(), 1.0/6)
+	rate := eng / hours
+	return rate / (rate + v.Target), fmt.Sprintf("%.0f interactions/h", rate), nil
+}
+
+// Audience prefers authors with between Min and Max followers: big enough
+// that a reply is seen, small enough that it isn't lost among thousands.
+type Audience struct {
+	Min, Max int
+}
+
+func (Audience) Name() string { return "audience" }
+
+func (a Audience) Score(_ context.Context, t Tweet, _ time.Time) (float64, string, error) {
+	n := t.Followers
+	band := fmt.Sprintf("band %s–%s", count(a.Min), count(a.Max))
+	switch {
+	case n < 0:
+		return 0.5, "follower count unknown", nil
+	case n < a.Min:
+		return float64(n) / float64(a.Min), fmt.Sprintf("%s followers, below %s", count(n), band), nil
+	case a.Max > 0 && n > a.Max:
+		// fall off by decade past the band
+		return clamp01(1 - math.Log10(float64(n)/float64(a.Max))), fmt.Sprintf("%s followers, above %s", count(n), band), nil
+	}
+	return 1, fmt.Sprintf("%s followers, in %s", count(n), band), nil
+}
+
+// Saturation penalizes tweets that already have many replies, reaching zero
+// at Limit.
+type Saturation struct {
+	Limit int
+}
+
+func (Saturation) Name() string { return "saturation" }
+
+func (s Saturation) Score(_ context.Context, t Tweet, _ time.Time) (float64, string, error) {
+	return 1 - float64(t.Replies)/float64(s.Limit), fmt.Sprintf("%d replies already", t.Replies), nil
+}
+
+// Relevance counts topic keywords in the tweet, full marks at three. A
+// keyword matches whole words only, so "eks" doesn't hit "weeks"; one of
+// several words ("platform engineering", "ci/cd") matches them in a row.
+type Relevance struct {
+	Keywords func() []string
+}
+
+func (Relevance) Name() string { return "relevance" }
+
+func (r Relevance) Score(_ context.Context, t Tweet, _ time.Time) (float64, string, error) {
+	text := words(t.Text)
+	var hits []string
+	seen := map[string]bool{}
+	for _, k := range r.Keywords() {
+		k = strings.ToLower(k)
+		if seen[k] || !containsRun(text, words(k)) {
+			continue
+		}
+		seen[k] = true
+		hits = append(hits, k)
+	}
+	if len(hits) == 0 {
+		return 0, "no topic keywords", nil
+	}
+	return float64(len(hits)) / 3, "mentions " + strings.Join(hits, ", "), nil
+}
+
+var reWord = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// words splits s into lower-case words, dropping punctuation and the # of
+// hashtags.
+func words(s string) []string {
+	return reWord.FindAllString(strings.ToLower(s), -1)
+}
+
+// containsRun reports whether run occurs in text as consecutive words.
+func containsRun(text, run []string) bool {
+	if len(run) == 0 {
+		return false
+	}
+	for i := 0; i+len(run) <= len(text); i++ {
+		if slices.Equal(text[i:i+len(run)], run) {
+			return true
+		}
+	}
+	return false
+}
+
+// Following favors authors we already follow, whose audience overlaps ours.
+type Following struct {
+	IDs func(ctx context.Context) (map[string]bool, error)
+}
+
+func (Following) Name() string { return "following" }
+
+func (f Following) Score(ctx context.Context, t Tweet, _ time.Time) (float64, string, error) {
+	ids, err := f.IDs(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+	if ids[t.AuthorID] {
+		return 1, "we follow the author", nil
+	}
+	return 0, "we don't follow the author", nil
+}
+
+// roundAge prints d to the minute, or to the hour past a day.
+func roundAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	if h := int(d.Hours()) % 24; h > 0 {
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, h)
+	}
+	return fmt.Sprintf("%dd", int(d.Hours())/24)
+}
+
+// count prints n compactly: 950, 12.3k, 4.1M.
+func count(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e6), ".0") + "M"
+	case n >= 1_000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e3), ".0") + "k"
+	}
+	return fmt.Sprint(n)
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,203 @@
+package replyscore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelevance(t *testing.T) {
+	r := Relevance{Keywords: func() []string {
+		return []string{"EKS", "aks", "opa", "logs", "iac", "cni", "kubernetes", "platform engineering", "ci/cd", "", "Kubernetes"}
+	}}
+	tests := []struct {
+		text   string
+		score  float64
+		reason string
+	}{
+		// substrings of longer words are not keywords
+		{"Two weeks of geeks talking", 0, "no topic keywords"},
+		{"It breaks when she speaks", 0, "no topic keywords"},
+		{"An opaque maniac of a technician reading blogs", 0, "no topic keywords"},
+		{"engineering a platform", 0, "no topic keywords"},
+
+		{"Moving from AKS to EKS this week", 2.0 / 3, "mentions eks, aks"},
+		{"#Kubernetes logs, everywhere!", 2.0 / 3, "mentions logs, kubernetes"},
+		{"Platform  Engineering is just IaC with a roadmap", 2.0 / 3, "mentions iac, platform engineering"},
+		{"Our CI/CD runs OPA before the CNI upgrade", 1, "mentions opa, cni, ci/cd"},
+	}
+	for _, tt := range tests {
+		score, reason, err := r.Score(context.Background(), Tweet{Text: tt.text}, time.Time{})
+		if err != nil || score != tt.score || reason != tt.reason {
+			t.Errorf("Score(%q) = %v %q %v, want %v %q", tt.text, score, reason, err, tt.score, tt.reason)
+		}
+	}
+}
+
+// fixed scores each tweet by ID, or fails with err.
+type fixed struct {
+	name string
+	by   map[string]float64
+	err  error
+}
+
+func (f fixed) Name() string { return f.name }
+
+func (f fixed) Score(_ context.Context, t Tweet, _ time.Time) (float64, string, error) {
+	if f.err != nil {
+		return 0, "", f.err
+	}
+	return f.by[t.ID], "fixed", nil
+}
+
+func all(v float64, ids ...string) map[string]float64 {
+	m := map[string]float64{}
+	for _, id := range ids {
+		m[id] = v
+	}
+	return m
+}
+
+func TestRank(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	type want struct {
+		id     string
+		score  float64
+		reason string // empty when not rejected
+	}
+	tests := []struct {
+		name    string
+		limits  Limits
+		signals []Weighted
+		tweets  []Tweet
+		want    []want
+		factors int // factors on the first result
+	}{
+		{
+			name:    "limits reject",
+			limits:  Limits{MinLikes: 10, MinRetweets: 2},
+			signals: []Weighted{{fixed{name: "a", by: all(0.5, "ok", "few", "rt")}, 1}},
+			tweets:  []Tweet{{ID: "few", Likes: 5, Retweets: 2}, {ID: "rt", Likes: 10, Retweets: 1}, {ID: "ok", Likes: 10, Retweets: 2}},
+			want:    []want{{"ok", 0.5, ""}, {"few", 0, "5 likes, want 10"}, {"rt", 0, "1 retweets, want 2"}},
+			factors: 1,
+		},
+		{
+			name:    "too old",
+			limits:  Limits{MaxAge: 2 * time.Hour},
+			signals: []Weighted{{fixed{name: "a", by: all(1, "old", "new")}, 1}},
+			tweets:  []Tweet{{ID: "old", CreatedAt: now.Add(-3 * time.Hour)}, {ID: "new", CreatedAt: now.Add(-time.Hour)}},
+			want:    []want{{"new", 1, ""}, {"old", 0, "posted 3h00m ago, max 2h00m"}},
+			factors: 1,
+		},
+		{
+			name:    "rejected sort last, the rest best first",
+			limits:  Limits{MinScore: 0.5},
+			signals: []Weighted{{fixed{name: "a", by: map[string]float64{"lo": 0.2, "mid": 0.6, "hi": 0.9}}, 1}},
+			tweets:  []Tweet{{ID: "lo"}, {ID: "mid"}, {ID: "hi"}},
+			want:    []want{{"hi", 0.9, ""}, {"mid", 0.6, ""}, {"lo", 0.2, "score 0.20 below 0.50"}},
+			factors: 1,
+		},
+		{
+			name:    "weighted average",
+			signals: []Weighted{{fixed{name: "a", by: all(1, "t")}, 3}, {fixed{name: "b", by: all(0, "t")}, 1}},
+			tweets:  []Tweet{{ID: "t"}},
+			want:    []want{{"t", 0.75, ""}},
+			factors: 2,
+		},
+		{
+			name:    "unavailable signal's weight skipped",
+			signals: []Weighted{{fixed{name: "a", by: all(1, "t")}, 1}, {fixed{name: "b", err: errors.New("boom")}, 3}},
+			tweets:  []Tweet{{ID: "t"}},
+			want:    []want{{"t", 1, ""}},
+			factors: 2,
+		},
+		{
+			name:    "zero weight not consulted",
+			signals: []Weighted{{fixed{name: "a", by: all(0.4, "t")}, 1}, {fixed{name: "b", err: errors.New("boom")}, 0}},
+			tweets:  []Tweet{{ID: "t"}},
+			want:    []want{{"t", 0.4, ""}},
+			factors: 1,
+		},
+		{
+			name:    "values clamped",
+			signals: []Weighted{{fixed{name: "a", by: all(1.5, "t")}, 1}, {fixed{name: "b", by: all(-0.5, "t")}, 1}},
+			tweets:  []Tweet{{ID: "t"}},
+			want:    []want{{"t", 0.5, ""}},
+			factors: 2,
+		},
+		{
+			name:    "all signals unavailable",
+			limits:  Limits{MinScore: 0.1},
+			signals: []Weighted{{fixed{name: "a", err: errors.New("boom")}, 1}},
+			tweets:  []Tweet{{ID: "t"}},
+			want:    []want{{"t", 0, "score 0.00 below 0.10"}},
+			factors: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(tt.limits, tt.signals...).Rank(context.Background(), tt.tweets, now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d results, want %d", len(got), len(tt.want))
+			}
+			for i, w := range tt.want {
+				g := got[i]
+				if g.Tweet.ID != w.id || math.Abs(g.Score-w.score) > 1e-9 || g.Rejected != (w.reason != "") || g.Reason != w.reason {
+					t.Errorf("[%d] = %s %.2f rejected=%v %q, want %s %.2f %q", i, g.Tweet.ID, g.Score, g.Rejected, g.Reason, w.id, w.score, w.reason)
+				}
+			}
+			if n := len(got[0].Factors); n != tt.factors {
+				t.Errorf("first result has %d factors, want %d: %s", n, tt.factors, got[0].Explain())
+			}
+			for _, f := range got[0].Factors {
+				if f.Value < 0 || f.Value > 1 {
+					t.Errorf("factor %s = %v, want it clamped to [0,1]", f.Name, f.Value)
+				}
+				if strings.HasPrefix(f.Why, "unavailable: ") && f.Weight != 0 {
+					t.Errorf("unavailable factor %s kept weight %v", f.Name, f.Weight)
+				}
+			}
+		})
+	}
+}
+
+func TestSignals(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+	tests := []struct {
+		name   string
+		sig    Signal
+		tweet  Tweet
+		score  float64
+		reason string
+	}{
+		{"fresh", Freshness{HalfLife: 2 * time.Hour}, Tweet{CreatedAt: now}, 1, "posted <1m ago"},
+		{"one half-life", Freshness{HalfLife: 2 * time.Hour}, Tweet{CreatedAt: ago(2 * time.Hour)}, 0.5, "posted 2h00m ago"},
+		{"two half-lives", Freshness{HalfLife: 2 * time.Hour}, Tweet{CreatedAt: ago(4 * time.Hour)}, 0.25, "posted 4h00m ago"},
+		{"posting time unknown", Freshness{HalfLife: 2 * time.Hour}, Tweet{}, 0.5, "posting time unknown"},
+
+		{"at target", Velocity{Target: 10}, Tweet{Likes: 10, CreatedAt: ago(time.Hour)}, 0.5, "10 interactions/h"},
+		{"retweets count double", Velocity{Target: 10}, Tweet{Retweets: 4, Replies: 1, Quotes: 1, CreatedAt: ago(2 * time.Hour)}, 5.0 / 15, "5 interactions/h"},
+		{"young tweet rated over ten minutes", Velocity{Target: 10}, Tweet{Likes: 5, CreatedAt: ago(time.Minute)}, 0.75, "30 interactions/h"},
+		{"no engagement", Velocity{Target: 10}, Tweet{CreatedAt: ago(time.Hour)}, 0, "0 interactions/h"},
+
+		{"followers unknown", Audience{Min: 1000, Max: 100_000}, Tweet{Followers: -1}, 0.5, "follower count unknown"},
+		{"below band", Audience{Min: 1000, Max: 100_000}, Tweet{Followers: 250}, 0.25, "250 followers, below band 1k–100k"},
+		{"in band", Audience{Min: 1000, Max: 100_000}, Tweet{Followers: 12_300}, 1, "12.3k followers, in band 1k–100k"},
+		{"a decade above", Audience{Min: 1000, Max: 100_000}, Tweet{Followers: 1_000_000}, 0, "1M followers, above band 1k–100k"},
+		{"half a decade above", Audience{Min: 1000, Max: 100_000}, Tweet{Followers: 316_228}, 0.5, "316.2k followers, above band 1k–100k"},
+
+		{"no replies", Saturation{Limit: 20}, Tweet{}, 1, "0 replies already"},
+		{"some replies", Saturation{Limit: 20}, Tweet{Replies: 5}, 0.75, "5 replies already"},
+		{"at limit", Saturation{Limit: 20}, Tweet{Replies: 20}, 0, "20 replies already"},
+	}
+	for _, tt := range tests {
+		score, reason, err := tt.sig.Score(context.Background(), tt.tweet, now)
+		if err != nil || math.Abs(score-tt.score) > 1e-6 || reason != tt.reason {
+			t.Errorf("%s %s: got %v %q %v, want %v %q", tt.sig.Name(), tt.name, score, reason, err, tt.score, tt.reason)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+// Retries spaces out attempts at slots whose last attempt failed. Each
+// failure doubles the wait before the next attempt, from Base up to Max, and
+// a slot is given up after Attempts failures. It is safe for concurrent use.
+type Retries struct {
+	Base     time.Duration
+	Max      time.Duration
+	Attempts int
+
+	mu    sync.Mutex
+	slots map[string]retry
+}
+
+type retry struct {
+	failures int
+	next     time.Time
+}
+
+// Due reports whether the slot key may be attempted at now.
+func (r *Retries) Due(key string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.slots[key]
+	return s.failures < r.Attempts && !now.Before(s.next)
+}
+
+// Failed records a failed attempt at key made at now and reports whether
+// that was the last one allowed.
+func (r *Retries) Failed(key string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.slots == nil {
+		r.slots = map[string]retry{}
+	}
+	s := r.slots[key]
+	wait := r.Base << s.failures
+	if wait > r.Max || wait <= 0 {
+		wait = r.Max
+	}
+	s.failures++
+	s.next = now.Add(wait)
+	r.slots[key] = s
+	return s.failures >= r.Attempts
+}
+
+// Failures returns how many attempts at key have failed.
+func (r *Retries) Failures(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.slots[key].failures
+}
+
+// Forget drops what is known about slots not in keep, such as past days'.
+func (r *Retries) Forget(keep []Slot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	live := map[string]bool{}
+	for _, s := range keep {
+		live[s.Key] = true
+	}
+	for key := range r.slots {
+		if !live[key] {
+			delete(r.slots, key)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	r := &Retries{Base: time.Minute, Max: 5 * time.Minute, Attempts: 4}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if !r.Due("a", now) {
+		t.Fatal("a fresh slot is not due")
+	}
+
+	steps := []struct {
+		wait   time.Duration // before the next attempt
+		gaveUp bool
+	}{
+		{time.Minute, false},
+		{2 * time.Minute, false},
+		{4 * time.Minute, false},
+		{0, true}, // the fourth failure is the last
+	}
+	for i, st := range steps {
+		if got := r.Failed("a", now); got != st.gaveUp {
+			t.Fatalf("failure %d: gave up = %v, want %v", i+1, got, st.gaveUp)
+		}
+		if r.Failures("a") != i+1 {
+			t.Fatalf("failure %d: Failures = %d", i+1, r.Failures("a"))
+		}
+		if st.gaveUp {
+			break
+		}
+		if r.Due("a", now.Add(st.wait-time.Second)) {
+			t.Fatalf("failure %d: due before its %v backoff", i+1, st.wait)
+		}
+		now = now.Add(st.wait)
+		if !r.Due("a", now) {
+			t.Fatalf("failure %d: not due after its %v backoff", i+1, st.wait)
+		}
+	}
+	if r.Due("a", now.Add(24*time.Hour)) {
+		t.Fatal("a given-up slot came due again")
+	}
+	if !r.Due("b", now) || r.Failures("b") != 0 {
+		t.Fatal("one slot's failures held back another")
+	}
+
+	r.Forget([]Slot{{Key: "b"}})
+	if r.Failures("a") != 0 || !r.Due("a", now) {
+		t.Fatal("Forget kept a slot not in the plan")
+	}
+}
+
+func TestRetriesCapWait(t *testing.T) {
+	r := &Retries{Base: time.Minute, Max: 3 * time.Minute, Attempts: 10}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for range 6 {
+		r.Failed("a", now)
+	}
+	if r.Due("a", now.Add(3*time.Minute-time.Second)) || !r.Due("a", now.Add(3*time.Minute)) {
+		t.Fatal("backoff grew past Max")
+	}
+}
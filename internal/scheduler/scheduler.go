@@ -7,8 +7,8 @@ import (
 )
 
 type Slot struct {
-	Time time.Time
-	Key  string // yyyymmdd-HHMM
+	Time time.Time `json:"time"`
+	Key  string    `json:"key"` // yyyymmdd-HHMM
 }
 
 func DailyRandomSlots(loc *time.Location, n int, startHHMM, endHHMM string) ([]Slot, error) {
@@ -0,0 +1,76 @@
+// Package secrets looks up credentials by name from the environment, from
+// files named by NAME_FILE variables, or from a Vault-compatible KV store.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNotFound means the provider has no value for the name.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider resolves a secret by its env-style name, e.g. "X_API_KEY".
+type Provider interface {
+	Name() string
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// Env reads the variable itself.
+type Env struct{}
+
+func (Env) Name() string { return "env" }
+
+func (Env) Get(_ context.Context, name string) (string, error) {
+	if v := os.Getenv(name); v != "" {
+		return v, nil
+	}
+	return "", ErrNotFound
+}
+
+// File reads the file named by NAME_FILE, the convention used by Docker and
+// Kubernetes secret mounts. The file is read on every call so rotated
+// contents are picked up.
+type File struct{}
+
+func (File) Name() string { return "file" }
+
+func (File) Get(_ context.Context, name string) (string, error) {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return "", ErrNotFound
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %w", name, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Chain asks each provider in order and returns the first value found.
+type Chain []Provider
+
+func (c Chain) Name() string {
+	names := make([]string, len(c))
+	for i, p := range c {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (c Chain) Get(ctx context.Context, name string) (string, error) {
+	for _, p := range c {
+		v, err := p.Get(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", p.Name(), err)
+		}
+		return v, nil
+	}
+	return "", ErrNotFound
+}
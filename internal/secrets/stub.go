@@ -0,0 +1,76 @@
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Stub is a minimal in-memory Vault KV server for local runs and manual
+// rotation drills. It serves GET /v1/<path> and accepts POST/PUT of
+// {"data":{...}} to replace a secret, bumping its version. Paths with a
+// data/ segment answer like KV v2, others like KV v1.
+type Stub struct {
+	Token string
+
+	mu      sync.Mutex
+	secrets map[string]map[string]string
+	version map[string]int
+}
+
+func NewStub(token string) *Stub {
+	return &Stub{Token: token, secrets: map[string]map[string]string{}, version: map[string]int{}}
+}
+
+// Put replaces the secret at path, e.g. "secret/data/twitter-automation".
+func (s *Stub) Put(path string, data map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[path] = data
+	s.version[path]++
+}
+
+func (s *Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token != "" && r.Header.Get("X-Vault-Token") != s.Token {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+	path, ok := strings.CutPrefix(r.URL.Path, "/v1/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		data, found := s.secrets[path]
+		version := s.version[path]
+		s.mu.Unlock()
+		if !found {
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(path, "/data/") {
+			// a KV v1 mount returns the secret itself
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"data": data, "metadata": map[string]any{"version": version}},
+		})
+	case http.MethodPost, http.MethodPut:
+		var body struct {
+			Data map[string]string `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, `{"errors":["invalid json"]}`, http.StatusBadRequest)
+			return
+		}
+		s.Put(path, body.Data)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Vault reads secrets from one KV path of a Vault-compatible HTTP API. Both
+// KV v1 ({"data":{...}}) and v2 ({"data":{"data":{...}}}) responses are
+// understood; each key in the secret is a name such as "X_API_KEY".
+type Vault struct {
+	Addr  string // e.g. http://127.0.0.1:8200
+	Token string
+	Path  string // e.g. secret/data/twitter-automation
+	HTTP  *http.Client
+
+	mu      sync.Mutex
+	fetched time.Time
+	data    map[string]string
+}
+
+// cacheTTL lets one config load resolve every name with a single request.
+const cacheTTL = 5 * time.Second
+
+func (v *Vault) Name() string { return "vault" }
+
+func (v *Vault) Get(ctx context.Context, name string) (string, error) {
+	data, err := v.read(ctx)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := data[name]; ok && s != "" {
+		return s, nil
+	}
+	return "", ErrNotFound
+}
+
+func (v *Vault) read(ctx context.Context) (map[string]string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.data != nil && time.Since(v.fetched) < cacheTTL {
+		return v.data, nil
+	}
+
+	url := strings.TrimRight(v.Addr, "/") + "/v1/" + strings.TrimLeft(v.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	hc := v.HTTP
+	if hc == nil {
+		hc = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("path %s not found", v.Path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("read %s: %s", v.Path, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", v.Path, err)
+	}
+	fields := body.Data
+	if inner, ok := body.Data["data"]; ok {
+		// KV v2 nests the secret under data.data
+		if err := json.Unmarshal(inner, &fields); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", v.Path, err)
+		}
+	}
+	data := make(map[string]string, len(fields))
+	for k, raw := range fields {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			data[k] = s
+		}
+	}
+	v.data, v.fetched = data, time.Now()
+	return data, nil
+}
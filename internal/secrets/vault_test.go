@@ -0,0 +1,158 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newStub serves a stub seeded with secrets at a KV v1 and a KV v2 path and
+// counts the requests it answers.
+func newStub(t *testing.T) (*Stub, *httptest.Server, *atomic.Int32) {
+	t.Helper()
+	stub := NewStub("dev")
+	stub.Put("kv/twitter-automation", map[string]string{"X_API_KEY": "v1-key"})
+	stub.Put("secret/data/twitter-automation", map[string]string{"X_API_KEY": "v2-key", "EMPTY": ""})
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		stub.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return stub, srv, &hits
+}
+
+func TestVaultRead(t *testing.T) {
+	_, srv, _ := newStub(t)
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		vault *Vault
+		key   string
+		want  string
+		err   string // substring; "not found" means ErrNotFound
+	}{
+		{"kv v1", &Vault{Addr: srv.URL, Token: "dev", Path: "kv/twitter-automation"}, "X_API_KEY", "v1-key", ""},
+		{"kv v2", &Vault{Addr: srv.URL + "/", Token: "dev", Path: "/secret/data/twitter-automation"}, "X_API_KEY", "v2-key", ""},
+		{"missing key", &Vault{Addr: srv.URL, Token: "dev", Path: "kv/twitter-automation"}, "GEMINI_API_KEY", "", "secret not found"},
+		{"empty value", &Vault{Addr: srv.URL, Token: "dev", Path: "secret/data/twitter-automation"}, "EMPTY", "", "secret not found"},
+		{"missing path", &Vault{Addr: srv.URL, Token: "dev", Path: "kv/other"}, "X_API_KEY", "", "path kv/other not found"},
+		{"bad token", &Vault{Addr: srv.URL, Token: "nope", Path: "kv/twitter-automation"}, "X_API_KEY", "", "403"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.vault.Get(ctx, tt.key)
+			if tt.err == "" {
+				if err != nil || got != tt.want {
+					t.Fatalf("Get(%s) = %q, %v, want %q", tt.key, got, err, tt.want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Get(%s) = %q, %v, want an error with %q", tt.key, got, err, tt.err)
+			}
+			if tt.err == "secret not found" && !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get(%s) error %v is not ErrNotFound", tt.key, err)
+			}
+		})
+	}
+}
+
+func TestVaultCache(t *testing.T) {
+	stub, srv, hits := newStub(t)
+	ctx := context.Background()
+	v := &Vault{Addr: srv.URL, Token: "dev", Path: "secret/data/twitter-automation"}
+
+	for _, key := range []string{"X_API_KEY", "X_API_KEY", "EMPTY"} {
+		_, _ = v.Get(ctx, key)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("three lookups made %d requests, want 1", n)
+	}
+
+	stub.Put("secret/data/twitter-automation", map[string]string{"X_API_KEY": "rotated"})
+	if got, _ := v.Get(ctx, "X_API_KEY"); got != "v2-key" {
+		t.Fatalf("within the cache TTL Get = %q, want the cached v2-key", got)
+	}
+
+	// age the cache past its TTL rather than sleeping through it
+	v.mu.Lock()
+	v.fetched = time.Now().Add(-cacheTTL)
+	v.mu.Unlock()
+	if got, _ := v.Get(ctx, "X_API_KEY"); got != "rotated" {
+		t.Fatalf("after the cache TTL Get = %q, want rotated", got)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("made %d requests, want 2", n)
+	}
+}
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRETS_TEST_TOKEN_FILE", path)
+
+	if got, err := (File{}).Get(ctx, "SECRETS_TEST_TOKEN"); err != nil || got != "first" {
+		t.Fatalf("Get = %q, %v, want first", got, err)
+	}
+	if err := os.WriteFile(path, []byte("second"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := (File{}).Get(ctx, "SECRETS_TEST_TOKEN"); got != "second" {
+		t.Fatalf("after rotation Get = %q, want second", got)
+	}
+	if _, err := (File{}).Get(ctx, "SECRETS_TEST_UNSET"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("without NAME_FILE err = %v, want ErrNotFound", err)
+	}
+	t.Setenv("SECRETS_TEST_GONE_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := (File{}).Get(ctx, "SECRETS_TEST_GONE"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("with a missing file err = %v, want a read error", err)
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	_, srv, _ := newStub(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("file-key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	vault := &Vault{Addr: srv.URL, Token: "dev", Path: "secret/data/twitter-automation"}
+	chain := Chain{Env{}, File{}, vault}
+	if got := chain.Name(); got != "env,file,vault" {
+		t.Fatalf("Name = %q", got)
+	}
+
+	t.Setenv("X_API_KEY_FILE", "")
+	t.Setenv("X_API_KEY", "")
+	if got, _ := chain.Get(ctx, "X_API_KEY"); got != "v2-key" {
+		t.Fatalf("with only vault set Get = %q, want v2-key", got)
+	}
+	t.Setenv("X_API_KEY_FILE", path)
+	if got, _ := chain.Get(ctx, "X_API_KEY"); got != "file-key" {
+		t.Fatalf("with a file Get = %q, want file-key ahead of vault", got)
+	}
+	t.Setenv("X_API_KEY", "env-key")
+	if got, _ := chain.Get(ctx, "X_API_KEY"); got != "env-key" {
+		t.Fatalf("with an env var Get = %q, want env-key ahead of the rest", got)
+	}
+
+	if _, err := chain.Get(ctx, "SECRETS_TEST_NOWHERE"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown name err = %v, want ErrNotFound", err)
+	}
+	// a failing provider stops the chain rather than falling through
+	broken := Chain{&Vault{Addr: srv.URL, Token: "nope", Path: "kv/twitter-automation"}, Env{}}
+	if _, err := broken.Get(ctx, "X_API_KEY"); err == nil || !strings.HasPrefix(err.Error(), "vault: ") {
+		t.Fatalf("broken vault err = %v, want a vault error", err)
+	}
+}
@@ -2,10 +2,17 @@ package selector
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
-var topics = [][]string{
+var (
+	mu     sync.RWMutex
+	topics = defaultTopics
+	styles = defaultStyles
+)
+
+var defaultTopics = [][]string{
 	{"Kubernetes", "K8s", "controller runtime", "Ingress", "CNI", "eBPF"},
 	{"CI/CD", "GitHub Actions", "GitLab CI", "Tekton", "Drone", "Argo Workflows"},
 	{"SRE", "SLI/SLO", "error budgets", "incident response", "postmortems"},
@@ -16,7 +23,7 @@ var topics = [][]string{
 	{"Cloud", "GKE", "EKS", "AKS", "serverless", "FinOps"},
 }
 
-var styles = []string{
+var defaultStyles = []string{
 	"punchy, 1-2 lines, no hashtags, use a rhetorical hook",
 	"curious, conversational, one emoji allowed, avoid buzzwords",
 	"mini-tip with a quick example, newline for readability",
@@ -26,11 +33,42 @@ var styles = []string{
 
 func init() { rand.Seed(time.Now().UnixNano()) }
 
-func RandomTopicSet() []string { return topics[rand.Intn(len(topics))] }
-func RandomStyle() string      { return styles[rand.Intn(len(styles))] }
+func RandomTopicSet() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return topics[rand.Intn(len(topics))]
+}
+
+func RandomStyle() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return styles[rand.Intn(len(styles))]
+}
+
+// SetCatalog replaces the topic groups and styles. An empty argument
+// restores the built-in list.
+func SetCatalog(t [][]string, s []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	topics, styles = defaultTopics, defaultStyles
+	if len(t) > 0 {
+		topics = t
+	}
+	if len(s) > 0 {
+		styles = s
+	}
+}
 
 // AllTopics returns the configured topic groups for UI selection.
-func AllTopics() [][]string { return topics }
+func AllTopics() [][]string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return topics
+}
 
 // AllStyles returns the configured styles for UI selection.
-func AllStyles() []string { return styles }
+func AllStyles() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return styles
+}
@@ -0,0 +1,170 @@
+// Package shadow implements dry-run publishing: posts and replies go to a
+// recorder in storage instead of X, so prompts, schedules and reply rules can
+// run against production data without tweeting.
+package shadow
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+	"github.com/UjjavalParmar/twitter-automation/internal/xclient"
+)
+
+// Publisher is the write side of the X client. Like and Repost return the
+// tweet acted on, or a shadow ID when recorded.
+type Publisher interface {
+	PostTweet(ctx context.Context, text string) (string, error)
+	PostTweetWith(ctx context.Context, text string, opts xclient.TweetOptions) (string, error)
+	Reply(ctx context.Context, tweetID, text string) (string, error)
+	QuoteTweet(ctx context.Context, tweetID, text string) (string, error)
+	Like(ctx context.Context, tweetID string) (string, error)
+	Repost(ctx context.Context, tweetID string) (string, error)
+}
+
+const idPrefix = "shadow-"
+
+// IsID reports whether id was issued by a Recorder rather than X.
+func IsID(id string) bool { return strings.HasPrefix(id, idPrefix) }
+
+// Record is a write that would have been published.
+type Record struct {
+	ID        string    `json:"id"`
+	At        time.Time `json:"at"`
+	Account   string    `json:"account"`
+	Kind      string    `json:"kind"` // "post", "reply", "quote", "like" or "repost"
+	Slot      string    `json:"slot,omitempty"`
+	InReplyTo string    `json:"in_reply_to,omitempty"`
+	TweetID   string    `json:"tweet_id,omitempty"` // quoted, liked or reposted
+	Text      string    `json:"text"`
+
+	Poll          *xclient.NewPoll `json:"poll,omitempty"`
+	ReplySettings string           `json:"reply_settings,omitempty"`
+}
+
+type slotKey struct{}
+
+// WithSlot tags ctx with the schedule slot a post is made for.
+func WithSlot(ctx context.Context, slot string) context.Context {
+	return context.WithValue(ctx, slotKey{}, slot)
+}
+
+func slotFrom(ctx context.Context) string {
+	s, _ := ctx.Value(slotKey{}).(string)
+	return s
+}
+
+// Recorder is a Publisher that stores writes instead of sending them.
+type Recorder struct {
+	store   storage.Store
+	account string
+}
+
+func NewRecorder(store storage.Store, account string) *Recorder {
+	return &Recorder{store: store, account: account}
+}
+
+func (r *Recorder) PostTweet(ctx context.Context, text string) (string, error) {
+	return r.record(ctx, Record{Kind: "post", Slot: slotFrom(ctx), Text: text})
+}
+
+// PostTweetWith checks opts as X would, so a dry run refuses what a live
+// post would.
+func (r *Recorder) PostTweetWith(ctx context.Context, text string, opts xclient.TweetOptions) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	return r.record(ctx, Record{Kind: "post", Slot: slotFrom(ctx), Text: text, Poll: opts.Poll, ReplySettings: opts.ReplySettings})
+}
+
+func (r *Recorder) Reply(ctx context.Context, tweetID, text string) (string, error) {
+	return r.record(ctx, Record{Kind: "reply", InReplyTo: tweetID, Text: text})
+}
+
+func (r *Recorder) QuoteTweet(ctx context.Context, tweetID, text string) (string, error) {
+	return r.record(ctx, Record{Kind: "quote", TweetID: tweetID, Text: text})
+}
+
+func (r *Recorder) Like(ctx context.Context, tweetID string) (string, error) {
+	return r.record(ctx, Record{Kind: "like", TweetID: tweetID})
+}
+
+func (r *Recorder) Repost(ctx context.Context, tweetID string) (string, error) {
+	return r.record(ctx, Record{Kind: "repost", TweetID: tweetID})
+}
+
+func (r *Recorder) record(ctx context.Context, rec Record) (string, error) {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	rec.ID = idPrefix + hex.EncodeToString(b[:])
+	rec.At = time.Now().UTC()
+	rec.Account = r.account
+	v, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	if err := r.store.AppendShadow(ctx, rec.At, v); err != nil {
+		return "", err
+	}
+	return rec.ID, nil
+}
+
+// Timeline returns up to limit records since t, newest first.
+func (r *Recorder) Timeline(ctx context.Context, since time.Time, limit int) ([]Record, error) {
+	var out []Record
+	err := r.store.ScanShadow(ctx, since, time.Time{}, func(v []byte) error {
+		var rec Record
+		if err := json.Unmarshal(v, &rec); err != nil {
+			return nil
+		}
+		out = append(out, rec)
+		if limit > 0 && len(out) >= limit {
+			return storage.ErrStop
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Switch routes writes to Shadow while DryRun reports true and to Live
+// otherwise. DryRun is asked on every call so the mode can change at runtime.
+type Switch struct {
+	Live   Publisher
+	Shadow Publisher
+	DryRun func() bool
+}
+
+func (s *Switch) pick() Publisher {
+	if s.DryRun() {
+		return s.Shadow
+	}
+	return s.Live
+}
+
+func (s *Switch) PostTweet(ctx context.Context, text string) (string, error) {
+	return s.pick().PostTweet(ctx, text)
+}
+
+func (s *Switch) PostTweetWith(ctx context.Context, text string, opts xclient.TweetOptions) (string, error) {
+	return s.pick().PostTweetWith(ctx, text, opts)
+}
+
+func (s *Switch) Reply(ctx context.Context, tweetID, text string) (string, error) {
+	return s.pick().Reply(ctx, tweetID, text)
+}
+
+func (s *Switch) QuoteTweet(ctx context.Context, tweetID, text string) (string, error) {
+	return s.pick().QuoteTweet(ctx, tweetID, text)
+}
+
+func (s *Switch) Like(ctx context.Context, tweetID string) (string, error) {
+	return s.pick().Like(ctx, tweetID)
+}
+
+func (s *Switch) Repost(ctx context.Context, tweetID string) (string, error) {
+	return s.pick().Repost(ctx, tweetID)
+}
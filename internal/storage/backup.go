@@ -0,0 +1,435 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Manifest sits next to a backup file as <file>.json and lets a restore check
+// the file is whole before it touches the live database.
+type Manifest struct {
+	Created time.Time `json:"created"`
+	Keys    int       `json:"keys"`
+	Bytes   int64     `json:"bytes"`
+	SHA256  string    `json:"sha256"`
+}
+
+const backupExt = ".bak"
+
+// BackupTo writes an online backup into dir as bot-<time>.bak with its
+// manifest. The file is written under a temporary name and renamed once
+// verified, so a crash never leaves a partial backup that looks complete.
+func (s *Badger) BackupTo(ctx context.Context, dir string) (string, Manifest, error) {
+	_, span := tracer.Start(ctx, "storage.BackupTo")
+	defer span.End()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", Manifest{}, err
+	}
+	now := time.Now().UTC()
+	path := filepath.Join(dir, "bot-"+now.Format("20060102-150405")+backupExt)
+	tmp, err := os.CreateTemp(dir, ".backup-*")
+	if err != nil {
+		return "", Manifest{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := countingCopy(tmp, h, s.Backup)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", Manifest{}, fmt.Errorf("backup: %w", err)
+	}
+	keys, err := countBackupKeys(tmp.Name())
+	if err != nil {
+		return "", Manifest{}, fmt.Errorf("backup unreadable: %w", err)
+	}
+	m := Manifest{Created: now, Keys: keys, Bytes: n, SHA256: hex.EncodeToString(h.Sum(nil))}
+	mb, _ := json.MarshalIndent(m, "", "  ")
+	if err := os.WriteFile(path+".json", mb, 0o644); err != nil {
+		return "", Manifest{}, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", Manifest{}, err
+	}
+	return path, m, nil
+}
+
+// countingCopy runs write into w while hashing into h, returning bytes written.
+func countingCopy(w io.Writer, h io.Writer, write func(io.Writer) error) (int64, error) {
+	cw := &countWriter{w: io.MultiWriter(w, h)}
+	err := write(cw)
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// PruneBackups removes all but the newest keep backups in dir.
+func PruneBackups(dir string, keep int) ([]string, error) {
+	files, err := ListBackups(dir)
+	if err != nil || keep <= 0 || len(files) <= keep {
+		return nil, err
+	}
+	old := files[:len(files)-keep]
+	for _, f := range old {
+		if err := os.Remove(f); err != nil {
+			return nil, err
+		}
+		_ = os.Remove(f + ".json")
+	}
+	return old, nil
+}
+
+// ListBackups returns the backup files in dir, oldest first.
+func ListBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), "bot-") && strings.HasSuffix(e.Name(), backupExt) {
+			out = append(out, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(out) // names embed the UTC time
+	return out, nil
+}
+
+// VerifyBackup checks a backup against its manifest, if there is one, and
+// that it loads into a scratch database. It returns the number of keys.
+func VerifyBackup(path string) (int, error) {
+	var m *Manifest
+	if b, err := os.ReadFile(path + ".json"); err == nil {
+		m = &Manifest{}
+		if err := json.Unmarshal(b, m); err != nil {
+			return 0, fmt.Errorf("manifest: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+
+	if m != nil {
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return 0, err
+		}
+		if n != m.Bytes {
+			return 0, fmt.Errorf("size %d, manifest says %d", n, m.Bytes)
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != m.SHA256 {
+			return 0, fmt.Errorf("checksum %s, manifest says %s", sum, m.SHA256)
+		}
+	}
+
+	keys, err := countBackupKeys(path)
+	if err != nil {
+		return 0, err
+	}
+	if m != nil && keys != m.Keys {
+		return 0, fmt.Errorf("%d keys, manifest says %d", keys, m.Keys)
+	}
+	return keys, nil
+}
+
+// countBackupKeys loads a backup into an in-memory database and counts keys.
+func countBackupKeys(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	opts := badger.DefaultOptions("").WithInMemory(true)
+	opts.Logger = nil
+	db, err := badger.Open(opts)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	if err := db.Load(f, 256); err != nil {
+		return 0, err
+	}
+	n := 0
+	err = db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Restore verifies a backup and loads it into the store.
+func (s *Badger) Restore(path string) (int, error) {
+	keys, err := VerifyBackup(path)
+	if err != nil {
+		return 0, fmt.Errorf("verify %s: %w", path, err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err := s.Load(f); err != nil {
+		return 0, err
+	}
+	return keys, s.Sync()
+}
+
+// Record is one line of a JSONL export. Kind is "post", "reply",
+// "engagement", "seen", "slot" (a posted slot marker), "mention", "draft",
+// "evergreen" (a content library entry) or "usage" (a Gemini usage metric),
+// and the matching field holds the record.
+type Record struct {
+	Kind       string       `json:"kind"`
+	ID         string       `json:"id"`
+	Post       *Post        `json:"post,omitempty"`
+	Reply      *Reply       `json:"reply,omitempty"`
+	Engagement *Engagement  `json:"engagement,omitempty"`
+	Seen       *SeenTweet   `json:"seen,omitempty"`
+	Slot       *SlotMark    `json:"slot,omitempty"`
+	Mention    *Mention     `json:"mention,omitempty"`
+	Draft      *Draft       `json:"draft,omitempty"`
+	Evergreen  *Evergreen   `json:"evergreen,omitempty"`
+	Usage      *UsageRecord `json:"usage,omitempty"`
+
+	// Text and At are the schema 1 export fields, still accepted on import.
+	Text string `json:"text,omitempty"`
+	At   string `json:"at,omitempty"`
+}
+
+// exportKinds maps record kinds to their key prefixes.
+var exportKinds = []struct{ kind, prefix string }{
+	{"post", prefixPost},
+	{"reply", prefixReply},
+	{"engagement", prefixEngagement},
+	{"seen", prefixSeen},
+	{"slot", prefixSlot},
+	{"mention", prefixMention},
+	{"draft", prefixDraft},
+	{"evergreen", prefixEvergreen},
+	{"usage", "usage:"},
+}
+
+// ExportJSONL writes posts, replies, engagements, seen tweets, slot markers,
+// mentions, drafts, library entries and usage records to w, one JSON object
+// per line. It returns the count per kind.
+func (s *Badger) ExportJSONL(ctx context.Context, w io.Writer) (map[string]int, error) {
+	counts := map[string]int{}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	err := s.view(ctx, "ExportJSONL", func(txn *badger.Txn) error {
+		for _, k := range exportKinds {
+			err := exportPrefix(txn, k.kind, k.prefix, func(r Record) error {
+				counts[r.Kind]++
+				return enc.Encode(r)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, bw.Flush()
+}
+
+func exportPrefix(txn *badger.Txn, kind, prefix string, emit func(Record) error) error {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	p := []byte(prefix)
+	for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+		r := Record{Kind: kind, ID: string(it.Item().Key()[len(p):])}
+		err := it.Item().Value(func(v []byte) error {
+			switch kind {
+			case "post":
+				r.Post = &Post{}
+				return decode(v, r.Post)
+			case "reply":
+				r.Reply = &Reply{}
+				return decode(v, r.Reply)
+			case "engagement":
+				r.Engagement = &Engagement{}
+				return decode(v, r.Engagement)
+			case "seen":
+				r.Seen = &SeenTweet{}
+				return decode(v, r.Seen)
+			case "slot":
+				r.Slot = &SlotMark{}
+				return decode(v, r.Slot)
+			case "mention":
+				r.Mention = &Mention{}
+				return decode(v, r.Mention)
+			case "draft":
+				r.Draft = &Draft{}
+				return decode(v, r.Draft)
+			case "evergreen":
+				r.Evergreen = &Evergreen{}
+				return decode(v, r.Evergreen)
+			default:
+				r.Usage = &UsageRecord{}
+				return json.Unmarshal(v, r.Usage)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", kind, r.ID, err)
+		}
+		if err := emit(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportJSONL reads records written by ExportJSONL. Keys are restored as
+// exported, so importing the same file twice changes nothing. Imported keys
+// carry no TTL; the next Sweep dates them from their record time.
+func (s *Badger) ImportJSONL(ctx context.Context, r io.Reader) (map[string]int, error) {
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	counts, err := readJSONL(ctx, r, func(rec Record) error {
+		switch rec.Kind {
+		case "post":
+			return putPostIndexed(wb, *rec.Post)
+		case "reply":
+			return putReplyIndexed(wb, *rec.Reply)
+		case "engagement":
+			return putIndexed(wb, prefixEngagement, "engagement", rec.ID, rec.Engagement.At, rec.Engagement)
+		case "seen":
+			return putIndexed(wb, prefixSeen, "seen", rec.ID, rec.Seen.At, rec.Seen)
+		case "slot":
+			return put(wb, prefixSlot+rec.ID, rec.Slot)
+		case "mention":
+			return putIndexed(wb, prefixMention, "mention", rec.ID, rec.Mention.At, rec.Mention)
+		case "draft":
+			return putIndexed(wb, prefixDraft, "draft", rec.ID, rec.Draft.At, rec.Draft)
+		case "evergreen":
+			rec.Evergreen.Recycles = nil
+			return putIndexed(wb, prefixEvergreen, "evergreen", rec.ID, rec.Evergreen.At, rec.Evergreen)
+		default:
+			v, _ := json.Marshal(rec.Usage)
+			return wb.Set([]byte("usage:"+rec.ID), v)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, wb.Flush()
+}
+
+// readJSONL decodes an export line by line, fills in records from older
+// exports and calls fn with each. It returns the count per kind.
+func readJSONL(ctx context.Context, r io.Reader, fn func(Record) error) (map[string]int, error) {
+	counts := map[string]int{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	line := 0
+	for sc.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		line++
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := rec.normalize(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		counts[rec.Kind]++
+	}
+	return counts, sc.Err()
+}
+
+// normalize checks the record and fills its typed field from the schema 1
+// Text and At fields when an older export left it empty.
+func (rec *Record) normalize() error {
+	if rec.ID == "" {
+		return errors.New("missing id")
+	}
+	switch rec.Kind {
+	case "post":
+		if rec.Post == nil {
+			rec.Post = &Post{ID: rec.ID, Text: rec.Text, At: snowflakeTime(rec.ID)}
+		}
+	case "reply":
+		if rec.Reply == nil {
+			rec.Reply = &Reply{ID: rec.ID, At: snowflakeTime(rec.ID)}
+		}
+	case "seen":
+		if rec.Seen == nil {
+			rec.Seen = &SeenTweet{ID: rec.ID, At: snowflakeTime(rec.ID)}
+		}
+	case "slot":
+		if rec.Slot == nil {
+			at, _ := time.Parse(time.RFC3339, rec.At)
+			rec.Slot = &SlotMark{Key: rec.ID, At: at}
+		}
+	case "engagement":
+		if rec.Engagement == nil {
+			return errors.New("engagement record without engagement")
+		}
+	case "mention":
+		if rec.Mention == nil {
+			return errors.New("mention record without mention")
+		}
+	case "draft":
+		if rec.Draft == nil {
+			return errors.New("draft record without draft")
+		}
+	case "evergreen":
+		if rec.Evergreen == nil {
+			return errors.New("evergreen record without entry")
+		}
+	case "usage":
+		if rec.Usage == nil {
+			return errors.New("usage record without usage")
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", rec.Kind)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	src, err := OpenBadger(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"p1", "p2", "p3"} {
+		if err := src.RecordPost(ctx, Post{ID: id, Text: "text " + id, At: at.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := src.RecordReply(ctx, Reply{ID: "r1", InReplyTo: "t1", Text: "hi", At: at}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	path, m, err := src.BackupTo(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Keys == 0 || m.Bytes == 0 || m.SHA256 == "" {
+		t.Fatalf("manifest = %+v", m)
+	}
+	if keys, err := VerifyBackup(path); err != nil || keys != m.Keys {
+		t.Fatalf("VerifyBackup = %d, %v; want %d", keys, err, m.Keys)
+	}
+
+	dst, err := OpenBadger(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	keys, err := dst.Restore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys != m.Keys {
+		t.Errorf("Restore loaded %d keys, manifest says %d", keys, m.Keys)
+	}
+	for _, c := range []struct {
+		name  string
+		count func(context.Context) (int, error)
+		want  int
+	}{
+		{"posts", dst.CountPosts, 3},
+		{"replies", dst.CountReplies, 1},
+	} {
+		if n, err := c.count(ctx); err != nil || n != c.want {
+			t.Errorf("restored %s = %d, %v; want %d", c.name, n, err, c.want)
+		}
+	}
+	if p, err := dst.GetPost(ctx, "p2"); err != nil || p == nil || p.Text != "text p2" {
+		t.Errorf("restored p2 = %+v, %v", p, err)
+	}
+}
+
+func TestVerifyBackupManifest(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenBadger(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.RecordPost(ctx, Post{ID: "p1", Text: "text", At: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	path, m, err := s.BackupTo(ctx, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(*Manifest)
+		want    string
+	}{
+		{"checksum", func(m *Manifest) { m.SHA256 = strings.Repeat("0", 64) }, "checksum"},
+		{"size", func(m *Manifest) { m.Bytes++ }, "size"},
+		{"keys", func(m *Manifest) { m.Keys++ }, "keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad := m
+			tt.corrupt(&bad)
+			b, _ := json.Marshal(bad)
+			if err := os.WriteFile(path+".json", b, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := VerifyBackup(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("VerifyBackup = %v, want a %s mismatch", err, tt.want)
+			}
+			if _, err := s.Restore(path); err == nil {
+				t.Error("Restore loaded a backup that failed verification")
+			}
+		})
+	}
+
+	if err := os.WriteFile(path+".json", []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyBackup(path); err == nil {
+		t.Error("VerifyBackup accepted an unreadable manifest")
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	var names []string
+	for _, ts := range []string{"20240301-120000", "20240303-120000", "20240302-120000", "20240304-120000"} {
+		name := filepath.Join(dir, "bot-"+ts+backupExt)
+		names = append(names, name)
+		for _, f := range []string{name, name + ".json"} {
+			if err := os.WriteFile(f, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// not backups, left alone
+	for _, f := range []string{"notes.txt", ".backup-123"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if removed, err := PruneBackups(dir, 0); err != nil || removed != nil {
+		t.Fatalf("PruneBackups(keep 0) = %v, %v; want no-op", removed, err)
+	}
+	removed, err := PruneBackups(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{names[0], names[2]}; !slices.Equal(removed, want) {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+	left, err := ListBackups(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{names[1], names[3]}; !slices.Equal(left, want) {
+		t.Errorf("kept %v, want the newest %v", left, want)
+	}
+	for _, f := range removed {
+		if _, err := os.Stat(f + ".json"); !os.IsNotExist(err) {
+			t.Errorf("manifest of %s not removed", f)
+		}
+	}
+	for _, f := range []string{"notes.txt", ".backup-123"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("%s: %v", f, err)
+		}
+	}
+	if removed, err := PruneBackups(dir, 2); err != nil || removed != nil {
+		t.Errorf("second PruneBackups = %v, %v; want nothing to do", removed, err)
+	}
+}
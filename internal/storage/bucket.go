@@ -1,120 +1,317 @@
 package storage
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 )
 
-type Store struct {
-	db *badger.DB
+// Badger is the Store backed by a Badger key-value database.
+type Badger struct {
+	db       *badger.DB
+	migrated []string
+
+	mu        sync.RWMutex // guards retention
+	retention Retention
 }
 
-func Open(dir string) (*Store, error) {
+// OpenBadger opens the Badger database in dir and migrates it to the
+// current schema.
+func OpenBadger(dir string) (*Badger, error) {
 	opts := badger.DefaultOptions(filepath.Join(dir, "bot.db"))
 	opts.Logger = nil
 	db, err := badger.Open(opts)
 	if err != nil {
 		return nil, err
 	}
-	return &Store{db: db}, nil
+	s := &Badger{db: db}
+	if err := s.migrate(context.Background(), 0); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
-func (s *Store) Close() error { return s.db.Close() }
+func (s *Badger) Close() error { return s.db.Close() }
 
-// MarkPosted stores a unique key per day+slot to avoid double posting.
-func (s *Store) MarkPosted(key string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.SetEntry(badger.NewEntry([]byte("posted:"+key), []byte(time.Now().Format(time.RFC3339))))
-	})
+var tracer = otel.Tracer("github.com/UjjavalParmar/twitter-automation/internal/storage")
+
+// update runs fn in a read-write transaction under a span named after op.
+func (s *Badger) update(ctx context.Context, op string, fn func(txn *badger.Txn) error) error {
+	_, span := tracer.Start(ctx, "storage."+op)
+	defer span.End()
+	err := s.db.Update(fn)
+	if err != nil && !errors.Is(err, ErrStop) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
 }
 
-func (s *Store) WasPosted(key string) (bool, error) {
-	var found bool
-	err := s.db.View(func(txn *badger.Txn) error {
-		_, err := txn.Get([]byte("posted:" + key))
-		if err == badger.ErrKeyNotFound {
-			found = false
-			return nil
+// view runs fn in a read-only transaction under a span named after op.
+func (s *Badger) view(ctx context.Context, op string, fn func(txn *badger.Txn) error) error {
+	_, span := tracer.Start(ctx, "storage."+op)
+	defer span.End()
+	err := s.db.View(fn)
+	if err != nil && !errors.Is(err, ErrStop) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// CountPrefix counts keys that start with the provided prefix.
+func (s *Badger) CountPrefix(ctx context.Context, prefix string) (int, error) {
+	count := 0
+	err := s.view(ctx, "CountPrefix", func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		p := []byte(prefix)
+		for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+			count++
 		}
-		if err != nil {
+		return nil
+	})
+	return count, err
+}
+
+// UsageRecord is the token usage of one Gemini call.
+type UsageRecord struct {
+	At               time.Time `json:"at"`
+	Model            string    `json:"model"`
+	Purpose          string    `json:"purpose"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	LatencyMS        int64     `json:"latency_ms"`
+}
+
+// AddUsage appends a usage record keyed by time so ranges can be scanned.
+func (s *Badger) AddUsage(ctx context.Context, u UsageRecord) error {
+	v, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return s.appendTimed(ctx, "usage:", u.At, v)
+}
+
+// UsageSince returns usage records at or after t, oldest first.
+func (s *Badger) UsageSince(ctx context.Context, t time.Time) ([]UsageRecord, error) {
+	var out []UsageRecord
+	err := s.scanTimed(ctx, "usage:", t, time.Time{}, false, func(v []byte) error {
+		var u UsageRecord
+		if err := json.Unmarshal(v, &u); err != nil {
 			return err
 		}
-		found = true
+		out = append(out, u)
 		return nil
 	})
-	return found, err
+	return out, err
+}
+
+// AppendAudit writes an audit entry. There is deliberately no way to update
+// or delete one.
+func (s *Badger) AppendAudit(ctx context.Context, at time.Time, v []byte) error {
+	return s.appendTimed(ctx, "audit:", at, v)
 }
 
-func (s *Store) SeenTweet(id string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte("seen:"+id), []byte("1"))
+// ScanAudit calls fn for audit entries between since and until (zero means
+// unbounded), newest first. Returning ErrStop from fn ends the scan early.
+func (s *Badger) ScanAudit(ctx context.Context, since, until time.Time, fn func(v []byte) error) error {
+	return s.scanTimed(ctx, "audit:", since, until, true, fn)
+}
+
+// AppendShadow writes a dry-run record of a post or reply that was not
+// published.
+func (s *Badger) AppendShadow(ctx context.Context, at time.Time, v []byte) error {
+	return s.appendTimed(ctx, "shadow:", at, v)
+}
+
+// ScanShadow calls fn for dry-run records between since and until, newest
+// first. Returning ErrStop from fn ends the scan early.
+func (s *Badger) ScanShadow(ctx context.Context, since, until time.Time, fn func(v []byte) error) error {
+	return s.scanTimed(ctx, "shadow:", since, until, true, fn)
+}
+
+// ErrStop can be returned from scan callbacks to stop iteration without error.
+var ErrStop = errors.New("stop scan")
+
+func timedKey(prefix string, at time.Time) string {
+	return fmt.Sprintf("%s%020d", prefix, at.UnixNano())
+}
+
+// appendTimed stores v under a time-ordered key; the random suffix keeps
+// concurrent writes in the same nanosecond from colliding.
+func (s *Badger) appendTimed(ctx context.Context, prefix string, at time.Time, v []byte) error {
+	key := fmt.Sprintf("%s-%04d", timedKey(prefix, at), rand.Intn(10000))
+	return s.update(ctx, "AppendTimed", func(txn *badger.Txn) error {
+		return s.expiring(txn).Set([]byte(key), v)
 	})
 }
 
-func (s *Store) IsSeen(id string) (bool, error) {
-	var seen bool
-	err := s.db.View(func(txn *badger.Txn) error {
-		_, err := txn.Get([]byte("seen:" + id))
-		if err == badger.ErrKeyNotFound {
-			seen = false
-			return nil
+func (s *Badger) scanTimed(ctx context.Context, prefix string, since, until time.Time, reverse bool, fn func(v []byte) error) error {
+	err := s.view(ctx, "ScanTimed", func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = reverse
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		p := []byte(prefix)
+		lo := []byte(prefix)
+		if !since.IsZero() {
+			lo = []byte(timedKey(prefix, since))
 		}
-		if err != nil {
-			return err
+		hi := append([]byte(prefix), 0xff)
+		if !until.IsZero() {
+			hi = []byte(timedKey(prefix, until))
+		}
+		start := lo
+		if reverse {
+			start = hi
+		}
+		for it.Seek(start); it.ValidForPrefix(p); it.Next() {
+			k := it.Item().Key()
+			if (reverse && string(k) < string(lo)) || (!reverse && !until.IsZero() && string(k) >= string(hi)) {
+				break
+			}
+			if reverse && !until.IsZero() && string(k) >= string(hi) {
+				continue
+			}
+			if err := it.Item().Value(fn); err != nil {
+				return err
+			}
 		}
-		seen = true
 		return nil
 	})
-	return seen, err
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	return err
 }
 
-// AddPostedID records an ID of a tweet we posted (for stats/metrics).
-func (s *Store) AddPostedID(id string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte("postedid:"+id), []byte("1"))
+// Size reports Badger's LSM and value log sizes in bytes.
+func (s *Badger) Size() (lsm, vlog int64) { return s.db.Size() }
+
+// Ping checks the database accepts writes by writing and removing a probe key.
+func (s *Badger) Ping(ctx context.Context) error {
+	return s.update(ctx, "Ping", func(txn *badger.Txn) error {
+		k := []byte("health:probe")
+		if err := txn.Set(k, []byte(time.Now().Format(time.RFC3339))); err != nil {
+			return err
+		}
+		return txn.Delete(k)
 	})
 }
 
-// AddReplyID records an ID of a reply we posted.
-func (s *Store) AddReplyID(id string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte("replyid:"+id), []byte("1"))
+// Intent marks an X write that has been started but not yet recorded. If the
+// process dies in between, the intent survives and is reconciled on restart.
+type Intent struct {
+	Kind    string    `json:"kind"` // "post", "reply" or "quote"
+	Key     string    `json:"key"`  // slot key for posts, target tweet ID for replies and quotes
+	Text    string    `json:"text"`
+	Started time.Time `json:"started"`
+
+	// AuthorID and ConversationID are the target's, so a reply or quote
+	// recovered by reconcile still counts toward the cooldowns.
+	AuthorID       string `json:"author_id,omitempty"`
+	ConversationID string `json:"conversation_id,omitempty"`
+
+	// Flagged is when reconcile could neither find the write on X nor rule
+	// it out. The intent then stays, holding back a retry, until someone
+	// settles it with "bot db intents".
+	Flagged time.Time `json:"flagged,omitempty"`
+
+	// Topics, Style and RecycledFrom let a post recovered by reconcile keep
+	// its metadata.
+	Topics       []string `json:"topics,omitempty"`
+	Style        string   `json:"style,omitempty"`
+	RecycledFrom string   `json:"recycled_from,omitempty"`
+}
+
+func intentKey(kind, key string) []byte { return []byte("intent:" + kind + ":" + key) }
+
+// BeginIntent records that an X write is about to happen.
+func (s *Badger) BeginIntent(ctx context.Context, in Intent) error {
+	v, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, "BeginIntent", func(txn *badger.Txn) error {
+		return txn.Set(intentKey(in.Kind, in.Key), v)
 	})
 }
 
-// CountPrefix counts keys that start with the provided prefix.
-func (s *Store) CountPrefix(prefix string) (int, error) {
-	count := 0
-	err := s.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-		p := []byte(prefix)
-		for it.Seek(p); it.ValidForPrefix(p); it.Next() {
-			count++
+// ClearIntent drops an intent once its write is known to have failed.
+func (s *Badger) ClearIntent(ctx context.Context, kind, key string) error {
+	return s.update(ctx, "ClearIntent", func(txn *badger.Txn) error {
+		return txn.Delete(intentKey(kind, key))
+	})
+}
+
+// HasIntent reports whether an unresolved intent exists.
+func (s *Badger) HasIntent(ctx context.Context, kind, key string) (bool, error) {
+	var found bool
+	err := s.view(ctx, "HasIntent", func(txn *badger.Txn) error {
+		_, err := txn.Get(intentKey(kind, key))
+		if err == badger.ErrKeyNotFound {
+			return nil
 		}
-		return nil
+		found = err == nil
+		return err
 	})
-	return count, err
+	return found, err
 }
 
-// ListIDs lists keys by prefix and returns the suffix (ID) values up to limit.
-func (s *Store) ListIDs(prefix string, limit int) ([]string, error) {
-	var ids []string
-	err := s.db.View(func(txn *badger.Txn) error {
+// Intents lists unresolved intents.
+func (s *Badger) Intents(ctx context.Context) ([]Intent, error) {
+	var out []Intent
+	err := s.view(ctx, "Intents", func(txn *badger.Txn) error {
 		it := txn.NewIterator(badger.DefaultIteratorOptions)
 		defer it.Close()
-		p := []byte(prefix)
+		p := []byte("intent:")
 		for it.Seek(p); it.ValidForPrefix(p); it.Next() {
-			if limit > 0 && len(ids) >= limit {
-				break
+			var in Intent
+			if err := it.Item().Value(func(v []byte) error { return json.Unmarshal(v, &in) }); err != nil {
+				return err
 			}
-			item := it.Item()
-			k := item.Key()
-			ids = append(ids, string(k[len(p):]))
+			out = append(out, in)
 		}
 		return nil
 	})
-	return ids, err
+	return out, err
+}
+
+// Sync flushes pending writes to disk.
+func (s *Badger) Sync() error { return s.db.Sync() }
+
+// Backup writes a full backup of the database to w.
+func (s *Badger) Backup(w io.Writer) error {
+	_, err := s.db.Backup(w, 0)
+	return err
+}
+
+// Load restores a backup written by Backup, overwriting matching keys, and
+// migrates whatever older-schema keys it brought in.
+func (s *Badger) Load(r io.Reader) error {
+	if err := s.db.Load(r, 256); err != nil {
+		return err
+	}
+	return s.migrate(context.Background(), 1)
+}
+
+// Compact merges the LSM tree and reclaims value log space.
+func (s *Badger) Compact() error {
+	if err := s.db.Flatten(2); err != nil {
+		return fmt.Errorf("flatten: %w", err)
+	}
+	_, err := s.GC(0.5)
+	return err
 }
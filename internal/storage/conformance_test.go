@@ -0,0 +1,19 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+	"github.com/UjjavalParmar/twitter-automation/internal/storage/storagetest"
+)
+
+// TestConformance runs the storagetest suite against every backend.
+func TestConformance(t *testing.T) {
+	for _, backend := range storage.Backends {
+		t.Run(backend, func(t *testing.T) {
+			storagetest.Run(t, func(dir string) (storage.Store, error) {
+				return storage.Open(backend, dir)
+			})
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Migration upgrades the database from Version-1 to Version. Up must be
+// idempotent: restoring an older backup into a current database runs every
+// migration again over whatever legacy keys it brought in.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(db *badger.DB) error
+}
+
+// migrations are applied in order on Open. Version 1 is the original
+// layout of bare "1" and timestamp values.
+var migrations = []Migration{
+	{Version: 2, Name: "typed-records", Up: migrateTypedRecords},
+}
+
+// SchemaVersion is the version a fully migrated database reports.
+func SchemaVersion() int { return migrations[len(migrations)-1].Version }
+
+const schemaKey = "meta/schema"
+
+// Migrated returns the names of migrations applied when the store opened.
+func (s *Badger) Migrated() []string { return s.migrated }
+
+// Version returns the database's schema version.
+func (s *Badger) Version(ctx context.Context) (int, error) {
+	var v int
+	err := s.view(ctx, "Version", func(txn *badger.Txn) error {
+		var err error
+		v, err = readVersion(txn)
+		return err
+	})
+	return v, err
+}
+
+func readVersion(txn *badger.Txn) (int, error) {
+	item, err := txn.Get([]byte(schemaKey))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var v int
+	err = item.Value(func(b []byte) error {
+		v, err = strconv.Atoi(string(b))
+		return err
+	})
+	return v, err
+}
+
+// migrate brings the schema up to date. from overrides the stored version
+// when positive, to re-run migrations after loading external data.
+func (s *Badger) migrate(ctx context.Context, from int) error {
+	_, span := tracer.Start(ctx, "storage.migrate")
+	defer span.End()
+
+	cur := from
+	if cur <= 0 {
+		v, err := s.Version(ctx)
+		if err != nil {
+			return err
+		}
+		cur = v
+	}
+	if cur == 0 {
+		// no version: an empty database starts current, anything else
+		// predates versioning
+		empty := true
+		err := s.db.View(func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.IteratorOptions{})
+			defer it.Close()
+			it.Rewind()
+			empty = !it.Valid()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		cur = 1
+		if empty {
+			cur = SchemaVersion()
+		}
+	}
+	for _, m := range migrations {
+		if m.Version <= cur {
+			continue
+		}
+		if err := m.Up(s.db); err != nil {
+			return fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+		s.migrated = append(s.migrated, m.Name)
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(schemaKey), []byte(strconv.Itoa(SchemaVersion())))
+	})
+}
+
+// snowflakeTime is when a tweet ID was minted, or zero for other IDs.
+func snowflakeTime(id string) time.Time {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli((n >> 22) + 1288834974657)
+}
+
+// migrateTypedRecords moves the schema 1 keys (postedid:, posttext:,
+// replyid:, seen:, posted:, plan:) to typed records with time indexes.
+func migrateTypedRecords(db *badger.DB) error {
+	type kv struct{ k, v []byte }
+	legacy := map[string][]kv{}
+	prefixes := []string{"postedid:", "posttext:", "replyid:", "seen:", "posted:", "plan:"}
+	err := db.View(func(txn *badger.Txn) error {
+		for _, p := range prefixes {
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			for it.Seek([]byte(p)); it.ValidForPrefix([]byte(p)); it.Next() {
+				v, err := it.Item().ValueCopy(nil)
+				if err != nil {
+					it.Close()
+					return err
+				}
+				legacy[p] = append(legacy[p], kv{it.Item().KeyCopy(nil), v})
+			}
+			it.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	at := func(id string) time.Time {
+		if t := snowflakeTime(id); !t.IsZero() {
+			return t
+		}
+		return now
+	}
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+	set := func(key string, v any) error {
+		b, err := encode(v)
+		if err != nil {
+			return err
+		}
+		return wb.Set([]byte(key), b)
+	}
+	index := func(kind, id string, t time.Time) error {
+		return wb.Set(indexKey(kind, t, id), nil)
+	}
+
+	// posts: postedid: holds the IDs, posttext: the texts
+	posts := map[string]*Post{}
+	for _, e := range legacy["postedid:"] {
+		id := string(e.k[len("postedid:"):])
+		posts[id] = &Post{ID: id, At: at(id)}
+	}
+	for _, e := range legacy["posttext:"] {
+		id := string(e.k[len("posttext:"):])
+		if posts[id] == nil {
+			posts[id] = &Post{ID: id, At: at(id)}
+		}
+		posts[id].Text = string(e.v)
+	}
+	for id, p := range posts {
+		if err := set(prefixPost+id, p); err != nil {
+			return err
+		}
+		if err := index("post", id, p.At); err != nil {
+			return err
+		}
+	}
+	for _, e := range legacy["replyid:"] {
+		id := string(e.k[len("replyid:"):])
+		r := Reply{ID: id, At: at(id)}
+		if err := set(prefixReply+id, r); err != nil {
+			return err
+		}
+		if err := index("reply", id, r.At); err != nil {
+			return err
+		}
+	}
+	for _, e := range legacy["seen:"] {
+		id := string(e.k[len("seen:"):])
+		t := at(id)
+		if err := set(prefixSeen+id, SeenTweet{ID: id, At: t}); err != nil {
+			return err
+		}
+		if err := index("seen", id, t); err != nil {
+			return err
+		}
+	}
+	for _, e := range legacy["posted:"] {
+		key := string(e.k[len("posted:"):])
+		t, _ := time.Parse(time.RFC3339, string(e.v))
+		if err := set(prefixSlot+key, SlotMark{Key: key, At: t}); err != nil {
+			return err
+		}
+	}
+	for _, e := range legacy["plan:"] {
+		day := string(e.k[len("plan:"):])
+		var old []struct {
+			Time time.Time `json:"time"`
+			Key  string    `json:"key"`
+		}
+		if err := json.Unmarshal(e.v, &old); err != nil {
+			continue // unreadable plans are redrawn
+		}
+		p := SlotPlan{Day: day}
+		for _, s := range old {
+			p.Slots = append(p.Slots, PlanSlot{Key: s.Key, At: s.Time})
+		}
+		b, err := encode(p)
+		if err != nil {
+			return err
+		}
+		if err := wb.SetEntry(badger.NewEntry([]byte(prefixPlan+day), b).WithTTL(planTTL)); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range prefixes {
+		for _, e := range legacy[p] {
+			if err := wb.Delete(e.k); err != nil {
+				return err
+			}
+		}
+	}
+	return wb.Flush()
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// TestMigrateTypedRecords upgrades a schema 1 database and checks that
+// opening it again, or running the migration again, changes nothing.
+func TestMigrateTypedRecords(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	const post, reply, seen = "1764000000000000000", "1764000000000000001", "1764000000000000002"
+
+	opts := badger.DefaultOptions(filepath.Join(dir, "bot.db"))
+	opts.Logger = nil
+	db, err := badger.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		for k, v := range map[string]string{
+			"postedid:" + post:     "1",
+			"posttext:" + post:     "hello & welcome",
+			"replyid:" + reply:     "1",
+			"seen:" + seen:         "1",
+			"posted:20240301-0900": "2024-03-01T09:00:00Z",
+			"plan:20240301":        `[{"time":"2024-03-01T09:00:00Z","key":"20240301-0900"}]`,
+			"plan:20240302":        `not json`,
+		} {
+			if err := txn.Set([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenBadger(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Migrated(); !reflect.DeepEqual(got, []string{"typed-records"}) {
+		t.Fatalf("Migrated = %v, want [typed-records]", got)
+	}
+	if v, err := s.Version(ctx); err != nil || v != SchemaVersion() {
+		t.Fatalf("Version = %d, %v, want %d", v, err, SchemaVersion())
+	}
+
+	p, err := s.GetPost(ctx, post)
+	if err != nil || p == nil || p.Text != "hello & welcome" || !p.At.Equal(snowflakeTime(post)) {
+		t.Fatalf("GetPost = %+v, %v", p, err)
+	}
+	var posts, replies []string
+	_ = s.ScanPosts(ctx, time.Time{}, time.Time{}, func(p Post) error { posts = append(posts, p.ID); return nil })
+	_ = s.ScanReplies(ctx, time.Time{}, time.Time{}, func(r Reply) error { replies = append(replies, r.ID); return nil })
+	if !reflect.DeepEqual(posts, []string{post}) || !reflect.DeepEqual(replies, []string{reply}) {
+		t.Fatalf("indexed posts %v, replies %v", posts, replies)
+	}
+	if ok, err := s.IsSeen(ctx, seen); err != nil || !ok {
+		t.Fatalf("IsSeen = %v, %v", ok, err)
+	}
+	if ok, err := s.WasPosted(ctx, "20240301-0900"); err != nil || !ok {
+		t.Fatalf("WasPosted = %v, %v", ok, err)
+	}
+	plan, err := s.LoadPlan(ctx, "20240301")
+	if err != nil || plan == nil || len(plan.Slots) != 1 || plan.Slots[0].Key != "20240301-0900" {
+		t.Fatalf("LoadPlan = %+v, %v", plan, err)
+	}
+	if plan, err := s.LoadPlan(ctx, "20240302"); err != nil || plan != nil {
+		t.Fatalf("unreadable plan = %+v, %v, want it dropped", plan, err)
+	}
+	for kind, want := range map[string]int{"post": 1, "reply": 1, "seen": 1} {
+		if n, _ := s.CountPrefix(ctx, prefixIndex+kind+"/"); n != want {
+			t.Errorf("%d %s index entries, want %d", n, kind, want)
+		}
+	}
+	for _, prefix := range []string{"postedid:", "posttext:", "replyid:", "seen:", "posted:", "plan:"} {
+		if n, _ := s.CountPrefix(ctx, prefix); n != 0 {
+			t.Errorf("%d legacy %s keys left", n, prefix)
+		}
+	}
+
+	before := dump(t, s.db)
+	if err := migrateTypedRecords(s.db); err != nil {
+		t.Fatal(err)
+	}
+	if after := dump(t, s.db); !reflect.DeepEqual(after, before) {
+		t.Fatalf("running the migration again changed the database:\nbefore %v\nafter  %v", before, after)
+	}
+	s.Close()
+
+	s, err = OpenBadger(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got := s.Migrated(); len(got) != 0 {
+		t.Fatalf("reopening migrated again: %v", got)
+	}
+	if after := dump(t, s.db); !reflect.DeepEqual(after, before) {
+		t.Fatalf("reopening changed the database:\nbefore %v\nafter  %v", before, after)
+	}
+}
+
+func dump(t *testing.T, db *badger.DB) map[string]string {
+	t.Helper()
+	out := map[string]string{}
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			out[string(it.Item().Key())] = string(v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
@@ -0,0 +1,914 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Key layout (schema 2):
+//
+//	post/<id>                  Post
+//	reply/<id>                 Reply
+//	seen/<tweet id>            SeenTweet
+//	slot/<slot key>            SlotMark, the posted-slot dedupe marker
+//	plan/<yyyymmdd>            SlotPlan
+//	idx/<kind>/<unix nano>/<id>  time index for post, reply and seen
+//	idx/author/<author id>/<unix nano>/<reply id>
+//	idx/conversation/<conversation id>/<unix nano>/<reply id>
+//	                           replies by who and where we replied
+//	mention/<tweet id>         Mention, indexed under idx/mention/
+//	engagement/<kind>/<tweet id>  Engagement, indexed under idx/engagement/
+//	draft/<id>                 Draft, indexed under idx/draft/ by target time
+//	evergreen/<id>             Evergreen, indexed under idx/evergreen/
+//	idx/recycled/<evergreen id>/<unix nano>/<post id>
+//	                           posts recycled from a library entry
+//	checkpoint/<name>          poller position, such as the newest mention
+//	meta/schema                schema version
+//
+// Values are a one-byte codec tag followed by the payload, so the encoding
+// can change without rewriting keys. The timed logs (audit:, usage:,
+// shadow:) and intents keep their own layout.
+const (
+	prefixPost  = "post/"
+	prefixReply = "reply/"
+	prefixSeen  = "seen/"
+	prefixSlot  = "slot/"
+	prefixPlan  = "plan/"
+	prefixIndex = "idx/"
+
+	prefixMention    = "mention/"
+	prefixCheckpoint = "checkpoint/"
+	prefixEngagement = "engagement/"
+	prefixDraft      = "draft/"
+	prefixEvergreen  = "evergreen/"
+)
+
+const codecJSON byte = 1
+
+// planTTL is how long a stored slot plan outlives its day's draw.
+const planTTL = 72 * time.Hour
+
+func encode(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{codecJSON}, b...), nil
+}
+
+func decode(b []byte, v any) error {
+	if len(b) == 0 || b[0] != codecJSON {
+		return fmt.Errorf("unknown record encoding")
+	}
+	return json.Unmarshal(b[1:], v)
+}
+
+// Post is a tweet we published.
+type Post struct {
+	ID      string       `json:"id"`
+	Text    string       `json:"text"`
+	Topics  []string     `json:"topics,omitempty"`
+	Style   string       `json:"style,omitempty"`
+	Slot    string       `json:"slot,omitempty"`
+	At      time.Time    `json:"at"`
+	Metrics *PostMetrics `json:"metrics,omitempty"`
+
+	Poll          *Poll  `json:"poll,omitempty"`
+	ReplySettings string `json:"reply_settings,omitempty"` // who may reply, empty for everyone
+
+	// RecycledFrom is the content library entry this post rephrases.
+	RecycledFrom string `json:"recycled_from,omitempty"`
+}
+
+// recycledKey is p's entry among the posts recycled from its library entry.
+func (p Post) recycledKey() []byte {
+	return indexKey("recycled/"+p.RecycledFrom, p.At, p.ID)
+}
+
+// putPostIndexed stores p with its time index entry and, for a recycled
+// post, its entry under the library entry it came from.
+func putPostIndexed(txn setter, p Post) error {
+	if err := putIndexed(txn, prefixPost, "post", p.ID, p.At, p); err != nil {
+		return err
+	}
+	if p.RecycledFrom == "" {
+		return nil
+	}
+	return txn.Set(p.recycledKey(), nil)
+}
+
+// Poll is the poll attached to a post.
+type Poll struct {
+	Options         []string `json:"options"`
+	DurationMinutes int      `json:"duration_minutes"`
+}
+
+// PostMetrics is the last engagement snapshot read from X.
+type PostMetrics struct {
+	Likes    int       `json:"likes"`
+	Replies  int       `json:"replies"`
+	Retweets int       `json:"retweets"`
+	Quotes   int       `json:"quotes"`
+	Updated  time.Time `json:"updated"`
+
+	// PollVotes are the votes per poll option, in option order, and
+	// PollStatus is "open" or "closed"; both are empty without a poll.
+	PollVotes  []int  `json:"poll_votes,omitempty"`
+	PollStatus string `json:"poll_status,omitempty"`
+}
+
+// Reply is a reply we published. AuthorID and ConversationID, of the tweet
+// replied to, let the reply scanner throttle itself per author and thread.
+type Reply struct {
+	ID             string    `json:"id"`
+	InReplyTo      string    `json:"in_reply_to,omitempty"`
+	AuthorID       string    `json:"author_id,omitempty"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Text           string    `json:"text,omitempty"`
+	At             time.Time `json:"at"`
+}
+
+// Keys LastReplyTo looks replies up by.
+const (
+	ByAuthor       = "author"
+	ByConversation = "conversation"
+)
+
+// interactionKeys are r's entries in the author and conversation indexes.
+func (r Reply) interactionKeys() [][]byte {
+	var ks [][]byte
+	if r.AuthorID != "" {
+		ks = append(ks, indexKey(ByAuthor+"/"+r.AuthorID, r.At, r.ID))
+	}
+	if r.ConversationID != "" {
+		ks = append(ks, indexKey(ByConversation+"/"+r.ConversationID, r.At, r.ID))
+	}
+	return ks
+}
+
+// putReplyIndexed stores r with its time and interaction index entries.
+func putReplyIndexed(txn setter, r Reply) error {
+	if err := putIndexed(txn, prefixReply, "reply", r.ID, r.At, r); err != nil {
+		return err
+	}
+	for _, k := range r.interactionKeys() {
+		if err := txn.Set(k, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SeenTweet is a tweet the reply scanner has handled.
+type SeenTweet struct {
+	ID      string    `json:"id"`
+	At      time.Time `json:"at"`
+	ReplyID string    `json:"reply_id,omitempty"`
+}
+
+// Mention is a tweet mentioning our account and what the inbox did with
+// it. At is when it was posted; Updated when its status last changed.
+type Mention struct {
+	ID             string    `json:"id"`
+	AuthorID       string    `json:"author_id,omitempty"`
+	Author         string    `json:"author,omitempty"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Text           string    `json:"text"`
+	At             time.Time `json:"at"`
+	Class          string    `json:"class,omitempty"`
+	Status         string    `json:"status"`
+	Draft          string    `json:"draft,omitempty"`
+	ReplyID        string    `json:"reply_id,omitempty"`
+	Note           string    `json:"note,omitempty"`
+	Updated        time.Time `json:"updated"`
+}
+
+// Engagement is a quote, like or repost of someone else's tweet. Replies
+// are stored as Reply, since the interaction limits read them.
+type Engagement struct {
+	Kind           string    `json:"kind"` // "quote", "like" or "repost"
+	TweetID        string    `json:"tweet_id"`
+	ResultID       string    `json:"result_id,omitempty"` // our quote tweet
+	AuthorID       string    `json:"author_id,omitempty"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Text           string    `json:"text,omitempty"`
+	Score          float64   `json:"score,omitempty"`
+	At             time.Time `json:"at"`
+}
+
+// Key identifies e among engagements: one of each kind per tweet.
+func (e Engagement) Key() string { return e.Kind + "/" + e.TweetID }
+
+// Draft is a hand-written tweet on the content calendar. At is the time to
+// publish it; TZ is the zone it was planned in, for showing it back the way
+// it was entered.
+type Draft struct {
+	ID      string    `json:"id"`
+	Account string    `json:"account"`
+	Text    string    `json:"text"`
+	At      time.Time `json:"at"`
+	TZ      string    `json:"tz"`
+	Status  string    `json:"status"`
+	PostID  string    `json:"post_id,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Author  string    `json:"author,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// Evergreen is a tweet in the content library, worth posting again in new
+// words: one of our posts, whose ID it shares and which PostID names, or
+// text written for the library. At is when the original was posted or the
+// entry written.
+type Evergreen struct {
+	ID     string    `json:"id"`
+	PostID string    `json:"post_id,omitempty"`
+	Text   string    `json:"text"`
+	Topics []string  `json:"topics,omitempty"`
+	Style  string    `json:"style,omitempty"`
+	Author string    `json:"author,omitempty"`
+	At     time.Time `json:"at"`
+
+	// Recycles are the posts made from this entry, oldest first. They are
+	// read from the posts' RecycledFrom, so saving an entry ignores them
+	// and removing one and adding it back keeps its history.
+	Recycles []Recycle `json:"recycles,omitempty"`
+}
+
+// Recycle is a post rephrased from a library entry.
+type Recycle struct {
+	PostID string    `json:"post_id"`
+	Text   string    `json:"text"`
+	At     time.Time `json:"at"`
+}
+
+// SlotMark records that a slot was filled, so it is never posted twice.
+type SlotMark struct {
+	Key    string    `json:"key"`
+	PostID string    `json:"post_id,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// SlotPlan is one day's drawn post slots.
+type SlotPlan struct {
+	Day   string     `json:"day"`
+	Slots []PlanSlot `json:"slots"`
+}
+
+type PlanSlot struct {
+	Key string    `json:"key"`
+	At  time.Time `json:"at"`
+}
+
+func indexKey(kind string, at time.Time, id string) []byte {
+	return []byte(fmt.Sprintf("%s%s/%020d/%s", prefixIndex, kind, at.UnixNano(), id))
+}
+
+// setter is a transaction or write batch.
+type setter interface {
+	Set(key, val []byte) error
+}
+
+// putIndexed stores v under prefix+id and adds it to the kind's time index.
+func putIndexed(txn setter, prefix, kind, id string, at time.Time, v any) error {
+	b, err := encode(v)
+	if err != nil {
+		return err
+	}
+	if err := txn.Set([]byte(prefix+id), b); err != nil {
+		return err
+	}
+	return txn.Set(indexKey(kind, at, id), nil)
+}
+
+// reindex drops the index entry of an existing record at prefix+id when it
+// is about to be rewritten with a different time.
+func reindex(txn *badger.Txn, prefix, kind, id string, at time.Time) error {
+	var old struct {
+		At time.Time `json:"at"`
+	}
+	found, err := get(txn, prefix+id, &old)
+	if err != nil || !found || old.At.Equal(at) {
+		return err
+	}
+	return txn.Delete(indexKey(kind, old.At, id))
+}
+
+func put(txn setter, key string, v any) error {
+	b, err := encode(v)
+	if err != nil {
+		return err
+	}
+	return txn.Set([]byte(key), b)
+}
+
+// get decodes key into v, reporting false if it doesn't exist.
+func get(txn *badger.Txn, key string, v any) (bool, error) {
+	item, err := txn.Get([]byte(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, item.Value(func(b []byte) error { return decode(b, v) })
+}
+
+func exists(txn *badger.Txn, key string) (bool, error) {
+	_, err := txn.Get([]byte(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// scanIndex walks the kind's time index between since and until (zero means
+// open) and calls fn with each ID, newest first when reverse is set.
+func scanIndex(txn *badger.Txn, kind string, since, until time.Time, reverse bool, fn func(id string) error) error {
+	p := []byte(prefixIndex + kind + "/")
+	opts := badger.IteratorOptions{Reverse: reverse}
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	lo := p
+	if !since.IsZero() {
+		lo = []byte(fmt.Sprintf("%s%020d", p, since.UnixNano()))
+	}
+	hi := append(append([]byte(nil), p...), 0xff)
+	if !until.IsZero() {
+		hi = []byte(fmt.Sprintf("%s%020d", p, until.UnixNano()))
+	}
+	start := lo
+	if reverse {
+		start = hi
+	}
+	for it.Seek(start); it.ValidForPrefix(p); it.Next() {
+		k := string(it.Item().Key())
+		if (reverse && k < string(lo)) || (!reverse && k >= string(hi)) {
+			break
+		}
+		if reverse && k >= string(hi) {
+			continue
+		}
+		// idx/<kind>/<20 digit nano>/<id>
+		if err := fn(k[len(p)+21:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkPosted stores a unique key per day+slot to avoid double posting.
+func (s *Badger) MarkPosted(ctx context.Context, key, postID string) error {
+	return s.update(ctx, "MarkPosted", func(txn *badger.Txn) error {
+		return put(s.expiring(txn), prefixSlot+key, SlotMark{Key: key, PostID: postID, At: time.Now()})
+	})
+}
+
+func (s *Badger) WasPosted(ctx context.Context, key string) (bool, error) {
+	var found bool
+	err := s.view(ctx, "WasPosted", func(txn *badger.Txn) (err error) {
+		found, err = exists(txn, prefixSlot+key)
+		return err
+	})
+	return found, err
+}
+
+// MarkSeen records that the reply scanner handled a tweet.
+func (s *Badger) MarkSeen(ctx context.Context, id string) error {
+	return s.update(ctx, "MarkSeen", func(txn *badger.Txn) error {
+		now := time.Now()
+		if err := reindex(txn, prefixSeen, "seen", id, now); err != nil {
+			return err
+		}
+		return putIndexed(s.expiring(txn), prefixSeen, "seen", id, now, SeenTweet{ID: id, At: now})
+	})
+}
+
+func (s *Badger) IsSeen(ctx context.Context, id string) (bool, error) {
+	var seen bool
+	err := s.view(ctx, "IsSeen", func(txn *badger.Txn) (err error) {
+		seen, err = exists(txn, prefixSeen+id)
+		return err
+	})
+	return seen, err
+}
+
+// RecordPost stores a successful post in one transaction: the record, its
+// time index entry and, if p.Slot is set, the slot marker and removal of the
+// slot's intent.
+func (s *Badger) RecordPost(ctx context.Context, p Post) error {
+	if p.At.IsZero() {
+		p.At = time.Now()
+	}
+	return s.update(ctx, "RecordPost", func(txn *badger.Txn) error {
+		var old Post
+		found, err := get(txn, prefixPost+p.ID, &old)
+		if err != nil {
+			return err
+		}
+		if found && !old.At.Equal(p.At) {
+			if err := txn.Delete(indexKey("post", old.At, old.ID)); err != nil {
+				return err
+			}
+		}
+		if found && old.RecycledFrom != "" {
+			if err := txn.Delete(old.recycledKey()); err != nil {
+				return err
+			}
+		}
+		if err := putPostIndexed(txn, p); err != nil {
+			return err
+		}
+		if p.Slot == "" {
+			return nil
+		}
+		if err := put(s.expiring(txn), prefixSlot+p.Slot, SlotMark{Key: p.Slot, PostID: p.ID, At: p.At}); err != nil {
+			return err
+		}
+		return txn.Delete(intentKey("post", p.Slot))
+	})
+}
+
+// RecordReply stores a successful reply, marks its target seen and resolves
+// the intent.
+func (s *Badger) RecordReply(ctx context.Context, r Reply) error {
+	if r.At.IsZero() {
+		r.At = time.Now()
+	}
+	return s.update(ctx, "RecordReply", func(txn *badger.Txn) error {
+		var old Reply
+		found, err := get(txn, prefixReply+r.ID, &old)
+		if err != nil {
+			return err
+		}
+		if found {
+			keys := append(old.interactionKeys(), indexKey("reply", old.At, old.ID))
+			for _, k := range keys {
+				if err := txn.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		if err := putReplyIndexed(txn, r); err != nil {
+			return err
+		}
+		if r.InReplyTo == "" {
+			return nil
+		}
+		if err := reindex(txn, prefixSeen, "seen", r.InReplyTo, r.At); err != nil {
+			return err
+		}
+		seen := SeenTweet{ID: r.InReplyTo, At: r.At, ReplyID: r.ID}
+		if err := putIndexed(s.expiring(txn), prefixSeen, "seen", r.InReplyTo, r.At, seen); err != nil {
+			return err
+		}
+		return txn.Delete(intentKey("reply", r.InReplyTo))
+	})
+}
+
+// GetPost returns the post with id, or nil if there is none.
+func (s *Badger) GetPost(ctx context.Context, id string) (*Post, error) {
+	var p Post
+	var found bool
+	err := s.view(ctx, "GetPost", func(txn *badger.Txn) (err error) {
+		found, err = get(txn, prefixPost+id, &p)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetPostMetrics attaches an engagement snapshot to a stored post.
+func (s *Badger) SetPostMetrics(ctx context.Context, id string, m PostMetrics) error {
+	return s.update(ctx, "SetPostMetrics", func(txn *badger.Txn) error {
+		var p Post
+		found, err := get(txn, prefixPost+id, &p)
+		if err != nil || !found {
+			return err
+		}
+		p.Metrics = &m
+		return put(txn, prefixPost+id, p)
+	})
+}
+
+// ScanPosts calls fn with posts published in [since, until), newest first.
+// Zero times leave that end open. Return ErrStop from fn to stop early.
+func (s *Badger) ScanPosts(ctx context.Context, since, until time.Time, fn func(Post) error) error {
+	err := s.view(ctx, "ScanPosts", func(txn *badger.Txn) error {
+		return scanIndex(txn, "post", since, until, true, func(id string) error {
+			var p Post
+			found, err := get(txn, prefixPost+id, &p)
+			if err != nil || !found {
+				return err
+			}
+			return fn(p)
+		})
+	})
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	return err
+}
+
+// ScanReplies calls fn with replies published in [since, until), newest
+// first.
+func (s *Badger) ScanReplies(ctx context.Context, since, until time.Time, fn func(Reply) error) error {
+	err := s.view(ctx, "ScanReplies", func(txn *badger.Txn) error {
+		return scanIndex(txn, "reply", since, until, true, func(id string) error {
+			var r Reply
+			found, err := get(txn, prefixReply+id, &r)
+			if err != nil || !found {
+				return err
+			}
+			return fn(r)
+		})
+	})
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	return err
+}
+
+// LastReplyTo returns our newest reply to an author or in a conversation,
+// by ByAuthor or ByConversation, or nil if there is none.
+func (s *Badger) LastReplyTo(ctx context.Context, by, key string) (*Reply, error) {
+	if by != ByAuthor && by != ByConversation {
+		return nil, fmt.Errorf("LastReplyTo: unknown key %q", by)
+	}
+	var last *Reply
+	err := s.view(ctx, "LastReplyTo", func(txn *badger.Txn) error {
+		return scanIndex(txn, by+"/"+key, time.Time{}, time.Time{}, true, func(id string) error {
+			var r Reply
+			found, err := get(txn, prefixReply+id, &r)
+			if err != nil || !found {
+				return err
+			}
+			last = &r
+			return ErrStop
+		})
+	})
+	if errors.Is(err, ErrStop) {
+		err = nil
+	}
+	return last, err
+}
+
+// RecentPosts returns up to limit posts, newest first.
+func (s *Badger) RecentPosts(ctx context.Context, limit int) ([]Post, error) {
+	var out []Post
+	err := s.ScanPosts(ctx, time.Time{}, time.Time{}, func(p Post) error {
+		if limit > 0 && len(out) >= limit {
+			return ErrStop
+		}
+		out = append(out, p)
+		return nil
+	})
+	return out, err
+}
+
+// RecentPostTexts returns up to limit post texts, newest first.
+func (s *Badger) RecentPostTexts(ctx context.Context, limit int) ([]string, error) {
+	posts, err := s.RecentPosts(ctx, limit)
+	out := make([]string, len(posts))
+	for i, p := range posts {
+		out[i] = p.Text
+	}
+	return out, err
+}
+
+// TopPosts returns up to limit posts published since the given time,
+// filtered to topic when it is set, with the most liked first and replies
+// breaking ties and newer posts first among equals.
+func (s *Badger) TopPosts(ctx context.Context, since time.Time, topic string, limit int) ([]Post, error) {
+	var out []Post
+	err := s.ScanPosts(ctx, since, time.Time{}, func(p Post) error {
+		if topic == "" || hasTopic(p, topic) {
+			out = append(out, p)
+		}
+		return nil
+	})
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].metrics(), out[j].metrics()
+		if a.Likes != b.Likes {
+			return a.Likes > b.Likes
+		}
+		return a.Replies > b.Replies
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, err
+}
+
+// metrics returns the post's metrics, zero if none have been read.
+func (p Post) metrics() PostMetrics {
+	if p.Metrics == nil {
+		return PostMetrics{}
+	}
+	return *p.Metrics
+}
+
+func hasTopic(p Post, topic string) bool {
+	for _, t := range p.Topics {
+		if strings.EqualFold(t, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// CountPosts and CountReplies count stored records.
+func (s *Badger) CountPosts(ctx context.Context) (int, error) {
+	return s.CountPrefix(ctx, prefixPost)
+}
+
+func (s *Badger) CountReplies(ctx context.Context) (int, error) {
+	return s.CountPrefix(ctx, prefixReply)
+}
+
+// SavePlan stores the day's slot plan so restarts and one-off commands see
+// the same slots the scheduler is working through.
+func (s *Badger) SavePlan(ctx context.Context, p SlotPlan) error {
+	b, err := encode(p)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, "SavePlan", func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry([]byte(prefixPlan+p.Day), b).WithTTL(planTTL))
+	})
+}
+
+// LoadPlan returns the stored plan for day, or nil if there is none.
+func (s *Badger) LoadPlan(ctx context.Context, day string) (*SlotPlan, error) {
+	var p SlotPlan
+	var found bool
+	err := s.view(ctx, "LoadPlan", func(txn *badger.Txn) (err error) {
+		found, err = get(txn, prefixPlan+day, &p)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveMention stores m, replacing any earlier version of it.
+func (s *Badger) SaveMention(ctx context.Context, m Mention) error {
+	if m.Updated.IsZero() {
+		m.Updated = time.Now()
+	}
+	return s.update(ctx, "SaveMention", func(txn *badger.Txn) error {
+		if err := reindex(txn, prefixMention, "mention", m.ID, m.At); err != nil {
+			return err
+		}
+		return putIndexed(txn, prefixMention, "mention", m.ID, m.At, m)
+	})
+}
+
+// GetMention returns the mention with id, or nil if there is none.
+func (s *Badger) GetMention(ctx context.Context, id string) (*Mention, error) {
+	var m Mention
+	var found bool
+	err := s.view(ctx, "GetMention", func(txn *badger.Txn) (err error) {
+		found, err = get(txn, prefixMention+id, &m)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ScanMentions calls fn with mentions posted in [since, until), newest
+// first.
+func (s *Badger) ScanMentions(ctx context.Context, since, until time.Time, fn func(Mention) error) error {
+	err := s.view(ctx, "ScanMentions", func(txn *badger.Txn) error {
+		return scanIndex(txn, "mention", since, until, true, func(id string) error {
+			var m Mention
+			found, err := get(txn, prefixMention+id, &m)
+			if err != nil || !found {
+				return err
+			}
+			return fn(m)
+		})
+	})
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	return err
+}
+
+// Checkpoint returns the position a poller saved under name, or "".
+func (s *Badger) Checkpoint(ctx context.Context, name string) (string, error) {
+	var v string
+	err := s.view(ctx, "Checkpoint", func(txn *badger.Txn) error {
+		_, err := get(txn, prefixCheckpoint+name, &v)
+		return err
+	})
+	return v, err
+}
+
+// SetCheckpoint saves a poller's position under name.
+func (s *Badger) SetCheckpoint(ctx context.Context, name, value string) error {
+	return s.update(ctx, "SetCheckpoint", func(txn *badger.Txn) error {
+		return put(txn, prefixCheckpoint+name, value)
+	})
+}
+
+// RecordEngagement stores a quote, like or repost, marks its target seen and
+// resolves the intent.
+func (s *Badger) RecordEngagement(ctx context.Context, e Engagement) error {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	return s.update(ctx, "RecordEngagement", func(txn *badger.Txn) error {
+		if err := reindex(txn, prefixEngagement, "engagement", e.Key(), e.At); err != nil {
+			return err
+		}
+		if err := putIndexed(txn, prefixEngagement, "engagement", e.Key(), e.At, e); err != nil {
+			return err
+		}
+		if err := reindex(txn, prefixSeen, "seen", e.TweetID, e.At); err != nil {
+			return err
+		}
+		seen := SeenTweet{ID: e.TweetID, At: e.At, ReplyID: e.ResultID}
+		if err := putIndexed(s.expiring(txn), prefixSeen, "seen", e.TweetID, e.At, seen); err != nil {
+			return err
+		}
+		return txn.Delete(intentKey(e.Kind, e.TweetID))
+	})
+}
+
+// ScanEngagements calls fn with engagements made in [since, until), newest
+// first.
+func (s *Badger) ScanEngagements(ctx context.Context, since, until time.Time, fn func(Engagement) error) error {
+	err := s.view(ctx, "ScanEngagements", func(txn *badger.Txn) error {
+		return scanIndex(txn, "engagement", since, until, true, func(key string) error {
+			var e Engagement
+			found, err := get(txn, prefixEngagement+key, &e)
+			if err != nil || !found {
+				return err
+			}
+			return fn(e)
+		})
+	})
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	return err
+}
+
+// SaveDraft stores d, replacing any earlier version of it.
+func (s *Badger) SaveDraft(ctx context.Context, d Draft) error {
+	if d.Updated.IsZero() {
+		d.Updated = time.Now()
+	}
+	if d.Created.IsZero() {
+		d.Created = d.Updated
+	}
+	return s.update(ctx, "SaveDraft", func(txn *badger.Txn) error {
+		if err := reindex(txn, prefixDraft, "draft", d.ID, d.At); err != nil {
+			return err
+		}
+		return putIndexed(txn, prefixDraft, "draft", d.ID, d.At, d)
+	})
+}
+
+// GetDraft returns the draft with id, or nil if there is none.
+func (s *Badger) GetDraft(ctx context.Context, id string) (*Draft, error) {
+	var d Draft
+	var found bool
+	err := s.view(ctx, "GetDraft", func(txn *badger.Txn) (err error) {
+		found, err = get(txn, prefixDraft+id, &d)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// DeleteDraft removes the draft with id, if there is one.
+func (s *Badger) DeleteDraft(ctx context.Context, id string) error {
+	return s.update(ctx, "DeleteDraft", func(txn *badger.Txn) error {
+		var d Draft
+		found, err := get(txn, prefixDraft+id, &d)
+		if err != nil || !found {
+			return err
+		}
+		if err := txn.Delete(indexKey("draft", d.At, id)); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(prefixDraft + id))
+	})
+}
+
+// ScanDrafts calls fn with drafts due in [since, until), soonest first.
+func (s *Badger) ScanDrafts(ctx context.Context, since, until time.Time, fn func(Draft) error) error {
+	err := s.view(ctx, "ScanDrafts", func(txn *badger.Txn) error {
+		return scanIndex(txn, "draft", since, until, false, func(id string) error {
+			var d Draft
+			found, err := get(txn, prefixDraft+id, &d)
+			if err != nil || !found {
+				return err
+			}
+			return fn(d)
+		})
+	})
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	return err
+}
+
+// SaveEvergreen stores e in the content library, replacing any earlier
+// version of it.
+func (s *Badger) SaveEvergreen(ctx context.Context, e Evergreen) error {
+	e.Recycles = nil
+	return s.update(ctx, "SaveEvergreen", func(txn *badger.Txn) error {
+		if err := reindex(txn, prefixEvergreen, "evergreen", e.ID, e.At); err != nil {
+			return err
+		}
+		return putIndexed(txn, prefixEvergreen, "evergreen", e.ID, e.At, e)
+	})
+}
+
+// GetEvergreen returns the library entry with id, or nil if there is none.
+func (s *Badger) GetEvergreen(ctx context.Context, id string) (*Evergreen, error) {
+	var e Evergreen
+	var found bool
+	err := s.view(ctx, "GetEvergreen", func(txn *badger.Txn) (err error) {
+		found, err = get(txn, prefixEvergreen+id, &e)
+		if err != nil || !found {
+			return err
+		}
+		e.Recycles, err = recycles(txn, id)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// DeleteEvergreen removes the library entry with id, if there is one. Posts
+// recycled from it keep their link.
+func (s *Badger) DeleteEvergreen(ctx context.Context, id string) error {
+	return s.update(ctx, "DeleteEvergreen", func(txn *badger.Txn) error {
+		var e Evergreen
+		found, err := get(txn, prefixEvergreen+id, &e)
+		if err != nil || !found {
+			return err
+		}
+		if err := txn.Delete(indexKey("evergreen", e.At, id)); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(prefixEvergreen + id))
+	})
+}
+
+// ScanEvergreen calls fn with library entries whose original is from
+// [since, until), newest first.
+func (s *Badger) ScanEvergreen(ctx context.Context, since, until time.Time, fn func(Evergreen) error) error {
+	err := s.view(ctx, "ScanEvergreen", func(txn *badger.Txn) error {
+		return scanIndex(txn, "evergreen", since, until, true, func(id string) error {
+			var e Evergreen
+			found, err := get(txn, prefixEvergreen+id, &e)
+			if err != nil || !found {
+				return err
+			}
+			if e.Recycles, err = recycles(txn, id); err != nil {
+				return err
+			}
+			return fn(e)
+		})
+	})
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	return err
+}
+
+// recycles lists the posts recycled from library entry id, oldest first.
+func recycles(txn *badger.Txn, id string) ([]Recycle, error) {
+	var out []Recycle
+	err := scanIndex(txn, "recycled/"+id, time.Time{}, time.Time{}, false, func(postID string) error {
+		var p Post
+		found, err := get(txn, prefixPost+postID, &p)
+		if err != nil || !found {
+			return err
+		}
+		out = append(out, Recycle{PostID: p.ID, Text: p.Text, At: p.At})
+		return nil
+	})
+	return out, err
+}
@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Retention is how long each expiring kind of key lives; zero keeps it
+// forever. Posts, replies, usage and audit records feed analytics and are
+// never expired.
+type Retention struct {
+	Seen   time.Duration // seen/ and its time index: reply-scan dedupe
+	Slots  time.Duration // slot/: posted-slot dedupe markers
+	Shadow time.Duration // shadow: dry-run timeline
+}
+
+// policy ties an expiring key prefix to its retention and to the time its
+// age is measured from.
+type policy struct {
+	prefix string
+	ttl    func(Retention) time.Duration
+	at     func(key, val []byte) time.Time
+}
+
+var policies = []policy{
+	{prefixSeen, func(r Retention) time.Duration { return r.Seen }, recordTime},
+	{prefixIndex + "seen/", func(r Retention) time.Duration { return r.Seen }, indexTime},
+	{prefixSlot, func(r Retention) time.Duration { return r.Slots }, recordTime},
+	{"shadow:", func(r Retention) time.Duration { return r.Shadow }, timedKeyTime},
+}
+
+// SetRetention sets the policies applied to new writes and by Sweep.
+func (s *Badger) SetRetention(r Retention) {
+	s.mu.Lock()
+	s.retention = r
+	s.mu.Unlock()
+}
+
+func (s *Badger) Retention() Retention {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.retention
+}
+
+// ttlFor returns the retention that applies to key, or zero.
+func (s *Badger) ttlFor(key string) time.Duration {
+	r := s.Retention()
+	for _, p := range policies {
+		if strings.HasPrefix(key, p.prefix) {
+			return p.ttl(r)
+		}
+	}
+	return 0
+}
+
+// expiring wraps a transaction or write batch so writes to retained
+// prefixes carry a TTL.
+type expiring struct {
+	s   *Badger
+	txn interface {
+		SetEntry(e *badger.Entry) error
+	}
+}
+
+func (s *Badger) expiring(txn interface{ SetEntry(e *badger.Entry) error }) expiring {
+	return expiring{s, txn}
+}
+
+func (e expiring) Set(key, val []byte) error {
+	ent := badger.NewEntry(key, val)
+	if ttl := e.s.ttlFor(string(key)); ttl > 0 {
+		ent = ent.WithTTL(ttl)
+	}
+	return e.txn.SetEntry(ent)
+}
+
+func recordTime(_, val []byte) time.Time {
+	var r struct {
+		At time.Time `json:"at"`
+	}
+	if decode(val, &r) != nil {
+		return time.Time{}
+	}
+	return r.At
+}
+
+// indexTime reads the time from idx/<kind>/<unix nano>/<id>.
+func indexTime(key, _ []byte) time.Time {
+	parts := strings.SplitN(string(key), "/", 4)
+	if len(parts) < 4 {
+		return time.Time{}
+	}
+	n, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// timedKeyTime reads the time from <prefix><20 digit unix nano>-<rand>.
+func timedKeyTime(key, _ []byte) time.Time {
+	i := strings.IndexByte(string(key), ':')
+	if i < 0 || len(key) < i+21 {
+		return time.Time{}
+	}
+	n, err := strconv.ParseInt(string(key[i+1:i+21]), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// SweepResult counts what a sweep changed.
+type SweepResult struct {
+	Expired int `json:"expired"` // past retention, deleted
+	Dated   int `json:"dated"`   // given a TTL for the time they have left
+}
+
+// Sweep applies retention to keys written without a TTL, such as those
+// migrated from an older schema or written before a policy was set: keys
+// already past retention are deleted and the rest get their remaining TTL.
+func (s *Badger) Sweep(ctx context.Context) (SweepResult, error) {
+	var res SweepResult
+	r := s.Retention()
+	now := time.Now()
+	for _, p := range policies {
+		ttl := p.ttl(r)
+		if ttl <= 0 {
+			continue
+		}
+		type kv struct {
+			k, v []byte
+			at   time.Time
+		}
+		var todo []kv
+		err := s.view(ctx, "Sweep", func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			defer it.Close()
+			pre := []byte(p.prefix)
+			for it.Seek(pre); it.ValidForPrefix(pre); it.Next() {
+				item := it.Item()
+				if item.ExpiresAt() != 0 {
+					continue
+				}
+				v, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				k := item.KeyCopy(nil)
+				todo = append(todo, kv{k, v, p.at(k, v)})
+			}
+			return nil
+		})
+		if err != nil {
+			return res, err
+		}
+
+		wb := s.db.NewWriteBatch()
+		for _, e := range todo {
+			left := ttl
+			if !e.at.IsZero() {
+				left = ttl - now.Sub(e.at)
+			}
+			if left <= 0 {
+				err = wb.Delete(e.k)
+				res.Expired++
+			} else {
+				err = wb.SetEntry(badger.NewEntry(e.k, e.v).WithTTL(left))
+				res.Dated++
+			}
+			if err != nil {
+				wb.Cancel()
+				return res, err
+			}
+		}
+		if err := wb.Flush(); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// GC runs value log garbage collection until a pass has nothing to
+// rewrite, returning how many files were rewritten.
+func (s *Badger) GC(ratio float64) (int, error) {
+	n := 0
+	for {
+		err := s.db.RunValueLogGC(ratio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("value log gc: %w", err)
+		}
+		n++
+	}
+}
+
+// PrefixUsage is the key count and estimated size of one key family.
+type PrefixUsage struct {
+	Prefix   string `json:"prefix"`
+	Keys     int    `json:"keys"`
+	Bytes    int64  `json:"bytes"`
+	Expiring int    `json:"expiring"`
+}
+
+// Usage reports keys and estimated bytes per key family: the part of the
+// key up to and including its first ':' or '/' (two levels for idx/).
+func (s *Badger) Usage(ctx context.Context) ([]PrefixUsage, error) {
+	by := map[string]*PrefixUsage{}
+	var order []string
+	err := s.view(ctx, "Usage", func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			p := keyFamily(string(item.Key()))
+			u := by[p]
+			if u == nil {
+				u = &PrefixUsage{Prefix: p}
+				by[p] = u
+				order = append(order, p)
+			}
+			u.Keys++
+			u.Bytes += item.EstimatedSize()
+			if item.ExpiresAt() != 0 {
+				u.Expiring++
+			}
+		}
+		return nil
+	})
+	out := make([]PrefixUsage, 0, len(order))
+	for _, p := range order {
+		out = append(out, *by[p])
+	}
+	return out, err
+}
+
+func keyFamily(k string) string {
+	if strings.HasPrefix(k, prefixIndex) {
+		if i := strings.IndexByte(k[len(prefixIndex):], '/'); i >= 0 {
+			return k[:len(prefixIndex)+i+1]
+		}
+	}
+	if i := strings.IndexAny(k, ":/"); i >= 0 {
+		return k[:i+1]
+	}
+	return k
+}
@@ -0,0 +1,1364 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	_ "modernc.org/sqlite"
+)
+
+// SQLite is the Store backed by a SQLite database. Posts, replies and
+// metrics live in plain tables so they can be queried with SQL directly;
+// metrics keep every snapshot in post_metrics rather than only the last.
+type SQLite struct {
+	db       *sql.DB
+	path     string
+	migrated []string
+
+	mu        sync.RWMutex // guards retention
+	retention Retention
+}
+
+// sqliteMigrations are applied in order on open; PRAGMA user_version holds
+// the last one applied.
+var sqliteMigrations = []struct {
+	Version int
+	Name    string
+	SQL     string
+}{
+	{1, "schema", `
+CREATE TABLE posts (
+	id    TEXT PRIMARY KEY,
+	text  TEXT NOT NULL,
+	style TEXT NOT NULL DEFAULT '',
+	slot  TEXT NOT NULL DEFAULT '',
+	at    INTEGER NOT NULL
+);
+CREATE INDEX posts_at ON posts(at);
+
+CREATE TABLE post_topics (
+	post_id TEXT NOT NULL,
+	pos     INTEGER NOT NULL,
+	topic   TEXT NOT NULL,
+	PRIMARY KEY (post_id, pos)
+);
+CREATE INDEX post_topics_topic ON post_topics(topic COLLATE NOCASE);
+
+CREATE TABLE post_metrics (
+	post_id  TEXT NOT NULL,
+	at       INTEGER NOT NULL,
+	likes    INTEGER NOT NULL,
+	replies  INTEGER NOT NULL,
+	retweets INTEGER NOT NULL,
+	quotes   INTEGER NOT NULL,
+	PRIMARY KEY (post_id, at)
+);
+
+CREATE VIEW post_latest_metrics AS
+	SELECT m.* FROM post_metrics m
+	WHERE m.at = (SELECT max(at) FROM post_metrics WHERE post_id = m.post_id);
+
+CREATE TABLE replies (
+	id          TEXT PRIMARY KEY,
+	in_reply_to TEXT NOT NULL DEFAULT '',
+	text        TEXT NOT NULL DEFAULT '',
+	at          INTEGER NOT NULL
+);
+CREATE INDEX replies_at ON replies(at);
+
+CREATE TABLE seen (
+	id       TEXT PRIMARY KEY,
+	at       INTEGER NOT NULL,
+	reply_id TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX seen_at ON seen(at);
+
+CREATE TABLE slots (
+	key     TEXT PRIMARY KEY,
+	post_id TEXT NOT NULL DEFAULT '',
+	at      INTEGER NOT NULL
+);
+
+CREATE TABLE plans (
+	day   TEXT PRIMARY KEY,
+	slots TEXT NOT NULL,
+	at    INTEGER NOT NULL
+);
+
+CREATE TABLE intents (
+	kind    TEXT NOT NULL,
+	key     TEXT NOT NULL,
+	text    TEXT NOT NULL,
+	started INTEGER NOT NULL,
+	topics  TEXT NOT NULL DEFAULT '[]',
+	style   TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (kind, key)
+);
+
+CREATE TABLE usage (
+	id                TEXT PRIMARY KEY,
+	at                INTEGER NOT NULL,
+	model             TEXT NOT NULL,
+	purpose           TEXT NOT NULL,
+	prompt_tokens     INTEGER NOT NULL,
+	completion_tokens INTEGER NOT NULL,
+	latency_ms        INTEGER NOT NULL
+);
+CREATE INDEX usage_at ON usage(at);
+
+CREATE TABLE audit (
+	seq   INTEGER PRIMARY KEY AUTOINCREMENT,
+	at    INTEGER NOT NULL,
+	entry TEXT NOT NULL
+);
+CREATE INDEX audit_at ON audit(at);
+
+CREATE TABLE shadow (
+	seq    INTEGER PRIMARY KEY AUTOINCREMENT,
+	at     INTEGER NOT NULL,
+	record TEXT NOT NULL
+);
+CREATE INDEX shadow_at ON shadow(at);
+
+CREATE TABLE meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`},
+	{2, "reply interactions", `
+ALTER TABLE replies ADD COLUMN author_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE replies ADD COLUMN conversation_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX replies_author ON replies(author_id, at) WHERE author_id != '';
+CREATE INDEX replies_conversation ON replies(conversation_id, at) WHERE conversation_id != '';
+`},
+	{3, "mentions", `
+CREATE TABLE mentions (
+	id              TEXT PRIMARY KEY,
+	author_id       TEXT NOT NULL DEFAULT '',
+	author          TEXT NOT NULL DEFAULT '',
+	conversation_id TEXT NOT NULL DEFAULT '',
+	text            TEXT NOT NULL,
+	at              INTEGER NOT NULL,
+	class           TEXT NOT NULL DEFAULT '',
+	status          TEXT NOT NULL,
+	draft           TEXT NOT NULL DEFAULT '',
+	reply_id        TEXT NOT NULL DEFAULT '',
+	note            TEXT NOT NULL DEFAULT '',
+	updated         INTEGER NOT NULL
+);
+CREATE INDEX mentions_at ON mentions(at);
+CREATE INDEX mentions_status ON mentions(status, at);
+`},
+	{4, "engagements", `
+CREATE TABLE engagements (
+	kind            TEXT NOT NULL,
+	tweet_id        TEXT NOT NULL,
+	result_id       TEXT NOT NULL DEFAULT '',
+	author_id       TEXT NOT NULL DEFAULT '',
+	conversation_id TEXT NOT NULL DEFAULT '',
+	text            TEXT NOT NULL DEFAULT '',
+	score           REAL NOT NULL DEFAULT 0,
+	at              INTEGER NOT NULL,
+	PRIMARY KEY (kind, tweet_id)
+);
+CREATE INDEX engagements_at ON engagements(at);
+`},
+	{5, "polls", `
+ALTER TABLE posts ADD COLUMN poll TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN reply_settings TEXT NOT NULL DEFAULT '';
+ALTER TABLE post_metrics ADD COLUMN poll_votes TEXT NOT NULL DEFAULT '';
+ALTER TABLE post_metrics ADD COLUMN poll_status TEXT NOT NULL DEFAULT '';
+DROP VIEW post_latest_metrics;
+CREATE VIEW post_latest_metrics AS
+	SELECT m.* FROM post_metrics m
+	WHERE m.at = (SELECT max(at) FROM post_metrics WHERE post_id = m.post_id);
+`},
+	{6, "drafts", `
+CREATE TABLE drafts (
+	id      TEXT PRIMARY KEY,
+	account TEXT NOT NULL DEFAULT '',
+	text    TEXT NOT NULL,
+	at      INTEGER NOT NULL,
+	tz      TEXT NOT NULL DEFAULT '',
+	status  TEXT NOT NULL,
+	post_id TEXT NOT NULL DEFAULT '',
+	error   TEXT NOT NULL DEFAULT '',
+	author  TEXT NOT NULL DEFAULT '',
+	created INTEGER NOT NULL,
+	updated INTEGER NOT NULL
+);
+CREATE INDEX drafts_at ON drafts(at);
+`},
+	{7, "evergreen", `
+ALTER TABLE posts ADD COLUMN recycled_from TEXT NOT NULL DEFAULT '';
+CREATE INDEX posts_recycled_from ON posts(recycled_from, at) WHERE recycled_from != '';
+ALTER TABLE intents ADD COLUMN recycled_from TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE evergreen (
+	id      TEXT PRIMARY KEY,
+	post_id TEXT NOT NULL DEFAULT '',
+	text    TEXT NOT NULL,
+	topics  TEXT NOT NULL DEFAULT '',
+	style   TEXT NOT NULL DEFAULT '',
+	author  TEXT NOT NULL DEFAULT '',
+	at      INTEGER NOT NULL
+);
+CREATE INDEX evergreen_at ON evergreen(at);
+`},
+	{8, "intent targets", `
+ALTER TABLE intents ADD COLUMN author_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE intents ADD COLUMN conversation_id TEXT NOT NULL DEFAULT '';
+`},
+	{9, "flagged intents", `
+ALTER TABLE intents ADD COLUMN flagged INTEGER NOT NULL DEFAULT 0;
+`},
+}
+
+// OpenSQLite opens or creates the SQLite database at path and migrates it
+// to the current schema.
+func OpenSQLite(path string) (*SQLite, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := &SQLite{db: db, path: path}
+	if err := s.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLite) migrate(ctx context.Context) error {
+	var cur int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&cur); err != nil {
+		return err
+	}
+	for _, m := range sqliteMigrations {
+		if m.Version <= cur {
+			continue
+		}
+		err := s.tx(ctx, "migrate", func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", m.Version))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+		s.migrated = append(s.migrated, m.Name)
+	}
+	return nil
+}
+
+// Migrated returns the names of migrations applied when the store opened.
+func (s *SQLite) Migrated() []string { return s.migrated }
+
+func (s *SQLite) Close() error { return s.db.Close() }
+
+// tx runs fn in a transaction under a span named after op.
+func (s *SQLite) tx(ctx context.Context, op string, fn func(tx *sql.Tx) error) error {
+	ctx, span := tracer.Start(ctx, "storage."+op)
+	defer span.End()
+	err := func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// query runs a read under a span named after op, calling fn for each row.
+// ErrStop from fn ends it without error.
+func (s *SQLite) query(ctx context.Context, op, q string, args []any, fn func(rows *sql.Rows) error) error {
+	ctx, span := tracer.Start(ctx, "storage."+op)
+	defer span.End()
+	err := func() error {
+		rows, err := s.db.QueryContext(ctx, q, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			if err := fn(rows); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	}()
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// exists reports whether q returns a row.
+func (s *SQLite) exists(ctx context.Context, op, q string, args ...any) (bool, error) {
+	found := false
+	err := s.query(ctx, op, q, args, func(*sql.Rows) error {
+		found = true
+		return ErrStop
+	})
+	return found, err
+}
+
+// Times are stored as Unix nanoseconds; zero times as 0.
+func nanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromNanos(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// timeRange turns since/until into the bounds of an at column, leaving
+// zero ends open.
+func timeRange(since, until time.Time) (lo, hi int64) {
+	lo, hi = 0, 1<<63-1
+	if !since.IsZero() {
+		lo = since.UnixNano()
+	}
+	if !until.IsZero() {
+		hi = until.UnixNano()
+	}
+	return lo, hi
+}
+
+const postColumns = `p.id, p.text, p.style, p.slot, p.at,
+	(SELECT json_group_array(topic) FROM (SELECT topic FROM post_topics WHERE post_id = p.id ORDER BY pos)),
+	p.poll, p.reply_settings, p.recycled_from,
+	m.at, m.likes, m.replies, m.retweets, m.quotes, m.poll_votes, m.poll_status`
+
+const postFrom = ` FROM posts p LEFT JOIN post_latest_metrics m ON m.post_id = p.id `
+
+func scanPost(rows *sql.Rows) (Post, error) {
+	var (
+		p       Post
+		at      int64
+		topics  string
+		poll    string
+		mAt     sql.NullInt64
+		metrics [4]sql.NullInt64
+		votes   sql.NullString
+		status  sql.NullString
+	)
+	err := rows.Scan(&p.ID, &p.Text, &p.Style, &p.Slot, &at, &topics, &poll, &p.ReplySettings, &p.RecycledFrom,
+		&mAt, &metrics[0], &metrics[1], &metrics[2], &metrics[3], &votes, &status)
+	if err != nil {
+		return p, err
+	}
+	p.At = fromNanos(at)
+	if err := json.Unmarshal([]byte(topics), &p.Topics); err != nil {
+		return p, err
+	}
+	if len(p.Topics) == 0 {
+		p.Topics = nil
+	}
+	if err := unmarshalOptional(poll, &p.Poll); err != nil {
+		return p, err
+	}
+	if mAt.Valid {
+		p.Metrics = &PostMetrics{
+			Likes:      int(metrics[0].Int64),
+			Replies:    int(metrics[1].Int64),
+			Retweets:   int(metrics[2].Int64),
+			Quotes:     int(metrics[3].Int64),
+			Updated:    fromNanos(mAt.Int64),
+			PollStatus: status.String,
+		}
+		if err := unmarshalOptional(votes.String, &p.Metrics.PollVotes); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// jsonColumn encodes v for an optional JSON column, which holds "" when
+// there is nothing to store.
+func jsonColumn(v any, none bool) (string, error) {
+	if none {
+		return "", nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// unmarshalOptional decodes a column written by jsonColumn.
+func unmarshalOptional(s string, v any) error {
+	if s == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s), v)
+}
+
+// putPost writes p, its topics and, if set, its metrics snapshot.
+func putPost(ctx context.Context, tx *sql.Tx, p Post) error {
+	poll, err := jsonColumn(p.Poll, p.Poll == nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO posts (id, text, style, slot, at, poll, reply_settings, recycled_from) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET text = excluded.text, style = excluded.style, slot = excluded.slot, at = excluded.at,
+			poll = excluded.poll, reply_settings = excluded.reply_settings, recycled_from = excluded.recycled_from`,
+		p.ID, p.Text, p.Style, p.Slot, nanos(p.At), poll, p.ReplySettings, p.RecycledFrom)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_topics WHERE post_id = ?`, p.ID); err != nil {
+		return err
+	}
+	for i, t := range p.Topics {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO post_topics (post_id, pos, topic) VALUES (?, ?, ?)`, p.ID, i, t); err != nil {
+			return err
+		}
+	}
+	if p.Metrics != nil {
+		return putMetrics(ctx, tx, p.ID, *p.Metrics)
+	}
+	return nil
+}
+
+func putMetrics(ctx context.Context, tx *sql.Tx, id string, m PostMetrics) error {
+	votes, err := jsonColumn(m.PollVotes, len(m.PollVotes) == 0)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO post_metrics (post_id, at, likes, replies, retweets, quotes, poll_votes, poll_status)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM posts WHERE id = ?)`,
+		id, nanos(m.Updated), m.Likes, m.Replies, m.Retweets, m.Quotes, votes, m.PollStatus, id)
+	return err
+}
+
+func putReply(ctx context.Context, tx *sql.Tx, r Reply) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO replies (id, in_reply_to, author_id, conversation_id, text, at) VALUES (?, ?, ?, ?, ?, ?)`,
+		r.ID, r.InReplyTo, r.AuthorID, r.ConversationID, r.Text, nanos(r.At))
+	return err
+}
+
+func putMention(ctx context.Context, tx *sql.Tx, m Mention) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO mentions (`+mentionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.ID, m.AuthorID, m.Author, m.ConversationID, m.Text, nanos(m.At), m.Class, m.Status, m.Draft, m.ReplyID, m.Note, nanos(m.Updated))
+	return err
+}
+
+func putDraft(ctx context.Context, tx *sql.Tx, d Draft) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO drafts (`+draftColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.ID, d.Account, d.Text, nanos(d.At), d.TZ, d.Status, d.PostID, d.Error, d.Author, nanos(d.Created), nanos(d.Updated))
+	return err
+}
+
+func putEvergreen(ctx context.Context, tx *sql.Tx, e Evergreen) error {
+	topics, err := jsonColumn(e.Topics, len(e.Topics) == 0)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO evergreen (id, post_id, text, topics, style, author, at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.PostID, e.Text, topics, e.Style, e.Author, nanos(e.At))
+	return err
+}
+
+func putEngagement(ctx context.Context, tx *sql.Tx, e Engagement) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO engagements (`+engagementColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Kind, e.TweetID, e.ResultID, e.AuthorID, e.ConversationID, e.Text, e.Score, nanos(e.At))
+	return err
+}
+
+func putSeen(ctx context.Context, tx *sql.Tx, st SeenTweet) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO seen (id, at, reply_id) VALUES (?, ?, ?)`,
+		st.ID, nanos(st.At), st.ReplyID)
+	return err
+}
+
+func putSlot(ctx context.Context, tx *sql.Tx, m SlotMark) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO slots (key, post_id, at) VALUES (?, ?, ?)`,
+		m.Key, m.PostID, nanos(m.At))
+	return err
+}
+
+func putUsage(ctx context.Context, tx *sql.Tx, id string, u UsageRecord) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO usage (id, at, model, purpose, prompt_tokens, completion_tokens, latency_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, nanos(u.At), u.Model, u.Purpose, u.PromptTokens, u.CompletionTokens, u.LatencyMS)
+	return err
+}
+
+// RecordPost stores a successful post in one transaction with its topics
+// and, if p.Slot is set, the slot marker and removal of the slot's intent.
+func (s *SQLite) RecordPost(ctx context.Context, p Post) error {
+	if p.At.IsZero() {
+		p.At = time.Now()
+	}
+	return s.tx(ctx, "RecordPost", func(tx *sql.Tx) error {
+		if err := putPost(ctx, tx, p); err != nil {
+			return err
+		}
+		if p.Slot == "" {
+			return nil
+		}
+		if err := putSlot(ctx, tx, SlotMark{Key: p.Slot, PostID: p.ID, At: p.At}); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM intents WHERE kind = 'post' AND key = ?`, p.Slot)
+		return err
+	})
+}
+
+// RecordReply stores a successful reply, marks its target seen and resolves
+// the intent.
+func (s *SQLite) RecordReply(ctx context.Context, r Reply) error {
+	if r.At.IsZero() {
+		r.At = time.Now()
+	}
+	return s.tx(ctx, "RecordReply", func(tx *sql.Tx) error {
+		if err := putReply(ctx, tx, r); err != nil {
+			return err
+		}
+		if r.InReplyTo == "" {
+			return nil
+		}
+		if err := putSeen(ctx, tx, SeenTweet{ID: r.InReplyTo, At: r.At, ReplyID: r.ID}); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM intents WHERE kind = 'reply' AND key = ?`, r.InReplyTo)
+		return err
+	})
+}
+
+// RecordEngagement stores a quote, like or repost, marks its target seen and
+// resolves the intent.
+func (s *SQLite) RecordEngagement(ctx context.Context, e Engagement) error {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	return s.tx(ctx, "RecordEngagement", func(tx *sql.Tx) error {
+		if err := putEngagement(ctx, tx, e); err != nil {
+			return err
+		}
+		if err := putSeen(ctx, tx, SeenTweet{ID: e.TweetID, At: e.At, ReplyID: e.ResultID}); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM intents WHERE kind = ? AND key = ?`, e.Kind, e.TweetID)
+		return err
+	})
+}
+
+// GetPost returns the post with id, or nil if there is none.
+func (s *SQLite) GetPost(ctx context.Context, id string) (*Post, error) {
+	var out *Post
+	err := s.query(ctx, "GetPost", `SELECT `+postColumns+postFrom+`WHERE p.id = ?`, []any{id}, func(rows *sql.Rows) error {
+		p, err := scanPost(rows)
+		out = &p
+		return err
+	})
+	return out, err
+}
+
+// SetPostMetrics adds an engagement snapshot to a stored post's history.
+func (s *SQLite) SetPostMetrics(ctx context.Context, id string, m PostMetrics) error {
+	return s.tx(ctx, "SetPostMetrics", func(tx *sql.Tx) error {
+		return putMetrics(ctx, tx, id, m)
+	})
+}
+
+// ScanPosts calls fn with posts published in [since, until), newest first.
+func (s *SQLite) ScanPosts(ctx context.Context, since, until time.Time, fn func(Post) error) error {
+	lo, hi := timeRange(since, until)
+	return s.query(ctx, "ScanPosts", `SELECT `+postColumns+postFrom+`WHERE p.at >= ? AND p.at < ? ORDER BY p.at DESC, p.id DESC`,
+		[]any{lo, hi}, func(rows *sql.Rows) error {
+			p, err := scanPost(rows)
+			if err != nil {
+				return err
+			}
+			return fn(p)
+		})
+}
+
+// ScanReplies calls fn with replies published in [since, until), newest
+// first.
+func (s *SQLite) ScanReplies(ctx context.Context, since, until time.Time, fn func(Reply) error) error {
+	lo, hi := timeRange(since, until)
+	return s.query(ctx, "ScanReplies", `SELECT `+replyColumns+` FROM replies WHERE at >= ? AND at < ? ORDER BY at DESC, id DESC`,
+		[]any{lo, hi}, func(rows *sql.Rows) error {
+			r, err := scanReply(rows)
+			if err != nil {
+				return err
+			}
+			return fn(r)
+		})
+}
+
+const replyColumns = `id, in_reply_to, author_id, conversation_id, text, at`
+
+func scanReply(rows *sql.Rows) (Reply, error) {
+	var r Reply
+	var at int64
+	err := rows.Scan(&r.ID, &r.InReplyTo, &r.AuthorID, &r.ConversationID, &r.Text, &at)
+	r.At = fromNanos(at)
+	return r, err
+}
+
+// LastReplyTo returns our newest reply to an author or in a conversation,
+// by ByAuthor or ByConversation, or nil if there is none.
+func (s *SQLite) LastReplyTo(ctx context.Context, by, key string) (*Reply, error) {
+	col := map[string]string{ByAuthor: "author_id", ByConversation: "conversation_id"}[by]
+	if col == "" {
+		return nil, fmt.Errorf("LastReplyTo: unknown key %q", by)
+	}
+	var last *Reply
+	err := s.query(ctx, "LastReplyTo", `SELECT `+replyColumns+` FROM replies WHERE `+col+` = ? AND `+col+` != '' ORDER BY at DESC, id DESC LIMIT 1`,
+		[]any{key}, func(rows *sql.Rows) error {
+			r, err := scanReply(rows)
+			last = &r
+			return err
+		})
+	return last, err
+}
+
+const engagementColumns = `kind, tweet_id, result_id, author_id, conversation_id, text, score, at`
+
+func scanEngagement(rows *sql.Rows) (Engagement, error) {
+	var e Engagement
+	var at int64
+	err := rows.Scan(&e.Kind, &e.TweetID, &e.ResultID, &e.AuthorID, &e.ConversationID, &e.Text, &e.Score, &at)
+	e.At = fromNanos(at)
+	return e, err
+}
+
+// ScanEngagements calls fn with engagements made in [since, until), newest
+// first.
+func (s *SQLite) ScanEngagements(ctx context.Context, since, until time.Time, fn func(Engagement) error) error {
+	lo, hi := timeRange(since, until)
+	return s.query(ctx, "ScanEngagements", `SELECT `+engagementColumns+` FROM engagements WHERE at >= ? AND at < ? ORDER BY at DESC, kind DESC, tweet_id DESC`,
+		[]any{lo, hi}, func(rows *sql.Rows) error {
+			e, err := scanEngagement(rows)
+			if err != nil {
+				return err
+			}
+			return fn(e)
+		})
+}
+
+const mentionColumns = `id, author_id, author, conversation_id, text, at, class, status, draft, reply_id, note, updated`
+
+func scanMention(rows *sql.Rows) (Mention, error) {
+	var m Mention
+	var at, updated int64
+	err := rows.Scan(&m.ID, &m.AuthorID, &m.Author, &m.ConversationID, &m.Text, &at, &m.Class, &m.Status, &m.Draft, &m.ReplyID, &m.Note, &updated)
+	m.At, m.Updated = fromNanos(at), fromNanos(updated)
+	return m, err
+}
+
+// SaveMention stores m, replacing any earlier version of it.
+func (s *SQLite) SaveMention(ctx context.Context, m Mention) error {
+	if m.Updated.IsZero() {
+		m.Updated = time.Now()
+	}
+	return s.tx(ctx, "SaveMention", func(tx *sql.Tx) error {
+		return putMention(ctx, tx, m)
+	})
+}
+
+// GetMention returns the mention with id, or nil if there is none.
+func (s *SQLite) GetMention(ctx context.Context, id string) (*Mention, error) {
+	var out *Mention
+	err := s.query(ctx, "GetMention", `SELECT `+mentionColumns+` FROM mentions WHERE id = ?`, []any{id}, func(rows *sql.Rows) error {
+		m, err := scanMention(rows)
+		out = &m
+		return err
+	})
+	return out, err
+}
+
+// ScanMentions calls fn with mentions posted in [since, until), newest
+// first.
+func (s *SQLite) ScanMentions(ctx context.Context, since, until time.Time, fn func(Mention) error) error {
+	lo, hi := timeRange(since, until)
+	return s.query(ctx, "ScanMentions", `SELECT `+mentionColumns+` FROM mentions WHERE at >= ? AND at < ? ORDER BY at DESC, id DESC`,
+		[]any{lo, hi}, func(rows *sql.Rows) error {
+			m, err := scanMention(rows)
+			if err != nil {
+				return err
+			}
+			return fn(m)
+		})
+}
+
+const draftColumns = `id, account, text, at, tz, status, post_id, error, author, created, updated`
+
+func scanDraft(rows *sql.Rows) (Draft, error) {
+	var d Draft
+	var at, created, updated int64
+	err := rows.Scan(&d.ID, &d.Account, &d.Text, &at, &d.TZ, &d.Status, &d.PostID, &d.Error, &d.Author, &created, &updated)
+	d.At, d.Created, d.Updated = fromNanos(at), fromNanos(created), fromNanos(updated)
+	return d, err
+}
+
+// SaveDraft stores d, replacing any earlier version of it.
+func (s *SQLite) SaveDraft(ctx context.Context, d Draft) error {
+	if d.Updated.IsZero() {
+		d.Updated = time.Now()
+	}
+	if d.Created.IsZero() {
+		d.Created = d.Updated
+	}
+	return s.tx(ctx, "SaveDraft", func(tx *sql.Tx) error {
+		return putDraft(ctx, tx, d)
+	})
+}
+
+// GetDraft returns the draft with id, or nil if there is none.
+func (s *SQLite) GetDraft(ctx context.Context, id string) (*Draft, error) {
+	var out *Draft
+	err := s.query(ctx, "GetDraft", `SELECT `+draftColumns+` FROM drafts WHERE id = ?`, []any{id}, func(rows *sql.Rows) error {
+		d, err := scanDraft(rows)
+		out = &d
+		return err
+	})
+	return out, err
+}
+
+// DeleteDraft removes the draft with id, if there is one.
+func (s *SQLite) DeleteDraft(ctx context.Context, id string) error {
+	return s.tx(ctx, "DeleteDraft", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM drafts WHERE id = ?`, id)
+		return err
+	})
+}
+
+// ScanDrafts calls fn with drafts due in [since, until), soonest first.
+func (s *SQLite) ScanDrafts(ctx context.Context, since, until time.Time, fn func(Draft) error) error {
+	lo, hi := timeRange(since, until)
+	return s.query(ctx, "ScanDrafts", `SELECT `+draftColumns+` FROM drafts WHERE at >= ? AND at < ? ORDER BY at, id`,
+		[]any{lo, hi}, func(rows *sql.Rows) error {
+			d, err := scanDraft(rows)
+			if err != nil {
+				return err
+			}
+			return fn(d)
+		})
+}
+
+// evergreenColumns reads an entry with its recycled posts as a JSON array.
+const evergreenColumns = `e.id, e.post_id, e.text, e.topics, e.style, e.author, e.at,
+	(SELECT json_group_array(json_object('post_id', id, 'text', text, 'at', at)) FROM
+		(SELECT id, text, at FROM posts WHERE recycled_from = e.id ORDER BY at, id))`
+
+func scanEvergreen(rows *sql.Rows) (Evergreen, error) {
+	var (
+		e        Evergreen
+		at       int64
+		topics   string
+		recycled string
+	)
+	if err := rows.Scan(&e.ID, &e.PostID, &e.Text, &topics, &e.Style, &e.Author, &at, &recycled); err != nil {
+		return e, err
+	}
+	e.At = fromNanos(at)
+	if err := unmarshalOptional(topics, &e.Topics); err != nil {
+		return e, err
+	}
+	var rs []struct {
+		PostID string `json:"post_id"`
+		Text   string `json:"text"`
+		At     int64  `json:"at"`
+	}
+	if err := json.Unmarshal([]byte(recycled), &rs); err != nil {
+		return e, err
+	}
+	for _, r := range rs {
+		e.Recycles = append(e.Recycles, Recycle{PostID: r.PostID, Text: r.Text, At: fromNanos(r.At)})
+	}
+	return e, nil
+}
+
+// SaveEvergreen stores e in the content library, replacing any earlier
+// version of it.
+func (s *SQLite) SaveEvergreen(ctx context.Context, e Evergreen) error {
+	return s.tx(ctx, "SaveEvergreen", func(tx *sql.Tx) error {
+		return putEvergreen(ctx, tx, e)
+	})
+}
+
+// GetEvergreen returns the library entry with id, or nil if there is none.
+func (s *SQLite) GetEvergreen(ctx context.Context, id string) (*Evergreen, error) {
+	var out *Evergreen
+	err := s.query(ctx, "GetEvergreen", `SELECT `+evergreenColumns+` FROM evergreen e WHERE e.id = ?`, []any{id}, func(rows *sql.Rows) error {
+		e, err := scanEvergreen(rows)
+		out = &e
+		return err
+	})
+	return out, err
+}
+
+// DeleteEvergreen removes the library entry with id, if there is one. Posts
+// recycled from it keep their link.
+func (s *SQLite) DeleteEvergreen(ctx context.Context, id string) error {
+	return s.tx(ctx, "DeleteEvergreen", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM evergreen WHERE id = ?`, id)
+		return err
+	})
+}
+
+// ScanEvergreen calls fn with library entries whose original is from
+// [since, until), newest first.
+func (s *SQLite) ScanEvergreen(ctx context.Context, since, until time.Time, fn func(Evergreen) error) error {
+	lo, hi := timeRange(since, until)
+	return s.query(ctx, "ScanEvergreen", `SELECT `+evergreenColumns+` FROM evergreen e WHERE e.at >= ? AND e.at < ? ORDER BY e.at DESC, e.id DESC`,
+		[]any{lo, hi}, func(rows *sql.Rows) error {
+			e, err := scanEvergreen(rows)
+			if err != nil {
+				return err
+			}
+			return fn(e)
+		})
+}
+
+// Checkpoint returns the position a poller saved under name, or "".
+// Checkpoints live in the meta table.
+func (s *SQLite) Checkpoint(ctx context.Context, name string) (string, error) {
+	var v string
+	err := s.query(ctx, "Checkpoint", `SELECT value FROM meta WHERE key = ?`, []any{"checkpoint/" + name}, func(rows *sql.Rows) error {
+		return rows.Scan(&v)
+	})
+	return v, err
+}
+
+// SetCheckpoint saves a poller's position under name.
+func (s *SQLite) SetCheckpoint(ctx context.Context, name, value string) error {
+	return s.tx(ctx, "SetCheckpoint", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, "checkpoint/"+name, value)
+		return err
+	})
+}
+
+// RecentPosts returns up to limit posts, newest first.
+func (s *SQLite) RecentPosts(ctx context.Context, limit int) ([]Post, error) {
+	var out []Post
+	err := s.ScanPosts(ctx, time.Time{}, time.Time{}, func(p Post) error {
+		if limit > 0 && len(out) >= limit {
+			return ErrStop
+		}
+		out = append(out, p)
+		return nil
+	})
+	return out, err
+}
+
+// RecentPostTexts returns up to limit post texts, newest first.
+func (s *SQLite) RecentPostTexts(ctx context.Context, limit int) ([]string, error) {
+	posts, err := s.RecentPosts(ctx, limit)
+	out := make([]string, len(posts))
+	for i, p := range posts {
+		out[i] = p.Text
+	}
+	return out, err
+}
+
+// TopPosts returns up to limit posts published since the given time,
+// filtered to topic when it is set, ordered by their latest metrics.
+func (s *SQLite) TopPosts(ctx context.Context, since time.Time, topic string, limit int) ([]Post, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	var out []Post
+	err := s.query(ctx, "TopPosts", `SELECT `+postColumns+postFrom+`
+		WHERE p.at >= ? AND (? = '' OR EXISTS (
+			SELECT 1 FROM post_topics t WHERE t.post_id = p.id AND t.topic = ? COLLATE NOCASE))
+		ORDER BY coalesce(m.likes, 0) DESC, coalesce(m.replies, 0) DESC, p.at DESC, p.id DESC
+		LIMIT ?`,
+		[]any{nanos(since), topic, topic, limit}, func(rows *sql.Rows) error {
+			p, err := scanPost(rows)
+			out = append(out, p)
+			return err
+		})
+	return out, err
+}
+
+func (s *SQLite) count(ctx context.Context, op, table string) (int, error) {
+	var n int
+	err := s.query(ctx, op, "SELECT count(*) FROM "+table, nil, func(rows *sql.Rows) error {
+		return rows.Scan(&n)
+	})
+	return n, err
+}
+
+// CountPosts and CountReplies count stored records.
+func (s *SQLite) CountPosts(ctx context.Context) (int, error) {
+	return s.count(ctx, "CountPosts", "posts")
+}
+
+func (s *SQLite) CountReplies(ctx context.Context) (int, error) {
+	return s.count(ctx, "CountReplies", "replies")
+}
+
+// MarkPosted stores a unique key per day+slot to avoid double posting.
+func (s *SQLite) MarkPosted(ctx context.Context, key, postID string) error {
+	return s.tx(ctx, "MarkPosted", func(tx *sql.Tx) error {
+		return putSlot(ctx, tx, SlotMark{Key: key, PostID: postID, At: time.Now()})
+	})
+}
+
+func (s *SQLite) WasPosted(ctx context.Context, key string) (bool, error) {
+	return s.exists(ctx, "WasPosted", `SELECT 1 FROM slots WHERE key = ?`, key)
+}
+
+// MarkSeen records that the reply scanner handled a tweet.
+func (s *SQLite) MarkSeen(ctx context.Context, id string) error {
+	return s.tx(ctx, "MarkSeen", func(tx *sql.Tx) error {
+		return putSeen(ctx, tx, SeenTweet{ID: id, At: time.Now()})
+	})
+}
+
+func (s *SQLite) IsSeen(ctx context.Context, id string) (bool, error) {
+	return s.exists(ctx, "IsSeen", `SELECT 1 FROM seen WHERE id = ?`, id)
+}
+
+// SavePlan stores the day's slot plan; Sweep drops it after 72 hours.
+func (s *SQLite) SavePlan(ctx context.Context, p SlotPlan) error {
+	v, err := json.Marshal(p.Slots)
+	if err != nil {
+		return err
+	}
+	return s.tx(ctx, "SavePlan", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO plans (day, slots, at) VALUES (?, ?, ?)`,
+			p.Day, string(v), time.Now().UnixNano())
+		return err
+	})
+}
+
+// LoadPlan returns the stored plan for day, or nil if there is none.
+func (s *SQLite) LoadPlan(ctx context.Context, day string) (*SlotPlan, error) {
+	var out *SlotPlan
+	err := s.query(ctx, "LoadPlan", `SELECT slots FROM plans WHERE day = ? AND at >= ?`,
+		[]any{day, time.Now().Add(-planTTL).UnixNano()}, func(rows *sql.Rows) error {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				return err
+			}
+			out = &SlotPlan{Day: day}
+			return json.Unmarshal([]byte(v), &out.Slots)
+		})
+	return out, err
+}
+
+// BeginIntent records that an X write is about to happen.
+func (s *SQLite) BeginIntent(ctx context.Context, in Intent) error {
+	topics, err := json.Marshal(in.Topics)
+	if err != nil {
+		return err
+	}
+	return s.tx(ctx, "BeginIntent", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO intents (kind, key, text, started, topics, style, recycled_from, author_id, conversation_id, flagged) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			in.Kind, in.Key, in.Text, nanos(in.Started), string(topics), in.Style, in.RecycledFrom, in.AuthorID, in.ConversationID, nanos(in.Flagged))
+		return err
+	})
+}
+
+// ClearIntent drops an intent once its write is known to have failed.
+func (s *SQLite) ClearIntent(ctx context.Context, kind, key string) error {
+	return s.tx(ctx, "ClearIntent", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM intents WHERE kind = ? AND key = ?`, kind, key)
+		return err
+	})
+}
+
+// HasIntent reports whether an unresolved intent exists.
+func (s *SQLite) HasIntent(ctx context.Context, kind, key string) (bool, error) {
+	return s.exists(ctx, "HasIntent", `SELECT 1 FROM intents WHERE kind = ? AND key = ?`, kind, key)
+}
+
+// Intents lists unresolved intents.
+func (s *SQLite) Intents(ctx context.Context) ([]Intent, error) {
+	var out []Intent
+	err := s.query(ctx, "Intents", `SELECT kind, key, text, started, topics, style, recycled_from, author_id, conversation_id, flagged FROM intents ORDER BY kind, key`, nil,
+		func(rows *sql.Rows) error {
+			var in Intent
+			var started, flagged int64
+			var topics string
+			if err := rows.Scan(&in.Kind, &in.Key, &in.Text, &started, &topics, &in.Style, &in.RecycledFrom, &in.AuthorID, &in.ConversationID, &flagged); err != nil {
+				return err
+			}
+			in.Started, in.Flagged = fromNanos(started), fromNanos(flagged)
+			if err := json.Unmarshal([]byte(topics), &in.Topics); err != nil {
+				return err
+			}
+			out = append(out, in)
+			return nil
+		})
+	return out, err
+}
+
+// AddUsage appends a usage record. IDs use the Badger key suffix so JSONL
+// exports line up across backends.
+func (s *SQLite) AddUsage(ctx context.Context, u UsageRecord) error {
+	id := fmt.Sprintf("%020d-%04d", u.At.UnixNano(), rand.Intn(10000))
+	return s.tx(ctx, "AddUsage", func(tx *sql.Tx) error {
+		return putUsage(ctx, tx, id, u)
+	})
+}
+
+// UsageSince returns usage records at or after t, oldest first.
+func (s *SQLite) UsageSince(ctx context.Context, t time.Time) ([]UsageRecord, error) {
+	var out []UsageRecord
+	err := s.query(ctx, "UsageSince", `SELECT at, model, purpose, prompt_tokens, completion_tokens, latency_ms
+		FROM usage WHERE at >= ? ORDER BY at, id`, []any{nanos(t)}, func(rows *sql.Rows) error {
+		u, err := scanUsage(rows)
+		out = append(out, u)
+		return err
+	})
+	return out, err
+}
+
+func scanUsage(rows *sql.Rows) (UsageRecord, error) {
+	var u UsageRecord
+	var at int64
+	err := rows.Scan(&at, &u.Model, &u.Purpose, &u.PromptTokens, &u.CompletionTokens, &u.LatencyMS)
+	u.At = fromNanos(at)
+	return u, err
+}
+
+// AppendAudit writes an audit entry. There is deliberately no way to update
+// or delete one.
+func (s *SQLite) AppendAudit(ctx context.Context, at time.Time, v []byte) error {
+	return s.appendLog(ctx, "AppendAudit", "audit", "entry", at, v)
+}
+
+// ScanAudit calls fn for audit entries in [since, until), newest first.
+func (s *SQLite) ScanAudit(ctx context.Context, since, until time.Time, fn func(v []byte) error) error {
+	return s.scanLog(ctx, "ScanAudit", "audit", "entry", since, until, fn)
+}
+
+// AppendShadow writes a dry-run record of a post or reply that was not
+// published.
+func (s *SQLite) AppendShadow(ctx context.Context, at time.Time, v []byte) error {
+	return s.appendLog(ctx, "AppendShadow", "shadow", "record", at, v)
+}
+
+// ScanShadow calls fn for dry-run records in [since, until), newest first.
+func (s *SQLite) ScanShadow(ctx context.Context, since, until time.Time, fn func(v []byte) error) error {
+	return s.scanLog(ctx, "ScanShadow", "shadow", "record", since, until, fn)
+}
+
+func (s *SQLite) appendLog(ctx context.Context, op, table, col string, at time.Time, v []byte) error {
+	return s.tx(ctx, op, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO "+table+" (at, "+col+") VALUES (?, ?)", at.UnixNano(), string(v))
+		return err
+	})
+}
+
+func (s *SQLite) scanLog(ctx context.Context, op, table, col string, since, until time.Time, fn func(v []byte) error) error {
+	lo, hi := timeRange(since, until)
+	return s.query(ctx, op, "SELECT "+col+" FROM "+table+" WHERE at >= ? AND at < ? ORDER BY at DESC, seq DESC",
+		[]any{lo, hi}, func(rows *sql.Rows) error {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				return err
+			}
+			return fn([]byte(v))
+		})
+}
+
+// ExportJSONL writes posts, replies, engagements, seen tweets, slot
+// markers, mentions, drafts, library entries and usage as one Record per
+// line, in the same form the Badger store writes.
+func (s *SQLite) ExportJSONL(ctx context.Context, w io.Writer) (map[string]int, error) {
+	enc := json.NewEncoder(w)
+	counts := map[string]int{}
+	emit := func(rec Record) error {
+		counts[rec.Kind]++
+		return enc.Encode(rec)
+	}
+	err := s.query(ctx, "ExportJSONL", `SELECT `+postColumns+postFrom+`ORDER BY p.id`, nil, func(rows *sql.Rows) error {
+		p, err := scanPost(rows)
+		if err != nil {
+			return err
+		}
+		return emit(Record{Kind: "post", ID: p.ID, Post: &p})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.query(ctx, "ExportJSONL", `SELECT `+replyColumns+` FROM replies ORDER BY id`, nil, func(rows *sql.Rows) error {
+		r, err := scanReply(rows)
+		if err != nil {
+			return err
+		}
+		return emit(Record{Kind: "reply", ID: r.ID, Reply: &r})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.query(ctx, "ExportJSONL", `SELECT `+engagementColumns+` FROM engagements ORDER BY kind, tweet_id`, nil, func(rows *sql.Rows) error {
+		e, err := scanEngagement(rows)
+		if err != nil {
+			return err
+		}
+		return emit(Record{Kind: "engagement", ID: e.Key(), Engagement: &e})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.query(ctx, "ExportJSONL", `SELECT id, at, reply_id FROM seen ORDER BY id`, nil, func(rows *sql.Rows) error {
+		var st SeenTweet
+		var at int64
+		if err := rows.Scan(&st.ID, &at, &st.ReplyID); err != nil {
+			return err
+		}
+		st.At = fromNanos(at)
+		return emit(Record{Kind: "seen", ID: st.ID, Seen: &st})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.query(ctx, "ExportJSONL", `SELECT key, post_id, at FROM slots ORDER BY key`, nil, func(rows *sql.Rows) error {
+		var m SlotMark
+		var at int64
+		if err := rows.Scan(&m.Key, &m.PostID, &at); err != nil {
+			return err
+		}
+		m.At = fromNanos(at)
+		return emit(Record{Kind: "slot", ID: m.Key, Slot: &m})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.query(ctx, "ExportJSONL", `SELECT `+mentionColumns+` FROM mentions ORDER BY id`, nil, func(rows *sql.Rows) error {
+		m, err := scanMention(rows)
+		if err != nil {
+			return err
+		}
+		return emit(Record{Kind: "mention", ID: m.ID, Mention: &m})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.query(ctx, "ExportJSONL", `SELECT `+draftColumns+` FROM drafts ORDER BY id`, nil, func(rows *sql.Rows) error {
+		d, err := scanDraft(rows)
+		if err != nil {
+			return err
+		}
+		return emit(Record{Kind: "draft", ID: d.ID, Draft: &d})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.query(ctx, "ExportJSONL", `SELECT `+evergreenColumns+` FROM evergreen e ORDER BY e.id`, nil, func(rows *sql.Rows) error {
+		e, err := scanEvergreen(rows)
+		if err != nil {
+			return err
+		}
+		e.Recycles = nil // rebuilt from the posts on import
+		return emit(Record{Kind: "evergreen", ID: e.ID, Evergreen: &e})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.query(ctx, "ExportJSONL", `SELECT id, at, model, purpose, prompt_tokens, completion_tokens, latency_ms
+		FROM usage ORDER BY id`, nil, func(rows *sql.Rows) error {
+		var u UsageRecord
+		var id string
+		var at int64
+		if err := rows.Scan(&id, &at, &u.Model, &u.Purpose, &u.PromptTokens, &u.CompletionTokens, &u.LatencyMS); err != nil {
+			return err
+		}
+		u.At = fromNanos(at)
+		return emit(Record{Kind: "usage", ID: id, Usage: &u})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// ImportJSONL reads records written by either backend's ExportJSONL in one
+// transaction. Rows are replaced by ID, so importing twice changes nothing.
+func (s *SQLite) ImportJSONL(ctx context.Context, r io.Reader) (map[string]int, error) {
+	var counts map[string]int
+	err := s.tx(ctx, "ImportJSONL", func(tx *sql.Tx) error {
+		var err error
+		counts, err = readJSONL(ctx, r, func(rec Record) error {
+			switch rec.Kind {
+			case "post":
+				return putPost(ctx, tx, *rec.Post)
+			case "reply":
+				return putReply(ctx, tx, *rec.Reply)
+			case "engagement":
+				return putEngagement(ctx, tx, *rec.Engagement)
+			case "seen":
+				return putSeen(ctx, tx, *rec.Seen)
+			case "slot":
+				return putSlot(ctx, tx, *rec.Slot)
+			case "mention":
+				return putMention(ctx, tx, *rec.Mention)
+			case "draft":
+				return putDraft(ctx, tx, *rec.Draft)
+			case "evergreen":
+				return putEvergreen(ctx, tx, *rec.Evergreen)
+			default:
+				return putUsage(ctx, tx, rec.ID, *rec.Usage)
+			}
+		})
+		return err
+	})
+	return counts, err
+}
+
+// SetRetention sets the policies applied by Sweep.
+func (s *SQLite) SetRetention(r Retention) {
+	s.mu.Lock()
+	s.retention = r
+	s.mu.Unlock()
+}
+
+func (s *SQLite) Retention() Retention {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.retention
+}
+
+// Sweep deletes seen tweets, slot markers and shadow records past retention,
+// and plans older than three days. SQLite has no TTLs, so every expiry is
+// counted as Expired.
+func (s *SQLite) Sweep(ctx context.Context) (SweepResult, error) {
+	var res SweepResult
+	r := s.Retention()
+	now := time.Now()
+	tables := []struct {
+		table string
+		ttl   time.Duration
+	}{
+		{"seen", r.Seen},
+		{"slots", r.Slots},
+		{"shadow", r.Shadow},
+		{"plans", planTTL},
+	}
+	err := s.tx(ctx, "Sweep", func(tx *sql.Tx) error {
+		for _, t := range tables {
+			if t.ttl <= 0 {
+				continue
+			}
+			// rows with no time predate it and are dated from now, as Badger does
+			if _, err := tx.ExecContext(ctx, "UPDATE "+t.table+" SET at = ? WHERE at = 0", now.UnixNano()); err != nil {
+				return err
+			}
+			out, err := tx.ExecContext(ctx, "DELETE FROM "+t.table+" WHERE at < ?", now.Add(-t.ttl).UnixNano())
+			if err != nil {
+				return err
+			}
+			n, _ := out.RowsAffected()
+			res.Expired += int(n)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// GC checkpoints the write-ahead log into the database and truncates it.
+// SQLite has no value log, so it never reports rewritten files.
+func (s *SQLite) GC(float64) (int, error) {
+	_, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	return 0, err
+}
+
+// Compact rebuilds the database file to reclaim free pages.
+func (s *SQLite) Compact() error {
+	if _, err := s.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	_, err := s.GC(0)
+	return err
+}
+
+// Usage reports rows and bytes per table. Bytes come from the dbstat table
+// where the SQLite build has it and are zero otherwise.
+func (s *SQLite) Usage(ctx context.Context) ([]PrefixUsage, error) {
+	bytes := map[string]int64{}
+	_ = s.query(ctx, "Usage", `SELECT name, sum(pgsize) FROM dbstat GROUP BY name`, nil, func(rows *sql.Rows) error {
+		var name string
+		var n int64
+		if err := rows.Scan(&name, &n); err != nil {
+			return err
+		}
+		bytes[name] = n
+		return nil
+	})
+	expiring := map[string]bool{"seen": true, "slots": true, "shadow": true, "plans": true}
+	var out []PrefixUsage
+	for _, t := range []string{"posts", "post_topics", "post_metrics", "replies", "engagements", "seen", "slots", "mentions", "drafts", "evergreen", "plans", "intents", "usage", "audit", "shadow"} {
+		n, err := s.count(ctx, "Usage", t)
+		if err != nil {
+			return out, err
+		}
+		if n == 0 {
+			continue
+		}
+		u := PrefixUsage{Prefix: t, Keys: n, Bytes: bytes[t]}
+		if expiring[t] {
+			u.Expiring = n
+		}
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// Size reports the database and write-ahead log sizes in bytes.
+func (s *SQLite) Size() (db, wal int64) {
+	if fi, err := os.Stat(s.path); err == nil {
+		db = fi.Size()
+	}
+	if fi, err := os.Stat(s.path + "-wal"); err == nil {
+		wal = fi.Size()
+	}
+	return db, wal
+}
+
+// Ping checks the database accepts writes by writing a probe row.
+func (s *SQLite) Ping(ctx context.Context) error {
+	return s.tx(ctx, "Ping", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO meta (key, value) VALUES ('health', ?)`,
+			time.Now().Format(time.RFC3339))
+		return err
+	})
+}
+
+// Sync checkpoints committed writes into the database file.
+func (s *SQLite) Sync() error {
+	_, err := s.db.Exec("PRAGMA wal_checkpoint(PASSIVE)")
+	return err
+}
@@ -0,0 +1,738 @@
+// Package storagetest is a conformance suite for storage.Store backends. It
+// is a package of its own so a new backend's tests can run it too; the
+// storage package's tests run it against every built-in backend.
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+)
+
+// Opener opens a fresh store in an empty directory.
+type Opener func(dir string) (storage.Store, error)
+
+type check struct {
+	name string
+	fn   func(ctx context.Context, s storage.Store) error
+}
+
+var checks = []check{
+	{"posts", checkPosts},
+	{"metrics", checkMetrics},
+	{"top posts", checkTopPosts},
+	{"replies", checkReplies},
+	{"interactions", checkInteractions},
+	{"engagements", checkEngagements},
+	{"mentions", checkMentions},
+	{"drafts", checkDrafts},
+	{"evergreen", checkEvergreen},
+	{"markers", checkMarkers},
+	{"plans", checkPlans},
+	{"intents", checkIntents},
+	{"usage", checkUsage},
+	{"logs", checkLogs},
+	{"sweep", checkSweep},
+	{"jsonl", checkJSONL},
+}
+
+// Run runs every check as a subtest against its own store opened in a
+// temporary directory.
+func Run(t *testing.T, open Opener) {
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := open(t.TempDir())
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer func() {
+				if err := s.Close(); err != nil {
+					t.Errorf("close: %v", err)
+				}
+			}()
+			if err := c.fn(t.Context(), s); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// base is a fixed time well in the past so ranges are deterministic.
+var base = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func at(h int) time.Time { return base.Add(time.Duration(h) * time.Hour) }
+
+func errorf(format string, args ...any) error { return fmt.Errorf(format, args...) }
+
+func postIDs(ps []storage.Post) []string {
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.ID
+	}
+	return out
+}
+
+func checkPosts(ctx context.Context, s storage.Store) error {
+	for i, id := range []string{"p1", "p2", "p3"} {
+		p := storage.Post{ID: id, Text: "text " + id, Topics: []string{"Go", "eBPF"}, Style: "tip", At: at(i)}
+		if err := s.RecordPost(ctx, p); err != nil {
+			return err
+		}
+	}
+	p, err := s.GetPost(ctx, "p2")
+	if err != nil {
+		return err
+	}
+	if p == nil || p.Text != "text p2" || p.Style != "tip" || !p.At.Equal(at(1)) || !reflect.DeepEqual(p.Topics, []string{"Go", "eBPF"}) {
+		return errorf("GetPost(p2) = %+v", p)
+	}
+	if p, err := s.GetPost(ctx, "missing"); err != nil || p != nil {
+		return errorf("GetPost(missing) = %v, %v; want nil, nil", p, err)
+	}
+
+	// re-recording a post at a new time moves it
+	if err := s.RecordPost(ctx, storage.Post{ID: "p1", Text: "text p1", At: at(5)}); err != nil {
+		return err
+	}
+	var got []string
+	err = s.ScanPosts(ctx, at(0), at(5), func(p storage.Post) error {
+		got = append(got, p.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(got, []string{"p3", "p2"}) {
+		return errorf("ScanPosts[0h,5h) = %v, want [p3 p2]", got)
+	}
+	got = nil
+	err = s.ScanPosts(ctx, time.Time{}, time.Time{}, func(p storage.Post) error {
+		got = append(got, p.ID)
+		return storage.ErrStop
+	})
+	if err != nil || !reflect.DeepEqual(got, []string{"p1"}) {
+		return errorf("ScanPosts with ErrStop = %v, %v; want [p1], nil", got, err)
+	}
+	n, err := s.CountPosts(ctx)
+	if err != nil || n != 3 {
+		return errorf("CountPosts = %d, %v; want 3", n, err)
+	}
+	texts, err := s.RecentPostTexts(ctx, 2)
+	if err != nil || !reflect.DeepEqual(texts, []string{"text p1", "text p3"}) {
+		return errorf("RecentPostTexts(2) = %v, %v", texts, err)
+	}
+	return nil
+}
+
+func checkMetrics(ctx context.Context, s storage.Store) error {
+	if err := s.RecordPost(ctx, storage.Post{ID: "p1", Text: "a", At: at(0)}); err != nil {
+		return err
+	}
+	for i, likes := range []int{3, 7} {
+		m := storage.PostMetrics{Likes: likes, Replies: 1, Retweets: 2, Quotes: i, Updated: at(1 + i)}
+		if err := s.SetPostMetrics(ctx, "p1", m); err != nil {
+			return err
+		}
+	}
+	// metrics for an unknown post are dropped, not an error
+	if err := s.SetPostMetrics(ctx, "missing", storage.PostMetrics{Likes: 1, Updated: at(1)}); err != nil {
+		return err
+	}
+	if p, _ := s.GetPost(ctx, "missing"); p != nil {
+		return errorf("SetPostMetrics created post %+v", p)
+	}
+	p, err := s.GetPost(ctx, "p1")
+	if err != nil {
+		return err
+	}
+	if p.Metrics == nil || p.Metrics.Likes != 7 || p.Metrics.Quotes != 1 || !p.Metrics.Updated.Equal(at(2)) {
+		return errorf("metrics = %+v, want the latest snapshot", p.Metrics)
+	}
+	if p.Metrics.PollVotes != nil || p.Metrics.PollStatus != "" {
+		return errorf("metrics of a post without a poll = %+v", p.Metrics)
+	}
+
+	// a poll and its votes come back as stored
+	poll := &storage.Poll{Options: []string{"yes", "no", "maybe"}, DurationMinutes: 1440}
+	if err := s.RecordPost(ctx, storage.Post{ID: "p2", Text: "ship it?", At: at(0), Poll: poll, ReplySettings: "following"}); err != nil {
+		return err
+	}
+	if err := s.SetPostMetrics(ctx, "p2", storage.PostMetrics{Likes: 1, PollVotes: []int{4, 0, 2}, PollStatus: "closed", Updated: at(3)}); err != nil {
+		return err
+	}
+	p, err = s.GetPost(ctx, "p2")
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(p.Poll, poll) || p.ReplySettings != "following" {
+		return errorf("poll post = %+v, want poll %+v and reply settings following", p, poll)
+	}
+	if p.Metrics == nil || !reflect.DeepEqual(p.Metrics.PollVotes, []int{4, 0, 2}) || p.Metrics.PollStatus != "closed" {
+		return errorf("poll metrics = %+v, want votes [4 0 2], closed", p.Metrics)
+	}
+	return nil
+}
+
+func checkTopPosts(ctx context.Context, s storage.Store) error {
+	posts := []struct {
+		id      string
+		topic   string
+		likes   int
+		replies int
+	}{
+		{"a", "go", 5, 0},
+		{"b", "rust", 9, 0},
+		{"c", "go", 5, 4},
+		{"d", "go", 0, 0},
+	}
+	for i, p := range posts {
+		if err := s.RecordPost(ctx, storage.Post{ID: p.id, Text: p.id, Topics: []string{p.topic}, At: at(i)}); err != nil {
+			return err
+		}
+		if p.likes+p.replies == 0 {
+			continue
+		}
+		if err := s.SetPostMetrics(ctx, p.id, storage.PostMetrics{Likes: p.likes, Replies: p.replies, Updated: at(10)}); err != nil {
+			return err
+		}
+	}
+	top, err := s.TopPosts(ctx, time.Time{}, "", 0)
+	if err != nil {
+		return err
+	}
+	if got := postIDs(top); !reflect.DeepEqual(got, []string{"b", "c", "a", "d"}) {
+		return errorf("TopPosts = %v, want [b c a d]", got)
+	}
+	top, err = s.TopPosts(ctx, at(1), "Go", 2)
+	if err != nil {
+		return err
+	}
+	if got := postIDs(top); !reflect.DeepEqual(got, []string{"c", "d"}) {
+		return errorf("TopPosts(since 1h, Go, 2) = %v, want [c d]", got)
+	}
+	return nil
+}
+
+func checkReplies(ctx context.Context, s storage.Store) error {
+	if err := s.BeginIntent(ctx, storage.Intent{Kind: "reply", Key: "t1", Text: "hi", Started: at(0)}); err != nil {
+		return err
+	}
+	if err := s.RecordReply(ctx, storage.Reply{ID: "r1", InReplyTo: "t1", Text: "hi", At: at(1)}); err != nil {
+		return err
+	}
+	if err := s.RecordReply(ctx, storage.Reply{ID: "r2", InReplyTo: "t2", Text: "yo", At: at(2)}); err != nil {
+		return err
+	}
+	if ok, err := s.IsSeen(ctx, "t1"); err != nil || !ok {
+		return errorf("IsSeen(t1) after reply = %v, %v", ok, err)
+	}
+	if ok, err := s.HasIntent(ctx, "reply", "t1"); err != nil || ok {
+		return errorf("HasIntent after reply = %v, %v; want false", ok, err)
+	}
+	var got []storage.Reply
+	err := s.ScanReplies(ctx, time.Time{}, time.Time{}, func(r storage.Reply) error {
+		got = append(got, r)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(got) != 2 || got[0].ID != "r2" || got[1].InReplyTo != "t1" || !got[1].At.Equal(at(1)) {
+		return errorf("ScanReplies = %+v", got)
+	}
+	if n, err := s.CountReplies(ctx); err != nil || n != 2 {
+		return errorf("CountReplies = %d, %v; want 2", n, err)
+	}
+	return nil
+}
+
+func checkInteractions(ctx context.Context, s storage.Store) error {
+	for _, r := range []storage.Reply{
+		{ID: "r1", InReplyTo: "t1", AuthorID: "a1", ConversationID: "c1", At: at(1)},
+		{ID: "r2", InReplyTo: "t2", AuthorID: "a1", ConversationID: "c2", At: at(3)},
+		{ID: "r3", InReplyTo: "t3", AuthorID: "a2", ConversationID: "c1", At: at(2)},
+	} {
+		if err := s.RecordReply(ctx, r); err != nil {
+			return err
+		}
+	}
+	for _, c := range []struct{ by, key, want string }{
+		{storage.ByAuthor, "a1", "r2"},
+		{storage.ByAuthor, "a2", "r3"},
+		{storage.ByConversation, "c1", "r3"},
+		{storage.ByConversation, "c2", "r2"},
+		{storage.ByAuthor, "a3", ""},
+		{storage.ByAuthor, "", ""},
+	} {
+		r, err := s.LastReplyTo(ctx, c.by, c.key)
+		if err != nil {
+			return err
+		}
+		got := ""
+		if r != nil {
+			got = r.ID
+		}
+		if got != c.want {
+			return errorf("LastReplyTo(%s, %q) = %q, want %q", c.by, c.key, got, c.want)
+		}
+	}
+	if _, err := s.LastReplyTo(ctx, "topic", "x"); err == nil {
+		return errorf("LastReplyTo with an unknown key succeeded")
+	}
+	return nil
+}
+
+func checkEngagements(ctx context.Context, s storage.Store) error {
+	if err := s.BeginIntent(ctx, storage.Intent{Kind: "quote", Key: "t1", Text: "nice", Started: at(0)}); err != nil {
+		return err
+	}
+	es := []storage.Engagement{
+		{Kind: "quote", TweetID: "t1", ResultID: "q1", AuthorID: "a1", Text: "nice", Score: 0.9, At: at(0)},
+		{Kind: "like", TweetID: "t2", AuthorID: "a2", Score: 0.3, At: at(1)},
+		{Kind: "repost", TweetID: "t2", AuthorID: "a2", Score: 0.3, At: at(2)},
+	}
+	for _, e := range es {
+		if err := s.RecordEngagement(ctx, e); err != nil {
+			return err
+		}
+	}
+	if ok, err := s.HasIntent(ctx, "quote", "t1"); err != nil || ok {
+		return errorf("quote intent survived RecordEngagement: %v, %v", ok, err)
+	}
+	for _, id := range []string{"t1", "t2"} {
+		if ok, err := s.IsSeen(ctx, id); err != nil || !ok {
+			return errorf("IsSeen(%s) after RecordEngagement = %v, %v", id, ok, err)
+		}
+	}
+	// recording the same engagement again keeps one copy of it
+	if err := s.RecordEngagement(ctx, es[1]); err != nil {
+		return err
+	}
+	var got []string
+	err := s.ScanEngagements(ctx, at(1), time.Time{}, func(e storage.Engagement) error {
+		got = append(got, e.Key())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if want := []string{"repost/t2", "like/t2"}; !reflect.DeepEqual(got, want) {
+		return errorf("ScanEngagements since 1h = %v, want %v", got, want)
+	}
+	var quote storage.Engagement
+	err = s.ScanEngagements(ctx, time.Time{}, at(1), func(e storage.Engagement) error {
+		quote = e
+		return nil
+	})
+	quote.At = quote.At.In(es[0].At.Location())
+	if err != nil || !reflect.DeepEqual(quote, es[0]) {
+		return errorf("ScanEngagements until 1h = %+v, %v, want %+v", quote, err, es[0])
+	}
+	if n, err := s.CountReplies(ctx); err != nil || n != 0 {
+		return errorf("CountReplies after engagements = %d, %v, want 0", n, err)
+	}
+	return nil
+}
+
+func checkMentions(ctx context.Context, s storage.Store) error {
+	if v, err := s.Checkpoint(ctx, "mentions"); err != nil || v != "" {
+		return errorf("Checkpoint before setting = %q, %v", v, err)
+	}
+	if err := s.SetCheckpoint(ctx, "mentions", "100"); err != nil {
+		return err
+	}
+	if err := s.SetCheckpoint(ctx, "mentions", "105"); err != nil {
+		return err
+	}
+	if v, err := s.Checkpoint(ctx, "mentions"); err != nil || v != "105" {
+		return errorf("Checkpoint = %q, %v, want 105", v, err)
+	}
+	for i, id := range []string{"m1", "m2", "m3"} {
+		m := storage.Mention{ID: id, AuthorID: "a1", Text: "@us " + id, At: at(i), Class: "question", Status: "queued", Updated: at(i)}
+		if err := s.SaveMention(ctx, m); err != nil {
+			return err
+		}
+	}
+	// updating a mention keeps one copy of it
+	m := storage.Mention{ID: "m2", AuthorID: "a1", Text: "@us m2", At: at(1), Class: "question", Status: "replied", ReplyID: "r9", Updated: at(5)}
+	if err := s.SaveMention(ctx, m); err != nil {
+		return err
+	}
+	got, err := s.GetMention(ctx, "m2")
+	if err != nil || got == nil || got.Status != "replied" || got.ReplyID != "r9" || !got.Updated.Equal(at(5)) {
+		return errorf("GetMention(m2) = %+v, %v", got, err)
+	}
+	if got, err := s.GetMention(ctx, "nope"); err != nil || got != nil {
+		return errorf("GetMention(nope) = %+v, %v", got, err)
+	}
+	var ids []string
+	err = s.ScanMentions(ctx, at(1), time.Time{}, func(m storage.Mention) error {
+		ids = append(ids, m.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if want := []string{"m3", "m2"}; !reflect.DeepEqual(ids, want) {
+		return errorf("ScanMentions since 1h = %v, want %v", ids, want)
+	}
+	return nil
+}
+
+func checkDrafts(ctx context.Context, s storage.Store) error {
+	for i, id := range []string{"d1", "d2", "d3"} {
+		d := storage.Draft{ID: id, Account: "default", Text: "draft " + id, At: at(i), TZ: "Europe/Berlin", Status: "scheduled", Created: at(0), Updated: at(0)}
+		if err := s.SaveDraft(ctx, d); err != nil {
+			return err
+		}
+	}
+	// rescheduling d1 past d3 moves it in the scan and keeps one copy
+	d := storage.Draft{ID: "d1", Account: "default", Text: "draft d1", At: at(5), TZ: "UTC", Status: "scheduled", Author: "ana", Created: at(0), Updated: at(1)}
+	if err := s.SaveDraft(ctx, d); err != nil {
+		return err
+	}
+	got, err := s.GetDraft(ctx, "d1")
+	if err != nil || got == nil || !got.At.Equal(at(5)) || got.TZ != "UTC" || got.Author != "ana" || !got.Created.Equal(at(0)) {
+		return errorf("GetDraft(d1) = %+v, %v", got, err)
+	}
+	if got, err := s.GetDraft(ctx, "nope"); err != nil || got != nil {
+		return errorf("GetDraft(nope) = %+v, %v", got, err)
+	}
+	scan := func(since, until time.Time) ([]string, error) {
+		var ids []string
+		err := s.ScanDrafts(ctx, since, until, func(d storage.Draft) error {
+			ids = append(ids, d.ID)
+			return nil
+		})
+		return ids, err
+	}
+	ids, err := scan(at(1), time.Time{})
+	if err != nil {
+		return err
+	}
+	if want := []string{"d2", "d3", "d1"}; !reflect.DeepEqual(ids, want) {
+		return errorf("ScanDrafts since 1h = %v, want %v", ids, want)
+	}
+	if err := s.DeleteDraft(ctx, "d2"); err != nil {
+		return err
+	}
+	if err := s.DeleteDraft(ctx, "d2"); err != nil {
+		return errorf("DeleteDraft twice: %v", err)
+	}
+	if ids, err = scan(time.Time{}, at(5)); err != nil || !reflect.DeepEqual(ids, []string{"d3"}) {
+		return errorf("ScanDrafts until 5h after deleting d2 = %v, %v, want [d3]", ids, err)
+	}
+	return nil
+}
+
+func checkEvergreen(ctx context.Context, s storage.Store) error {
+	entries := []storage.Evergreen{
+		{ID: "e1", PostID: "e1", Text: "original", Topics: []string{"go", "k8s"}, Style: "tip", At: at(0)},
+		{ID: "e2", Text: "written for the library", Author: "ana", At: at(2)},
+		{ID: "e3", Text: "another", At: at(1)},
+	}
+	for _, e := range entries {
+		if err := s.SaveEvergreen(ctx, e); err != nil {
+			return err
+		}
+	}
+	for i, id := range []string{"p2", "p1"} {
+		if err := s.RecordPost(ctx, storage.Post{ID: id, Text: "again " + id, RecycledFrom: "e1", At: at(5 - 2*i)}); err != nil {
+			return err
+		}
+	}
+	if err := s.RecordPost(ctx, storage.Post{ID: "p3", Text: "fresh", At: at(4)}); err != nil {
+		return err
+	}
+	recycled := func(e *storage.Evergreen) []string {
+		var ids []string
+		for _, r := range e.Recycles {
+			ids = append(ids, fmt.Sprintf("%s %s %s", r.PostID, r.At.Sub(base), r.Text))
+		}
+		return ids
+	}
+	want := []string{"p1 3h0m0s again p1", "p2 5h0m0s again p2"}
+	got, err := s.GetEvergreen(ctx, "e1")
+	if err != nil || got == nil || got.Text != "original" || got.Style != "tip" || !reflect.DeepEqual(got.Topics, entries[0].Topics) || !got.At.Equal(at(0)) {
+		return errorf("GetEvergreen(e1) = %+v, %v", got, err)
+	}
+	if ids := recycled(got); !reflect.DeepEqual(ids, want) {
+		return errorf("GetEvergreen(e1) recycles = %v, want %v", ids, want)
+	}
+	if got, err := s.GetEvergreen(ctx, "nope"); err != nil || got != nil {
+		return errorf("GetEvergreen(nope) = %+v, %v", got, err)
+	}
+	scan := func() ([]string, error) {
+		var ids []string
+		err := s.ScanEvergreen(ctx, time.Time{}, time.Time{}, func(e storage.Evergreen) error {
+			ids = append(ids, fmt.Sprintf("%s:%d", e.ID, len(e.Recycles)))
+			return nil
+		})
+		return ids, err
+	}
+	ids, err := scan()
+	if err != nil {
+		return err
+	}
+	if want := []string{"e2:0", "e3:0", "e1:2"}; !reflect.DeepEqual(ids, want) {
+		return errorf("ScanEvergreen = %v, want %v", ids, want)
+	}
+	// recycles come from the posts: removing an entry and adding it back
+	// keeps them, and saving one ignores what it carries
+	if err := s.DeleteEvergreen(ctx, "e1"); err != nil {
+		return err
+	}
+	if err := s.DeleteEvergreen(ctx, "e1"); err != nil {
+		return errorf("DeleteEvergreen twice: %v", err)
+	}
+	if got, err := s.GetEvergreen(ctx, "e1"); err != nil || got != nil {
+		return errorf("GetEvergreen after delete = %+v, %v", got, err)
+	}
+	e := entries[0]
+	e.Recycles = []storage.Recycle{{PostID: "bogus", At: at(9)}}
+	if err := s.SaveEvergreen(ctx, e); err != nil {
+		return err
+	}
+	if got, err = s.GetEvergreen(ctx, "e1"); err != nil || got == nil || !reflect.DeepEqual(recycled(got), want) {
+		return errorf("GetEvergreen(e1) after adding it back = %+v, %v, want recycles %v", got, err, want)
+	}
+	if p, err := s.GetPost(ctx, "p1"); err != nil || p == nil || p.RecycledFrom != "e1" {
+		return errorf("GetPost(p1) = %+v, %v, want recycled from e1", p, err)
+	}
+	return nil
+}
+
+func checkMarkers(ctx context.Context, s storage.Store) error {
+	if ok, err := s.WasPosted(ctx, "20240301-0900"); err != nil || ok {
+		return errorf("WasPosted before marking = %v, %v", ok, err)
+	}
+	if err := s.MarkPosted(ctx, "20240301-0900", "shadow-1"); err != nil {
+		return err
+	}
+	if ok, err := s.WasPosted(ctx, "20240301-0900"); err != nil || !ok {
+		return errorf("WasPosted after MarkPosted = %v, %v", ok, err)
+	}
+	if err := s.BeginIntent(ctx, storage.Intent{Kind: "post", Key: "20240301-1200", Text: "x", Started: at(0)}); err != nil {
+		return err
+	}
+	if err := s.RecordPost(ctx, storage.Post{ID: "p1", Text: "x", Slot: "20240301-1200", At: at(0)}); err != nil {
+		return err
+	}
+	if ok, err := s.WasPosted(ctx, "20240301-1200"); err != nil || !ok {
+		return errorf("WasPosted after RecordPost with slot = %v, %v", ok, err)
+	}
+	if ok, err := s.HasIntent(ctx, "post", "20240301-1200"); err != nil || ok {
+		return errorf("slot intent survived RecordPost: %v, %v", ok, err)
+	}
+	if err := s.MarkSeen(ctx, "t9"); err != nil {
+		return err
+	}
+	if ok, err := s.IsSeen(ctx, "t9"); err != nil || !ok {
+		return errorf("IsSeen after MarkSeen = %v, %v", ok, err)
+	}
+	if ok, err := s.IsSeen(ctx, "t10"); err != nil || ok {
+		return errorf("IsSeen(unseen) = %v, %v", ok, err)
+	}
+	return nil
+}
+
+func checkPlans(ctx context.Context, s storage.Store) error {
+	day := time.Now().Format("20060102")
+	want := storage.SlotPlan{Day: day, Slots: []storage.PlanSlot{{Key: day + "-0900", At: at(0)}, {Key: day + "-1800", At: at(9)}}}
+	if err := s.SavePlan(ctx, want); err != nil {
+		return err
+	}
+	got, err := s.LoadPlan(ctx, day)
+	if err != nil {
+		return err
+	}
+	if got == nil || got.Day != day || len(got.Slots) != 2 || got.Slots[1].Key != want.Slots[1].Key || !got.Slots[1].At.Equal(at(9)) {
+		return errorf("LoadPlan = %+v", got)
+	}
+	if p, err := s.LoadPlan(ctx, "19990101"); err != nil || p != nil {
+		return errorf("LoadPlan(missing) = %v, %v", p, err)
+	}
+	return nil
+}
+
+func checkIntents(ctx context.Context, s storage.Store) error {
+	in := storage.Intent{Kind: "post", Key: "k1", Text: "draft", Started: at(0), Topics: []string{"go"}, Style: "tip", RecycledFrom: "e1"}
+	if err := s.BeginIntent(ctx, in); err != nil {
+		return err
+	}
+	if err := s.BeginIntent(ctx, storage.Intent{Kind: "reply", Key: "t1", Text: "r", Started: at(1), AuthorID: "a1", ConversationID: "c1", Flagged: at(2)}); err != nil {
+		return err
+	}
+	all, err := s.Intents(ctx)
+	if err != nil {
+		return err
+	}
+	if len(all) != 2 || all[0].Key != "k1" || all[0].Style != "tip" || all[0].RecycledFrom != "e1" || !reflect.DeepEqual(all[0].Topics, in.Topics) || !all[0].Started.Equal(at(0)) ||
+		all[1].AuthorID != "a1" || all[1].ConversationID != "c1" || !all[1].Flagged.Equal(at(2)) || !all[0].Flagged.IsZero() {
+		return errorf("Intents = %+v", all)
+	}
+	if err := s.ClearIntent(ctx, "post", "k1"); err != nil {
+		return err
+	}
+	if ok, err := s.HasIntent(ctx, "post", "k1"); err != nil || ok {
+		return errorf("HasIntent after ClearIntent = %v, %v", ok, err)
+	}
+	if ok, err := s.HasIntent(ctx, "reply", "t1"); err != nil || !ok {
+		return errorf("HasIntent(reply t1) = %v, %v", ok, err)
+	}
+	return nil
+}
+
+func checkUsage(ctx context.Context, s storage.Store) error {
+	for i := 0; i < 3; i++ {
+		u := storage.UsageRecord{At: at(i), Model: "m", Purpose: "post", PromptTokens: 10 * (i + 1), CompletionTokens: i, LatencyMS: 5}
+		if err := s.AddUsage(ctx, u); err != nil {
+			return err
+		}
+	}
+	got, err := s.UsageSince(ctx, at(1))
+	if err != nil {
+		return err
+	}
+	if len(got) != 2 || got[0].PromptTokens != 20 || got[1].PromptTokens != 30 || !got[0].At.Equal(at(1)) {
+		return errorf("UsageSince(1h) = %+v, want the last two, oldest first", got)
+	}
+	return nil
+}
+
+func checkLogs(ctx context.Context, s storage.Store) error {
+	logs := []struct {
+		name   string
+		append func(context.Context, time.Time, []byte) error
+		scan   func(context.Context, time.Time, time.Time, func([]byte) error) error
+	}{
+		{"audit", s.AppendAudit, s.ScanAudit},
+		{"shadow", s.AppendShadow, s.ScanShadow},
+	}
+	for _, l := range logs {
+		for i := 0; i < 4; i++ {
+			if err := l.append(ctx, at(i), []byte(fmt.Sprintf(`{"n":%d}`, i))); err != nil {
+				return err
+			}
+		}
+		var got []string
+		err := l.scan(ctx, at(1), at(3), func(v []byte) error {
+			got = append(got, string(v))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(got, []string{`{"n":2}`, `{"n":1}`}) {
+			return errorf("%s scan [1h,3h) = %v, want n 2 then 1", l.name, got)
+		}
+		got = nil
+		err = l.scan(ctx, time.Time{}, time.Time{}, func(v []byte) error {
+			got = append(got, string(v))
+			if len(got) == 2 {
+				return storage.ErrStop
+			}
+			return nil
+		})
+		if err != nil || len(got) != 2 || got[0] != `{"n":3}` {
+			return errorf("%s scan with ErrStop = %v, %v", l.name, got, err)
+		}
+	}
+	return nil
+}
+
+func checkSweep(ctx context.Context, s storage.Store) error {
+	old := time.Now().Add(-40 * 24 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+	lines := []string{
+		fmt.Sprintf(`{"kind":"seen","id":"old","seen":{"id":"old","at":%q}}`, old.Format(time.RFC3339Nano)),
+		fmt.Sprintf(`{"kind":"seen","id":"new","seen":{"id":"new","at":%q}}`, recent.Format(time.RFC3339Nano)),
+		fmt.Sprintf(`{"kind":"post","id":"p1","post":{"id":"p1","text":"kept","at":%q}}`, old.Format(time.RFC3339Nano)),
+	}
+	if _, err := s.ImportJSONL(ctx, strings.NewReader(strings.Join(lines, "\n"))); err != nil {
+		return err
+	}
+	s.SetRetention(storage.Retention{Seen: 30 * 24 * time.Hour})
+	res, err := s.Sweep(ctx)
+	if err != nil {
+		return err
+	}
+	// Badger counts keys, so a record and its index entry are two
+	if res.Expired < 1 {
+		return errorf("Sweep expired %d, want at least 1", res.Expired)
+	}
+	if ok, _ := s.IsSeen(ctx, "old"); ok {
+		return errorf("seen tweet past retention survived Sweep")
+	}
+	if ok, _ := s.IsSeen(ctx, "new"); !ok {
+		return errorf("seen tweet within retention was swept")
+	}
+	if p, _ := s.GetPost(ctx, "p1"); p == nil {
+		return errorf("Sweep removed a post")
+	}
+	return nil
+}
+
+func checkJSONL(ctx context.Context, s storage.Store) error {
+	if err := s.RecordPost(ctx, storage.Post{ID: "p1", Text: "a", Topics: []string{"go"}, Slot: "20240301-0900", RecycledFrom: "e1", At: at(0)}); err != nil {
+		return err
+	}
+	if err := s.SaveEvergreen(ctx, storage.Evergreen{ID: "e1", Text: "A", At: at(0)}); err != nil {
+		return err
+	}
+	if err := s.SetPostMetrics(ctx, "p1", storage.PostMetrics{Likes: 4, Updated: at(1)}); err != nil {
+		return err
+	}
+	if err := s.RecordReply(ctx, storage.Reply{ID: "r1", InReplyTo: "t1", AuthorID: "a1", ConversationID: "c1", Text: "b", At: at(2)}); err != nil {
+		return err
+	}
+	if err := s.AddUsage(ctx, storage.UsageRecord{At: at(3), Model: "m", Purpose: "reply", PromptTokens: 1}); err != nil {
+		return err
+	}
+	if err := s.SaveMention(ctx, storage.Mention{ID: "m1", Text: "@us hi", At: at(4), Status: "queued", Draft: "hello", Updated: at(4)}); err != nil {
+		return err
+	}
+	if err := s.RecordEngagement(ctx, storage.Engagement{Kind: "like", TweetID: "t2", Score: 0.4, At: at(5)}); err != nil {
+		return err
+	}
+	if err := s.SaveDraft(ctx, storage.Draft{ID: "d1", Account: "default", Text: "later", At: at(6), TZ: "UTC", Status: "scheduled"}); err != nil {
+		return err
+	}
+	var first bytes.Buffer
+	counts, err := s.ExportJSONL(ctx, &first)
+	if err != nil {
+		return err
+	}
+	want := map[string]int{"post": 1, "reply": 1, "engagement": 1, "seen": 2, "slot": 1, "mention": 1, "draft": 1, "evergreen": 1, "usage": 1}
+	if !reflect.DeepEqual(counts, want) {
+		return errorf("ExportJSONL counts = %v, want %v", counts, want)
+	}
+	if !strings.Contains(first.String(), `"conversation_id":"c1"`) {
+		return errorf("export dropped the reply's conversation:\n%s", first.String())
+	}
+	// importing our own export is a no-op
+	if _, err := s.ImportJSONL(ctx, bytes.NewReader(first.Bytes())); err != nil {
+		return err
+	}
+	var second bytes.Buffer
+	if _, err := s.ExportJSONL(ctx, &second); err != nil {
+		return err
+	}
+	if first.String() != second.String() {
+		return errorf("export changed after re-importing it:\n%s\nvs\n%s", first.String(), second.String())
+	}
+	p, err := s.GetPost(ctx, "p1")
+	if err != nil || p == nil || p.Metrics == nil || p.Metrics.Likes != 4 {
+		return errorf("post after import = %+v, %v", p, err)
+	}
+	if e, err := s.GetEvergreen(ctx, "e1"); err != nil || e == nil || len(e.Recycles) != 1 || e.Recycles[0].PostID != "p1" {
+		return errorf("library entry after import = %+v, %v", e, err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// Store is everything the bot keeps: published posts, replies, quotes, likes
+// and reposts, dedupe markers, the mentions inbox, calendar drafts, the
+// content library, the day's plan, write intents, poller checkpoints and the
+// usage, audit and shadow logs. Badger and SQLite implement it; storagetest
+// checks that they agree.
+//
+// Scans with since/until treat zero times as open ends, include since and
+// exclude until. Returning ErrStop from a scan callback ends it early.
+type Store interface {
+	// RecordPost stores a published post and, if it filled a slot, marks
+	// the slot and clears its intent, all at once.
+	RecordPost(ctx context.Context, p Post) error
+	// RecordReply stores a published reply, marks its target seen and
+	// clears its intent.
+	RecordReply(ctx context.Context, r Reply) error
+	// RecordEngagement stores a quote, like or repost the same way.
+	RecordEngagement(ctx context.Context, e Engagement) error
+	GetPost(ctx context.Context, id string) (*Post, error)
+	SetPostMetrics(ctx context.Context, id string, m PostMetrics) error
+	// ScanPosts, ScanReplies and ScanEngagements run newest first.
+	ScanPosts(ctx context.Context, since, until time.Time, fn func(Post) error) error
+	ScanReplies(ctx context.Context, since, until time.Time, fn func(Reply) error) error
+	ScanEngagements(ctx context.Context, since, until time.Time, fn func(Engagement) error) error
+	// LastReplyTo returns our newest reply to an author or in a
+	// conversation, by ByAuthor or ByConversation, or nil.
+	LastReplyTo(ctx context.Context, by, key string) (*Reply, error)
+	RecentPosts(ctx context.Context, limit int) ([]Post, error)
+	RecentPostTexts(ctx context.Context, limit int) ([]string, error)
+	// TopPosts returns posts since the given time, optionally with topic,
+	// ordered by likes then replies.
+	TopPosts(ctx context.Context, since time.Time, topic string, limit int) ([]Post, error)
+	CountPosts(ctx context.Context) (int, error)
+	CountReplies(ctx context.Context) (int, error)
+
+	MarkPosted(ctx context.Context, key, postID string) error
+	WasPosted(ctx context.Context, key string) (bool, error)
+	MarkSeen(ctx context.Context, id string) error
+	IsSeen(ctx context.Context, id string) (bool, error)
+	// SaveMention stores or updates a mention; ScanMentions runs newest
+	// first by the time it was posted.
+	SaveMention(ctx context.Context, m Mention) error
+	GetMention(ctx context.Context, id string) (*Mention, error)
+	ScanMentions(ctx context.Context, since, until time.Time, fn func(Mention) error) error
+	// SaveDraft stores or updates a content calendar draft; ScanDrafts runs
+	// soonest first by the time it is due.
+	SaveDraft(ctx context.Context, d Draft) error
+	GetDraft(ctx context.Context, id string) (*Draft, error)
+	DeleteDraft(ctx context.Context, id string) error
+	ScanDrafts(ctx context.Context, since, until time.Time, fn func(Draft) error) error
+	// SaveEvergreen stores or updates a content library entry; ScanEvergreen
+	// runs newest first by the time of the original. Entries come back with
+	// the posts recycled from them.
+	SaveEvergreen(ctx context.Context, e Evergreen) error
+	GetEvergreen(ctx context.Context, id string) (*Evergreen, error)
+	DeleteEvergreen(ctx context.Context, id string) error
+	ScanEvergreen(ctx context.Context, since, until time.Time, fn func(Evergreen) error) error
+	// Checkpoint and SetCheckpoint keep a poller's position, such as the
+	// since_id of the mentions poller, across restarts.
+	Checkpoint(ctx context.Context, name string) (string, error)
+	SetCheckpoint(ctx context.Context, name, value string) error
+
+	SavePlan(ctx context.Context, p SlotPlan) error
+	LoadPlan(ctx context.Context, day string) (*SlotPlan, error)
+
+	BeginIntent(ctx context.Context, in Intent) error
+	ClearIntent(ctx context.Context, kind, key string) error
+	HasIntent(ctx context.Context, kind, key string) (bool, error)
+	Intents(ctx context.Context) ([]Intent, error)
+
+	// AddUsage appends; UsageSince runs oldest first.
+	AddUsage(ctx context.Context, u UsageRecord) error
+	UsageSince(ctx context.Context, t time.Time) ([]UsageRecord, error)
+	// The audit and shadow logs are append-only; scans run newest first.
+	AppendAudit(ctx context.Context, at time.Time, v []byte) error
+	ScanAudit(ctx context.Context, since, until time.Time, fn func(v []byte) error) error
+	AppendShadow(ctx context.Context, at time.Time, v []byte) error
+	ScanShadow(ctx context.Context, since, until time.Time, fn func(v []byte) error) error
+
+	// ExportJSONL and ImportJSONL move posts, replies, engagements, seen
+	// tweets, slot markers, mentions, drafts, library entries and usage
+	// between stores, including across backends.
+	ExportJSONL(ctx context.Context, w io.Writer) (map[string]int, error)
+	ImportJSONL(ctx context.Context, r io.Reader) (map[string]int, error)
+
+	SetRetention(r Retention)
+	Retention() Retention
+	// Sweep removes dedupe and shadow data past retention.
+	Sweep(ctx context.Context) (SweepResult, error)
+	// GC reclaims space freed by deletes and expiry.
+	GC(ratio float64) (int, error)
+	Compact() error
+	Usage(ctx context.Context) ([]PrefixUsage, error)
+	// Size reports the main and log file sizes in bytes: LSM and value log
+	// for Badger, database and WAL for SQLite.
+	Size() (main, log int64)
+	Migrated() []string
+	Ping(ctx context.Context) error
+	Sync() error
+	Close() error
+}
+
+// Backuper is implemented by stores that support online backups.
+type Backuper interface {
+	Backup(w io.Writer) error
+	Load(r io.Reader) error
+	BackupTo(ctx context.Context, dir string) (string, Manifest, error)
+	Restore(path string) (int, error)
+}
+
+// ErrNoBackup is returned for backup operations on a store that isn't a
+// Backuper.
+var ErrNoBackup = errors.New("storage backend has no online backups; use db export --format jsonl")
+
+// AsBackuper returns s as a Backuper, or ErrNoBackup.
+func AsBackuper(s Store) (Backuper, error) {
+	b, ok := s.(Backuper)
+	if !ok {
+		return nil, ErrNoBackup
+	}
+	return b, nil
+}
+
+var (
+	_ Store    = (*Badger)(nil)
+	_ Backuper = (*Badger)(nil)
+	_ Store    = (*SQLite)(nil)
+)
+
+// Backends lists the names Open accepts.
+var Backends = []string{"badger", "sqlite"}
+
+// Open opens the named backend in dir: dir/bot.db for Badger, dir/bot.sqlite
+// for SQLite.
+func Open(backend, dir string) (Store, error) {
+	switch backend {
+	case "badger", "":
+		return OpenBadger(dir)
+	case "sqlite":
+		return OpenSQLite(filepath.Join(dir, "bot.sqlite"))
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
@@ -0,0 +1,201 @@
+// Package throttle decides whether the bot may reply to a tweet, from what
+// it remembers of earlier replies: per-author and per-conversation
+// cooldowns, a daily cap, and allow and deny lists of authors. It is
+// consulted before a reply is generated, so refused tweets cost no tokens.
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+)
+
+// Rules are the limits a Throttle applies. Zero cooldowns and a zero cap
+// turn that limit off.
+type Rules struct {
+	AuthorCooldown       time.Duration
+	ConversationCooldown time.Duration
+	DailyCap             int
+	// Allow and Deny hold author IDs or @usernames. Deny wins; a non-empty
+	// Allow restricts replies to the authors on it.
+	Allow []string
+	Deny  []string
+}
+
+// Target is the tweet a reply would answer.
+type Target struct {
+	AuthorID       string
+	Author         string // username, without the @
+	ConversationID string
+}
+
+// Refusal is the rule that stopped a reply. Rule is one of "deny",
+// "allow", "author", "conversation" or "daily_cap".
+type Refusal struct {
+	Rule   string
+	Reason string
+}
+
+func (r *Refusal) Error() string { return r.Reason }
+
+// IsDailyCap reports whether err is a daily cap refusal, after which no
+// other reply in the pass can go out either.
+func IsDailyCap(err error) bool {
+	var r *Refusal
+	return errors.As(err, &r) && r.Rule == "daily_cap"
+}
+
+// Throttle checks targets for one pass of replies. Replies the pass sends
+// are noted with Sent, so the rules hold within the pass even when a dry run
+// stores nothing.
+type Throttle struct {
+	store storage.Store
+	rules Rules
+	loc   *time.Location
+	now   func() time.Time
+
+	today   int // replies stored today, read on first check
+	counted bool
+	sent    int
+	authors map[string]bool
+	convs   map[string]bool
+}
+
+// New returns a Throttle reading reply history from store. Days for the
+// daily cap start at midnight in loc.
+func New(store storage.Store, rules Rules, loc *time.Location) *Throttle {
+	return &Throttle{store: store, rules: rules, loc: loc, now: time.Now,
+		authors: map[string]bool{}, convs: map[string]bool{}}
+}
+
+// Check returns nil if a reply to t is allowed, or a *Refusal naming the
+// rule that refuses it.
+func (th *Throttle) Check(ctx context.Context, t Target) error {
+	if listed(th.rules.Deny, t) {
+		return &Refusal{"deny", "author is on the deny list"}
+	}
+	if len(th.rules.Allow) > 0 && !listed(th.rules.Allow, t) {
+		return &Refusal{"allow", "author is not on the allow list"}
+	}
+	if err := th.checkCap(ctx); err != nil {
+		return err
+	}
+	return th.Cooldown(ctx, t)
+}
+
+// Cooldown returns a *Refusal if t's author or conversation was replied to
+// too recently, ignoring the lists and the daily cap.
+func (th *Throttle) Cooldown(ctx context.Context, t Target) error {
+	now := th.now()
+	if cd := th.rules.AuthorCooldown; cd > 0 && t.AuthorID != "" {
+		if th.authors[t.AuthorID] {
+			return &Refusal{"author", "already replied to this author in this pass"}
+		}
+		if err := th.cooldown(ctx, storage.ByAuthor, t.AuthorID, cd, now, "author"); err != nil {
+			return err
+		}
+	}
+	if cd := th.rules.ConversationCooldown; cd > 0 && t.ConversationID != "" {
+		if th.convs[t.ConversationID] {
+			return &Refusal{"conversation", "already replied in this conversation in this pass"}
+		}
+		if err := th.cooldown(ctx, storage.ByConversation, t.ConversationID, cd, now, "conversation"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sent notes a reply to t made in this pass.
+func (th *Throttle) Sent(t Target) {
+	th.sent++
+	if t.AuthorID != "" {
+		th.authors[t.AuthorID] = true
+	}
+	if t.ConversationID != "" {
+		th.convs[t.ConversationID] = true
+	}
+}
+
+// Remaining is how many more replies the daily cap allows today, or -1
+// without a cap.
+func (th *Throttle) Remaining(ctx context.Context) (int, error) {
+	if th.rules.DailyCap <= 0 {
+		return -1, nil
+	}
+	if err := th.count(ctx); err != nil {
+		return 0, err
+	}
+	return max(th.rules.DailyCap-th.today-th.sent, 0), nil
+}
+
+func (th *Throttle) checkCap(ctx context.Context) error {
+	left, err := th.Remaining(ctx)
+	if err != nil {
+		return err
+	}
+	if left == 0 {
+		return &Refusal{"daily_cap", fmt.Sprintf("daily cap of %d replies reached", th.rules.DailyCap)}
+	}
+	return nil
+}
+
+// count reads how many replies were stored since midnight, once per pass;
+// replies sent since are counted by Sent.
+func (th *Throttle) count(ctx context.Context) error {
+	if th.counted {
+		return nil
+	}
+	y, m, d := th.now().In(th.loc).Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, th.loc)
+	n := 0
+	err := th.store.ScanReplies(ctx, midnight, time.Time{}, func(storage.Reply) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	th.today, th.counted = n, true
+	return nil
+}
+
+func (th *Throttle) cooldown(ctx context.Context, by, key string, cd time.Duration, now time.Time, rule string) error {
+	last, err := th.store.LastReplyTo(ctx, by, key)
+	if err != nil || last == nil {
+		return err
+	}
+	if ago := now.Sub(last.At); ago < cd {
+		return &Refusal{rule, fmt.Sprintf("replied to this %s %s ago; cooldown %s", rule, days(ago), days(cd))}
+	}
+	return nil
+}
+
+// listed reports whether t's author is on list, by ID or username.
+func listed(list []string, t Target) bool {
+	for _, e := range list {
+		e = strings.TrimPrefix(strings.TrimSpace(e), "@")
+		if e == "" {
+			continue
+		}
+		if e == t.AuthorID || (t.Author != "" && strings.EqualFold(e, t.Author)) {
+			return true
+		}
+	}
+	return false
+}
+
+// days prints d in days past 48 hours, else in hours or minutes.
+func days(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours())/24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
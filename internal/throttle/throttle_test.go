@@ -0,0 +1,118 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+)
+
+var now = time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+
+// newThrottle returns a Throttle at now over a store holding three replies:
+// to a1 in c1 two hours ago, a2 in c2 three days ago and a3 in c3 yesterday.
+func newThrottle(t *testing.T, rules Rules) *Throttle {
+	t.Helper()
+	store, err := storage.Open("sqlite", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	for _, r := range []storage.Reply{
+		{AuthorID: "a1", ConversationID: "c1", At: now.Add(-2 * time.Hour)},
+		{AuthorID: "a2", ConversationID: "c2", At: now.Add(-72 * time.Hour)},
+		{AuthorID: "a3", ConversationID: "c3", At: now.Add(-20 * time.Hour)},
+	} {
+		r.ID, r.InReplyTo = "r"+r.AuthorID, "t"+r.AuthorID
+		if err := store.RecordReply(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	th := New(store, rules, time.UTC)
+	th.now = func() time.Time { return now }
+	return th
+}
+
+func TestCheck(t *testing.T) {
+	rules := Rules{
+		AuthorCooldown:       7 * 24 * time.Hour,
+		ConversationCooldown: 24 * time.Hour,
+		DailyCap:             10,
+		Deny:                 []string{" @Spammer ", "666", ""},
+	}
+	tests := []struct {
+		name   string
+		rules  Rules
+		target Target
+		rule   string // "" when allowed
+		reason string
+	}{
+		{"fresh author", rules, Target{AuthorID: "a9", ConversationID: "c9"}, "", ""},
+		{"deny by username", rules, Target{AuthorID: "a9", Author: "spammer"}, "deny", "author is on the deny list"},
+		{"deny by ID", rules, Target{AuthorID: "666"}, "deny", "author is on the deny list"},
+		{"deny beats cooldown", rules, Target{AuthorID: "a2", Author: "spammer"}, "deny", "author is on the deny list"},
+		{"author cooldown", rules, Target{AuthorID: "a2", ConversationID: "c9"}, "author", "replied to this author 3d ago; cooldown 7d"},
+		{"conversation cooldown", rules, Target{AuthorID: "a9", ConversationID: "c3"}, "conversation", "replied to this conversation 20h ago; cooldown 24h"},
+		{"conversation cooled down", rules, Target{AuthorID: "a9", ConversationID: "c2"}, "", ""},
+		{"no cooldowns", Rules{}, Target{AuthorID: "a1", ConversationID: "c1"}, "", ""},
+		{"not on the allow list", Rules{Allow: []string{"@friend"}}, Target{AuthorID: "a9", Author: "other"}, "allow", "author is not on the allow list"},
+		{"on the allow list", Rules{Allow: []string{"@friend"}}, Target{AuthorID: "a9", Author: "Friend"}, "", ""},
+		{"allowed by ID", Rules{Allow: []string{"a9"}}, Target{AuthorID: "a9"}, "", ""},
+		{"deny beats allow", Rules{Allow: []string{"a9"}, Deny: []string{"a9"}}, Target{AuthorID: "a9"}, "deny", "author is on the deny list"},
+		{"cap already reached today", Rules{DailyCap: 1}, Target{AuthorID: "a9"}, "daily_cap", "daily cap of 1 replies reached"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newThrottle(t, tt.rules).Check(context.Background(), tt.target)
+			if tt.rule == "" {
+				if err != nil {
+					t.Fatalf("Check = %v, want allowed", err)
+				}
+				return
+			}
+			r, ok := err.(*Refusal)
+			if !ok || r.Rule != tt.rule || r.Reason != tt.reason {
+				t.Fatalf("Check = %v, want %s refusal %q", err, tt.rule, tt.reason)
+			}
+			if IsDailyCap(err) != (tt.rule == "daily_cap") {
+				t.Fatalf("IsDailyCap(%v) = %v", err, IsDailyCap(err))
+			}
+		})
+	}
+}
+
+func TestWithinPass(t *testing.T) {
+	ctx := context.Background()
+	th := newThrottle(t, Rules{AuthorCooldown: time.Hour, ConversationCooldown: time.Hour, DailyCap: 3})
+
+	// one reply today (a1, two hours ago) leaves two
+	if left, err := th.Remaining(ctx); err != nil || left != 2 {
+		t.Fatalf("Remaining = %d, %v, want 2", left, err)
+	}
+	first := Target{AuthorID: "a8", ConversationID: "c8"}
+	if err := th.Check(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	th.Sent(first)
+
+	// the store knows nothing of a8 yet, the pass does
+	if err := th.Check(ctx, Target{AuthorID: "a8", ConversationID: "c9"}); err == nil || err.(*Refusal).Rule != "author" {
+		t.Fatalf("same author again = %v, want an author refusal", err)
+	}
+	if err := th.Check(ctx, Target{AuthorID: "a9", ConversationID: "c8"}); err == nil || err.(*Refusal).Rule != "conversation" {
+		t.Fatalf("same conversation again = %v, want a conversation refusal", err)
+	}
+
+	th.Sent(Target{AuthorID: "a9", ConversationID: "c9"})
+	if left, _ := th.Remaining(ctx); left != 0 {
+		t.Fatalf("Remaining = %d, want 0", left)
+	}
+	if err := th.Check(ctx, Target{AuthorID: "a7"}); !IsDailyCap(err) {
+		t.Fatalf("past the cap Check = %v, want the daily cap", err)
+	}
+
+	if left, _ := newThrottle(t, Rules{}).Remaining(ctx); left != -1 {
+		t.Fatalf("without a cap Remaining = %d, want -1", left)
+	}
+}
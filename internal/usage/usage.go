@@ -0,0 +1,219 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/gen"
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+)
+
+// ErrBudgetExceeded is returned by Allow once the daily or monthly spend
+// reaches its budget. Generation resumes when the period rolls over.
+var ErrBudgetExceeded = errors.New("generation budget exceeded")
+
+// Price is USD per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// ParsePrices parses "model=in/out,model=in/out" (USD per 1M tokens).
+func ParsePrices(s string) (map[string]Price, error) {
+	out := map[string]Price{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		model, rates, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("price %q: want model=input/output", part)
+		}
+		in, outRate, ok := strings.Cut(rates, "/")
+		if !ok {
+			return nil, fmt.Errorf("price %q: want model=input/output", part)
+		}
+		pi, err := strconv.ParseFloat(strings.TrimSpace(in), 64)
+		if err != nil {
+			return nil, fmt.Errorf("price %q: %v", part, err)
+		}
+		po, err := strconv.ParseFloat(strings.TrimSpace(outRate), 64)
+		if err != nil {
+			return nil, fmt.Errorf("price %q: %v", part, err)
+		}
+		out[strings.TrimSpace(model)] = Price{Input: pi, Output: po}
+	}
+	return out, nil
+}
+
+// Accountant records Gemini usage in storage and enforces spend budgets.
+// It implements gen.Meter.
+type Accountant struct {
+	store   storage.Store
+	prices  map[string]Price
+	daily   float64
+	monthly float64
+	loc     *time.Location
+	now     func() time.Time
+
+	mu       sync.Mutex
+	cachedAt time.Time
+	cached   Summary
+}
+
+func New(store storage.Store, prices map[string]Price, dailyUSD, monthlyUSD float64, loc *time.Location) *Accountant {
+	return &Accountant{store: store, prices: prices, daily: dailyUSD, monthly: monthlyUSD, loc: loc, now: time.Now}
+}
+
+// Cost converts token counts into USD for model.
+func (a *Accountant) Cost(model string, prompt, completion int) float64 {
+	p := a.prices[model]
+	return (float64(prompt)*p.Input + float64(completion)*p.Output) / 1e6
+}
+
+// Allow refuses new calls while either budget is spent. Totals are cached
+// briefly so bursts of candidate generation don't rescan storage each time.
+func (a *Accountant) Allow(ctx context.Context, _ string) error {
+	if a.daily <= 0 && a.monthly <= 0 {
+		return nil
+	}
+	s, err := a.summary(ctx, 5*time.Second)
+	if err != nil {
+		return nil // don't block generation on a storage read error
+	}
+	if s.Paused {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+// Record stores u and adds its cost to the cached totals, so the next Allow
+// sees it without rescanning the month.
+func (a *Accountant) Record(ctx context.Context, u gen.Usage) {
+	rec := storage.UsageRecord{
+		At:               a.now(),
+		Model:            u.Model,
+		Purpose:          u.Purpose,
+		PromptTokens:     int(u.PromptTokens),
+		CompletionTokens: int(u.CompletionTokens),
+		LatencyMS:        u.Latency.Milliseconds(),
+	}
+	if err := a.store.AddUsage(ctx, rec); err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cachedAt.IsZero() {
+		return
+	}
+	// totals cached on another day start from zero; let the next read rescan
+	if !sameDay(a.cachedAt.In(a.loc), rec.At.In(a.loc)) {
+		a.cachedAt = time.Time{}
+		return
+	}
+	// Summary hands out the cached maps, so update copies
+	s := a.cached
+	s.ByModel, s.ByPurpose = maps.Clone(s.ByModel), maps.Clone(s.ByPurpose)
+	s.Unpriced = slices.Clone(s.Unpriced)
+	a.add(&s, rec, time.Time{})
+	a.cached = s
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// Totals aggregates usage over a period or a breakdown key.
+type Totals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	AvgLatencyMS     int64   `json:"avg_latency_ms"`
+}
+
+func (t *Totals) add(u storage.UsageRecord, cost float64) {
+	t.AvgLatencyMS = (t.AvgLatencyMS*int64(t.Calls) + u.LatencyMS) / int64(t.Calls+1)
+	t.Calls++
+	t.PromptTokens += u.PromptTokens
+	t.CompletionTokens += u.CompletionTokens
+	t.CostUSD += cost
+}
+
+// Summary is the dashboard cost panel payload.
+type Summary struct {
+	Today            Totals            `json:"today"`
+	Month            Totals            `json:"month"`
+	ByModel          map[string]Totals `json:"by_model"`
+	ByPurpose        map[string]Totals `json:"by_purpose"`
+	BudgetDailyUSD   float64           `json:"budget_daily_usd"`
+	BudgetMonthlyUSD float64           `json:"budget_monthly_usd"`
+	Paused           bool              `json:"paused"`
+	Unpriced         []string          `json:"unpriced_models,omitempty"`
+}
+
+// Summary returns month-to-date usage with today's subset and breakdowns.
+func (a *Accountant) Summary(ctx context.Context) (Summary, error) {
+	return a.summary(ctx, 0)
+}
+
+func (a *Accountant) summary(ctx context.Context, maxAge time.Duration) (Summary, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if maxAge > 0 && a.now().Sub(a.cachedAt) < maxAge {
+		return a.cached, nil
+	}
+
+	now := a.now().In(a.loc)
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, a.loc)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, a.loc)
+	recs, err := a.store.UsageSince(ctx, monthStart)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	s := Summary{
+		ByModel:          map[string]Totals{},
+		ByPurpose:        map[string]Totals{},
+		BudgetDailyUSD:   a.daily,
+		BudgetMonthlyUSD: a.monthly,
+	}
+	for _, u := range recs {
+		a.add(&s, u, dayStart)
+	}
+
+	a.cached, a.cachedAt = s, now
+	return s, nil
+}
+
+// add counts u in s, in today's totals too if it is from dayStart on, and
+// re-evaluates the budgets.
+func (a *Accountant) add(s *Summary, u storage.UsageRecord, dayStart time.Time) {
+	if _, ok := a.prices[u.Model]; !ok {
+		if i, found := slices.BinarySearch(s.Unpriced, u.Model); !found {
+			s.Unpriced = slices.Insert(s.Unpriced, i, u.Model)
+		}
+	}
+	c := a.Cost(u.Model, u.PromptTokens, u.CompletionTokens)
+	s.Month.add(u, c)
+	if !u.At.Before(dayStart) {
+		s.Today.add(u, c)
+	}
+	m := s.ByModel[u.Model]
+	m.add(u, c)
+	s.ByModel[u.Model] = m
+	p := s.ByPurpose[u.Purpose]
+	p.add(u, c)
+	s.ByPurpose[u.Purpose] = p
+	s.Paused = (a.daily > 0 && s.Today.CostUSD >= a.daily) || (a.monthly > 0 && s.Month.CostUSD >= a.monthly)
+}
@@ -0,0 +1,160 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/UjjavalParmar/twitter-automation/internal/gen"
+	"github.com/UjjavalParmar/twitter-automation/internal/storage"
+)
+
+func TestParsePrices(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]Price
+		wantErr bool
+	}{
+		{in: "", want: map[string]Price{}},
+		{in: "a=0.10/0.40", want: map[string]Price{"a": {0.10, 0.40}}},
+		{in: " a = 1 / 2 , b=0.075/0.30,", want: map[string]Price{"a": {1, 2}, "b": {0.075, 0.30}}},
+		{in: "a", wantErr: true},
+		{in: "a=1", wantErr: true},
+		{in: "a=x/2", wantErr: true},
+		{in: "a=1/y", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePrices(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePrices(%q) err = %v", tt.in, err)
+			continue
+		}
+		if !tt.wantErr && !maps.Equal(got, tt.want) {
+			t.Errorf("ParsePrices(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCost(t *testing.T) {
+	a := New(nil, map[string]Price{"m": {Input: 1, Output: 2}}, 0, 0, time.UTC)
+	tests := []struct {
+		model              string
+		prompt, completion int
+		want               float64
+	}{
+		{"m", 1_000_000, 1_000_000, 3},
+		{"m", 500_000, 0, 0.5},
+		{"m", 0, 250_000, 0.5},
+		{"unpriced", 1_000_000, 1_000_000, 0},
+	}
+	for _, tt := range tests {
+		if got := a.Cost(tt.model, tt.prompt, tt.completion); got != tt.want {
+			t.Errorf("Cost(%s, %d, %d) = %v, want %v", tt.model, tt.prompt, tt.completion, got, tt.want)
+		}
+	}
+}
+
+var now = time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+func newAccountant(t *testing.T, daily, monthly float64) (*Accountant, storage.Store) {
+	t.Helper()
+	store, err := storage.Open("sqlite", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	a := New(store, map[string]Price{"m": {Input: 1, Output: 1}}, daily, monthly, time.UTC)
+	a.now = func() time.Time { return now }
+	return a, store
+}
+
+// usd stores a call costing cost dollars at the given time.
+func usd(t *testing.T, store storage.Store, at time.Time, cost float64) {
+	t.Helper()
+	err := store.AddUsage(context.Background(), storage.UsageRecord{
+		At: at, Model: "m", Purpose: "post", PromptTokens: int(cost * 1e6),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPaused(t *testing.T) {
+	yesterday := now.AddDate(0, 0, -1)
+	lastMonth := now.AddDate(0, -1, 0)
+	tests := []struct {
+		name           string
+		daily, monthly float64
+		spend          map[time.Time]float64
+		want           bool
+	}{
+		{"no budgets", 0, 0, map[time.Time]float64{now: 100}, false},
+		{"under the daily budget", 1, 0, map[time.Time]float64{now: 0.5}, false},
+		{"daily budget reached", 1, 0, map[time.Time]float64{now: 1}, true},
+		{"yesterday doesn't count today", 1, 0, map[time.Time]float64{yesterday: 5, now: 0.5}, false},
+		{"monthly budget reached", 0, 5, map[time.Time]float64{yesterday: 4, now: 1}, true},
+		{"last month doesn't count", 0, 5, map[time.Time]float64{lastMonth: 10, now: 1}, false},
+		{"either budget pauses", 10, 5, map[time.Time]float64{yesterday: 5}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, store := newAccountant(t, tt.daily, tt.monthly)
+			for at, c := range tt.spend {
+				usd(t, store, at, c)
+			}
+			s, err := a.Summary(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Paused != tt.want {
+				t.Errorf("Paused = %v, want %v (today $%.2f, month $%.2f)", s.Paused, tt.want, s.Today.CostUSD, s.Month.CostUSD)
+			}
+			err = a.Allow(context.Background(), "m")
+			if got := errors.Is(err, ErrBudgetExceeded); got != tt.want {
+				t.Errorf("Allow = %v, want exceeded %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordUpdatesCache(t *testing.T) {
+	ctx := context.Background()
+	a, store := newAccountant(t, 1, 0)
+	if err := a.Allow(ctx, "m"); err != nil {
+		t.Fatal(err)
+	}
+	// written behind the accountant's back, so only a rescan would see it
+	usd(t, store, now, 0.25)
+
+	a.Record(ctx, gen.Usage{Model: "m", Purpose: "reply", PromptTokens: 600_000})
+	s, err := a.summary(ctx, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Today.Calls != 1 || s.Today.CostUSD != 0.6 || s.ByPurpose["reply"].Calls != 1 {
+		t.Fatalf("cached totals = %+v, want the recorded call added", s.Today)
+	}
+	if err := a.Allow(ctx, "m"); err != nil {
+		t.Fatalf("Allow = %v under budget", err)
+	}
+
+	a.Record(ctx, gen.Usage{Model: "other", Purpose: "reply", PromptTokens: 1})
+	a.Record(ctx, gen.Usage{Model: "m", Purpose: "reply", PromptTokens: 400_000})
+	if err := a.Allow(ctx, "m"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Allow = %v, want ErrBudgetExceeded", err)
+	}
+	s, _ = a.summary(ctx, 5*time.Second)
+	if len(s.Unpriced) != 1 || s.Unpriced[0] != "other" {
+		t.Errorf("Unpriced = %v", s.Unpriced)
+	}
+
+	s, err = a.Summary(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Today.Calls != 4 {
+		t.Errorf("rescan found %d calls, want 4", s.Today.Calls)
+	}
+}
@@ -1,18 +1,136 @@
 package xclient
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"html"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/dghubble/oauth1"
 	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// APIError is a non-success response from the X API.
+type APIError struct {
+	Op         string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed: %s - %s", e.Op, e.Status, e.Body)
+}
+
+func apiError(op string, r *resty.Response) error {
+	return &APIError{Op: op, StatusCode: r.StatusCode(), Status: r.Status(), Body: r.String()}
+}
+
+// StatusCode returns the HTTP status carried by err, or 0 if err didn't come
+// from an X API response.
+func StatusCode(err error) int {
+	var ae *APIError
+	if errors.As(err, &ae) {
+		return ae.StatusCode
+	}
+	return 0
+}
+
+// Temporary reports whether err is an X response worth retrying later as
+// is: rate limited (429) or a server error (5xx).
+func Temporary(err error) bool {
+	code := StatusCode(err)
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
 type Client struct {
-	rest  *resty.Client
-	creds Creds
+	rest     *resty.Client
+	base     *http.Client
+	signer   *signer
+	observer ObserveFunc
+
+	// self caches our user ID for the endpoints keyed by it; SetCreds
+	// clears it since new credentials may belong to another account.
+	self atomic.Pointer[string]
+}
+
+// signer is the OAuth1 transport, swappable while requests are in flight.
+type signer struct {
+	cur atomic.Pointer[http.Client]
+}
+
+func (s *signer) RoundTrip(req *http.Request) (*http.Response, error) {
+	return s.cur.Load().Transport.RoundTrip(req)
+}
+
+func oauthClient(base *http.Client, creds Creds) *http.Client {
+	config := oauth1.NewConfig(creds.APIKey, creds.APISecret)
+	token := oauth1.NewToken(creds.AccessToken, creds.AccessSecret)
+	ctx := context.WithValue(context.Background(), oauth1.HTTPClient, base)
+	return config.Client(ctx, token)
+}
+
+// ObserveFunc is told about every X API attempt: status is 0 for transport
+// errors and remaining is -1 when no rate-limit header came back.
+type ObserveFunc func(op string, status int, latency time.Duration, remaining int)
+
+// SetObserver installs fn for request metrics. Call it before use.
+func (c *Client) SetObserver(fn ObserveFunc) { c.observer = fn }
+
+type opKey struct{}
+
+var tracer = otel.Tracer("github.com/UjjavalParmar/twitter-automation/internal/xclient")
+
+// req starts a request tagged with op for observation.
+func (c *Client) req(ctx context.Context, op string) *resty.Request {
+	return c.rest.R().SetContext(context.WithValue(ctx, opKey{}, op))
+}
+
+// startSpan opens a client span for an X API operation.
+func startSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "xclient."+op, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// endSpan records the outcome of an X API call on span and ends it.
+func endSpan(span trace.Span, r *resty.Response, err error) {
+	if r != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", r.StatusCode()))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (c *Client) observe(req *resty.Request, resp *resty.Response) {
+	if c.observer == nil || req == nil {
+		return
+	}
+	op, _ := req.Context().Value(opKey{}).(string)
+	status, remaining := 0, -1
+	var latency time.Duration
+	if resp != nil && resp.RawResponse != nil {
+		status = resp.StatusCode()
+		latency = resp.Time()
+		if v, err := strconv.Atoi(resp.Header().Get("x-rate-limit-remaining")); err == nil {
+			remaining = v
+		}
+	} else {
+		latency = time.Since(req.Time)
+	}
+	c.observer(op, status, latency, remaining)
 }
 
 type Creds struct {
@@ -24,73 +142,254 @@ type Creds struct {
 
 // NewWithCreds initializes a new Client with OAuth1 signing.
 func NewWithCreds(httpClient *http.Client, creds Creds) *Client {
-	// OAuth1 config for X (Twitter)
-	config := oauth1.NewConfig(creds.APIKey, creds.APISecret)
-	token := oauth1.NewToken(creds.AccessToken, creds.AccessSecret)
+	// Sign every request with OAuth1; SetCreds swaps the signing client
+	s := &signer{}
+	s.cur.Store(oauthClient(httpClient, creds))
 
-	// Create an HTTP client that automatically signs requests
-	oauthClient := config.Client(oauth1.NoContext, token)
-
-	rc := resty.NewWithClient(oauthClient).
+	rc := resty.NewWithClient(&http.Client{Transport: s}).
 		SetBaseURL("https://api.twitter.com/2"). // Correct base URL for X API
 		SetRetryCount(4).
 		SetRetryWaitTime(2 * time.Second).
 		SetRetryMaxWaitTime(20 * time.Second)
 
-	return &Client{rest: rc, creds: creds}
+	c := &Client{rest: rc, base: httpClient, signer: s}
+	rc.OnAfterResponse(func(_ *resty.Client, r *resty.Response) error {
+		c.observe(r.Request, r)
+		return nil
+	})
+	rc.OnError(func(req *resty.Request, err error) {
+		var re *resty.ResponseError
+		if errors.As(err, &re) {
+			return // already observed in OnAfterResponse
+		}
+		c.observe(req, nil)
+	})
+	return c
+}
+
+// SetCreds rebuilds the OAuth1 signer with rotated credentials. Requests
+// already in flight keep the old signature.
+func (c *Client) SetCreds(creds Creds) {
+	c.signer.cur.Store(oauthClient(c.base, creds))
+	c.self.Store(nil)
+}
+
+// selfID returns our user ID, looking it up once.
+func (c *Client) selfID(ctx context.Context) (string, error) {
+	if id := c.self.Load(); id != nil {
+		return *id, nil
+	}
+	me, err := c.Me(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.self.Store(&me.ID)
+	return me.ID, nil
 }
 
 // PostTweet posts a new tweet.
-func (c *Client) PostTweet(text string) (string, error) {
+func (c *Client) PostTweet(ctx context.Context, text string) (string, error) {
+	return c.PostTweetWith(ctx, text, TweetOptions{})
+}
+
+// Limits X puts on polls.
+const (
+	PollMinOptions   = 2
+	PollMaxOptions   = 4
+	PollOptionMaxLen = 25
+	PollMinMinutes   = 5
+	PollMaxMinutes   = 7 * 24 * 60
+)
+
+// Who may reply to a new tweet. ReplyEveryone is X's default.
+const (
+	ReplyEveryone  = ""
+	ReplyFollowing = "following"
+	ReplyMentioned = "mentionedUsers"
+)
+
+// NewPoll is a poll to attach to a new tweet.
+type NewPoll struct {
+	Options         []string `json:"options"`
+	DurationMinutes int      `json:"duration_minutes"`
+}
+
+// TweetOptions are the optional parts of a new tweet.
+type TweetOptions struct {
+	Poll          *NewPoll `json:"poll,omitempty"`
+	ReplySettings string   `json:"reply_settings,omitempty"`
+}
+
+// Validate checks o against X's limits, so a bad tweet fails before it is
+// sent.
+func (o TweetOptions) Validate() error {
+	switch o.ReplySettings {
+	case ReplyEveryone, ReplyFollowing, ReplyMentioned:
+	default:
+		return fmt.Errorf("reply settings %q: want %q or %q", o.ReplySettings, ReplyFollowing, ReplyMentioned)
+	}
+	if o.Poll == nil {
+		return nil
+	}
+	if n := len(o.Poll.Options); n < PollMinOptions || n > PollMaxOptions {
+		return fmt.Errorf("poll has %d options, want %d to %d", n, PollMinOptions, PollMaxOptions)
+	}
+	seen := map[string]bool{}
+	for _, opt := range o.Poll.Options {
+		if opt == "" || utf8.RuneCountInString(opt) > PollOptionMaxLen {
+			return fmt.Errorf("poll option %q: want 1 to %d characters", opt, PollOptionMaxLen)
+		}
+		if seen[opt] {
+			return fmt.Errorf("poll option %q appears twice", opt)
+		}
+		seen[opt] = true
+	}
+	if d := o.Poll.DurationMinutes; d < PollMinMinutes || d > PollMaxMinutes {
+		return fmt.Errorf("poll duration %d minutes, want %d to %d", d, PollMinMinutes, PollMaxMinutes)
+	}
+	return nil
+}
+
+// PostTweetWith posts a new tweet with a poll or reply restrictions.
+func (c *Client) PostTweetWith(ctx context.Context, text string, opts TweetOptions) (id string, err error) {
+	ctx, span := startSpan(ctx, "PostTweet")
+	var r *resty.Response
+	defer func() { endSpan(span, r, err) }()
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+
 	var resp struct {
 		Data struct{ ID, Text string } `json:"data"`
 	}
-	r, err := c.rest.R().
+	body := struct {
+		Text string `json:"text"`
+		TweetOptions
+	}{text, opts}
+	r, err = c.req(ctx, "post_tweet").
 		SetHeader("Content-Type", "application/json").
-		SetBody(map[string]string{"text": text}).
+		SetBody(body).
 		SetResult(&resp).
 		Post("/tweets")
 	if err != nil {
 		return "", err
 	}
 	if r.StatusCode() != http.StatusCreated && r.StatusCode() != http.StatusOK {
-		return "", fmt.Errorf("post tweet failed: %s - %s", r.Status(), r.String())
+		return "", apiError("post tweet", r)
 	}
 	return resp.Data.ID, nil
 }
 
 type Tweet struct {
-	ID            string `json:"id"`
-	Text          string `json:"text"`
-	AuthorID      string `json:"author_id"`
-	PublicMetrics struct {
+	ID             string    `json:"id"`
+	Text           string    `json:"text"`
+	AuthorID       string    `json:"author_id"`
+	ConversationID string    `json:"conversation_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	PublicMetrics  struct {
 		RetweetCount int `json:"retweet_count"`
 		ReplyCount   int `json:"reply_count"`
 		LikeCount    int `json:"like_count"`
 		QuoteCount   int `json:"quote_count"`
 	} `json:"public_metrics"`
+
+	Attachments struct {
+		PollIDs []string `json:"poll_ids"`
+	} `json:"attachments"`
+
+	// ReferencedTweets are the tweets this one replies to, quotes or
+	// reposts, when the call asked for them.
+	ReferencedTweets []Reference `json:"referenced_tweets"`
+
+	// Author and Poll are filled from the response's expansions when the
+	// call asked for them; nil otherwise.
+	Author *User `json:"-"`
+	Poll   *Poll `json:"-"`
+}
+
+// Kinds of Reference.
+const (
+	RefRepliedTo = "replied_to"
+	RefQuoted    = "quoted"
+	RefRetweeted = "retweeted"
+)
+
+// Reference links a tweet to one it replies to, quotes or reposts.
+type Reference struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+var (
+	leadingMentions = regexp.MustCompile(`^(?:@\w+\s*)+`)
+	links           = regexp.MustCompile(`https?://\S+`)
+)
+
+// PlainText reduces tweet text to what survives X's rendering, so text we
+// sent can be compared with text X returns: HTML entities are decoded, the
+// @handles X puts in front of replies and every link (X turns them into
+// t.co ones) are dropped, and runs of whitespace become one space.
+func PlainText(s string) string {
+	s = links.ReplaceAllString(html.UnescapeString(s), " ")
+	s = leadingMentions.ReplaceAllString(strings.TrimSpace(s), "")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Poll is a tweet's poll as X reports it, with the votes so far.
+type Poll struct {
+	ID              string       `json:"id"`
+	Options         []PollOption `json:"options"`
+	DurationMinutes int          `json:"duration_minutes"`
+	EndDatetime     time.Time    `json:"end_datetime"`
+	VotingStatus    string       `json:"voting_status"` // "open" or "closed"
+}
+
+type PollOption struct {
+	Position int    `json:"position"`
+	Label    string `json:"label"`
+	Votes    int    `json:"votes"`
 }
 
 type searchResp struct {
-	Data []Tweet `json:"data"`
+	Data     []Tweet `json:"data"`
+	Includes struct {
+		Users []User `json:"users"`
+	} `json:"includes"`
 	Meta struct {
 		NextToken string `json:"next_token"`
 	} `json:"meta"`
 }
 
+// attachAuthors points each tweet at its author from the expanded users.
+func (r *searchResp) attachAuthors() {
+	users := make(map[string]*User, len(r.Includes.Users))
+	for i := range r.Includes.Users {
+		users[r.Includes.Users[i].ID] = &r.Includes.Users[i]
+	}
+	for i := range r.Data {
+		r.Data[i].Author = users[r.Data[i].AuthorID]
+	}
+}
+
 // SearchDevOpsRecent searches for recent DevOps tweets.
-func (c *Client) SearchDevOpsRecent(max int) ([]Tweet, error) {
+func (c *Client) SearchDevOpsRecent(ctx context.Context, max int) (_ []Tweet, err error) {
+	ctx, span := startSpan(ctx, "SearchDevOpsRecent")
+	var r *resty.Response
+	defer func() { endSpan(span, r, err) }()
+
 	q := `("Kubernetes" OR K8s OR "CI/CD" OR "SRE" OR "Terraform" OR "OpenTofu" OR "ArgoCD" OR "OpenTelemetry" OR "Istio" OR "FinOps" OR "supply chain security") lang:en -is:retweet -is:quote`
 
 	var out []Tweet
 	params := map[string]string{
 		"query":        q,
 		"max_results":  "50",
-		"tweet.fields": "public_metrics,author_id,created_at",
+		"tweet.fields": "public_metrics,author_id,created_at,conversation_id",
+		"expansions":   "author_id",
+		"user.fields":  "public_metrics",
 	}
 
 	var resp searchResp
-	r, err := c.rest.R().
+	r, err = c.req(ctx, "search").
 		SetQueryParams(params).
 		SetResult(&resp).
 		Get("/tweets/search/recent")
@@ -98,8 +397,9 @@ func (c *Client) SearchDevOpsRecent(max int) ([]Tweet, error) {
 		return nil, err
 	}
 	if r.IsError() {
-		return nil, fmt.Errorf("search failed: %s - %s", r.Status(), r.String())
+		return nil, apiError("search", r)
 	}
+	resp.attachAuthors()
 	for _, t := range resp.Data {
 		out = append(out, t)
 		if len(out) >= max {
@@ -110,7 +410,11 @@ func (c *Client) SearchDevOpsRecent(max int) ([]Tweet, error) {
 }
 
 // Reply posts a reply to a tweet.
-func (c *Client) Reply(tweetID string, text string) (string, error) {
+func (c *Client) Reply(ctx context.Context, tweetID string, text string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "Reply")
+	var r *resty.Response
+	defer func() { endSpan(span, r, err) }()
+
 	payload := map[string]any{
 		"text": text,
 		"reply": map[string]string{
@@ -120,7 +424,7 @@ func (c *Client) Reply(tweetID string, text string) (string, error) {
 	var resp struct {
 		Data struct{ ID, Text string } `json:"data"`
 	}
-	r, err := c.rest.R().
+	r, err = c.req(ctx, "reply").
 		SetHeader("Content-Type", "application/json").
 		SetBody(payload).
 		SetResult(&resp).
@@ -129,16 +433,116 @@ func (c *Client) Reply(tweetID string, text string) (string, error) {
 		return "", err
 	}
 	if r.IsError() {
-		return "", fmt.Errorf("reply failed: %s - %s", r.Status(), r.String())
+		return "", apiError("reply", r)
+	}
+	return resp.Data.ID, nil
+}
+
+// QuoteTweet posts text as a quote of tweetID.
+func (c *Client) QuoteTweet(ctx context.Context, tweetID, text string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "QuoteTweet")
+	var r *resty.Response
+	defer func() { endSpan(span, r, err) }()
+
+	var resp struct {
+		Data struct{ ID, Text string } `json:"data"`
+	}
+	r, err = c.req(ctx, "quote").
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]string{"text": text, "quote_tweet_id": tweetID}).
+		SetResult(&resp).
+		Post("/tweets")
+	if err != nil {
+		return "", err
+	}
+	if r.IsError() {
+		return "", apiError("quote", r)
 	}
 	return resp.Data.ID, nil
 }
 
-// GetTweets fetches tweets by IDs with public metrics.
-func (c *Client) GetTweets(ids []string) ([]Tweet, error) {
+// Like likes tweetID as our account. It returns tweetID, so it fits the
+// Publisher shape where dry runs return a shadow ID.
+func (c *Client) Like(ctx context.Context, tweetID string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "Like")
+	var r *resty.Response
+	defer func() { endSpan(span, r, err) }()
+	r, err = c.userAction(ctx, "like", "likes", tweetID)
+	return tweetID, err
+}
+
+// Repost reposts (retweets) tweetID as our account, returning tweetID.
+func (c *Client) Repost(ctx context.Context, tweetID string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "Repost")
+	var r *resty.Response
+	defer func() { endSpan(span, r, err) }()
+	r, err = c.userAction(ctx, "repost", "retweets", tweetID)
+	return tweetID, err
+}
+
+// userAction posts tweetID to /users/<our id>/<path>, the shape of the like
+// and repost endpoints. Both are idempotent on X's side.
+func (c *Client) userAction(ctx context.Context, op, path, tweetID string) (*resty.Response, error) {
+	id, err := c.selfID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.req(ctx, op).
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]string{"tweet_id": tweetID}).
+		Post("/users/" + id + "/" + path)
+	if err != nil {
+		return r, err
+	}
+	if r.IsError() {
+		return r, apiError(op, r)
+	}
+	return r, nil
+}
+
+// User is an X account.
+type User struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Username      string `json:"username"`
+	PublicMetrics struct {
+		FollowersCount int `json:"followers_count"`
+		FollowingCount int `json:"following_count"`
+	} `json:"public_metrics"`
+}
+
+// Me returns the account the credentials belong to. It doubles as a
+// credentials check.
+func (c *Client) Me(ctx context.Context) (_ *User, err error) {
+	ctx, span := startSpan(ctx, "Me")
+	var r *resty.Response
+	defer func() { endSpan(span, r, err) }()
+
+	var resp struct {
+		Data User `json:"data"`
+	}
+	r, err = c.req(ctx, "users_me").
+		SetResult(&resp).
+		Get("/users/me")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, apiError("users me", r)
+	}
+	return &resp.Data, nil
+}
+
+// GetTweets fetches tweets by IDs with public metrics and poll results.
+func (c *Client) GetTweets(ctx context.Context, ids []string) (_ []Tweet, err error) {
 	if len(ids) == 0 {
 		return nil, nil
 	}
+	ctx, span := startSpan(ctx, "GetTweets")
+	span.SetAttributes(attribute.Int("tweet.count", len(ids)))
+	var r *resty.Response
+	defer func() { endSpan(span, r, err) }()
+
 	var all []Tweet
 	for start := 0; start < len(ids); start += 100 {
 		end := start + 100
@@ -147,12 +551,17 @@ func (c *Client) GetTweets(ids []string) ([]Tweet, error) {
 		}
 		batch := ids[start:end]
 		var resp struct {
-			Data []Tweet `json:"data"`
+			Data     []Tweet `json:"data"`
+			Includes struct {
+				Polls []Poll `json:"polls"`
+			} `json:"includes"`
 		}
-		r, err := c.rest.R().
+		r, err = c.req(ctx, "get_tweets").
 			SetQueryParams(map[string]string{
 				"ids":          strings.Join(batch, ","),
-				"tweet.fields": "public_metrics",
+				"tweet.fields": "public_metrics,attachments",
+				"expansions":   "attachments.poll_ids",
+				"poll.fields":  "options,duration_minutes,end_datetime,voting_status",
 			}).
 			SetResult(&resp).
 			Get("/tweets")
@@ -160,9 +569,146 @@ func (c *Client) GetTweets(ids []string) ([]Tweet, error) {
 			return nil, err
 		}
 		if r.IsError() {
-			return nil, fmt.Errorf("get tweets failed: %s - %s", r.Status(), r.String())
+			return nil, apiError("get tweets", r)
 		}
+		attachPolls(resp.Data, resp.Includes.Polls)
 		all = append(all, resp.Data...)
 	}
 	return all, nil
 }
+
+// attachPolls points each tweet at its poll from a response's includes.
+func attachPolls(ts []Tweet, polls []Poll) {
+	byID := make(map[string]*Poll, len(polls))
+	for i := range polls {
+		byID[polls[i].ID] = &polls[i]
+	}
+	for i := range ts {
+		for _, id := range ts[i].Attachments.PollIDs {
+			if p := byID[id]; p != nil {
+				ts[i].Poll = p
+			}
+		}
+	}
+}
+
+// UserTweets returns the most recent tweets (including replies) posted by
+// userID, newest first, with the tweets they reference.
+func (c *Client) UserTweets(ctx context.Context, userID string, max int) (_ []Tweet, err error) {
+	ctx, span := startSpan(ctx, "UserTweets")
+	var r *resty.Response
+	defer func() { endSpan(span, r, err) }()
+
+	if max < 5 {
+		max = 5
+	}
+	if max > 100 {
+		max = 100
+	}
+	var resp struct {
+		Data []Tweet `json:"data"`
+	}
+	r, err = c.req(ctx, "user_tweets").
+		SetQueryParams(map[string]string{
+			"max_results":  strconv.Itoa(max),
+			"tweet.fields": "public_metrics,author_id,created_at,referenced_tweets",
+		}).
+		SetResult(&resp).
+		Get("/users/" + userID + "/tweets")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, apiError("user tweets", r)
+	}
+	return resp.Data, nil
+}
+
+// Mentions returns up to max tweets mentioning userID posted after the
+// tweet sinceID (all recent ones when it is empty), newest first, with
+// their authors attached.
+func (c *Client) Mentions(ctx context.Context, userID, sinceID string, max int) (_ []Tweet, err error) {
+	ctx, span := startSpan(ctx, "Mentions")
+	var r *resty.Response
+	defer func() { endSpan(span, r, err) }()
+
+	var out []Tweet
+	token := ""
+	for len(out) < max {
+		params := map[string]string{
+			"max_results":  "100",
+			"tweet.fields": "public_metrics,author_id,created_at,conversation_id",
+			"expansions":   "author_id",
+			"user.fields":  "public_metrics",
+		}
+		if sinceID != "" {
+			params["since_id"] = sinceID
+		}
+		if token != "" {
+			params["pagination_token"] = token
+		}
+		var resp searchResp
+		r, err = c.req(ctx, "mentions").
+			SetQueryParams(params).
+			SetResult(&resp).
+			Get("/users/" + userID + "/mentions")
+		if err != nil {
+			return nil, err
+		}
+		if r.IsError() {
+			return nil, apiError("mentions", r)
+		}
+		resp.attachAuthors()
+		out = append(out, resp.Data...)
+		if token = resp.Meta.NextToken; token == "" {
+			break
+		}
+	}
+	if len(out) > max {
+		out = out[:max]
+	}
+	return out, nil
+}
+
+// Following returns the IDs of up to max accounts userID follows, paging
+// through the list 1000 at a time.
+func (c *Client) Following(ctx context.Context, userID string, max int) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "Following")
+	var r *resty.Response
+	defer func() { endSpan(span, r, err) }()
+
+	var ids []string
+	token := ""
+	for len(ids) < max {
+		params := map[string]string{"max_results": "1000"}
+		if token != "" {
+			params["pagination_token"] = token
+		}
+		var resp struct {
+			Data []User `json:"data"`
+			Meta struct {
+				NextToken string `json:"next_token"`
+			} `json:"meta"`
+		}
+		r, err = c.req(ctx, "following").
+			SetQueryParams(params).
+			SetResult(&resp).
+			Get("/users/" + userID + "/following")
+		if err != nil {
+			return nil, err
+		}
+		if r.IsError() {
+			return nil, apiError("following", r)
+		}
+		for _, u := range resp.Data {
+			ids = append(ids, u.ID)
+		}
+		if token = resp.Meta.NextToken; token == "" {
+			break
+		}
+	}
+	if len(ids) > max {
+		ids = ids[:max]
+	}
+	return ids, nil
+}
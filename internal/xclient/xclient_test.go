@@ -0,0 +1,50 @@
+package xclient
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		sent, rendered string
+	}{
+		{"Ship small PRs", "Ship small PRs"},
+		{"Good point, and the fix is easy", "@alice Good point, and the fix is easy"},
+		{"Agreed", "@alice @bob Agreed"},
+		{"Q&A: is <main> > trunk?", "Q&amp;A: is &lt;main&gt; &gt; trunk?"},
+		{"Read https://example.com/a-long-post first", "Read https://t.co/AbC123 first"},
+		{"Thread\n\nwith  gaps ", "Thread with gaps"},
+		{"Worth a look", "Worth a look https://t.co/q1"}, // a quote's link to the quoted tweet
+	}
+	for _, tt := range tests {
+		if got, want := PlainText(tt.rendered), PlainText(tt.sent); got != want {
+			t.Errorf("PlainText(%q) = %q, want %q as for %q", tt.rendered, got, want, tt.sent)
+		}
+	}
+	if got := PlainText("ping @alice about it"); got != "ping @alice about it" {
+		t.Errorf("PlainText dropped a mention inside the text: %q", got)
+	}
+}
+
+func TestTemporary(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&APIError{StatusCode: 429}, true},
+		{&APIError{StatusCode: 500}, true},
+		{&APIError{StatusCode: 503}, true},
+		{fmt.Errorf("post: %w", &APIError{StatusCode: 502}), true},
+		{&APIError{StatusCode: 400}, false},
+		{&APIError{StatusCode: 403}, false},
+		{errors.New("connection reset"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := Temporary(tt.err); got != tt.want {
+			t.Errorf("Temporary(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}